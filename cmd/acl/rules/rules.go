@@ -0,0 +1,189 @@
+package rules
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/zk/txpool"
+	"github.com/ledgerwatch/erigon/zkevm/log"
+	"github.com/urfave/cli/v2"
+)
+
+const failedToOpenDB = "Failed to open ACL database"
+
+var errDataDirNotSet = errors.New("data directory is not set")
+
+var (
+	contract string
+	selector string
+	sender   string
+	action   string
+)
+
+var (
+	contractFlag = cli.StringFlag{
+		Name:        "contract",
+		Usage:       "Address of the called contract",
+		Required:    true,
+		Destination: &contract,
+	}
+	selectorFlag = cli.StringFlag{
+		Name:        "selector",
+		Usage:       "Method selector as 4 bytes hex (0x3659cfe6) or method signature (upgradeTo(address))",
+		Required:    true,
+		Destination: &selector,
+	}
+	senderFlag = cli.StringFlag{
+		Name:        "sender",
+		Usage:       "Sender the rule applies to (optional, any sender if not set)",
+		Destination: &sender,
+	}
+)
+
+var AddCommand = cli.Command{
+	Action: addRun,
+	Name:   "add-rule",
+	Usage:  "Allow or deny calls of a contract method",
+	Flags: []cli.Flag{
+		&utils.DataDirFlag,
+		&contractFlag,
+		&selectorFlag,
+		&senderFlag,
+		&cli.StringFlag{
+			Name:        "action",
+			Usage:       "Action for the matching calls (allow or deny)",
+			Required:    true,
+			Destination: &action,
+		},
+	},
+}
+
+var RemoveCommand = cli.Command{
+	Action: removeRun,
+	Name:   "remove-rule",
+	Usage:  "Remove the rule of a contract method",
+	Flags: []cli.Flag{
+		&utils.DataDirFlag,
+		&contractFlag,
+		&selectorFlag,
+		&senderFlag,
+	},
+}
+
+// addRun is the entry point for the add-rule command that sets the method rule for the given contract and selector
+func addRun(cliCtx *cli.Context) error {
+	if !cliCtx.IsSet(utils.DataDirFlag.Name) {
+		return errDataDirNotSet
+	}
+
+	dataDir := cliCtx.String(utils.DataDirFlag.Name)
+
+	log.Info("Adding method rule", "dataDir", dataDir, "contract", contract, "selector", selector, "sender", sender, "action", action)
+
+	rule, err := parseMethodRule(contract, selector, sender)
+	if err != nil {
+		log.Error("Failed to parse method rule", "err", err)
+		return err
+	}
+
+	if rule.Action, err = txpool.ResolveMethodRuleAction(action); err != nil {
+		log.Error("Failed to resolve method rule action", "err", err)
+		return err
+	}
+
+	aclDB, err := txpool.OpenACLDB(cliCtx.Context, dataDir)
+	if err != nil {
+		log.Error(failedToOpenDB, "err", err)
+		return err
+	}
+
+	if err := txpool.SetMethodRule(cliCtx.Context, aclDB, rule); err != nil {
+		log.Error("Failed to add method rule", "err", err)
+		return err
+	}
+
+	log.Info("Method rule added", "rule", rule.ToString())
+
+	return nil
+}
+
+// removeRun is the entry point for the remove-rule command that removes the method rule for the given contract and selector
+func removeRun(cliCtx *cli.Context) error {
+	if !cliCtx.IsSet(utils.DataDirFlag.Name) {
+		return errDataDirNotSet
+	}
+
+	dataDir := cliCtx.String(utils.DataDirFlag.Name)
+
+	log.Info("Removing method rule", "dataDir", dataDir, "contract", contract, "selector", selector, "sender", sender)
+
+	rule, err := parseMethodRule(contract, selector, sender)
+	if err != nil {
+		log.Error("Failed to parse method rule", "err", err)
+		return err
+	}
+
+	aclDB, err := txpool.OpenACLDB(cliCtx.Context, dataDir)
+	if err != nil {
+		log.Error(failedToOpenDB, "err", err)
+		return err
+	}
+
+	if err := txpool.RemoveMethodRule(cliCtx.Context, aclDB, rule.Contract, rule.Selector, rule.Sender); err != nil {
+		log.Error("Failed to remove method rule", "err", err)
+		return err
+	}
+
+	log.Info("Method rule removed", "contract", contract, "selector", selector, "sender", sender)
+
+	return nil
+}
+
+// parseMethodRule builds the method rule, without action, from its textual contract, selector and sender
+func parseMethodRule(contract, selector, sender string) (txpool.MethodRule, error) {
+	var (
+		rule txpool.MethodRule
+		err  error
+	)
+
+	if !common.IsHexAddress(contract) {
+		return rule, fmt.Errorf("invalid contract address: %s", contract)
+	}
+	rule.Contract = common.HexToAddress(contract)
+
+	if sender != "" {
+		if !common.IsHexAddress(sender) {
+			return rule, fmt.Errorf("invalid sender address: %s", sender)
+		}
+		rule.Sender = common.HexToAddress(sender)
+	}
+
+	rule.Selector, err = ParseSelector(selector)
+
+	return rule, err
+}
+
+// ParseSelector parses a method selector given as 4 bytes hex or as a method signature
+func ParseSelector(s string) ([4]byte, error) {
+	var selector [4]byte
+
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "(") {
+		// method signature, the selector is the first 4 bytes of its hash
+		copy(selector[:], crypto.Keccak256([]byte(strings.ReplaceAll(s, " ", "")))[:4])
+		return selector, nil
+	}
+
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(b) != 4 {
+		return selector, fmt.Errorf("invalid selector %s, expected 4 bytes hex or method signature", s)
+	}
+	copy(selector[:], b)
+
+	return selector, nil
+}
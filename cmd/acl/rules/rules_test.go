@@ -0,0 +1,59 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSelector(t *testing.T) {
+	testCases := []struct {
+		name        string
+		selector    string
+		expected    [4]byte
+		expectedErr string
+	}{
+		{
+			name:     "Hex selector",
+			selector: "0x3659cfe6",
+			expected: [4]byte{0x36, 0x59, 0xcf, 0xe6},
+		},
+		{
+			name:     "Hex selector without prefix",
+			selector: "a9059cbb",
+			expected: [4]byte{0xa9, 0x05, 0x9c, 0xbb},
+		},
+		{
+			name:     "Method signature",
+			selector: "upgradeTo(address)",
+			expected: [4]byte{0x36, 0x59, 0xcf, 0xe6},
+		},
+		{
+			name:     "Method signature with spaces",
+			selector: "transfer(address, uint256)",
+			expected: [4]byte{0xa9, 0x05, 0x9c, 0xbb},
+		},
+		{
+			name:        "Wrong length",
+			selector:    "0x3659cf",
+			expectedErr: "invalid selector",
+		},
+		{
+			name:        "Not hex",
+			selector:    "upgradeTo",
+			expectedErr: "invalid selector",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			selector, err := ParseSelector(tc.selector)
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, selector)
+		})
+	}
+}
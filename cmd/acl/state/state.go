@@ -0,0 +1,187 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/zk/txpool"
+	"github.com/ledgerwatch/erigon/zkevm/log"
+	"github.com/urfave/cli/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const failedToOpenDB = "Failed to open ACL database"
+
+var errDataDirNotSet = errors.New("data directory is not set")
+
+var (
+	file   string
+	dryRun bool
+)
+
+var ExportCommand = cli.Command{
+	Action: exportRun,
+	Name:   "export",
+	Usage:  "Export the whole ACL (mode, allowlist, blocklist and method rules) to a YAML or JSON file",
+	Flags: []cli.Flag{
+		&utils.DataDirFlag,
+		&cli.StringFlag{
+			Name:        "file",
+			Usage:       "File to write the ACL to, YAML if the extension is .yaml or .yml, JSON otherwise",
+			Required:    true,
+			Destination: &file,
+		},
+	},
+}
+
+var ImportCommand = cli.Command{
+	Action: importRun,
+	Name:   "import",
+	Usage:  "Bring the ACL to the state of a YAML or JSON file, the changes are applied atomically",
+	Flags: []cli.Flag{
+		&utils.DataDirFlag,
+		&cli.StringFlag{
+			Name:        "file",
+			Usage:       "File with the desired ACL, YAML if the extension is .yaml or .yml, JSON otherwise",
+			Required:    true,
+			Destination: &file,
+		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "Only print the changes that would be applied",
+			Destination: &dryRun,
+		},
+	},
+}
+
+// exportRun is the entry point for the export command that writes the ACL to a file
+func exportRun(cliCtx *cli.Context) error {
+	if !cliCtx.IsSet(utils.DataDirFlag.Name) {
+		return errDataDirNotSet
+	}
+
+	dataDir := cliCtx.String(utils.DataDirFlag.Name)
+
+	log.Info("Exporting ACL", "dataDir", dataDir, "file", file)
+
+	aclDB, err := txpool.OpenACLDB(cliCtx.Context, dataDir)
+	if err != nil {
+		log.Error(failedToOpenDB, "err", err)
+		return err
+	}
+	defer aclDB.Close()
+
+	state, err := txpool.ExportACLState(cliCtx.Context, aclDB)
+	if err != nil {
+		log.Error("Failed to export ACL", "err", err)
+		return err
+	}
+
+	if err := WriteStateFile(file, state); err != nil {
+		log.Error("Failed to write ACL file", "err", err)
+		return err
+	}
+
+	log.Info("ACL exported", "file", file)
+
+	return nil
+}
+
+// importRun is the entry point for the import command that brings the ACL to the state of a file
+func importRun(cliCtx *cli.Context) error {
+	if !cliCtx.IsSet(utils.DataDirFlag.Name) {
+		return errDataDirNotSet
+	}
+
+	dataDir := cliCtx.String(utils.DataDirFlag.Name)
+
+	log.Info("Importing ACL", "dataDir", dataDir, "file", file, "dryRun", dryRun)
+
+	state, err := ReadStateFile(file)
+	if err != nil {
+		log.Error("Failed to read ACL file", "err", err)
+		return err
+	}
+
+	aclDB, err := txpool.OpenACLDB(cliCtx.Context, dataDir)
+	if err != nil {
+		log.Error(failedToOpenDB, "err", err)
+		return err
+	}
+	defer aclDB.Close()
+
+	var diff *txpool.ACLDiff
+	if dryRun {
+		diff, err = txpool.DiffACLState(cliCtx.Context, aclDB, state)
+	} else {
+		diff, err = txpool.ImportACLState(cliCtx.Context, aclDB, state)
+	}
+	if err != nil {
+		log.Error("Failed to import ACL", "err", err)
+		return err
+	}
+
+	fmt.Println(diff.String())
+
+	if !dryRun {
+		log.Info("ACL imported", "changes", len(diff.Changes))
+	}
+
+	return nil
+}
+
+func isYAML(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// ReadStateFile reads the ACL state from a YAML or JSON file, unknown fields are rejected
+// so that a typo does not silently empty a list
+func ReadStateFile(path string) (*txpool.ACLState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &txpool.ACLState{}
+	if isYAML(path) {
+		err = yaml.UnmarshalStrict(data, state)
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(state)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return state, nil
+}
+
+// WriteStateFile writes the ACL state to a YAML or JSON file
+func WriteStateFile(path string, state *txpool.ACLState) error {
+	var (
+		data []byte
+		err  error
+	)
+	if isYAML(path) {
+		data, err = yaml.Marshal(state)
+	} else {
+		data, err = json.MarshalIndent(state, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
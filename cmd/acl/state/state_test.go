@@ -0,0 +1,57 @@
+package state
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/zk/txpool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadStateFile(t *testing.T) {
+	state, err := ReadStateFile("tests/acl_state.yaml")
+	require.NoError(t, err)
+	require.Equal(t, txpool.AllowlistMode, state.Mode)
+	require.Len(t, state.Allowlist, 2)
+	require.Equal(t, []string{"sendTx", "deploy"}, state.Allowlist[0].Policies)
+	require.Equal(t, common.HexToAddress("0xdead"), *state.Allowlist[1].Scoped[0].Contract)
+	require.Len(t, state.Blocklist, 1)
+	require.Len(t, state.MethodRules, 1)
+
+	_, err = ReadStateFile("tests/acl_state_unknown_field.yaml")
+	require.ErrorContains(t, err, "alowlist")
+
+	_, err = ReadStateFile("tests/missing.yaml")
+	require.Error(t, err)
+}
+
+func TestImportExportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	aclDB, err := txpool.OpenACLDB(ctx, t.TempDir())
+	require.NoError(t, err)
+	defer aclDB.Close()
+
+	state, err := ReadStateFile("tests/acl_state.yaml")
+	require.NoError(t, err)
+
+	diff, err := txpool.ImportACLState(ctx, aclDB, state)
+	require.NoError(t, err)
+	require.Len(t, diff.Changes, 6)
+
+	for _, name := range []string{"acl.json", "acl.yml"} {
+		path := filepath.Join(t.TempDir(), name)
+
+		exported, err := txpool.ExportACLState(ctx, aclDB)
+		require.NoError(t, err)
+		require.NoError(t, WriteStateFile(path, exported))
+
+		reread, err := ReadStateFile(path)
+		require.NoError(t, err)
+
+		diff, err := txpool.DiffACLState(ctx, aclDB, reread)
+		require.NoError(t, err)
+		require.True(t, diff.IsEmpty(), diff.String())
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/kv"
@@ -21,6 +23,14 @@ const (
 	aclTypeFlag     = "type"
 	aclTypeFlagDesc = "Type of the ACL (allowlist or blocklist)"
 
+	contractFlag     = "contract"
+	contractFlagDesc = "Destination contract the policy is restricted to (optional, any destination if not set)"
+
+	validFromFlag      = "valid-from"
+	validFromFlagDesc  = "Start of the policy validity window as RFC3339 or unix seconds (optional)"
+	validUntilFlag     = "valid-until"
+	validUntilFlagDesc = "End of the policy validity window as RFC3339 or unix seconds (optional)"
+
 	failedToOpenDB = "Failed to open ACL database"
 )
 
@@ -30,8 +40,11 @@ var (
 	csvFile string
 	aclType string
 
-	address string
-	policy  string
+	address    string
+	policy     string
+	contract   string
+	validFrom  string
+	validUntil string
 )
 
 var UpdateCommand = cli.Command{
@@ -75,6 +88,11 @@ var RemoveCommand = cli.Command{
 			Required:    true,
 			Destination: &policy,
 		},
+		&cli.StringFlag{
+			Name:        contractFlag,
+			Usage:       contractFlagDesc,
+			Destination: &contract,
+		},
 		&cli.StringFlag{
 			Name:        aclTypeFlag,
 			Usage:       aclTypeFlagDesc,
@@ -103,6 +121,21 @@ var AddCommand = cli.Command{
 			Required:    true,
 			Destination: &policy,
 		},
+		&cli.StringFlag{
+			Name:        contractFlag,
+			Usage:       contractFlagDesc,
+			Destination: &contract,
+		},
+		&cli.StringFlag{
+			Name:        validFromFlag,
+			Usage:       validFromFlagDesc,
+			Destination: &validFrom,
+		},
+		&cli.StringFlag{
+			Name:        validUntilFlag,
+			Usage:       validUntilFlagDesc,
+			Destination: &validUntil,
+		},
 		&cli.StringFlag{
 			Name:        "type",
 			Usage:       "Type of the ACL (allowlist or blocklist)",
@@ -136,12 +169,31 @@ func addRun(cliCtx *cli.Context) error {
 		return err
 	}
 
-	if err := txpool.AddPolicy(cliCtx.Context, aclDB, aclType, addr, policy); err != nil {
-		log.Error("Failed to add policy", "err", err)
+	if contract == "" && validFrom == "" && validUntil == "" {
+		if err := txpool.AddPolicy(cliCtx.Context, aclDB, aclType, addr, policy); err != nil {
+			log.Error("Failed to add policy", "err", err)
+			return err
+		}
+
+		log.Info("Policy added", "address", address, "policy", policy)
+
+		return nil
+	}
+
+	sp, err := parseScopedPolicy(contract, validFrom, validUntil)
+	if err != nil {
+		log.Error("Failed to parse policy scope", "err", err)
+		return err
+	}
+	sp.Addr = addr
+	sp.Policy = policy
+
+	if err := txpool.AddScopedPolicy(cliCtx.Context, aclDB, aclType, sp); err != nil {
+		log.Error("Failed to add scoped policy", "err", err)
 		return err
 	}
 
-	log.Info("Policy added", "address", address, "policy", policy)
+	log.Info("Scoped policy added", "address", address, "policy", policy, "contract", contract, "validFrom", validFrom, "validUntil", validUntil)
 
 	return nil
 }
@@ -162,19 +214,44 @@ func removeRun(cliCtx *cli.Context) error {
 		return err
 	}
 
-	addr := common.HexToAddress(address)
 	policy, err := txpool.ResolvePolicy(policy)
 	if err != nil {
 		log.Error("Failed to resolve policy", "err", err)
 		return err
 	}
 
-	if err := txpool.RemovePolicy(cliCtx.Context, aclDB, aclType, addr, policy); err != nil {
+	return removePolicy(cliCtx.Context, aclDB, aclType, common.HexToAddress(address), contract, policy)
+}
+
+// removePolicy removes the policy of the address, from the scoped policies when one is held for the contract
+func removePolicy(ctx context.Context, aclDB kv.RwDB, aclType string, addr common.Address, contract string, policy txpool.Policy) error {
+	// a policy scoped only by its validity window has no contract, so the scoped table is checked for it too
+	scoped := contract != ""
+	if !scoped {
+		var err error
+		if scoped, err = txpool.HasScopedPolicy(ctx, aclDB, aclType, addr, common.Address{}, policy); err != nil {
+			log.Error("Failed to look up scoped policy", "err", err)
+			return err
+		}
+	}
+
+	if scoped {
+		if err := txpool.RemoveScopedPolicy(ctx, aclDB, aclType, addr, common.HexToAddress(contract), policy); err != nil {
+			log.Error("Failed to remove scoped policy", "err", err)
+			return err
+		}
+
+		log.Info("Scoped policy removed", "address", addr, "policy", policy, "contract", contract)
+
+		return nil
+	}
+
+	if err := txpool.RemovePolicy(ctx, aclDB, aclType, addr, policy); err != nil {
 		log.Error("Failed to remove policy", "err", err)
 		return err
 	}
 
-	log.Info("Policy removed", "address", address, "policy", policy)
+	log.Info("Policy removed", "address", addr, "policy", policy)
 
 	return nil
 }
@@ -200,13 +277,13 @@ func updateRun(cliCtx *cli.Context) error {
 
 // updatePolicies updates the ACL based on the given CSV file
 func updatePolicies(ctx context.Context, csvFilePath, aclType string, aclDB kv.RwDB) error {
-	addresses, policies, err := readCSV(csvFilePath)
+	addresses, policies, scoped, err := readCSV(csvFilePath)
 	if err != nil {
 		log.Error("Failed to read CSV file", "err", err)
 		return err
 	}
 
-	if err := txpool.UpdatePolicies(ctx, aclDB, aclType, addresses, policies); err != nil {
+	if err := txpool.UpdateScopedPolicies(ctx, aclDB, aclType, addresses, policies, scoped); err != nil {
 		log.Error("Failed to update policies", "err", err)
 		return err
 	}
@@ -214,19 +291,25 @@ func updatePolicies(ctx context.Context, csvFilePath, aclType string, aclDB kv.R
 	return nil
 }
 
-// readCSV reads the CSV file and returns the addresses and policies
-func readCSV(filePath string) ([]common.Address, [][]txpool.Policy, error) {
+// readCSV reads the CSV file and returns the addresses with their plain and scoped policies.
+// Rows are in format address,"policy1,policy2"[,contract[,validFrom[,validUntil]]]. Rows with a scope
+// add scoped policies to the address, so the same address can appear in several of them.
+func readCSV(filePath string) ([]common.Address, [][]txpool.Policy, [][]txpool.ScopedPolicy, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(file)
+	// plain and scoped rows have a different number of fields
+	reader.FieldsPerRecord = -1
 
 	var (
 		addresses []common.Address
 		policies  [][]txpool.Policy
+		scoped    [][]txpool.ScopedPolicy
+		indexes   = make(map[common.Address]int)
 		row       int
 	)
 
@@ -236,14 +319,22 @@ func readCSV(filePath string) ([]common.Address, [][]txpool.Policy, error) {
 			break
 		}
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to read record: %w", err)
+			return nil, nil, nil, fmt.Errorf("failed to read record: %w", err)
 		}
 
-		if len(record) != 2 {
-			return nil, nil, fmt.Errorf("invalid record on row: %d", row)
+		if len(record) < 2 || len(record) > 5 {
+			return nil, nil, nil, fmt.Errorf("invalid record on row: %d", row)
 		}
 
-		addresses = append(addresses, common.HexToAddress(record[0]))
+		addr := common.HexToAddress(record[0])
+		idx, ok := indexes[addr]
+		if !ok {
+			idx = len(addresses)
+			indexes[addr] = idx
+			addresses = append(addresses, addr)
+			policies = append(policies, make([]txpool.Policy, 0))
+			scoped = append(scoped, nil)
+		}
 
 		addressPolicies := make([]txpool.Policy, 0)
 
@@ -251,18 +342,78 @@ func readCSV(filePath string) ([]common.Address, [][]txpool.Policy, error) {
 		for _, pc := range stringPolicies {
 			policy, err := txpool.ResolvePolicy(pc)
 			if err != nil {
-				return nil, nil, fmt.Errorf("failed to resolve policy: %w in row: %d", err, row)
+				return nil, nil, nil, fmt.Errorf("failed to resolve policy: %w in row: %d", err, row)
 			}
 
 			addressPolicies = append(addressPolicies, policy)
 		}
 
-		policies = append(policies, addressPolicies)
+		if len(record) == 2 {
+			policies[idx] = addressPolicies
+			row++
+			continue
+		}
+
+		scopeFields := make([]string, 3)
+		copy(scopeFields, record[2:])
+		sp, err := parseScopedPolicy(scopeFields[0], scopeFields[1], scopeFields[2])
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to parse policy scope: %w in row: %d", err, row)
+		}
+		sp.Addr = addr
+
+		for _, policy := range addressPolicies {
+			sp.Policy = policy
+			scoped[idx] = append(scoped[idx], sp)
+		}
 
 		row++
 	}
 
-	return addresses, policies, nil
+	return addresses, policies, scoped, nil
+}
+
+// parseScopedPolicy builds the scope of a policy from its textual contract and validity window
+func parseScopedPolicy(contract, validFrom, validUntil string) (txpool.ScopedPolicy, error) {
+	var (
+		sp  txpool.ScopedPolicy
+		err error
+	)
+
+	if contract = strings.TrimSpace(contract); contract != "" {
+		if !common.IsHexAddress(contract) {
+			return sp, fmt.Errorf("invalid contract address: %s", contract)
+		}
+		sp.Contract = common.HexToAddress(contract)
+	}
+
+	if sp.ValidFrom, err = parsePolicyTime(validFrom); err != nil {
+		return sp, err
+	}
+	if sp.ValidUntil, err = parsePolicyTime(validUntil); err != nil {
+		return sp, err
+	}
+
+	return sp, nil
+}
+
+// parsePolicyTime parses a time given as RFC3339 or unix seconds, an empty string is an unbounded time
+func parsePolicyTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if unix, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s, expected RFC3339 or unix seconds", s)
+	}
+
+	return t, nil
 }
 
 func splitPolicies(s string) []string {
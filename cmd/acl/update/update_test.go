@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon/zk/txpool"
 	"github.com/stretchr/testify/require"
@@ -81,6 +82,18 @@ func TestUpdatePolicies(t *testing.T) {
 			aclType:     "blocklist",
 			expectedErr: "unknown policy",
 		},
+		{
+			name:        "Update allowlist with scoped policies",
+			csvFile:     "tests/acls_scoped.csv",
+			aclType:     "allowlist",
+			expectedErr: "",
+		},
+		{
+			name:        "Deploy policy scoped to a contract",
+			csvFile:     "tests/acls_invalid_scope.csv",
+			aclType:     "allowlist",
+			expectedErr: "invalid policy scope",
+		},
 	}
 
 	// Run the test cases
@@ -103,3 +116,63 @@ func TestUpdatePolicies(t *testing.T) {
 		})
 	}
 }
+
+func TestReadCSVScopedPolicies(t *testing.T) {
+	addresses, policies, scoped, err := readCSV("tests/acls_scoped.csv")
+	require.NoError(t, err)
+
+	require.Len(t, addresses, 3)
+	require.Len(t, policies, 3)
+	require.Len(t, scoped, 3)
+
+	// plain row
+	require.Equal(t, []txpool.Policy{txpool.SendTx, txpool.Deploy}, policies[0])
+	require.Empty(t, scoped[0])
+
+	// two scoped rows for the same address
+	require.Empty(t, policies[1])
+	require.Len(t, scoped[1], 2)
+	require.Equal(t, common.HexToAddress("0xab7c74abc0c4d48d1bdad5dcb26153fc8780f83e"), scoped[1][0].Contract)
+	require.Equal(t, time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), scoped[1][0].ValidUntil.Unix())
+	require.True(t, scoped[1][1].ValidUntil.IsZero())
+
+	// scoped row with only an expiry
+	require.Len(t, scoped[2], 1)
+	require.Equal(t, txpool.Deploy, scoped[2][0].Policy)
+	require.Equal(t, common.Address{}, scoped[2][0].Contract)
+	require.Equal(t, int64(4070908800), scoped[2][0].ValidUntil.Unix())
+}
+
+func TestRemoveScopedPolicy(t *testing.T) {
+	ctx := context.Background()
+	aclDB := newTestACLDB(t)
+
+	addr := common.HexToAddress("0x0921598333cf3ce5fe2031c056c79aec59ee10b6")
+	contract := common.HexToAddress("0xab7c74abc0c4d48d1bdad5dcb26153fc8780f83e")
+
+	require.NoError(t, txpool.AddPolicy(ctx, aclDB, txpool.Allowlist, addr, txpool.Deploy))
+	require.NoError(t, txpool.AddScopedPolicy(ctx, aclDB, txpool.Allowlist, txpool.ScopedPolicy{
+		Addr: addr, Policy: txpool.SendTx, ValidUntil: time.Now().Add(time.Hour),
+	}))
+	require.NoError(t, txpool.AddScopedPolicy(ctx, aclDB, txpool.Allowlist, txpool.ScopedPolicy{
+		Addr: addr, Contract: contract, Policy: txpool.SendTx,
+	}))
+
+	// a policy scoped only by its window is removed without a contract
+	require.NoError(t, removePolicy(ctx, aclDB, txpool.Allowlist, addr, "", txpool.SendTx))
+	scoped, err := txpool.ListScopedPolicies(ctx, aclDB, txpool.Allowlist)
+	require.NoError(t, err)
+	require.Len(t, scoped, 1)
+	require.Equal(t, contract, scoped[0].Contract)
+
+	require.NoError(t, removePolicy(ctx, aclDB, txpool.Allowlist, addr, contract.Hex(), txpool.SendTx))
+	scoped, err = txpool.ListScopedPolicies(ctx, aclDB, txpool.Allowlist)
+	require.NoError(t, err)
+	require.Empty(t, scoped)
+
+	// plain policies are still removed from the plain table
+	require.NoError(t, removePolicy(ctx, aclDB, txpool.Allowlist, addr, "", txpool.Deploy))
+	policies, err := txpool.ListPolicies(ctx, aclDB, txpool.Allowlist)
+	require.NoError(t, err)
+	require.Empty(t, policies)
+}
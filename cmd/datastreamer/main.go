@@ -9,6 +9,7 @@ import (
 
 	"github.com/ledgerwatch/erigon/cmd/datastreamer/checker"
 	"github.com/ledgerwatch/erigon/cmd/datastreamer/decoder"
+	"github.com/ledgerwatch/erigon/cmd/datastreamer/segments"
 	"github.com/ledgerwatch/erigon/params"
 	"github.com/ledgerwatch/erigon/zkevm/log"
 	"github.com/urfave/cli/v2"
@@ -22,6 +23,7 @@ func main() {
 	app.Commands = []*cli.Command{
 		&checker.Command,
 		&decoder.Command,
+		&segments.Command,
 	}
 
 	app.UsageText = app.Name + ` [command] [flags]`
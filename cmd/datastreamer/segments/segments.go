@@ -0,0 +1,307 @@
+package segments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/zk/datastream/proto/github.com/0xPolygonHermez/zkevm-node/state/datastream"
+	"github.com/ledgerwatch/erigon/zk/datastream/server"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/ledgerwatch/erigon/zkevm/log"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	dataDirFlag = cli.StringFlag{
+		Name:     "datadir",
+		Usage:    "data directory of the node, it must not be running",
+		Required: true,
+	}
+	dirFlag = cli.StringFlag{
+		Name:  "dir",
+		Usage: "directory of the segments, defaults to <datadir>/data-stream-archive",
+	}
+	segmentBatchesFlag = cli.Uint64Flag{
+		Name:  "segment-batches",
+		Usage: "number of batches archived per segment",
+		Value: server.DefaultSegmentBatches,
+	}
+	versionFlag = cli.UintFlag{
+		Name:  "datastream-version",
+		Usage: "version of the data stream, as set with zkevm.datastream-version on the node",
+		Value: 2,
+	}
+)
+
+var Command = cli.Command{
+	Name:  "segments",
+	Usage: "archive the data stream of a node into segments, and verify or rebuild them",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "compact",
+			Usage:  "archive the batches older than the retained ones and remove them from the data stream",
+			Action: compact,
+			Flags: []cli.Flag{
+				&dataDirFlag,
+				&dirFlag,
+				&segmentBatchesFlag,
+				&versionFlag,
+				&cli.Uint64Flag{
+					Name:     "retain-batches",
+					Usage:    "number of the latest closed batches kept in the data stream",
+					Required: true,
+				},
+			},
+		},
+		{
+			Name:   "verify",
+			Usage:  "check the checksums of the segments and that they hold every batch in order",
+			Action: verify,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "dir",
+					Usage:    "directory of the segments",
+					Required: true,
+				},
+			},
+		},
+		{
+			Name:   "rebuild",
+			Usage:  "write the segments of a range of batches again from the hermez db of the node",
+			Action: rebuild,
+			Flags: []cli.Flag{
+				&dataDirFlag,
+				&dirFlag,
+				&segmentBatchesFlag,
+				&versionFlag,
+				&cli.Uint64Flag{
+					Name:     "from-batch",
+					Usage:    "first batch to rebuild",
+					Required: true,
+				},
+				&cli.Uint64Flag{
+					Name:     "to-batch",
+					Usage:    "last batch to rebuild, the node must have the next batch",
+					Required: true,
+				},
+			},
+		},
+	},
+}
+
+func segmentsDir(cliCtx *cli.Context) string {
+	if dir := cliCtx.String(dirFlag.Name); dir != "" {
+		return dir
+	}
+	return filepath.Join(cliCtx.String(dataDirFlag.Name), "data-stream-archive")
+}
+
+func openStream(fileName string, version uint) (*datastreamer.StreamServer, error) {
+	// the stream is never started on this port, except the temporary ones which listen on a random port
+	return datastreamer.NewServer(0, uint8(version), 1, datastreamer.StreamType(1), fileName, 5*time.Second, 10*time.Minute, time.Minute, nil)
+}
+
+// compact archives the batches before the retained ones, then copies the retained batches to a new stream file
+// that replaces the one of the node. The entry numbers of the compacted stream start again from 0, the datastreamer
+// file numbers its entries from its start, so the stream can not be compacted while a node serves it.
+func compact(cliCtx *cli.Context) error {
+	dataDir := cliCtx.String(dataDirFlag.Name)
+	retain := cliCtx.Uint64("retain-batches")
+	streamFile := filepath.Join(dataDir, "data-stream")
+
+	// the bookmarks db of the stream is locked by the server that owns it, and by this command until it exits so the
+	// node can not start serving the stream in the middle of the compaction
+	stream, err := openStream(streamFile, cliCtx.Uint(versionFlag.Name))
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return fmt.Errorf("the data stream %s is in use, stop the node before compacting it", streamFile)
+	}
+	if err != nil {
+		return fmt.Errorf("open the data stream: %w", err)
+	}
+	highestClosed, err := server.NewZkEVMDataStreamServerFactory().CreateDataStreamServer(stream, 0).GetHighestClosedBatchNoCache()
+	if err != nil {
+		return err
+	}
+	if highestClosed+1 <= retain {
+		log.Infof("The data stream has %d closed batches, nothing to compact", highestClosed+1)
+		return nil
+	}
+	keepFrom := highestClosed + 1 - retain
+	bookmark, err := types.NewBookmarkProto(keepFrom, datastream.BookmarkType_BOOKMARK_TYPE_BATCH).Marshal()
+	if err != nil {
+		return err
+	}
+	if entry, err := stream.GetBookmark(bookmark); err == nil && entry == 0 {
+		log.Infof("The data stream starts at batch %d, nothing to compact", keepFrom)
+		return nil
+	}
+
+	store := server.NewSegmentStore(segmentsDir(cliCtx))
+	written, err := server.ArchiveStream(stream, store, keepFrom, cliCtx.Uint64(segmentBatchesFlag.Name))
+	for _, info := range written {
+		log.Infof("Archived the batches %d to %d in %s", info.FromBatch, info.ToBatch, info.Path)
+	}
+	if err != nil {
+		return err
+	}
+
+	compactedFile := streamFile + "-compacted"
+	for _, ext := range []string{".bin", ".db"} {
+		if err = os.RemoveAll(compactedFile + ext); err != nil {
+			return err
+		}
+	}
+	compacted, err := openStream(compactedFile, cliCtx.Uint(versionFlag.Name))
+	if err != nil {
+		return fmt.Errorf("create the compacted data stream: %w", err)
+	}
+	if err = compacted.Start(); err != nil {
+		return err
+	}
+	copied, err := server.CopyStream(stream, keepFrom, compacted)
+	if err != nil {
+		return fmt.Errorf("copy the retained batches: %w", err)
+	}
+
+	for _, ext := range []string{".bin", ".db"} {
+		if err = os.RemoveAll(streamFile + ext); err != nil {
+			return err
+		}
+		if err = os.Rename(compactedFile+ext, streamFile+ext); err != nil {
+			return err
+		}
+	}
+	log.Infof("Compacted the data stream from batch %d, %d entries left", keepFrom, copied)
+	return nil
+}
+
+func verify(cliCtx *cli.Context) error {
+	segments, err := server.NewSegmentStore(cliCtx.String("dir")).Segments()
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return errors.New("no segments found")
+	}
+
+	var failed []string
+	for i, segment := range segments {
+		if i > 0 && segment.FromBatch != segments[i-1].ToBatch+1 {
+			failed = append(failed, fmt.Sprintf("batches %d to %d are missing", segments[i-1].ToBatch+1, segment.FromBatch-1))
+		}
+		if _, err := server.VerifySegment(segment.Path); err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		log.Infof("Segment %s holds the batches %d to %d, %d entries", filepath.Base(segment.Path), segment.FromBatch, segment.ToBatch, segment.Entries)
+	}
+
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "\n"))
+	}
+	log.Infof("Verified %d segments, batches %d to %d", len(segments), segments[0].FromBatch, segments[len(segments)-1].ToBatch)
+	return nil
+}
+
+// rebuild writes the batches into a temporary stream from the hermez db, the same way the node writes its stream,
+// and archives them from there. The entry numbers of the rebuilt segments are those of the temporary stream.
+func rebuild(cliCtx *cli.Context) error {
+	dataDir := cliCtx.String(dataDirFlag.Name)
+	fromBatch, toBatch := cliCtx.Uint64("from-batch"), cliCtx.Uint64("to-batch")
+	if toBatch < fromBatch {
+		return fmt.Errorf("to-batch %d is before from-batch %d", toBatch, fromBatch)
+	}
+	segmentBatches := cliCtx.Uint64(segmentBatchesFlag.Name)
+	if segmentBatches == 0 {
+		segmentBatches = server.DefaultSegmentBatches
+	}
+
+	tmpDir, err := os.MkdirTemp("", "data-stream-rebuild")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	stream, err := openStream(filepath.Join(tmpDir, "data-stream"), cliCtx.Uint(versionFlag.Name))
+	if err != nil {
+		return err
+	}
+	if err = stream.Start(); err != nil {
+		return err
+	}
+
+	db := mdbx.MustOpen(filepath.Join(dataDir, "chaindata"))
+	defer db.Close()
+
+	if err = db.View(cliCtx.Context, func(tx kv.Tx) error {
+		return writeBatches(cliCtx.Context, tx, stream, fromBatch, toBatch)
+	}); err != nil {
+		return err
+	}
+
+	dir := segmentsDir(cliCtx)
+	for from := fromBatch; from <= toBatch; from += segmentBatches {
+		info, err := server.WriteSegment(stream, dir, from, min(from+segmentBatches-1, toBatch))
+		if err != nil {
+			return err
+		}
+		log.Infof("Rebuilt the batches %d to %d in %s", info.FromBatch, info.ToBatch, info.Path)
+	}
+	return nil
+}
+
+// writeBatches writes the batches [fromBatch, toBatch] and the start of the next one to the stream
+func writeBatches(ctx context.Context, tx kv.Tx, stream *datastreamer.StreamServer, fromBatch, toBatch uint64) error {
+	reader := hermez_db.NewHermezDbReader(tx)
+
+	genesisHash, err := rawdb.ReadCanonicalHash(tx, 0)
+	if err != nil {
+		return err
+	}
+	chainConfig, err := rawdb.ReadChainConfig(tx, genesisHash)
+	if err != nil {
+		return err
+	}
+	if chainConfig == nil {
+		return errors.New("chain config not found, is the datadir of a synced node?")
+	}
+	srv := server.NewZkEVMDataStreamServerFactory().CreateDataStreamServer(stream, chainConfig.ChainID.Uint64())
+
+	from, found, err := reader.GetLowestBlockInBatch(fromBatch)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("batch %d not found in the db", fromBatch)
+	}
+	to, found, err := reader.GetLowestBlockInBatch(toBatch + 1)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("batch %d not found in the db, the batch %d can not be closed", toBatch+1, toBatch)
+	}
+
+	if from == 0 {
+		genesis, err := rawdb.ReadBlockByNumber(tx, 0)
+		if err != nil {
+			return err
+		}
+		if err = srv.WriteGenesisToStream(genesis, reader, tx); err != nil {
+			return err
+		}
+		from = 1
+	}
+
+	return srv.WriteBlocksToStreamConsecutively(ctx, "[segments rebuild]", tx, reader, from, to)
+}
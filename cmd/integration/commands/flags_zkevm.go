@@ -14,3 +14,15 @@ func withUnwindBatchNo(cmd *cobra.Command) {
 func withDsUnwindBlockNumber(cmd *cobra.Command) {
 	cmd.Flags().Uint64Var(&unwindDsBlockNo, "unwind-block-no", 0, "block number to unwind to (this block number will be the tip)")
 }
+
+var smtGcChunkSize uint64
+
+func withSmtGcChunkSize(cmd *cobra.Command) {
+	cmd.Flags().Uint64Var(&smtGcChunkSize, "smt-gc-chunk-size", 1_000_000, "state tree branches marked and entries swept per committed transaction, 0 to collect in a single transaction")
+}
+
+var smtSnapshotDir string
+
+func withSmtSnapshotDir(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&smtSnapshotDir, "smt-snapshot-dir", "", "directory of the state tree snapshot files and manifest")
+}
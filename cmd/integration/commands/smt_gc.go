@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	common2 "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	smtdb "github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/smt/pkg/smt"
+	"github.com/ledgerwatch/erigon/turbo/debug"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/spf13/cobra"
+)
+
+var smtGc = &cobra.Command{
+	Use: "smt_gc",
+	Short: `Delete the state tree nodes, hash keys and key sources no live root reaches.
+Examples:
+smt_gc --datadir=/datadirs/hermez-mainnet --smt-gc-chunk-size=1000000 # mark or sweep a million branches and entries per transaction
+The freed pages are reused by the database, run mdbx_copy -c on the chaindata afterwards to shrink the file.
+		`,
+	Example: "go run ./cmd/integration smt_gc --datadir=... --smt-gc-chunk-size=1000000",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, _ := common2.RootContext()
+		logger := debug.SetupCobra(cmd, "integration")
+		db, err := openDB(dbCfg(kv.ChainDB, chaindata), true, logger)
+		if err != nil {
+			logger.Error("Opening DB", "error", err)
+			return
+		}
+		defer db.Close()
+
+		if err := collectSmtGarbage(ctx, db); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				log.Error(err.Error())
+			}
+			return
+		}
+	},
+}
+
+func init() {
+	withDataDir2(smtGc)
+	withSmtGcChunkSize(smtGc)
+	rootCmd.AddCommand(smtGc)
+}
+
+// collectSmtGarbage runs a whole garbage collection cycle, committing after every chunk of smtGcChunkSize branches and entries
+func collectSmtGarbage(ctx context.Context, db kv.RwDB) error {
+	gc := smt.NewGarbageCollector()
+	for {
+		var report smt.GCReport
+		if err := db.Update(ctx, func(tx kv.RwTx) (err error) {
+			report, err = gc.Collect(ctx, smtdb.NewEriDb(tx), int(smtGcChunkSize))
+			return err
+		}); err != nil {
+			return err
+		}
+
+		if !report.Done {
+			log.Info("Collecting state tree garbage", "marked", report.MarkedBranches, "scanned", report.ScannedEntries, "reclaimed", common2.ByteCount(report.ReclaimedBytes))
+			continue
+		}
+
+		fmt.Printf("roots: %d\nlive nodes: %d\nlive keys: %d\nscanned entries: %d\ndeleted nodes: %d\ndeleted hash keys: %d\ndeleted key sources: %d\nreclaimed: %s\n",
+			report.Roots, report.LiveNodes, report.LiveKeys, report.ScannedEntries,
+			report.DeletedNodes, report.DeletedHashKeys, report.DeletedKeySources, common2.ByteCount(report.ReclaimedBytes))
+		return nil
+	}
+}
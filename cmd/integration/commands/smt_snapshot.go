@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	common2 "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/datadir"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	smtdb "github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/turbo/debug"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/ledgerwatch/log/v3"
+	"github.com/spf13/cobra"
+)
+
+var smtExport = &cobra.Command{
+	Use: "smt_export",
+	Short: `Export the state tree at the intermediate hashes stage progress to a checksummed snapshot.
+Examples:
+smt_export --datadir=/datadirs/hermez-mainnet --smt-snapshot-dir=/snapshots/smt
+		`,
+	Example: "go run ./cmd/integration smt_export --datadir=... --smt-snapshot-dir=...",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, _ := common2.RootContext()
+		logger := debug.SetupCobra(cmd, "integration")
+		db, err := openDB(dbCfg(kv.ChainDB, chaindata), true, logger)
+		if err != nil {
+			logger.Error("Opening DB", "error", err)
+			return
+		}
+		defer db.Close()
+
+		if err := exportSmtSnapshot(ctx, db); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				log.Error(err.Error())
+			}
+			return
+		}
+	},
+}
+
+var smtImport = &cobra.Command{
+	Use: "smt_import",
+	Short: `Replace the state tree with a snapshot and move the intermediate hashes stage to its block.
+The root of the snapshot must match the state root of the block synced from the datastream, and every node of the
+imported tree must hash to its key from that root. The node must have executed the block, the stage then catches up
+from the snapshot block on the next run.
+Examples:
+smt_import --datadir=/datadirs/hermez-mainnet --smt-snapshot-dir=/snapshots/smt
+		`,
+	Example: "go run ./cmd/integration smt_import --datadir=... --smt-snapshot-dir=...",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, _ := common2.RootContext()
+		logger := debug.SetupCobra(cmd, "integration")
+		db, err := openDB(dbCfg(kv.ChainDB, chaindata), true, logger)
+		if err != nil {
+			logger.Error("Opening DB", "error", err)
+			return
+		}
+		defer db.Close()
+
+		if err := importSmtSnapshot(ctx, db); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				log.Error(err.Error())
+			}
+			return
+		}
+	},
+}
+
+func init() {
+	withDataDir2(smtExport)
+	withSmtSnapshotDir(smtExport)
+	rootCmd.AddCommand(smtExport)
+
+	withDataDir2(smtImport)
+	withSmtSnapshotDir(smtImport)
+	rootCmd.AddCommand(smtImport)
+}
+
+// exportSmtSnapshot exports the state tree once its root is checked against the header of the block it is at
+func exportSmtSnapshot(ctx context.Context, db kv.RwDB) error {
+	if smtSnapshotDir == "" {
+		return errors.New("--smt-snapshot-dir is required")
+	}
+
+	tx, err := db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	blockNumber, err := stages.GetStageProgress(tx, stages.IntermediateHashes)
+	if err != nil {
+		return err
+	}
+	header := rawdb.ReadHeaderByNumber(tx, blockNumber)
+	if header == nil {
+		return fmt.Errorf("no header found with number %d", blockNumber)
+	}
+	root, err := smtdb.NewRoEriDb(tx).GetLastRoot()
+	if err != nil {
+		return err
+	}
+	if common2.BigToHash(root) != header.Root {
+		return fmt.Errorf("state tree root %x does not match the root of block %d: %x", common2.BigToHash(root), blockNumber, header.Root)
+	}
+
+	log.Info("Exporting state tree", "block", blockNumber, "root", header.Root, "dir", smtSnapshotDir)
+	manifest, err := smtdb.ExportSnapshot(ctx, tx, smtSnapshotDir, datadir.New(datadirCli).Tmp, blockNumber)
+	if err != nil {
+		return err
+	}
+
+	var size uint64
+	for _, f := range manifest.Files {
+		size += f.Size
+	}
+	log.Info("Exported state tree", "block", manifest.BlockNumber, "root", manifest.Root, "files", len(manifest.Files), "size", common2.ByteCount(size))
+	return nil
+}
+
+// importSmtSnapshot replaces the state tree with the snapshot in a single transaction, nothing is switched over unless
+// the snapshot verifies against the state root of its block
+func importSmtSnapshot(ctx context.Context, db kv.RwDB) error {
+	if smtSnapshotDir == "" {
+		return errors.New("--smt-snapshot-dir is required")
+	}
+
+	manifest, err := smtdb.ReadSnapshotManifest(smtSnapshotDir)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(ctx, func(tx kv.RwTx) error {
+		expectedRoot, err := hermez_db.NewHermezDbReader(tx).GetStateRoot(manifest.BlockNumber)
+		if err != nil {
+			return err
+		}
+		if expectedRoot == (common2.Hash{}) {
+			return fmt.Errorf("no state root synced for block %d", manifest.BlockNumber)
+		}
+		if manifest.Root != expectedRoot {
+			return fmt.Errorf("snapshot root %x does not match the state root of block %d: %x", manifest.Root, manifest.BlockNumber, expectedRoot)
+		}
+
+		executed, err := stages.GetStageProgress(tx, stages.Execution)
+		if err != nil {
+			return err
+		}
+		if executed < manifest.BlockNumber {
+			return fmt.Errorf("snapshot block %d is ahead of the executed block %d", manifest.BlockNumber, executed)
+		}
+
+		log.Info("Importing state tree", "block", manifest.BlockNumber, "root", manifest.Root, "files", len(manifest.Files))
+		if _, err = smtdb.ImportSnapshot(ctx, tx, smtSnapshotDir); err != nil {
+			return err
+		}
+
+		if err = stages.SaveStageProgress(tx, stages.IntermediateHashes, manifest.BlockNumber); err != nil {
+			return err
+		}
+
+		log.Info("Imported state tree", "block", manifest.BlockNumber, "root", manifest.Root)
+		return nil
+	})
+}
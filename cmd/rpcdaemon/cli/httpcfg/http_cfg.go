@@ -88,6 +88,7 @@ type HttpCfg struct {
 	LogsMaxRange                uint64 // Maximum number of logs that can be requested in a single call
 	AllowUnprotectedTxs         bool   // Whether to allow non EIP-155 protected transactions  txs over RPC
 	MaxGetProofRewindBlockCount int    //Max GetProof rewind block count
+	MaxGetMultiProofKeys        int    // Max number of keys proved by a GetMultiProof call
 	// Ots API
 	OtsMaxPageSize uint64
 
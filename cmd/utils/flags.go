@@ -420,11 +420,41 @@ var (
 		Usage: "Use TLS connection to L2 datastreamer endpoint",
 		Value: false,
 	}
+	L2DataStreamerTLSCertFlag = cli.StringFlag{
+		Name:  "zkevm.l2-datastreamer-tls-cert",
+		Usage: "Client certificate presented to an L2 datastreamer requiring mutual TLS",
+		Value: "",
+	}
+	L2DataStreamerTLSKeyFlag = cli.StringFlag{
+		Name:  "zkevm.l2-datastreamer-tls-key",
+		Usage: "Key of the client certificate presented to the L2 datastreamer",
+		Value: "",
+	}
+	L2DataStreamerTLSCAFlag = cli.StringFlag{
+		Name:  "zkevm.l2-datastreamer-tls-ca",
+		Usage: "CA used to verify the certificate of the L2 datastreamer instead of the system roots",
+		Value: "",
+	}
 	L2DataStreamerTimeout = cli.StringFlag{
 		Name:  "zkevm.l2-datastreamer-timeout",
 		Usage: "The time to wait for data to arrive from the stream before reporting an error (0s doesn't check)",
 		Value: "3s",
 	}
+	L2DataStreamerFailoverUrlsFlag = cli.StringFlag{
+		Name:  "zkevm.l2-datastreamer-failover-urls",
+		Usage: "Comma separated L2 datastreamer endpoints to fail over to when zkevm.l2-datastreamer-url stalls, errors or diverges",
+		Value: "",
+	}
+	L2DataStreamerStallTimeout = cli.DurationFlag{
+		Name:  "zkevm.l2-datastreamer-stall-timeout",
+		Usage: "Time without entries from the L2 datastreamer, while another endpoint has newer blocks, before failing over (0s doesn't fail over on stalls)",
+		Value: 30 * time.Second,
+	}
+	L2DataStreamerCrossCheckInterval = cli.Uint64Flag{
+		Name:  "zkevm.l2-datastreamer-cross-check-interval",
+		Usage: "Number of blocks between the checks of the block hashes against the other L2 datastreamer endpoints (0 doesn't check)",
+		Value: 100,
+	}
 	L2ShortCircuitToVerifiedBatchFlag = cli.BoolFlag{
 		Name:  "zkevm.l2-short-circuit-to-verified-batch",
 		Usage: "Short circuit block execution up to the batch after the latest verified batch (default: true). When disabled, the sequencer will execute all downloaded batches",
@@ -460,6 +490,16 @@ var (
 		Usage: "The port used for the L1 cache",
 		Value: 6969,
 	}
+	L1CacheMaxSizeFlag = DatasizeFlag{
+		Name:  "zkevm.l1-cache-max-size",
+		Usage: "Maximum size of the L1 responses cached in format \"1GB\", the least recently used are evicted past it. 0 is unbounded",
+		Value: datasizeFlagValue(1 * datasize.GB),
+	}
+	L1CacheLatestTTLFlag = cli.DurationFlag{
+		Name:  "zkevm.l1-cache-latest-ttl",
+		Usage: "How long the L1 responses about blocks which are not finalized yet are cached, 0 does not cache them",
+		Value: 5 * time.Second,
+	}
 	AddressSequencerFlag = cli.StringFlag{
 		Name:  "zkevm.address-sequencer",
 		Usage: "Sequencer address",
@@ -506,6 +546,16 @@ var (
 		Usage: "The type of the highest block in the L1 chain. latest, safe, or finalized",
 		Value: "finalized",
 	}
+	L1QuorumFlag = cli.Uint64Flag{
+		Name:  "zkevm.l1-quorum",
+		Usage: "Number of the zkevm.l1-rpc-url providers that must agree on the sequenced batch logs, the acc input hashes and the finalized block for them to be accepted (0 reads from one provider at a time)",
+		Value: 0,
+	}
+	L1ProviderMaxFaultsFlag = cli.Uint64Flag{
+		Name:  "zkevm.l1-provider-max-faults",
+		Usage: "Score, lowered by every error or result the quorum disagreed with and raised by every result agreed on, at which an L1 provider is evicted from the quorum for a while",
+		Value: 3,
+	}
 	L1MaticContractAddressFlag = cli.StringFlag{
 		Name:  "zkevm.l1-matic-contract-address",
 		Usage: "Ethereum L1 Matic contract address",
@@ -546,6 +596,21 @@ var (
 		Usage: "Regenerate the SMT in memory (requires a lot of RAM for most chains)",
 		Value: false,
 	}
+	SmtRetainBlocks = cli.Uint64Flag{
+		Name:  "zkevm.smt-retain-blocks",
+		Usage: "Number of recent blocks whose state tree is retained to serve proofs and witnesses without rewinding the tree, 0 to disable",
+		Value: 0,
+	}
+	SmtGcChunkSize = cli.Uint64Flag{
+		Name:  "zkevm.smt-gc-chunk-size",
+		Usage: "Number of state tree branches and entries the garbage collector marks or sweeps per stage loop cycle, 0 to disable",
+		Value: 0,
+	}
+	SmtHashWorkers = cli.IntFlag{
+		Name:  "zkevm.smt-hash-workers",
+		Usage: "Number of workers hashing the subtrees of a state tree batch insert concurrently, 1 to hash in a single pass. Benchmarked against mdbx it gave no gain, so do not expect faster block times from it",
+		Value: 1,
+	}
 	SequencerBlockSealTime = cli.StringFlag{
 		Name:  "zkevm.sequencer-block-seal-time",
 		Usage: "Block seal time. Defaults to 6s",
@@ -606,6 +671,41 @@ var (
 		Usage: "The timeout for the executor request",
 		Value: 60 * time.Second,
 	}
+	ExecutorWeights = cli.StringFlag{
+		Name:  "zkevm.executor-weights",
+		Usage: "A comma separated list of routing weights for the executors in the order of zkevm.executor-urls, an executor with weight 2 takes twice the load of one with weight 1. Defaults to 1 for every executor",
+		Value: "",
+	}
+	ExecutorBreakerFailures = cli.IntFlag{
+		Name:  "zkevm.executor-breaker-failures",
+		Usage: "The number of consecutive failures that takes an executor out of rotation",
+		Value: 3,
+	}
+	ExecutorBreakerCooldown = cli.DurationFlag{
+		Name:  "zkevm.executor-breaker-cooldown",
+		Usage: "How long an executor stays out of rotation before it is probed again, the cooldown doubles every time the probe fails",
+		Value: 30 * time.Second,
+	}
+	ExecutorHedgeDelay = cli.DurationFlag{
+		Name:  "zkevm.executor-hedge-delay",
+		Usage: "Send a verification request to a second executor when the first one did not answer within this delay. Overdue requests are always hedged, 0 hedges only those",
+		Value: 0,
+	}
+	VerificationFailuresLimit = cli.IntFlag{
+		Name:  "zkevm.verification-failures-limit",
+		Usage: "The number of failed verifications, with their witness and data stream, the sequencer keeps in the verification_failures database of the datadir. 0 disables the archive",
+		Value: 100,
+	}
+	LocalExecutorAddr = cli.StringFlag{
+		Name:  "zkevm.local-executor-addr",
+		Usage: "The grpc address to serve a local executor on, re-executing the batches with erigon's own zk EVM. Point zkevm.executor-urls at it to run the sequencer end-to-end locally",
+		Value: "",
+	}
+	LocalExecutorCompare = cli.BoolFlag{
+		Name:  "zkevm.local-executor-compare",
+		Usage: "Re-execute every batch sent to the executors locally and log where the executors diverge from erigon",
+		Value: false,
+	}
 	DatastreamNewBlockTimeout = cli.DurationFlag{
 		Name:  "zkevm.datastream-new-block-timeout",
 		Usage: "The timeout for the executor request",
@@ -668,6 +768,46 @@ var (
 		Usage: "Define the inactivity check interval timeout when interacting with a data stream server",
 		Value: 5 * time.Minute,
 	}
+	DataStreamTLSCert = cli.StringFlag{
+		Name:  "zkevm.data-stream-tls-cert",
+		Usage: "Certificate to serve the zkevm data stream over TLS, requires zkevm.data-stream-tls-key",
+		Value: "",
+	}
+	DataStreamTLSKey = cli.StringFlag{
+		Name:  "zkevm.data-stream-tls-key",
+		Usage: "Key of the certificate used to serve the zkevm data stream over TLS",
+		Value: "",
+	}
+	DataStreamTLSClientCA = cli.StringFlag{
+		Name:  "zkevm.data-stream-tls-client-ca",
+		Usage: "Require the data stream clients to present a certificate signed by this CA (mutual TLS)",
+		Value: "",
+	}
+	DataStreamAllowedIPs = cli.StringFlag{
+		Name:  "zkevm.data-stream-allowed-ips",
+		Usage: "Comma separated IPs or CIDRs allowed to connect to the data stream, empty allows any",
+		Value: "",
+	}
+	DataStreamAllowedCerts = cli.StringFlag{
+		Name:  "zkevm.data-stream-allowed-certs",
+		Usage: "Comma separated SHA-256 fingerprints of the client certificates allowed to connect to the data stream, empty allows any",
+		Value: "",
+	}
+	DataStreamInternalPort = cli.UintFlag{
+		Name:  "zkevm.data-stream-internal-port",
+		Usage: "Port of the data stream server behind the TLS and allowlist guard, required by them. It is bound to the loopback only",
+		Value: 0,
+	}
+	DataStreamGatewayAddr = cli.StringFlag{
+		Name:  "zkevm.data-stream-gateway-addr",
+		Usage: "Address to serve the zkevm data stream as JSON entries over websocket, i.e. localhost:6901. Empty disables the gateway",
+		Value: "",
+	}
+	DataStreamArchiveDir = cli.StringFlag{
+		Name:  "zkevm.data-stream-archive-dir",
+		Usage: "Directory of the archived data stream segments served by the gateway, defaults to <datadir>/data-stream-archive",
+		Value: "",
+	}
 	Limbo = cli.BoolFlag{
 		Name:  "zkevm.limbo",
 		Usage: "Enable limbo processing on batches that failed verification",
@@ -789,6 +929,31 @@ var (
 		Usage: "Reject smart contract deployments",
 		Value: false,
 	}
+	TxPoolSenderTxLimit = cli.Uint64Flag{
+		Name:  "zkevm.txpool-sender-tx-limit",
+		Usage: "Maximum number of transactions a single sender can add to the pool per window, 0 disables the limit",
+		Value: 0,
+	}
+	TxPoolSenderGasLimit = cli.Uint64Flag{
+		Name:  "zkevm.txpool-sender-gas-limit",
+		Usage: "Maximum gas of the transactions a single sender can add to the pool per window, 0 disables the limit",
+		Value: 0,
+	}
+	TxPoolSenderLimitWindow = cli.DurationFlag{
+		Name:  "zkevm.txpool-sender-limit-window",
+		Usage: "Window of the per sender transaction and gas limits",
+		Value: time.Minute,
+	}
+	TxPoolSenderLimitACLExempt = cli.BoolFlag{
+		Name:  "zkevm.txpool-sender-limit-acl-exempt",
+		Usage: "Exempt the senders with the rateLimitExempt policy in the ACL allowlist from the per sender limits",
+		Value: false,
+	}
+	TxPoolOrdering = cli.StringFlag{
+		Name:  "zkevm.txpool-ordering",
+		Usage: "Order in which the sequencer takes the pending transactions from the pool: default, tip, fifo, round-robin or priority",
+		Value: "default",
+	}
 	DisableVirtualCounters = cli.BoolFlag{
 		Name:  "zkevm.disable-virtual-counters",
 		Usage: "Disable the virtual counters. This has an effect on on sequencer node and when external executor is not enabled.",
@@ -804,6 +969,21 @@ var (
 		Usage: "The URL of the data availability service",
 		Value: "",
 	}
+	DACommitteeUrls = cli.StringFlag{
+		Name:  "zkevm.da-committee-urls",
+		Usage: "Comma separated URLs of data availability committee members queried along with zkevm.da-url, the first one returning data matching its hash is used",
+		Value: "",
+	}
+	DADataDir = cli.StringFlag{
+		Name:  "zkevm.da-data-dir",
+		Usage: "Directory of validium off chain data files named by hash, read instead of the data availability service, cannot be set along with its urls or zkevm.da-cache-dir",
+		Value: "",
+	}
+	DACacheDir = cli.StringFlag{
+		Name:  "zkevm.da-cache-dir",
+		Usage: "Directory where the off chain data fetched from the data availability service is cached",
+		Value: "",
+	}
 	VirtualCountersSmtReduction = cli.Float64Flag{
 		Name:  "zkevm.virtual-counters-smt-reduction",
 		Usage: "The multiplier to reduce the SMT depth by when calculating virtual counters",
@@ -829,6 +1009,11 @@ var (
 		Usage: "Seal the batch immediately when detecting a counter overflow",
 		Value: false,
 	}
+	SequencerCounterAwarePacking = cli.BoolFlag{
+		Name:  "zkevm.sequencer-counter-aware-packing",
+		Usage: "Only take from the pool the transactions whose counters, estimated from a dry run or a previous execution, fit in what is left of the batch",
+		Value: false,
+	}
 
 	VerifyZkProofForkid = cli.Uint64SliceFlag{
 		Name:  "zkevm.verify.zkProof.forkid",
@@ -865,6 +1050,11 @@ var (
 		Usage: "Number of entries to print from the ACL history on node start up",
 		Value: 10,
 	}
+	ACLRpcEnabled = cli.BoolFlag{
+		Name:  "acl.rpc",
+		Usage: "Serve the acl_ namespace to manage the ACL on the JWT authenticated RPC endpoint",
+		Value: false,
+	}
 	DebugTimers = cli.BoolFlag{
 		Name:  "debug.timers",
 		Usage: "Enable debug timers",
@@ -1030,6 +1220,13 @@ var (
 		Usage: "Max GetProof rewind block count",
 		Value: 100_000,
 	}
+	// Every key of zkevm_getMultiProof walks the tree and adds its path to the proof held in memory,
+	// over a rewound tree for a block that is not the head.
+	RpcMaxGetMultiProofKeys = cli.IntFlag{
+		Name:  "rpc.maxgetmultiproofkeys.limit",
+		Usage: "Max number of keys proved by a zkevm_getMultiProof call, every account counts 4 keys and every storage slot 1",
+		Value: 1000,
+	}
 	StateCacheFlag = cli.StringFlag{
 		Name:  "state.cache",
 		Value: "0MB",
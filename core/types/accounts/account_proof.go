@@ -40,3 +40,25 @@ type SMTStorageProofResult struct {
 	Value *hexutil.Big       `json:"value"`
 	Proof []hexutility.Bytes `json:"proof"`
 }
+
+// Result structs for GetMultiProof
+type SMTMultiProofResult struct {
+	StateRoot libcommon.Hash         `json:"stateRoot"`
+	Accounts  []SMTMultiProofAccount `json:"accounts"`
+	Nodes     []hexutility.Bytes     `json:"nodes"`
+	Values    []hexutility.Bytes     `json:"values"`
+}
+
+type SMTMultiProofAccount struct {
+	Address    libcommon.Address          `json:"address"`
+	Balance    *hexutil.Big               `json:"balance"`
+	CodeHash   libcommon.Hash             `json:"codeHash"`
+	CodeLength hexutil.Uint64             `json:"codeLength"`
+	Nonce      hexutil.Uint64             `json:"nonce"`
+	Storage    []SMTMultiProofStorageSlot `json:"storage"`
+}
+
+type SMTMultiProofStorageSlot struct {
+	Key   libcommon.Hash `json:"key"`
+	Value *hexutil.Big   `json:"value"`
+}
@@ -188,6 +188,21 @@ func (bcc *BatchCounterCollector) CheckForOverflow(verifyMerkleProof bool) (bool
 	return overflow, nil
 }
 
+// RemainingCounters returns what is left of every counter in the batch so far, in the order of CounterKeyNames
+func (bcc *BatchCounterCollector) RemainingCounters(verifyMerkleProof bool) ([]int, error) {
+	combined, err := bcc.CombineCollectors(verifyMerkleProof)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := make([]int, len(combined))
+	for i, v := range combined {
+		remaining[i] = v.remaining
+	}
+
+	return remaining, nil
+}
+
 // CounterStats returns a string with combined counter stats.
 func (bcc *BatchCounterCollector) CounterStats(verifyMerkleProof bool) (string, error) {
 	combined, err := bcc.CombineCollectors(verifyMerkleProof)
@@ -545,9 +545,19 @@ const (
 	TableAccountValues                = "HermezSmtAccountValues"
 	TableMetadata                     = "HermezSmtMetadata"
 	TableHashKey                      = "HermezSmtHashKey"
+	TableSmtRoots                     = "HermezSmtRoots"             // block number -> root of the tree retained at the block
+	TableSmtStaleNodes                = "HermezSmtStaleNodes"        // node key -> block number the node left the tree at
+	TableSmtStaleNodesByBlock         = "HermezSmtStaleNodesByBlock" // block number + node key -> nil
+	TableSmtGcLiveNodes               = "HermezSmtGcLiveNodes"       // node or value key -> nil, marked live by the running collection
+	TableSmtGcLiveKeys                = "HermezSmtGcLiveKeys"        // full leaf key -> nil, marked live by the running collection
+	TableSmtGcPending                 = "HermezSmtGcPending"         // node key -> path of a live branch whose children are not marked yet
 	TablePoolLimbo                    = "PoolLimbo"
 	BATCH_ENDS                        = "batch_ends"
 	BAD_TX_HASHES                     = "bad_tx_hashes"
+	OFF_CHAIN_DATA                    = "off_chain_data"         // data hash -> validium batch data
+	OFF_CHAIN_DATA_BATCHES            = "off_chain_data_batches" // data hash + batch number -> nil
+	BATCH_OFF_CHAIN_DATA              = "batch_off_chain_data"   // batch number -> data hash
+	L1_BLOCK_HASHES                   = "l1_block_hashes"        // l1 block number -> l1 block hash
 	//Diagnostics tables
 	DiagSystemInfo = "DiagSystemInfo"
 	DiagSyncStages = "DiagSyncStages"
@@ -790,9 +800,19 @@ var ChaindataTables = []string{
 	TableAccountValues,
 	TableMetadata,
 	TableHashKey,
+	TableSmtRoots,
+	TableSmtStaleNodes,
+	TableSmtStaleNodesByBlock,
+	TableSmtGcLiveNodes,
+	TableSmtGcLiveKeys,
+	TableSmtGcPending,
 	TablePoolLimbo,
 	BATCH_ENDS,
 	BAD_TX_HASHES,
+	OFF_CHAIN_DATA,
+	OFF_CHAIN_DATA_BATCHES,
+	BATCH_OFF_CHAIN_DATA,
+	L1_BLOCK_HASHES,
 }
 
 const (
@@ -109,6 +109,7 @@ type TxSlot struct {
 	Commitments []gokzg4844.KZGCommitment
 	Proofs      []gokzg4844.KZGProof
 	To          common.Address
+	Selector    [4]byte // First 4 bytes of the transaction's data, identifies the called method if DataLen >= 4
 }
 
 const (
@@ -225,7 +226,6 @@ func (ctx *TxParseContext) ParseTransaction(payload []byte, pos int, slot *TxSlo
 		}
 
 		dataPos, dataLen, err = rlp.List(payload, p)
-		slot.To = common.BytesToAddress(payload[dataPos : dataPos+dataLen])
 		if err != nil {
 			return 0, fmt.Errorf("%w: blobs len: %s", ErrParseTxn, err) //nolint
 		}
@@ -378,6 +378,10 @@ func (ctx *TxParseContext) parseTransactionBody(payload []byte, pos, p0 int, slo
 
 	// Only note if To field is empty or not
 	slot.Creation = dataLen == 0
+	slot.To = common.Address{}
+	if !slot.Creation {
+		slot.To = common.BytesToAddress(payload[dataPos : dataPos+dataLen])
+	}
 	p = dataPos + dataLen
 	// Next follows value
 	p, err = rlp.U256(payload, p, &slot.Value)
@@ -390,6 +394,10 @@ func (ctx *TxParseContext) parseTransactionBody(payload []byte, pos, p0 int, slo
 		return 0, fmt.Errorf("%w: data len: %s", ErrParseTxn, err) //nolint
 	}
 	slot.DataLen = dataLen
+	slot.Selector = [4]byte{}
+	if dataLen >= 4 {
+		copy(slot.Selector[:], payload[dataPos:dataPos+4])
+	}
 
 	// Zero and non-zero bytes are priced differently
 	slot.DataNonZeroLen = 0
@@ -52,7 +52,7 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/protobuf/types/known/emptypb"
 
-	"net/url"
+	"net/http"
 	"path"
 
 	log2 "github.com/0xPolygonHermez/zkevm-data-streamer/log"
@@ -128,7 +128,6 @@ import (
 	stages2 "github.com/ledgerwatch/erigon/turbo/stages"
 	"github.com/ledgerwatch/erigon/turbo/stages/headerdownload"
 	"github.com/ledgerwatch/erigon/zk/contracts"
-	"github.com/ledgerwatch/erigon/zk/datastream/client"
 	"github.com/ledgerwatch/erigon/zk/datastream/server"
 	"github.com/ledgerwatch/erigon/zk/hermez_db"
 	"github.com/ledgerwatch/erigon/zk/l1_cache"
@@ -226,6 +225,10 @@ type Ethereum struct {
 	l1Syncer        *syncer.L1Syncer
 	etherManClients []*etherman.Client
 	l1Cache         *l1_cache.L1Cache
+	l1Quorum        *syncer.Quorum
+	legacyVerifier  *legacy_executor_verifier.LegacyExecutorVerifier
+	// verificationFailures archives the batches the executors failed to verify
+	verificationFailures *legacy_executor_verifier.FailureArchive
 
 	preStartTasks *PreStartTasks
 
@@ -982,7 +985,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 			}
 
 			// todo [zkevm] read the stream version from config and figure out what system id is used for
-			backend.streamServer, err = dataStreamServerFactory.CreateStreamServer(uint16(httpCfg.DataStreamPort), uint8(backend.config.DatastreamVersion), 1, datastreamer.StreamType(1), file, httpCfg.DataStreamWriteTimeout, httpCfg.DataStreamInactivityTimeout, httpCfg.DataStreamInactivityCheckInterval, logConfig)
+			backend.streamServer, err = dataStreamServerFactory.CreateSecureStreamServer(uint16(httpCfg.DataStreamPort), uint8(backend.config.DatastreamVersion), 1, datastreamer.StreamType(1), file, httpCfg.DataStreamWriteTimeout, httpCfg.DataStreamInactivityTimeout, httpCfg.DataStreamInactivityCheckInterval, logConfig, dataStreamSecurityConfig(backend.config.Zk))
 			if err != nil {
 				return nil, err
 			}
@@ -1004,25 +1007,29 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 		backend.chainConfig.ZkDefaultGasPrice = cfg.DefaultGasPrice
 		l1Urls := strings.Split(cfg.L1RpcUrl, ",")
 
+		// the etherman clients read through the cache in process, it is also served to other nodes
+		var l1Transport http.RoundTripper
 		if cfg.Zk.L1CacheEnabled {
-			l1Cache, err := l1_cache.NewL1Cache(ctx, path.Join(stack.DataDir(), "l1cache"), cfg.Zk.L1CachePort)
+			l1Cache, err := l1_cache.NewL1Cache(ctx, path.Join(stack.DataDir(), "l1cache"), l1_cache.Config{
+				MaxSize:   cfg.Zk.L1CacheMaxSize.Bytes(),
+				LatestTTL: cfg.Zk.L1CacheLatestTTL,
+			})
 			if err != nil {
 				return nil, err
 			}
+			l1Cache.Serve(cfg.Zk.L1CachePort)
 			backend.l1Cache = l1Cache
-
-			var cacheL1Urls []string
-			for _, l1Url := range l1Urls {
-				encoded := url.QueryEscape(l1Url)
-				cacheL1Url := fmt.Sprintf("http://localhost:%d?endpoint=%s&chainid=%d", cfg.Zk.L1CachePort, encoded, cfg.L2ChainId)
-				cacheL1Urls = append(cacheL1Urls, cacheL1Url)
+			if cfg.L1Quorum > 0 {
+				// the providers read with quorum must not share cached answers, their agreement would mean nothing
+				l1Transport = l1Cache.EndpointTransport(cfg.L2ChainId, http.DefaultTransport)
+			} else {
+				l1Transport = l1Cache.Transport(cfg.L2ChainId, http.DefaultTransport)
 			}
-			l1Urls = cacheL1Urls
 		}
 
 		backend.etherManClients = make([]*etherman.Client, len(l1Urls))
 		for i, url := range l1Urls {
-			backend.etherManClients[i] = newEtherMan(cfg, chainConfig.ChainName, url)
+			backend.etherManClients[i] = newEtherMan(cfg, chainConfig.ChainName, url, l1Transport)
 		}
 
 		isSequencer := sequencer.IsSequencer()
@@ -1067,6 +1074,16 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 			ethermanClients[i] = c.EthClient
 		}
 
+		// the syncers share the quorum so that a provider misbehaving for one of them is evicted for all of them
+		var l1Quorum *syncer.Quorum
+		if cfg.L1Quorum > 0 {
+			if cfg.L1Quorum > uint64(len(ethermanClients)) {
+				return nil, fmt.Errorf("the L1 quorum of %d is higher than the %d L1 providers", cfg.L1Quorum, len(ethermanClients))
+			}
+			l1Quorum = syncer.NewQuorum(ethermanClients, int(cfg.L1Quorum), cfg.L1ProviderMaxFaults)
+			backend.l1Quorum = l1Quorum
+		}
+
 		seqVerSyncer := syncer.NewL1Syncer(
 			ctx,
 			ethermanClients,
@@ -1076,6 +1093,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 			cfg.L1QueryDelay,
 			cfg.L1HighestBlockType,
 		)
+		seqVerSyncer.SetQuorum(l1Quorum)
 
 		backend.l1Syncer = syncer.NewL1Syncer(
 			ctx,
@@ -1086,6 +1104,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 			cfg.L1QueryDelay,
 			cfg.L1HighestBlockType,
 		)
+		backend.l1Syncer.SetQuorum(l1Quorum)
 
 		log.Info("Rollup ID", "rollupId", cfg.L1RollupId)
 
@@ -1101,6 +1120,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 			cfg.L1QueryDelay,
 			cfg.L1HighestBlockType,
 		)
+		l1InfoTreeSyncer.SetQuorum(l1Quorum)
 
 		l1InfoTreeUpdater := l1infotree.NewUpdater(cfg.Zk, l1InfoTreeSyncer)
 
@@ -1123,6 +1143,22 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 				backend.config.WitnessUnwindLimit,
 			)
 
+			localExecutor := legacy_executor_verifier.NewLocalExecutor(backend.chainConfig, cfg.VirtualCountersSmtReduction)
+			if cfg.LocalExecutorAddr != "" {
+				addr, err := localExecutor.Serve(ctx, cfg.LocalExecutorAddr)
+				if err != nil {
+					return nil, err
+				}
+				log.Info("Local executor started", "addr", addr)
+			}
+
+			if cfg.HasExecutors() && cfg.VerificationFailuresLimit > 0 {
+				backend.verificationFailures, err = legacy_executor_verifier.OpenFailureArchive(ctx, stack.DataDir(), cfg.VerificationFailuresLimit)
+				if err != nil {
+					return nil, err
+				}
+			}
+
 			var legacyExecutors []*legacy_executor_verifier.Executor = make([]*legacy_executor_verifier.Executor, 0, len(cfg.ExecutorUrls))
 			if len(cfg.ExecutorUrls) > 0 && cfg.ExecutorUrls[0] != "" {
 				levCfg := legacy_executor_verifier.Config{
@@ -1130,6 +1166,12 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 					Timeout:               cfg.ExecutorRequestTimeout,
 					MaxConcurrentRequests: cfg.ExecutorMaxConcurrentRequests,
 					OutputLocation:        cfg.ExecutorPayloadOutput,
+					Weights:               cfg.ExecutorWeights,
+					BreakerFailures:       cfg.ExecutorBreakerFailures,
+					BreakerCooldown:       cfg.ExecutorBreakerCooldown,
+				}
+				if cfg.LocalExecutorCompare {
+					levCfg.LocalExecutor = localExecutor
 				}
 				executors := legacy_executor_verifier.NewExecutors(levCfg)
 				for _, e := range executors {
@@ -1143,7 +1185,9 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 				backend.chainDB,
 				witnessGenerator,
 				dataStreamServer,
+				backend.verificationFailures,
 			)
+			backend.legacyVerifier = verifier
 
 			if cfg.Zk.Limbo {
 				limboSubPoolProcessor := txpool.NewLimboSubPoolProcessor(ctx, cfg.Zk, backend.chainConfig, backend.chainDB, backend.txPool2, verifier)
@@ -1165,6 +1209,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 				cfg.L1QueryDelay,
 				cfg.L1HighestBlockType,
 			)
+			l1BlockSyncer.SetQuorum(l1Quorum)
 
 			backend.syncStages = stages2.NewSequencerZkStages(
 				backend.sentryCtx,
@@ -1270,7 +1315,7 @@ func recordStartupVersionInDb(tx kv.RwTx) error {
 }
 
 // creates an EtherMan instance with default parameters
-func newEtherMan(cfg *ethconfig.Config, l2ChainName, url string) *etherman.Client {
+func newEtherMan(cfg *ethconfig.Config, l2ChainName, url string, transport http.RoundTripper) *etherman.Client {
 	ethmanConf := etherman.Config{
 		URL:                       url,
 		L1ChainID:                 cfg.L1ChainId,
@@ -1279,6 +1324,7 @@ func newEtherMan(cfg *ethconfig.Config, l2ChainName, url string) *etherman.Clien
 		PoEAddr:                   cfg.AddressRollup,
 		MaticAddr:                 cfg.L1MaticContractAddress,
 		GlobalExitRootManagerAddr: cfg.AddressGerManager,
+		Transport:                 transport,
 	}
 
 	em, err := etherman.NewClient(ethmanConf)
@@ -1289,9 +1335,21 @@ func newEtherMan(cfg *ethconfig.Config, l2ChainName, url string) *etherman.Clien
 	return em
 }
 
+// dataStreamSecurityConfig restricts the access to the data stream server and its gateway
+func dataStreamSecurityConfig(cfg *ethconfig.Zk) *server.StreamSecurityConfig {
+	return &server.StreamSecurityConfig{
+		CertFile:     cfg.DataStreamTLSCert,
+		KeyFile:      cfg.DataStreamTLSKey,
+		ClientCAFile: cfg.DataStreamTLSClientCA,
+		AllowedIPs:   cfg.DataStreamAllowedIPs,
+		AllowedCerts: cfg.DataStreamAllowedCerts,
+		InternalPort: uint16(cfg.DataStreamInternalPort),
+	}
+}
+
 // creates a datastream client with default parameters
-func initDataStreamClient(ctx context.Context, cfg *ethconfig.Zk, latestForkId uint16) *client.StreamClient {
-	return client.NewClient(ctx, cfg.L2DataStreamerUrl, cfg.L2DataStreamerUseTLS, cfg.DatastreamVersion, cfg.L2DataStreamerTimeout, latestForkId)
+func initDataStreamClient(ctx context.Context, cfg *ethconfig.Zk, latestForkId uint16) zkStages.DatastreamClient {
+	return zkStages.NewDatastreamClient(ctx, cfg, latestForkId)
 }
 
 func (s *Ethereum) Init(stack *node.Node, config *ethconfig.Config, chainConfig *chain.Config) error {
@@ -1378,7 +1436,21 @@ func (s *Ethereum) Init(stack *node.Node, config *ethconfig.Config, chainConfig
 	}
 
 	if chainConfig.Bor == nil {
-		go s.engineBackendRPC.Start(ctx, &httpRpcCfg, s.chainDB, s.blockReader, ff, stateCache, s.agg, s.engine, ethRpcClient, txPoolRpcClient, miningRpcClient)
+		// apis that change the node state are only served on the JWT authenticated endpoint
+		var authApiList []rpc.API
+		if config.Zk.ACLRpcEnabled && s.txPool2 != nil {
+			authApiList = append(authApiList, jsonrpc.AclAPIList(s.txPool2.ACLDB())...)
+		}
+		if config.Zk.Limbo && s.txPool2 != nil {
+			authApiList = append(authApiList, jsonrpc.LimboAPIList(s.txPool2, s.txPool2DB)...)
+		}
+		if s.legacyVerifier != nil && config.Zk.HasExecutors() {
+			authApiList = append(authApiList, jsonrpc.ExecutorAPIList(s.legacyVerifier, s.verificationFailures, s.chainDB)...)
+		}
+		if s.l1Quorum != nil {
+			authApiList = append(authApiList, jsonrpc.L1QuorumAPIList(s.l1Quorum)...)
+		}
+		go s.engineBackendRPC.Start(ctx, &httpRpcCfg, s.chainDB, s.blockReader, ff, stateCache, s.agg, s.engine, ethRpcClient, txPoolRpcClient, miningRpcClient, authApiList...)
 	}
 
 	go func() {
@@ -1388,6 +1460,19 @@ func (s *Ethereum) Init(stack *node.Node, config *ethconfig.Config, chainConfig
 		}
 	}()
 
+	if s.streamServer != nil && config.Zk.DataStreamGatewayAddr != "" {
+		go func() {
+			archiveDir := config.Zk.DataStreamArchiveDir
+			if archiveDir == "" {
+				archiveDir = filepath.Join(stack.Config().Dirs.DataDir, "data-stream-archive")
+			}
+			gateway := server.NewDataStreamGateway(s.streamServer, server.NewSegmentStore(archiveDir), 0)
+			if err := server.ServeDataStreamGateway(s.sentryCtx, config.Zk.DataStreamGatewayAddr, gateway, dataStreamSecurityConfig(config.Zk)); err != nil {
+				log.Error("failed to serve the data stream gateway", "err", err)
+			}
+		}()
+	}
+
 	// Register the backend on the node
 	stack.RegisterLifecycle(s)
 	return nil
@@ -1956,6 +2041,9 @@ func (s *Ethereum) Stop() error {
 	if s.txPool2DB != nil {
 		s.txPool2DB.Close()
 	}
+	if s.verificationFailures != nil {
+		s.verificationFailures.Close()
+	}
 	if s.agg != nil {
 		s.agg.Close()
 	}
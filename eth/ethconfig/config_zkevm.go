@@ -12,7 +12,13 @@ type Zk struct {
 	L2RpcUrl                               string
 	L2DataStreamerUrl                      string
 	L2DataStreamerUseTLS                   bool
+	L2DataStreamerTLSCert                  string
+	L2DataStreamerTLSKey                   string
+	L2DataStreamerTLSCA                    string
 	L2DataStreamerTimeout                  time.Duration
+	L2DataStreamerFailoverUrls             []string
+	L2DataStreamerStallTimeout             time.Duration
+	L2DataStreamerCrossCheckInterval       uint64
 	L2ShortCircuitToVerifiedBatch          bool
 	L1SyncStartBlock                       uint64
 	L1SyncStopBatch                        uint64
@@ -29,11 +35,15 @@ type Zk struct {
 	L1BlockRange                           uint64
 	L1QueryDelay                           uint64
 	L1HighestBlockType                     string
+	L1Quorum                               uint64
+	L1ProviderMaxFaults                    uint64
 	L1MaticContractAddress                 common.Address
 	L1FirstBlock                           uint64
 	L1FinalizedBlockRequirement            uint64
 	L1CacheEnabled                         bool
 	L1CachePort                            uint
+	L1CacheMaxSize                         datasize.ByteSize
+	L1CacheLatestTTL                       time.Duration
 	RpcRateLimits                          int
 	RpcGetBatchWitnessConcurrencyLimit     int
 	DatastreamVersion                      int
@@ -49,6 +59,13 @@ type Zk struct {
 	ExecutorUrls                           []string
 	ExecutorStrictMode                     bool
 	ExecutorRequestTimeout                 time.Duration
+	ExecutorWeights                        []int
+	ExecutorBreakerFailures                int
+	ExecutorBreakerCooldown                time.Duration
+	ExecutorHedgeDelay                     time.Duration
+	VerificationFailuresLimit              int
+	LocalExecutorAddr                      string
+	LocalExecutorCompare                   bool
 	DatastreamNewBlockTimeout              time.Duration
 	WitnessMemdbSize                       datasize.ByteSize
 	WitnessUnwindLimit                     uint64
@@ -66,15 +83,29 @@ type Zk struct {
 	GasPriceFactor                         float64
 	GasPriceCfg                            *GasPriceConf
 	DAUrl                                  string
+	DACommitteeUrls                        []string
+	DADataDir                              string
+	DACacheDir                             string
 	DataStreamHost                         string
 	DataStreamPort                         uint
 	DataStreamWriteTimeout                 time.Duration
 	DataStreamInactivityTimeout            time.Duration
 	DataStreamInactivityCheckInterval      time.Duration
+	DataStreamTLSCert                      string
+	DataStreamTLSKey                       string
+	DataStreamTLSClientCA                  string
+	DataStreamAllowedIPs                   []string
+	DataStreamAllowedCerts                 []string
+	DataStreamInternalPort                 uint
+	DataStreamGatewayAddr                  string
+	DataStreamArchiveDir                   string
 
 	RebuildTreeAfter      uint64
 	IncrementTreeAlways   bool
 	SmtRegenerateInMemory bool
+	SmtRetainBlocks       uint64
+	SmtGcChunkSize        uint64
+	SmtHashWorkers        int
 	WitnessFull           bool
 	SyncLimit             uint64
 	Gasless               bool
@@ -91,13 +122,20 @@ type Zk struct {
 	ExecutorPayloadOutput       string
 
 	TxPoolRejectSmartContractDeployments bool
+	TxPoolSenderTxLimit                  uint64
+	TxPoolSenderGasLimit                 uint64
+	TxPoolSenderLimitWindow              time.Duration
+	TxPoolSenderLimitACLExempt           bool
+	TxPoolOrdering                       string
 	// support for the block hash calculate
 	*Merlin
 	InitialBatchCfgFile            string
 	ACLPrintHistory                int
+	ACLRpcEnabled                  bool
 	InfoTreeUpdateInterval         time.Duration
 	BadBatches                     []uint64
 	SealBatchImmediatelyOnOverflow bool
+	SequencerCounterAwarePacking   bool
 	MockWitnessGeneration          bool
 	WitnessContractInclusion       []common.Address
 	BadTxAllowance                 uint64
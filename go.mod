@@ -11,6 +11,9 @@ require (
 
 replace github.com/ledgerwatch/erigon-lib => ./erigon-lib
 
+// the stream server bound to the loopback behind the data stream access guard, see third_party/zkevm-data-streamer
+replace github.com/0xPolygonHermez/zkevm-data-streamer => ./third_party/zkevm-data-streamer
+
 require (
 	gfx.cafe/util/go/generic v0.0.0-20230721185457-c559e86c829c
 	github.com/0xPolygonHermez/zkevm-data-streamer v0.2.8
@@ -2,12 +2,15 @@ package db
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"math/big"
 
 	"fmt"
 	"strings"
 
+	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon-lib/kv/membatch"
 	"github.com/ledgerwatch/erigon/smt/pkg/utils"
@@ -30,13 +33,27 @@ const TableStats = "HermezSmtStats"
 const TableAccountValues = "HermezSmtAccountValues"
 const TableMetadata = "HermezSmtMetadata"
 const TableHashKey = "HermezSmtHashKey"
+const TableRoots = "HermezSmtRoots"                         // block number -> root of the tree retained at the block
+const TableStaleNodes = "HermezSmtStaleNodes"               // node key -> block number the node left the tree at
+const TableStaleNodesByBlock = "HermezSmtStaleNodesByBlock" // block number + node key -> nil
+const TableGcLiveNodes = "HermezSmtGcLiveNodes"             // node or value key -> nil, marked live by the running collection
+const TableGcLiveKeys = "HermezSmtGcLiveKeys"               // full leaf key -> nil, marked live by the running collection
+const TableGcPending = "HermezSmtGcPending"                 // node key -> path of a live branch whose children are not marked yet
 
-var HermezSmtTables = []string{TableSmt, TableStats, TableAccountValues, TableMetadata, TableHashKey}
+var HermezSmtTables = []string{TableSmt, TableStats, TableAccountValues, TableMetadata, TableHashKey, TableRoots, TableStaleNodes, TableStaleNodesByBlock, TableGcLiveNodes, TableGcLiveKeys, TableGcPending}
+
+// gcTables are the tables of the marks of a garbage collection cycle
+var gcTables = []string{TableGcLiveNodes, TableGcLiveKeys, TableGcPending}
 
 type EriDb struct {
 	kvTx kv.RwTx
 	tx   SmtDbTx
 	*EriRoDb
+
+	// retainNodes keeps the nodes the tree drops until they are pruned, staleNodes are the ones dropped since the
+	// last retained root
+	retainNodes bool
+	staleNodes  map[string]struct{}
 }
 
 type EriRoDb struct {
@@ -69,6 +86,27 @@ func CreateEriDbBuckets(tx kv.RwTx) error {
 		return err
 	}
 
+	err = tx.CreateBucket(TableRoots)
+	if err != nil {
+		return err
+	}
+
+	err = tx.CreateBucket(TableStaleNodes)
+	if err != nil {
+		return err
+	}
+
+	err = tx.CreateBucket(TableStaleNodesByBlock)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range gcTables {
+		if err = tx.CreateBucket(table); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -186,6 +224,14 @@ func (m *EriDb) Insert(key utils.NodeKey, value utils.NodeValue12) error {
 	vConc := utils.ArrayToScalarBig(vals)
 	v := utils.ConvertBigIntToHex(vConc)
 
+	if m.retainNodes {
+		// a stale node written again is back in the tree
+		delete(m.staleNodes, k)
+		if err := m.tx.Delete(TableStaleNodes, []byte(k)); err != nil {
+			return err
+		}
+	}
+
 	return m.tx.Put(TableSmt, []byte(k), []byte(v))
 }
 
@@ -196,6 +242,10 @@ func (m *EriDb) Delete(key string) error {
 func (m *EriDb) DeleteByNodeKey(key utils.NodeKey) error {
 	keyConc := utils.ArrayToScalar(key[:])
 	k := utils.ConvertBigIntToHex(keyConc)
+	if m.retainNodes {
+		m.staleNodes[k] = struct{}{}
+		return nil
+	}
 	return m.tx.Delete(TableSmt, []byte(k))
 }
 
@@ -267,6 +317,10 @@ func (m *EriDb) InsertHashKey(key utils.NodeKey, value utils.NodeKey) error {
 }
 
 func (m *EriDb) DeleteHashKey(key utils.NodeKey) error {
+	if m.retainNodes {
+		// pruned with the node
+		return nil
+	}
 	keyConc := utils.ArrayToScalar(key[:])
 	return m.tx.Delete(TableHashKey, keyConc.Bytes())
 }
@@ -370,3 +424,247 @@ func (m *EriRoDb) GetDb() map[string][]string {
 
 	return transformedDb
 }
+
+// SetRetainNodes keeps the nodes the tree drops until they are pruned so the retained roots stay readable
+func (m *EriDb) SetRetainNodes(retain bool) {
+	m.retainNodes = retain
+	if retain && m.staleNodes == nil {
+		m.staleNodes = make(map[string]struct{})
+	}
+}
+
+// RetainRoot records the root of the tree at the block, the nodes dropped since the last retained root become stale
+// from the block
+func (m *EriDb) RetainRoot(blockNumber uint64, root *big.Int) error {
+	block := uint64ToBytes(blockNumber)
+	for k := range m.staleNodes {
+		if err := m.tx.Put(TableStaleNodes, []byte(k), block); err != nil {
+			return err
+		}
+		if err := m.tx.Put(TableStaleNodesByBlock, append(uint64ToBytes(blockNumber), k...), []byte{}); err != nil {
+			return err
+		}
+	}
+	m.staleNodes = make(map[string]struct{})
+
+	return m.tx.Put(TableRoots, block, []byte(utils.ConvertBigIntToHex(root)))
+}
+
+// GetRetainedRoot returns the root of the tree retained at the block, nil if the tree of the block is not retained
+func (m *EriRoDb) GetRetainedRoot(blockNumber uint64) (*big.Int, error) {
+	data, err := m.kvTxRo.GetOne(TableRoots, uint64ToBytes(blockNumber))
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	return utils.ConvertHexToBigInt(string(data)), nil
+}
+
+// TruncateRetainedRoots drops the roots retained after the block
+func (m *EriDb) TruncateRetainedRoots(blockNumber uint64) error {
+	var blocks [][]byte
+	if err := m.tx.ForEach(TableRoots, uint64ToBytes(blockNumber+1), func(k, _ []byte) error {
+		blocks = append(blocks, common.Copy(k))
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, k := range blocks {
+		if err := m.tx.Delete(TableRoots, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneRetainedRoots drops the roots retained before the block and deletes the nodes only their trees use, it returns
+// the number of nodes deleted
+func (m *EriDb) PruneRetainedRoots(blockNumber uint64) (int, error) {
+	var staleKeys [][]byte
+	if err := m.tx.ForEach(TableStaleNodesByBlock, nil, func(k, _ []byte) error {
+		// the nodes dropped after the block are part of its tree
+		if bytesToUint64(k[:8]) > blockNumber {
+			return errStopIteration
+		}
+		staleKeys = append(staleKeys, common.Copy(k))
+		return nil
+	}); err != nil && !errors.Is(err, errStopIteration) {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, k := range staleKeys {
+		nodeKey := k[8:]
+		staleFrom, err := m.tx.GetOne(TableStaleNodes, nodeKey)
+		if err != nil {
+			return pruned, err
+		}
+		// the node is back in the tree or it left it again later
+		if staleFrom != nil && bytesToUint64(staleFrom) == bytesToUint64(k[:8]) {
+			if err = m.tx.Delete(TableSmt, nodeKey); err != nil {
+				return pruned, err
+			}
+			if err = m.tx.Delete(TableHashKey, utils.ConvertHexToBigInt(string(nodeKey)).Bytes()); err != nil {
+				return pruned, err
+			}
+			if err = m.tx.Delete(TableStaleNodes, nodeKey); err != nil {
+				return pruned, err
+			}
+			pruned++
+		}
+		if err = m.tx.Delete(TableStaleNodesByBlock, k); err != nil {
+			return pruned, err
+		}
+	}
+
+	var blocks [][]byte
+	if err := m.tx.ForEach(TableRoots, nil, func(k, _ []byte) error {
+		if bytesToUint64(k) >= blockNumber {
+			return errStopIteration
+		}
+		blocks = append(blocks, common.Copy(k))
+		return nil
+	}); err != nil && !errors.Is(err, errStopIteration) {
+		return pruned, err
+	}
+	for _, k := range blocks {
+		if err := m.tx.Delete(TableRoots, k); err != nil {
+			return pruned, err
+		}
+	}
+
+	return pruned, nil
+}
+
+// GetRetainedRoots returns the roots of the retained trees by block number
+func (m *EriRoDb) GetRetainedRoots() (map[uint64]*big.Int, error) {
+	roots := make(map[uint64]*big.Int)
+	if err := m.kvTxRo.ForEach(TableRoots, nil, func(k, v []byte) error {
+		roots[bytesToUint64(k)] = utils.ConvertHexToBigInt(string(v))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return roots, nil
+}
+
+// SweepTable deletes the entries of the table from the key on that isGarbage reports, scanning at most limit entries
+// when limit is above 0. It returns the key to carry on from, nil once the end of the table is reached, with the
+// number of entries scanned and deleted and the bytes of the deleted keys and values.
+func (m *EriDb) SweepTable(table string, from []byte, limit int, isGarbage func(k []byte) (bool, error)) (next []byte, scanned, deleted int, reclaimed uint64, err error) {
+	var garbage [][]byte
+	if err = m.tx.ForEach(table, from, func(k, v []byte) error {
+		if limit > 0 && scanned == limit {
+			next = common.Copy(k)
+			return errStopIteration
+		}
+		scanned++
+		isGarbage, err := isGarbage(k)
+		if err != nil {
+			return err
+		}
+		if isGarbage {
+			garbage = append(garbage, common.Copy(k))
+			reclaimed += uint64(len(k) + len(v))
+		}
+		return nil
+	}); err != nil && !errors.Is(err, errStopIteration) {
+		return nil, 0, 0, 0, err
+	}
+
+	for _, k := range garbage {
+		if err = m.tx.Delete(table, k); err != nil {
+			return nil, 0, 0, 0, err
+		}
+	}
+
+	return next, scanned, len(garbage), reclaimed, nil
+}
+
+// MarkGcLive marks the key live in the table of the marks, it returns false if the key was marked already
+func (m *EriDb) MarkGcLive(table string, key []byte) (bool, error) {
+	marked, err := m.tx.Has(table, key)
+	if err != nil || marked {
+		return false, err
+	}
+	return true, m.tx.Put(table, key, []byte{})
+}
+
+// IsGcLive checks whether the key is marked live in the table of the marks
+func (m *EriRoDb) IsGcLive(table string, key []byte) (bool, error) {
+	return m.kvTxRo.Has(table, key)
+}
+
+// CountGcLive returns the number of keys marked live in the table of the marks
+func (m *EriDb) CountGcLive(table string) (uint64, error) {
+	c, err := m.kvTx.Cursor(table)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	return c.Count()
+}
+
+// PushGcPending records a live branch whose children are still to be marked, with its path from the root
+func (m *EriDb) PushGcPending(key, path []byte) error {
+	return m.tx.Put(TableGcPending, key, path)
+}
+
+// HasGcPending checks whether a live branch is left whose children are still to be marked
+func (m *EriRoDb) HasGcPending() (bool, error) {
+	var pending bool
+	if err := m.kvTxRo.ForEach(TableGcPending, nil, func(_, _ []byte) error {
+		pending = true
+		return errStopIteration
+	}); err != nil && !errors.Is(err, errStopIteration) {
+		return false, err
+	}
+	return pending, nil
+}
+
+// PopGcPending removes and returns at most limit of the branches whose children are still to be marked, no branch
+// once they all are
+func (m *EriDb) PopGcPending(limit int) (keys, paths [][]byte, err error) {
+	if err = m.tx.ForEach(TableGcPending, nil, func(k, v []byte) error {
+		if len(keys) == limit {
+			return errStopIteration
+		}
+		keys = append(keys, common.Copy(k))
+		paths = append(paths, common.Copy(v))
+		return nil
+	}); err != nil && !errors.Is(err, errStopIteration) {
+		return nil, nil, err
+	}
+
+	for _, k := range keys {
+		if err = m.tx.Delete(TableGcPending, k); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return keys, paths, nil
+}
+
+// ClearGcMarks drops the marks of a garbage collection cycle
+func (m *EriDb) ClearGcMarks() error {
+	for _, table := range gcTables {
+		if err := m.kvTx.ClearBucket(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errStopIteration = errors.New("stop iteration")
+
+func uint64ToBytes(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return b
+}
+
+func bytesToUint64(b []byte) uint64 {
+	return binary.BigEndian.Uint64(b)
+}
@@ -0,0 +1,583 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/etl"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// SnapshotTables are the tables a state tree snapshot holds, the retained roots and stale nodes only matter to the
+// node that wrote them and are dropped by an import
+var SnapshotTables = []string{TableSmt, TableAccountValues, TableMetadata, TableHashKey, TableStats}
+
+const (
+	SnapshotVersion      = 1
+	SnapshotManifestFile = "manifest.json"
+)
+
+// the entries written to a snapshot file before the next one of the table is started
+var snapshotFileEntries uint64 = 1 << 21
+
+var ErrSnapshotExists = errors.New("snapshot already exists")
+
+// SnapshotFile is a file of a snapshot, the entries of a table are split over files in key order
+type SnapshotFile struct {
+	Name    string `json:"name"`
+	Table   string `json:"table"`
+	Entries uint64 `json:"entries"`
+	Size    uint64 `json:"size"`
+	Sha256  string `json:"sha256"`
+}
+
+// SnapshotManifest describes a snapshot of the state tree at a block, it is written once all the files are
+type SnapshotManifest struct {
+	Version     int            `json:"version"`
+	BlockNumber uint64         `json:"blockNumber"`
+	Root        common.Hash    `json:"root"`
+	Depth       uint8          `json:"depth"`
+	Files       []SnapshotFile `json:"files"`
+}
+
+// ExportSnapshot writes the state tree of the tx, which is at the block, to the directory. Only the nodes reachable
+// from the root are written, with the hash keys, account values and key sources of their leaves, so the stale nodes
+// retained for the older roots are left out. Each file holds the entries of a table as length prefixed keys and values
+// in key order, sorted through the tmpdir, and is checksummed in the manifest.
+func ExportSnapshot(ctx context.Context, tx kv.Tx, dir, tmpdir string, blockNumber uint64) (*SnapshotManifest, error) {
+	manifestPath := filepath.Join(dir, SnapshotManifestFile)
+	if _, err := os.Stat(manifestPath); err == nil {
+		return nil, fmt.Errorf("%w in %s", ErrSnapshotExists, dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	eridb := NewRoEriDb(tx)
+	root, err := eridb.GetLastRoot()
+	if err != nil {
+		return nil, err
+	}
+	depth, err := eridb.GetDepth()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &SnapshotManifest{
+		Version:     SnapshotVersion,
+		BlockNumber: blockNumber,
+		Root:        common.BigToHash(root),
+		Depth:       depth,
+		Files:       make([]SnapshotFile, 0),
+	}
+
+	collectors := make(map[string]*etl.Collector, len(SnapshotTables))
+	for _, table := range SnapshotTables {
+		if table != TableStats {
+			collectors[table] = etl.NewCollector("smt snapshot", tmpdir, etl.NewSortableBuffer(etl.BufferOptimalSize/4), log.New())
+			defer collectors[table].Close()
+		}
+	}
+
+	err = walkSnapshotTree(ctx, tx, root, false, func(k utils.NodeKey) error {
+		key := snapshotNodeKey(k)
+		v, err := tx.GetOne(TableSmt, key)
+		if err != nil {
+			return err
+		}
+		return collectors[TableSmt].Collect(key, v)
+	}, func(leaf snapshotLeaf) error {
+		source, err := tx.GetOne(TableMetadata, snapshotScalarKey(leaf.key))
+		if err != nil {
+			return err
+		}
+		if source == nil {
+			return fmt.Errorf("leaf %x has no key source", leaf.hash)
+		}
+		if err = collectors[TableMetadata].Collect(snapshotScalarKey(leaf.key), source); err != nil {
+			return err
+		}
+		if err = collectors[TableHashKey].Collect(snapshotScalarKey(leaf.hash), snapshotScalarKey(leaf.key)); err != nil {
+			return err
+		}
+		return collectors[TableAccountValues].Collect(snapshotNodeKey(leaf.key), snapshotAccountValue(leaf.value))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("export %s: %w", TableSmt, err)
+	}
+
+	for _, table := range SnapshotTables {
+		w := &snapshotWriter{dir: dir, table: table}
+		if collector, ok := collectors[table]; ok {
+			// a node shared by several paths, e.g. the value of equal balances, is collected once per path so the copies are skipped
+			var last []byte
+			err = collector.Load(nil, "", func(k, v []byte, _ etl.CurrentTableReader, _ etl.LoadNextFunc) error {
+				if last != nil && bytes.Equal(last, k) {
+					return nil
+				}
+				last = common.Copy(k)
+				return w.write(k, v)
+			}, etl.TransformArgs{Quit: ctx.Done()})
+		} else {
+			err = tx.ForEach(table, nil, w.write)
+		}
+		if err == nil {
+			err = w.closeFile()
+		}
+		if err != nil {
+			w.abort()
+			return nil, fmt.Errorf("export %s: %w", table, err)
+		}
+		manifest.Files = append(manifest.Files, w.files...)
+	}
+
+	if err = writeSnapshotManifest(dir, manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// writeSnapshotManifest renames the manifest into place so a snapshot with a manifest is always complete
+func writeSnapshotManifest(dir string, manifest *SnapshotManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifestPath := filepath.Join(dir, SnapshotManifestFile)
+	if err = os.WriteFile(manifestPath+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(manifestPath+".tmp", manifestPath)
+}
+
+// ReadSnapshotManifest reads the manifest of the snapshot in the directory
+func ReadSnapshotManifest(dir string) (*SnapshotManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, SnapshotManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &SnapshotManifest{}
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("decode snapshot manifest: %w", err)
+	}
+	if manifest.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d, expected %d", manifest.Version, SnapshotVersion)
+	}
+
+	return manifest, nil
+}
+
+// ImportSnapshot replaces the state tree of the tx with the snapshot in the directory. The files are checked against
+// their checksums while they are loaded, but the checksums come with the snapshot, so the loaded tree is then walked
+// from the root of the manifest and the hash of every node recomputed. The hash keys, account values and key sources
+// must be those of the leaves reached, and nothing else. On error the tx must be rolled back as the tables are left
+// partially loaded.
+func ImportSnapshot(ctx context.Context, tx kv.RwTx, dir string) (*SnapshotManifest, error) {
+	manifest, err := ReadSnapshotManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range HermezSmtTables {
+		if err = tx.ClearBucket(table); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range manifest.Files {
+		if err = importSnapshotFile(ctx, tx, dir, f); err != nil {
+			return nil, fmt.Errorf("import %s: %w", f.Name, err)
+		}
+	}
+
+	root, err := NewRoEriDb(tx).GetLastRoot()
+	if err != nil {
+		return nil, err
+	}
+	if common.BigToHash(root) != manifest.Root {
+		return nil, fmt.Errorf("imported root %x does not match the snapshot root %x", common.BigToHash(root), manifest.Root)
+	}
+	if err = verifySnapshotTree(ctx, tx, root); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// snapshotLeaf is a leaf reached by the walk of a state tree, the full key is made of its path and remaining key
+type snapshotLeaf struct {
+	hash  utils.NodeKey
+	key   utils.NodeKey
+	value utils.NodeValue8
+}
+
+// verifySnapshotTree recomputes the hash of every node reachable from the root, with the capacity of a branch or a
+// leaf, along with the hash of the value of every leaf. A node that is missing or does not hash to its key fails it.
+// The hash key, account value and key source of every leaf must match the leaf, and the tables must hold no others.
+func verifySnapshotTree(ctx context.Context, tx kv.Tx, root *big.Int) error {
+	var leaves uint64
+	err := walkSnapshotTree(ctx, tx, root, true, nil, func(leaf snapshotLeaf) error {
+		leaves++
+
+		key, err := tx.GetOne(TableHashKey, snapshotScalarKey(leaf.hash))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(key, snapshotScalarKey(leaf.key)) {
+			return fmt.Errorf("hash key of leaf %x is %x, expected %x", leaf.hash, key, snapshotScalarKey(leaf.key))
+		}
+
+		value, err := tx.GetOne(TableAccountValues, snapshotNodeKey(leaf.key))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(value, snapshotAccountValue(leaf.value)) {
+			return fmt.Errorf("account value of leaf %x does not match its value", leaf.hash)
+		}
+
+		source, err := tx.GetOne(TableMetadata, snapshotScalarKey(leaf.key))
+		if err != nil {
+			return err
+		}
+		sourceKey, err := keySourceNodeKey(source)
+		if err != nil {
+			return fmt.Errorf("key source of leaf %x: %w", leaf.hash, err)
+		}
+		if sourceKey != leaf.key {
+			return fmt.Errorf("key source of leaf %x is the one of key %x", leaf.hash, sourceKey)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{TableHashKey, TableAccountValues, TableMetadata} {
+		c, err := tx.Cursor(table)
+		if err != nil {
+			return err
+		}
+		count, err := c.Count()
+		c.Close()
+		if err != nil {
+			return err
+		}
+		if count != leaves {
+			return fmt.Errorf("%s holds %d entries for %d leaves", table, count, leaves)
+		}
+	}
+
+	return nil
+}
+
+// walkSnapshotTree visits every node reachable from the root, the values of the leaves included, and every leaf. With
+// verify the hash of every node is recomputed, see verifySnapshotNode.
+func walkSnapshotTree(ctx context.Context, tx kv.Tx, root *big.Int, verify bool, visitNode func(k utils.NodeKey) error, visitLeaf func(leaf snapshotLeaf) error) error {
+	eridb := NewRoEriDb(tx)
+	get := func(k utils.NodeKey, capacity *[4]uint64) (utils.NodeValue12, error) {
+		if verify {
+			return verifySnapshotNode(eridb, k, capacity)
+		}
+		v, err := eridb.Get(k)
+		if err == nil && v[0] == nil {
+			err = fmt.Errorf("node %x is missing", k)
+		}
+		return v, err
+	}
+	visit := func(k utils.NodeKey) error {
+		if visitNode == nil {
+			return nil
+		}
+		return visitNode(k)
+	}
+
+	type item struct {
+		k    utils.NodeKey
+		path []int
+	}
+
+	var visited uint64
+	stack := []item{{k: utils.ScalarToRoot(root)}}
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if it.k.IsZero() {
+			continue
+		}
+
+		if visited++; visited%1024 == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+		}
+
+		v, err := get(it.k, nil)
+		if err != nil {
+			return err
+		}
+		if err = visit(it.k); err != nil {
+			return err
+		}
+		if !v.IsFinalNode() {
+			for bit, child := range []*utils.NodeKey{v.Get0to4(), v.Get4to8()} {
+				path := make([]int, len(it.path), len(it.path)+1)
+				copy(path, it.path)
+				stack = append(stack, item{k: *child, path: append(path, bit)})
+			}
+			continue
+		}
+
+		value, err := get(*v.Get4to8(), &utils.BranchCapacity)
+		if err != nil {
+			return fmt.Errorf("value of leaf %x: %w", it.k, err)
+		}
+		if err = visit(*v.Get4to8()); err != nil {
+			return err
+		}
+		if visitLeaf != nil {
+			leaf := snapshotLeaf{hash: it.k, key: *utils.JoinKey(it.path, *v.Get0to4()), value: *value.GetNodeValue8()}
+			if err = visitLeaf(leaf); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifySnapshotNode reads the node and checks that it hashes to its key, with the given capacity or the one of a
+// branch or a leaf as the node says when nil
+func verifySnapshotNode(eridb *EriRoDb, k utils.NodeKey, capacity *[4]uint64) (utils.NodeValue12, error) {
+	v, err := eridb.Get(k)
+	if err != nil {
+		return v, err
+	}
+	if v[0] == nil {
+		return v, fmt.Errorf("node %x is missing", k)
+	}
+
+	if capacity == nil {
+		capacity = &utils.BranchCapacity
+		if v.IsFinalNode() {
+			capacity = &utils.LeafCapacity
+		}
+	}
+	for i, c := range capacity {
+		if v[8+i].Cmp(new(big.Int).SetUint64(c)) != 0 {
+			return v, fmt.Errorf("node %x has capacity %v, expected %v", k, v[8:], *capacity)
+		}
+	}
+	if h := utils.NodeKey(utils.Hash(v.Get0to8(), *capacity)); h != k {
+		return v, fmt.Errorf("node %x hashes to %x", k, h)
+	}
+
+	return v, nil
+}
+
+// keySourceNodeKey returns the key of the state tree the key source is the one of
+func keySourceNodeKey(source []byte) (utils.NodeKey, error) {
+	t, addr, storage, err := utils.DecodeKeySource(source)
+	if err != nil {
+		return utils.NodeKey{}, err
+	}
+
+	switch t {
+	case utils.KEY_BALANCE, utils.KEY_NONCE, utils.SC_CODE, utils.SC_LENGTH:
+		return utils.Key(addr.String(), t), nil
+	case utils.SC_STORAGE:
+		return utils.KeyContractStorage(utils.ScalarToArrayBig(utils.ConvertHexToBigInt(addr.String())), storage.String()), nil
+	default:
+		return utils.NodeKey{}, fmt.Errorf("unknown key type %d", t)
+	}
+}
+
+// snapshotNodeKey is the key of the node in TableSmt, also the one of the account values
+func snapshotNodeKey(k utils.NodeKey) []byte {
+	return []byte(utils.ConvertBigIntToHex(utils.ArrayToScalar(k[:])))
+}
+
+// snapshotScalarKey is the key as stored in TableHashKey and TableMetadata
+func snapshotScalarKey(k utils.NodeKey) []byte {
+	return utils.ArrayToScalar(k[:]).Bytes()
+}
+
+// snapshotAccountValue is the value as stored in TableAccountValues
+func snapshotAccountValue(v utils.NodeValue8) []byte {
+	return []byte(utils.ConvertBigIntToHex(utils.ArrayToScalarBig(v[:])))
+}
+
+func importSnapshotFile(ctx context.Context, tx kv.RwTx, dir string, f SnapshotFile) error {
+	if !isSnapshotTable(f.Table) {
+		return fmt.Errorf("unexpected table %s", f.Table)
+	}
+
+	file, err := os.Open(filepath.Join(dir, filepath.Base(f.Name)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	r := bufio.NewReader(io.TeeReader(file, h))
+
+	var entries uint64
+	for {
+		k, err := readSnapshotBytes(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		v, err := readSnapshotBytes(r)
+		if err != nil {
+			return fmt.Errorf("truncated entry: %w", err)
+		}
+
+		// the entries of a table are exported in key order
+		if err = tx.Append(f.Table, k, v); err != nil {
+			return err
+		}
+
+		if entries++; entries%1024 == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+		}
+	}
+
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != f.Sha256 {
+		return fmt.Errorf("checksum mismatch, expected %s, got %s", f.Sha256, sum)
+	}
+	if entries != f.Entries {
+		return fmt.Errorf("entry count mismatch, expected %d, got %d", f.Entries, entries)
+	}
+
+	return nil
+}
+
+func isSnapshotTable(table string) bool {
+	for _, t := range SnapshotTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+func readSnapshotBytes(r *bufio.Reader) ([]byte, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, l)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// snapshotWriter writes the entries of a table to files of at most snapshotFileEntries entries
+type snapshotWriter struct {
+	dir     string
+	table   string
+	file    *os.File
+	buf     *bufio.Writer
+	hash    hash.Hash
+	current SnapshotFile
+	files   []SnapshotFile
+}
+
+func (w *snapshotWriter) write(k, v []byte) error {
+	if w.file != nil && w.current.Entries >= snapshotFileEntries {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+	if w.file == nil {
+		if err := w.openFile(); err != nil {
+			return err
+		}
+	}
+
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	for _, b := range [][]byte{k, v} {
+		n := binary.PutUvarint(lenBuf, uint64(len(b)))
+		if _, err := w.buf.Write(lenBuf[:n]); err != nil {
+			return err
+		}
+		if _, err := w.buf.Write(b); err != nil {
+			return err
+		}
+		w.current.Size += uint64(n + len(b))
+	}
+	w.current.Entries++
+
+	return nil
+}
+
+func (w *snapshotWriter) openFile() error {
+	name := fmt.Sprintf("%s-%05d.kv", w.table, len(w.files))
+	file, err := os.Create(filepath.Join(w.dir, name))
+	if err != nil {
+		return err
+	}
+
+	w.file = file
+	w.hash = sha256.New()
+	w.buf = bufio.NewWriter(io.MultiWriter(file, w.hash))
+	w.current = SnapshotFile{Name: name, Table: w.table}
+
+	return nil
+}
+
+func (w *snapshotWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	w.current.Sha256 = hex.EncodeToString(w.hash.Sum(nil))
+	w.files = append(w.files, w.current)
+	w.file = nil
+
+	return nil
+}
+
+// abort closes the file being written, the files of a failed export are left behind without a manifest
+func (w *snapshotWriter) abort() {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+}
@@ -0,0 +1,253 @@
+package db
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotExportImport(t *testing.T) {
+	ctx := context.Background()
+	defer func(entries uint64) { snapshotFileEntries = entries }(snapshotFileEntries)
+	snapshotFileEntries = 3
+
+	newTx := func() kv.RwTx {
+		dbi, err := mdbx.NewTemporaryMdbx(ctx, t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(dbi.Close)
+		tx, err := dbi.BeginRw(ctx)
+		require.NoError(t, err)
+		t.Cleanup(tx.Rollback)
+		require.NoError(t, CreateEriDbBuckets(tx))
+		return tx
+	}
+
+	srcTx := newTx()
+	src := NewEriDb(srcTx)
+	root, keys := insertSnapshotTestTree(t, src)
+	require.NoError(t, src.RetainRoot(7, root))
+	want := make(map[string]map[string]string)
+	for _, table := range SnapshotTables {
+		want[table] = tableEntries(t, srcTx, table)
+	}
+
+	// a stale node retained for an older root, with its hash key and key source, and a stale account value
+	stale := utils.NodeKey{1, 2, 3, 4}
+	require.NoError(t, src.Insert(stale, snapshotTestValue12(snapshotTestNodeValue8(5), utils.LeafCapacity)))
+	require.NoError(t, src.InsertHashKey(stale, utils.NodeKey{5}))
+	require.NoError(t, src.InsertKeySource(utils.NodeKey{5}, utils.EncodeKeySource(utils.KEY_NONCE, common.Address{5}, common.Hash{})))
+	require.NoError(t, src.InsertAccountValue(keys[0], *snapshotTestNodeValue8(999)))
+
+	dir := filepath.Join(t.TempDir(), "snapshot")
+	manifest, err := ExportSnapshot(ctx, srcTx, dir, t.TempDir(), 7)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), manifest.BlockNumber)
+	require.Equal(t, common.BigToHash(root), manifest.Root)
+	// the eleven reachable nodes and the four entries in each of the other three tables split by three, and the stats
+	require.Len(t, manifest.Files, 4+3*2+1)
+
+	_, err = ExportSnapshot(ctx, srcTx, dir, t.TempDir(), 7)
+	require.ErrorIs(t, err, ErrSnapshotExists)
+
+	dstTx := newTx()
+	dst := NewEriDb(dstTx)
+	require.NoError(t, dst.InsertKeySource(utils.NodeKey{99}, []byte{99}))
+	require.NoError(t, dst.RetainRoot(3, big.NewInt(1)))
+
+	imported, err := ImportSnapshot(ctx, dstTx, dir)
+	require.NoError(t, err)
+	require.Equal(t, manifest, imported)
+	// the stale entries are left out and the account values are those of the tree
+	for _, table := range SnapshotTables {
+		require.Equal(t, want[table], tableEntries(t, dstTx, table), table)
+	}
+	lastRoot, err := dst.GetLastRoot()
+	require.NoError(t, err)
+	require.Equal(t, root, lastRoot)
+	// the retained roots of either node are not carried over
+	retained, err := dst.GetRetainedRoots()
+	require.NoError(t, err)
+	require.Empty(t, retained)
+
+	// a corrupted file fails the import
+	path := filepath.Join(dir, manifest.Files[1].Name)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	_, err = ImportSnapshot(ctx, newTx(), dir)
+	require.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestSnapshotImportForgedTree(t *testing.T) {
+	ctx := context.Background()
+
+	newTx := func(t *testing.T) kv.RwTx {
+		dbi, err := mdbx.NewTemporaryMdbx(ctx, t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(dbi.Close)
+		tx, err := dbi.BeginRw(ctx)
+		require.NoError(t, err)
+		t.Cleanup(tx.Rollback)
+		require.NoError(t, CreateEriDbBuckets(tx))
+		return tx
+	}
+
+	// the forged snapshots hold the tables as is, so their checksums and root match the forged tables
+	for name, tc := range map[string]struct {
+		forge func(src *EriDb, root utils.NodeKey, keys [4]utils.NodeKey) error
+		err   string
+	}{
+		"value": {err: "hashes to", forge: func(src *EriDb, root utils.NodeKey, _ [4]utils.NodeKey) error {
+			leaf, err := src.Get(snapshotTestLeaf(t, src, root))
+			if err != nil {
+				return err
+			}
+			value := leaf.GetNodeValue8()
+			value[0] = big.NewInt(999)
+			return src.Insert(*leaf.Get4to8(), snapshotTestValue12(value, utils.BranchCapacity))
+		}},
+		"missing": {err: "is missing", forge: func(src *EriDb, root utils.NodeKey, _ [4]utils.NodeKey) error {
+			return src.DeleteByNodeKey(snapshotTestLeaf(t, src, root))
+		}},
+		"capacity": {err: "hashes to", forge: func(src *EriDb, root utils.NodeKey, _ [4]utils.NodeKey) error {
+			branch, err := src.Get(root)
+			if err != nil {
+				return err
+			}
+			// the children of a branch passed off as the key and value hash of a leaf
+			return src.Insert(root, snapshotTestValue12(branch.GetNodeValue8(), utils.LeafCapacity))
+		}},
+		"hash key": {err: "hash key of leaf", forge: func(src *EriDb, root utils.NodeKey, keys [4]utils.NodeKey) error {
+			return src.InsertHashKey(snapshotTestLeaf(t, src, root), keys[1])
+		}},
+		"account value": {err: "account value of leaf", forge: func(src *EriDb, _ utils.NodeKey, keys [4]utils.NodeKey) error {
+			return src.InsertAccountValue(keys[0], *snapshotTestNodeValue8(999))
+		}},
+		"key source": {err: "key source of leaf", forge: func(src *EriDb, _ utils.NodeKey, keys [4]utils.NodeKey) error {
+			return src.InsertKeySource(keys[0], utils.EncodeKeySource(utils.KEY_NONCE, snapshotTestAddresses()[0], common.Hash{}))
+		}},
+		"extra key source": {err: "holds 5 entries for 4 leaves", forge: func(src *EriDb, _ utils.NodeKey, _ [4]utils.NodeKey) error {
+			addr := common.Address{5}
+			return src.InsertKeySource(utils.Key(addr.String(), utils.KEY_NONCE), utils.EncodeKeySource(utils.KEY_NONCE, addr, common.Hash{}))
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srcTx := newTx(t)
+			src := NewEriDb(srcTx)
+			root, keys := insertSnapshotTestTree(t, src)
+			require.NoError(t, tc.forge(src, utils.ScalarToRoot(root), keys))
+
+			dir := filepath.Join(t.TempDir(), "snapshot")
+			exportSnapshotTables(t, srcTx, dir)
+
+			_, err := ImportSnapshot(ctx, newTx(t), dir)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+// exportSnapshotTables writes the snapshot tables as is, the way a forged snapshot would be
+func exportSnapshotTables(t *testing.T, tx kv.Tx, dir string) {
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	root, err := NewRoEriDb(tx).GetLastRoot()
+	require.NoError(t, err)
+
+	manifest := &SnapshotManifest{Version: SnapshotVersion, BlockNumber: 7, Root: common.BigToHash(root)}
+	for _, table := range SnapshotTables {
+		w := &snapshotWriter{dir: dir, table: table}
+		require.NoError(t, tx.ForEach(table, nil, w.write))
+		require.NoError(t, w.closeFile())
+		manifest.Files = append(manifest.Files, w.files...)
+	}
+	require.NoError(t, writeSnapshotManifest(dir, manifest))
+}
+
+// snapshotTestAddresses returns four addresses whose balance keys start with the paths 00, 01, 10 and 11
+func snapshotTestAddresses() [4]common.Address {
+	var addrs [4]common.Address
+	var found int
+	for i := uint64(1); found < len(addrs); i++ {
+		addr := common.BytesToAddress(new(big.Int).SetUint64(i).Bytes())
+		key := utils.KeyEthAddrBalance(addr.String())
+		path := key[0]&1<<1 | key[1]&1
+		if addrs[path] == (common.Address{}) {
+			addrs[path] = addr
+			found++
+		}
+	}
+	return addrs
+}
+
+// insertSnapshotTestTree inserts a tree of the balances of four accounts, with their hash keys, account values and
+// key sources, under two branches and returns its root and the keys of the leaves
+func insertSnapshotTestTree(t *testing.T, eridb *EriDb) (*big.Int, [4]utils.NodeKey) {
+	insert := func(in [8]uint64, capacity [4]uint64) [4]uint64 {
+		h, v := utils.HashKeyAndValueByPointers(&in, &capacity)
+		require.NoError(t, eridb.Insert(*h, *v))
+		return *h
+	}
+
+	var keys, leaves [4]utils.NodeKey
+	for i, addr := range snapshotTestAddresses() {
+		keys[i] = utils.KeyEthAddrBalance(addr.String())
+		value := snapshotTestNodeValue8(uint64(i + 1))
+		valueHash := insert(value.ToUintArray(), utils.BranchCapacity)
+		leaves[i] = insert(utils.ConcatArrays4(utils.RemoveKeyBits(keys[i], 2), valueHash), utils.LeafCapacity)
+
+		require.NoError(t, eridb.InsertHashKey(leaves[i], keys[i]))
+		require.NoError(t, eridb.InsertAccountValue(keys[i], *value))
+		require.NoError(t, eridb.InsertKeySource(keys[i], utils.EncodeKeySource(utils.KEY_BALANCE, addr, common.Hash{})))
+	}
+	left := insert(utils.ConcatArrays4(leaves[0], leaves[1]), utils.BranchCapacity)
+	right := insert(utils.ConcatArrays4(leaves[2], leaves[3]), utils.BranchCapacity)
+	root := insert(utils.ConcatArrays4(left, right), utils.BranchCapacity)
+
+	rootScalar := utils.ArrayToScalar(root[:])
+	require.NoError(t, eridb.SetLastRoot(rootScalar))
+	return rootScalar, keys
+}
+
+// snapshotTestLeaf returns the leftmost leaf of the test tree
+func snapshotTestLeaf(t *testing.T, eridb *EriDb, root utils.NodeKey) utils.NodeKey {
+	k := root
+	for {
+		v, err := eridb.Get(k)
+		require.NoError(t, err)
+		if v.IsFinalNode() {
+			return k
+		}
+		k = *v.Get0to4()
+	}
+}
+
+func snapshotTestNodeValue8(v uint64) *utils.NodeValue8 {
+	value := utils.NodeValue8{}
+	for i := range value {
+		value[i] = new(big.Int)
+	}
+	value[0].SetUint64(v)
+	return &value
+}
+
+func snapshotTestValue12(value *utils.NodeValue8, capacity [4]uint64) utils.NodeValue12 {
+	in := value.ToUintArray()
+	return *utils.ConcatArrays8AndCapacityByPointers(&in, &capacity)
+}
+
+func tableEntries(t *testing.T, tx kv.Tx, table string) map[string]string {
+	entries := make(map[string]string)
+	require.NoError(t, tx.ForEach(table, nil, func(k, v []byte) error {
+		entries[string(k)] = string(v)
+		return nil
+	}))
+	return entries
+}
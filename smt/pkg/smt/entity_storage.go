@@ -347,6 +347,7 @@ func (s *SMT) SetStorage(ctx context.Context, logPrefix string, accChanges map[l
 	}
 
 	insertBatchCfg := NewInsertBatchConfig(ctx, logPrefix, true)
+	insertBatchCfg.SetHashWorkers(s.hashWorkers)
 	if _, err = s.InsertBatch(insertBatchCfg, keysBatchStorage, valuesBatchStorage, nil, nil); err != nil {
 		return nil, nil, err
 	}
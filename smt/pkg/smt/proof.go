@@ -192,3 +192,144 @@ func VerifyAndGetVal(stateRoot utils.NodeKey, proof []hexutility.Bytes, key util
 
 	return proof[len(proof)-1], nil
 }
+
+// SMTMultiProof proves the values of many keys against one root. Nodes holds every node on the paths to the keys once,
+// encoded as the elements of a single key proof, and Values holds the values of the leaves among them. Both are
+// addressed by their hash, so their order does not matter to the verifier.
+type SMTMultiProof struct {
+	Nodes  []hexutility.Bytes `json:"nodes"`
+	Values []hexutility.Bytes `json:"values"`
+}
+
+// BuildMultiProof builds a multiproof for the keys from the root of the tree, the nodes shared by the paths of several
+// keys are in the proof once.
+func BuildMultiProof(s *RoSMT, keys []utils.NodeKey, ctx context.Context) (*SMTMultiProof, error) {
+	proof := &SMTMultiProof{
+		Nodes:  make([]hexutility.Bytes, 0),
+		Values: make([]hexutility.Bytes, 0),
+	}
+
+	root, err := s.getLastRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[utils.NodeKey]utils.NodeValue12)
+	seenValues := make(map[utils.NodeKey]struct{})
+	for _, key := range keys {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		path := key.GetPath()
+		for node, level := root, 0; !node.IsZero(); level++ {
+			v, ok := seen[node]
+			if !ok {
+				if v, err = s.DbRo.Get(node); err != nil {
+					return nil, err
+				}
+				if v.IsZero() {
+					return nil, fmt.Errorf("node %x not found", node.ToBigInt())
+				}
+				seen[node] = v
+
+				nodeBytes := make([]byte, 64)
+				utils.ArrayToScalar(v.Get0to4()[:]).FillBytes(nodeBytes[:32])
+				utils.ArrayToScalar(v.Get4to8()[:]).FillBytes(nodeBytes[32:])
+				if v.IsFinalNode() {
+					nodeBytes = append(nodeBytes, 1)
+
+					if _, ok := seenValues[*v.Get4to8()]; !ok {
+						value, err := s.DbRo.Get(*v.Get4to8())
+						if err != nil {
+							return nil, err
+						}
+						seenValues[*v.Get4to8()] = struct{}{}
+						proof.Values = append(proof.Values, utils.ArrayBigToScalar(utils.BigIntArrayFromNodeValue8(value.GetNodeValue8())).Bytes())
+					}
+				}
+				proof.Nodes = append(proof.Nodes, nodeBytes)
+			}
+
+			if v.IsFinalNode() {
+				break
+			}
+			if path[level] == 0 {
+				node = *v.Get0to4()
+			} else {
+				node = *v.Get4to8()
+			}
+		}
+	}
+
+	return proof, nil
+}
+
+// VerifyMultiProof verifies a multiproof against a given state root and returns the values of the keys in their order,
+// nil for a key the proof shows not to exist. It fails when a node or value of the proof does not hash to what its
+// parent refers to, or when the proof lacks a node on the path to a key.
+func VerifyMultiProof(stateRoot utils.NodeKey, proof *SMTMultiProof, keys []utils.NodeKey) ([][]byte, error) {
+	type proofNode struct {
+		left, right utils.NodeKey
+		final       bool
+	}
+
+	nodes := make(map[utils.NodeKey]proofNode, len(proof.Nodes))
+	for i, nodeBytes := range proof.Nodes {
+		if len(nodeBytes) != 64 && len(nodeBytes) != 65 {
+			return nil, fmt.Errorf("node %d has invalid length %d", i, len(nodeBytes))
+		}
+		final := len(nodeBytes) == 65
+
+		capacity := utils.BranchCapacity
+		if final {
+			capacity = utils.LeafCapacity
+		}
+
+		left := utils.ScalarToRoot(new(big.Int).SetBytes(nodeBytes[:32]))
+		right := utils.ScalarToRoot(new(big.Int).SetBytes(nodeBytes[32:64]))
+		nodes[utils.Hash(utils.ConcatArrays4(left, right), capacity)] = proofNode{left: left, right: right, final: final}
+	}
+
+	values := make(map[utils.NodeKey][]byte, len(proof.Values))
+	for _, valueBytes := range proof.Values {
+		nodeValue, err := utils.NodeValue8FromBigIntArray(utils.ScalarToArrayBig(new(big.Int).SetBytes(valueBytes)))
+		if err != nil {
+			return nil, err
+		}
+		values[utils.Hash(nodeValue.ToUintArray(), utils.BranchCapacity)] = valueBytes
+	}
+
+	result := make([][]byte, len(keys))
+	for k, key := range keys {
+		path := key.GetPath()
+		for node, level := stateRoot, 0; !node.IsZero(); level++ {
+			n, ok := nodes[node]
+			if !ok {
+				return nil, fmt.Errorf("proof is insufficient for key %x, node missing at level %d", key.ToBigInt(), level)
+			}
+
+			if n.final {
+				// a leaf of another key on the path proves the key does not exist
+				if utils.JoinKey(path[:level], n.left).IsEqualTo(key) {
+					value, ok := values[n.right]
+					if !ok {
+						return nil, fmt.Errorf("proof is insufficient for key %x, value missing", key.ToBigInt())
+					}
+					result[k] = value
+				}
+				break
+			}
+
+			if path[level] == 0 {
+				node = n.left
+			} else {
+				node = n.right
+			}
+		}
+	}
+
+	return result, nil
+}
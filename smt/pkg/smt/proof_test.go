@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math/big"
 	"reflect"
 	"strings"
 	"testing"
@@ -223,3 +224,93 @@ func TestVerifyAndGetVal(t *testing.T) {
 		}
 	})
 }
+
+func TestMultiProof(t *testing.T) {
+	smtTrie := smt.NewSMT(nil, false)
+	values := make(map[int64]int64)
+	for i := int64(1); i <= 200; i++ {
+		values[i*7] = i % 5 * 1000
+		if _, err := smtTrie.InsertBI(big.NewInt(i*7), big.NewInt(values[i*7])); err != nil {
+			t.Fatalf("InsertBI() error = %v", err)
+		}
+	}
+
+	// existing keys, keys deleted with a zero value and keys never set
+	keys := make([]utils.NodeKey, 0)
+	for i := int64(1); i <= 600; i += 3 {
+		keys = append(keys, utils.ScalarToNodeKey(big.NewInt(i)))
+	}
+
+	smtRoot, err := smtTrie.RoSMT.DbRo.GetLastRoot()
+	if err != nil {
+		t.Fatalf("GetLastRoot() error = %v", err)
+	}
+	root := utils.ScalarToRoot(smtRoot)
+
+	proof, err := smt.BuildMultiProof(smtTrie.RoSMT, keys, context.Background())
+	if err != nil {
+		t.Fatalf("BuildMultiProof() error = %v", err)
+	}
+
+	t.Run("Values and non-existence are proven", func(t *testing.T) {
+		vals, err := smt.VerifyMultiProof(root, proof, keys)
+		if err != nil {
+			t.Fatalf("VerifyMultiProof() error = %v", err)
+		}
+		for i, key := range keys {
+			expected := values[int64(i*3+1)]
+			if got := new(big.Int).SetBytes(vals[i]).Int64(); got != expected {
+				t.Errorf("VerifyMultiProof() value of key %v = %d, want %d", key, got, expected)
+			}
+		}
+	})
+
+	t.Run("Shared nodes are deduplicated", func(t *testing.T) {
+		seen := make(map[string]struct{})
+		for _, node := range proof.Nodes {
+			if _, ok := seen[string(node)]; ok {
+				t.Fatalf("node %x is in the proof twice", node)
+			}
+			seen[string(node)] = struct{}{}
+		}
+
+		singleProofNodes := 0
+		for _, key := range keys {
+			rl := trie.NewRetainList(0)
+			keyPath := key.GetPath()
+			keyBytes := make([]byte, 0, len(keyPath))
+			for _, v := range keyPath {
+				keyBytes = append(keyBytes, byte(v))
+			}
+			rl.AddHex(keyBytes)
+			proofs, err := smt.BuildProofs(smtTrie.RoSMT, rl, context.Background())
+			if err != nil {
+				t.Fatalf("BuildProofs() error = %v", err)
+			}
+			singleProofNodes += len(smt.FilterProofs(proofs, key))
+		}
+		if len(proof.Nodes)+len(proof.Values) >= singleProofNodes {
+			t.Errorf("multiproof has %d elements, the single proofs %d", len(proof.Nodes)+len(proof.Values), singleProofNodes)
+		}
+	})
+
+	t.Run("Corrupted node", func(t *testing.T) {
+		corrupted := &smt.SMTMultiProof{Nodes: make([]hexutility.Bytes, len(proof.Nodes)), Values: proof.Values}
+		for i, node := range proof.Nodes {
+			corrupted.Nodes[i] = append(hexutility.Bytes{}, node...)
+		}
+		corrupted.Nodes[len(corrupted.Nodes)/2][0] ^= 0xFF
+
+		if _, err := smt.VerifyMultiProof(root, corrupted, keys); err == nil || !strings.Contains(err.Error(), "insufficient") {
+			t.Errorf("VerifyMultiProof() expected error containing 'insufficient', got %v", err)
+		}
+	})
+
+	t.Run("Missing value", func(t *testing.T) {
+		missing := &smt.SMTMultiProof{Nodes: proof.Nodes, Values: proof.Values[1:]}
+
+		if _, err := smt.VerifyMultiProof(root, missing, keys); err == nil || !strings.Contains(err.Error(), "value missing") {
+			t.Errorf("VerifyMultiProof() expected error containing 'value missing', got %v", err)
+		}
+	})
+}
@@ -53,6 +53,7 @@ type DebuggableDB interface {
 
 type SMT struct {
 	noSaveOnInsert bool
+	hashWorkers    int
 	Db             DB
 	*RoSMT
 }
@@ -79,6 +80,12 @@ func NewSMT(database DB, noSaveOnInsert bool) *SMT {
 	}
 }
 
+// SetHashWorkers sets the number of workers hashing the batch inserts of the storage concurrently, see
+// InsertBatchConfig.SetHashWorkers
+func (s *SMT) SetHashWorkers(workers int) {
+	s.hashWorkers = workers
+}
+
 func NewRoSMT(database RoDB) *RoSMT {
 	if database == nil {
 		database = db.NewMemDb()
@@ -89,6 +96,34 @@ func NewRoSMT(database RoDB) *RoSMT {
 	}
 }
 
+// NewSMTAtRoot reads the tree of a retained root instead of the last one
+func NewSMTAtRoot(database DB, root *big.Int) *SMT {
+	return NewSMT(&atRootDb{DB: database, root: root}, true)
+}
+
+// NewRoSMTAtRoot reads the tree of a retained root instead of the last one
+func NewRoSMTAtRoot(database RoDB, root *big.Int) *RoSMT {
+	return NewRoSMT(&atRootRoDb{RoDB: database, root: root})
+}
+
+type atRootDb struct {
+	DB
+	root *big.Int
+}
+
+func (d *atRootDb) GetLastRoot() (*big.Int, error) {
+	return new(big.Int).Set(d.root), nil
+}
+
+type atRootRoDb struct {
+	RoDB
+	root *big.Int
+}
+
+func (d *atRootRoDb) GetLastRoot() (*big.Int, error) {
+	return new(big.Int).Set(d.root), nil
+}
+
 func (s *RoSMT) LastRoot() *big.Int {
 	s.clearUpMutex.Lock()
 	defer s.clearUpMutex.Unlock()
@@ -607,7 +642,7 @@ func (s *SMT) CheckOrphanedNodes(ctx context.Context) int {
 		return 0
 	}
 
-	err = s.traverseAndMark(ctx, root, visited)
+	err = s.traverseAndMark(ctx, root, visited, nil)
 	if err != nil {
 		return 0
 	}
@@ -721,8 +756,21 @@ func (s *RoSMT) traverse(ctx context.Context, node *big.Int, action TraverseActi
 	return nil
 }
 
-func (s *RoSMT) traverseAndMark(ctx context.Context, node *big.Int, visited VisitedNodesMap) error {
+// traverseAndMark marks the nodes reachable from the node and the values of its leaves in visited, and the full keys
+// of the leaves in keys when it is not nil. A node already marked is not entered again, its subtree is taken as marked.
+func (s *RoSMT) traverseAndMark(ctx context.Context, node *big.Int, visited VisitedNodesMap, keys VisitedNodesMap) error {
 	return s.Traverse(ctx, node, func(prefix []byte, k utils.NodeKey, v utils.NodeValue12) (bool, error) {
+		if v.IsFinalNode() {
+			visited[utils.ConvertBigIntToHex(v.Get4to8().ToBigInt())] = true
+			if keys != nil {
+				usedBits := make([]int, len(prefix))
+				for i, bit := range prefix {
+					usedBits[i] = int(bit)
+				}
+				keys[utils.ConvertBigIntToHex(utils.JoinKey(usedBits, *v.Get0to4()).ToBigInt())] = true
+			}
+		}
+
 		if visited[utils.ConvertBigIntToHex(k.ToBigInt())] {
 			return false, nil
 		}
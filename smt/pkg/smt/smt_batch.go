@@ -3,6 +3,7 @@ package smt
 import (
 	"context"
 	"fmt"
+	"math/bits"
 	"sync"
 
 	"github.com/dgravesa/go-parallel/parallel"
@@ -10,10 +11,14 @@ import (
 	"github.com/ledgerwatch/erigon/zk"
 )
 
+// the batches below this size are hashed in a single dfs, splitting them costs more than it saves
+const parallelHashingMinKeys = 256
+
 type InsertBatchConfig struct {
 	ctx                 context.Context
 	logPrefix           string
 	shouldPrintProgress bool
+	hashWorkers         int
 }
 
 func NewInsertBatchConfig(ctx context.Context, logPrefix string, shouldPrintProgress bool) InsertBatchConfig {
@@ -21,9 +26,16 @@ func NewInsertBatchConfig(ctx context.Context, logPrefix string, shouldPrintProg
 		ctx:                 ctx,
 		logPrefix:           logPrefix,
 		shouldPrintProgress: shouldPrintProgress,
+		hashWorkers:         1,
 	}
 }
 
+// SetHashWorkers sets the number of workers hashing the subtrees of the batch concurrently, 1 or less hashes the whole
+// batch in a single dfs, as by default
+func (cfg *InsertBatchConfig) SetHashWorkers(workers int) {
+	cfg.hashWorkers = workers
+}
+
 func (s *SMT) InsertBatch(cfg InsertBatchConfig, nodeKeys []*utils.NodeKey, nodeValues []*utils.NodeValue8, nodeValuesHashes []*[4]uint64, rootNodeHash *utils.NodeKey) (*SMTResponse, error) {
 	s.clearUpMutex.Lock()
 	defer s.clearUpMutex.Unlock()
@@ -205,7 +217,11 @@ func (s *SMT) InsertBatch(cfg InsertBatchConfig, nodeKeys []*utils.NodeKey, node
 	}
 	defer stopProgressPrinterDel()
 	for _, mapLevel0 := range nodeHashesForDelete {
-		progressChanDel <- uint64(1)
+		// nothing reads the buffered channel when progress is not printed
+		select {
+		case progressChanDel <- uint64(1):
+		default:
+		}
 		for _, mapLevel1 := range mapLevel0 {
 			for _, mapLevel2 := range mapLevel1 {
 				for _, nodeHash := range mapLevel2 {
@@ -253,7 +269,11 @@ func (s *SMT) InsertBatch(cfg InsertBatchConfig, nodeKeys []*utils.NodeKey, node
 		go func() {
 			defer sdh.destroy()
 
-			calculateAndSaveHashesDfs(sdh, smtBatchNodeRoot, make([]int, 256), 0)
+			if cfg.hashWorkers > 1 && size >= parallelHashingMinKeys {
+				calculateAndSaveHashesParallel(sdh, smtBatchNodeRoot, cfg.hashWorkers)
+			} else {
+				calculateAndSaveHashesDfs(sdh, smtBatchNodeRoot, make([]int, 256), 0)
+			}
 			rootNodeHash = (*utils.NodeKey)(smtBatchNodeRoot.hash)
 		}()
 
@@ -479,19 +499,115 @@ func calculateAndSaveHashesDfs(sdh *smtDfsHelper, smtBatchNode *smtBatchNode, pa
 		return
 	}
 
-	var totalHash utils.NodeValue8
-
 	if smtBatchNode.leftNode != nil {
 		path[level] = 0
 		calculateAndSaveHashesDfs(sdh, smtBatchNode.leftNode, path, level+1)
+	}
+
+	if smtBatchNode.rightNode != nil {
+		path[level] = 1
+		calculateAndSaveHashesDfs(sdh, smtBatchNode.rightNode, path, level+1)
+	}
+
+	calculateAndSaveBranchHash(sdh, smtBatchNode)
+}
+
+// calculateAndSaveHashesParallel hashes the subtrees below the top levels of the batch tree concurrently and then
+// the top levels over their hashes. Every node hashes its children only, so the hashes and the saved nodes are the
+// ones of calculateAndSaveHashesDfs whatever the order the subtrees finish in. The nodes are still saved by a single
+// consumer, so it only pays off when hashing is the bottleneck rather than the db.
+func calculateAndSaveHashesParallel(sdh *smtDfsHelper, root *smtBatchNode, workers int) {
+	// a few subtrees per worker evens out the unbalanced branches of the batch
+	splitLevel := bits.Len(uint(workers*4 - 1))
+	if splitLevel > 8 {
+		splitLevel = 8
+	}
+
+	var subtrees []*smtBatchNode
+	var paths [][]int
+	collectBatchSubtrees(root, make([]int, 256), 0, splitLevel, &subtrees, &paths)
+
+	var wg sync.WaitGroup
+	jobs := make(chan int, len(subtrees))
+	for i := range subtrees {
+		jobs <- i
+	}
+	close(jobs)
+
+	if workers > len(subtrees) {
+		workers = len(subtrees)
+	}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				calculateAndSaveHashesDfs(sdh, subtrees[i], paths[i], splitLevel)
+			}
+		}()
+	}
+	wg.Wait()
+
+	calculateAndSaveHashesAbove(sdh, root, make([]int, 256), 0, splitLevel)
+}
+
+// collectBatchSubtrees collects the nodes of the batch tree at the split level with their paths
+func collectBatchSubtrees(smtBatchNode *smtBatchNode, path []int, level, splitLevel int, subtrees *[]*smtBatchNode, paths *[][]int) {
+	if level == splitLevel {
+		subtreePath := make([]int, 256)
+		copy(subtreePath, path[:level])
+		*subtrees = append(*subtrees, smtBatchNode)
+		*paths = append(*paths, subtreePath)
+		return
+	}
+	if smtBatchNode.isLeaf() {
+		return
+	}
+
+	if smtBatchNode.leftNode != nil {
+		path[level] = 0
+		collectBatchSubtrees(smtBatchNode.leftNode, path, level+1, splitLevel, subtrees, paths)
+	}
+	if smtBatchNode.rightNode != nil {
+		path[level] = 1
+		collectBatchSubtrees(smtBatchNode.rightNode, path, level+1, splitLevel, subtrees, paths)
+	}
+}
+
+// calculateAndSaveHashesAbove hashes the nodes of the batch tree above the split level, the ones at the split level
+// are hashed already
+func calculateAndSaveHashesAbove(sdh *smtDfsHelper, smtBatchNode *smtBatchNode, path []int, level, splitLevel int) {
+	if level == splitLevel {
+		return
+	}
+	if smtBatchNode.isLeaf() {
+		calculateAndSaveHashesDfs(sdh, smtBatchNode, path, level)
+		return
+	}
+
+	if smtBatchNode.leftNode != nil {
+		path[level] = 0
+		calculateAndSaveHashesAbove(sdh, smtBatchNode.leftNode, path, level+1, splitLevel)
+	}
+	if smtBatchNode.rightNode != nil {
+		path[level] = 1
+		calculateAndSaveHashesAbove(sdh, smtBatchNode.rightNode, path, level+1, splitLevel)
+	}
+
+	calculateAndSaveBranchHash(sdh, smtBatchNode)
+}
+
+// calculateAndSaveBranchHash hashes a branch over the hashes of its children, hashed already when they are in the batch
+func calculateAndSaveBranchHash(sdh *smtDfsHelper, smtBatchNode *smtBatchNode) {
+	var totalHash utils.NodeValue8
+
+	if smtBatchNode.leftNode != nil {
 		totalHash.SetHalfValue(*smtBatchNode.leftNode.hash, 0) // no point to check for error because we used hardcoded 0 which ensures that no error will be returned
 	} else {
 		totalHash.SetHalfValue(*smtBatchNode.nodeLeftHashOrRemainingKey, 0) // no point to check for error because we used hardcoded 0 which ensures that no error will be returned
 	}
 
 	if smtBatchNode.rightNode != nil {
-		path[level] = 1
-		calculateAndSaveHashesDfs(sdh, smtBatchNode.rightNode, path, level+1)
 		totalHash.SetHalfValue(*smtBatchNode.rightNode.hash, 1) // no point to check for error because we used hardcoded 1 which ensures that no error will be returned
 	} else {
 		totalHash.SetHalfValue(*smtBatchNode.nodeRightHashOrValueHash, 1) // no point to check for error because we used hardcoded 1 which ensures that no error will be returned
@@ -8,6 +8,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/erigon/smt/pkg/db"
 	"github.com/ledgerwatch/erigon/smt/pkg/smt"
 	"github.com/ledgerwatch/erigon/smt/pkg/utils"
 	"gotest.tools/v3/assert"
@@ -215,6 +217,116 @@ func BenchmarkBatchInsertNoSave(b *testing.B) {
 	}
 }
 
+func BenchmarkBatchInsertHashWorkers(b *testing.B) {
+	keys := []*big.Int{}
+	vals := []*big.Int{}
+	for i := 0; i < 20000; i++ {
+		keys = append(keys, big.NewInt(rand.Int63()))
+		vals = append(vals, big.NewInt(rand.Int63()))
+	}
+	keyPointers, valuePointers := batchInsertData(keys, vals)
+
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			insertBatchCfg := smt.NewInsertBatchConfig(context.Background(), "", false)
+			insertBatchCfg.SetHashWorkers(workers)
+			for i := 0; i < b.N; i++ {
+				smtBatch := smt.NewSMT(nil, true)
+				if _, err := smtBatch.InsertBatch(insertBatchCfg, keyPointers, valuePointers, nil, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkBatchInsertHashWorkersSave saves the nodes to mdbx, as the stages do, where the single consumer writing them
+// to the db rather than the hashing may well be the bottleneck
+func BenchmarkBatchInsertHashWorkersSave(b *testing.B) {
+	keys := []*big.Int{}
+	vals := []*big.Int{}
+	for i := 0; i < 20000; i++ {
+		keys = append(keys, big.NewInt(rand.Int63()))
+		vals = append(vals, big.NewInt(rand.Int63()))
+	}
+	keyPointers, valuePointers := batchInsertData(keys, vals)
+
+	dbi, err := mdbx.NewTemporaryMdbx(context.Background(), b.TempDir())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer dbi.Close()
+
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			insertBatchCfg := smt.NewInsertBatchConfig(context.Background(), "", false)
+			insertBatchCfg.SetHashWorkers(workers)
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				tx, err := dbi.BeginRw(context.Background())
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err = db.CreateEriDbBuckets(tx); err != nil {
+					b.Fatal(err)
+				}
+				smtBatch := smt.NewSMT(db.NewEriDb(tx), false)
+				b.StartTimer()
+
+				if _, err = smtBatch.InsertBatch(insertBatchCfg, keyPointers, valuePointers, nil, nil); err != nil {
+					b.Fatal(err)
+				}
+
+				b.StopTimer()
+				tx.Rollback()
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+func TestBatchInsertHashWorkers(t *testing.T) {
+	keys := []*big.Int{}
+	vals := []*big.Int{}
+	for i := 0; i < 3000; i++ {
+		keys = append(keys, big.NewInt(rand.Int63n(5000)))
+		vals = append(vals, big.NewInt(rand.Int63n(100)))
+	}
+
+	smtSerial := smt.NewSMT(nil, false)
+	smtParallel := smt.NewSMT(nil, false)
+	// the second half updates and deletes (zero values) the keys of the first one in the existing tree
+	for _, half := range [][2]int{{0, 1500}, {1500, 3000}} {
+		batchInsertWithHashWorkers(smtSerial, keys[half[0]:half[1]], vals[half[0]:half[1]], 1)
+		batchInsertWithHashWorkers(smtParallel, keys[half[0]:half[1]], vals[half[0]:half[1]], 8)
+
+		serialRootHash, _ := smtSerial.Db.GetLastRoot()
+		parallelRootHash, _ := smtParallel.Db.GetLastRoot()
+		assert.Equal(t, utils.ConvertBigIntToHex(serialRootHash), utils.ConvertBigIntToHex(parallelRootHash))
+	}
+
+	assert.DeepEqual(t, smtSerial.Db.(smt.DebuggableDB).GetDb(), smtParallel.Db.(smt.DebuggableDB).GetDb())
+}
+
+func TestBatchDeleteWithoutProgress(t *testing.T) {
+	keys := []*big.Int{}
+	vals := []*big.Int{}
+	zeros := []*big.Int{}
+	for i := 0; i < 2000; i++ {
+		keys = append(keys, big.NewInt(int64(i)))
+		vals = append(vals, big.NewInt(int64(i+1)))
+		zeros = append(zeros, big.NewInt(0))
+	}
+
+	// the nodes of far more than a progress channel buffer of deletes, with no printer reading the channel
+	smtBatch := smt.NewSMT(nil, false)
+	batchInsert(smtBatch, keys, vals)
+	batchInsert(smtBatch, keys, zeros)
+
+	rootHash, _ := smtBatch.Db.GetLastRoot()
+	assert.Equal(t, rootHash.Sign(), 0)
+}
+
 func TestBatchSimpleInsert2(t *testing.T) {
 	keys := []*big.Int{}
 	vals := []*big.Int{}
@@ -265,3 +377,25 @@ func batchInsert(tree *smt.SMT, key, val []*big.Int) {
 	insertBatchCfg := smt.NewInsertBatchConfig(context.Background(), "", false)
 	tree.InsertBatch(insertBatchCfg, keyPointers, valuePointers, nil, nil)
 }
+
+func batchInsertWithHashWorkers(tree *smt.SMT, key, val []*big.Int, workers int) {
+	keyPointers, valuePointers := batchInsertData(key, val)
+	insertBatchCfg := smt.NewInsertBatchConfig(context.Background(), "", false)
+	insertBatchCfg.SetHashWorkers(workers)
+	tree.InsertBatch(insertBatchCfg, keyPointers, valuePointers, nil, nil)
+}
+
+func batchInsertData(key, val []*big.Int) ([]*utils.NodeKey, []*utils.NodeValue8) {
+	keyPointers := []*utils.NodeKey{}
+	valuePointers := []*utils.NodeValue8{}
+
+	for i := range key {
+		k := utils.ScalarToNodeKey(key[i])
+		vArray := utils.ScalarToArrayBig(val[i])
+		v, _ := utils.NodeValue8FromBigIntArray(vArray)
+
+		keyPointers = append(keyPointers, &k)
+		valuePointers = append(valuePointers, v)
+	}
+	return keyPointers, valuePointers
+}
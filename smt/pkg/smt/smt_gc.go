@@ -0,0 +1,261 @@
+package smt
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+)
+
+// the tables swept by the garbage collector in order
+var gcTables = []string{db.TableSmt, db.TableHashKey, db.TableMetadata}
+
+// the live branches taken at once from the ones whose children are still to be marked
+const gcPendingBatch = 1024
+
+// GCReport sums up the work of a garbage collection cycle so far
+type GCReport struct {
+	Roots             int
+	LiveNodes         int
+	LiveKeys          int
+	MarkedBranches    int
+	ScannedEntries    int
+	DeletedNodes      int
+	DeletedHashKeys   int
+	DeletedKeySources int
+	ReclaimedBytes    uint64
+	// Done is set on the report of the chunk that completes the cycle
+	Done bool
+}
+
+// GarbageCollector deletes the nodes, hash keys and key sources that neither the last root nor a retained root
+// reaches. A cycle marks the trees of the live roots and then sweeps the tables, both in chunks of bounded work so a
+// cycle runs over many transactions. The marks are kept in the db along with the live branches whose children are
+// still to be marked, so the memory used does not grow with the state and a cycle carries on after a restart. Every
+// chunk marks the roots again first, the nodes written since the previous chunk are only reachable from them, and
+// nothing is swept until no branch is left to mark in the same transaction.
+type GarbageCollector struct {
+	table  int
+	next   []byte
+	report GCReport
+}
+
+func NewGarbageCollector() *GarbageCollector {
+	gc := &GarbageCollector{}
+	gc.reset()
+	return gc
+}
+
+func (gc *GarbageCollector) reset() {
+	gc.table = 0
+	gc.next = nil
+	gc.report = GCReport{}
+}
+
+// Collect runs the next chunk of the cycle, marking and scanning at most limit branches and entries of the tables, or
+// the whole cycle when limit is 0. It returns the report of the cycle so far. A failed chunk is rolled back with its
+// transaction and the sweep of the next one starts over, the marks already committed are kept.
+func (gc *GarbageCollector) Collect(ctx context.Context, eridb *db.EriDb, limit int) (GCReport, error) {
+	budget := limit
+	marked, err := gc.mark(ctx, eridb, limit, &budget)
+	if err != nil {
+		gc.reset()
+		return GCReport{}, err
+	}
+	if !marked {
+		return gc.report, nil
+	}
+
+	for gc.table < len(gcTables) {
+		if limit > 0 && budget <= 0 {
+			break
+		}
+
+		table := gcTables[gc.table]
+		next, scanned, deleted, reclaimed, err := eridb.SweepTable(table, gc.next, budget, gc.isGarbage(eridb, table))
+		if err != nil {
+			gc.reset()
+			return GCReport{}, err
+		}
+
+		gc.report.ScannedEntries += scanned
+		gc.report.ReclaimedBytes += reclaimed
+		switch table {
+		case db.TableSmt:
+			gc.report.DeletedNodes += deleted
+		case db.TableHashKey:
+			gc.report.DeletedHashKeys += deleted
+		case db.TableMetadata:
+			gc.report.DeletedKeySources += deleted
+		}
+
+		if gc.next = next; next == nil {
+			gc.table++
+		}
+		if limit > 0 {
+			budget -= scanned
+		}
+	}
+
+	report := gc.report
+	if gc.table < len(gcTables) {
+		return report, nil
+	}
+
+	liveNodes, err := eridb.CountGcLive(db.TableGcLiveNodes)
+	if err != nil {
+		gc.reset()
+		return GCReport{}, err
+	}
+	liveKeys, err := eridb.CountGcLive(db.TableGcLiveKeys)
+	if err != nil {
+		gc.reset()
+		return GCReport{}, err
+	}
+	if err = eridb.ClearGcMarks(); err != nil {
+		gc.reset()
+		return GCReport{}, err
+	}
+	report.LiveNodes = int(liveNodes)
+	report.LiveKeys = int(liveKeys)
+	report.Done = true
+	gc.reset()
+
+	return report, nil
+}
+
+// mark marks the live roots and then the children of the live branches, taking one off the budget for every branch
+// when limit is above 0. It returns true once no branch is left to mark.
+func (gc *GarbageCollector) mark(ctx context.Context, eridb *db.EriDb, limit int, budget *int) (bool, error) {
+	lastRoot, err := eridb.GetLastRoot()
+	if err != nil {
+		return false, err
+	}
+	retained, err := eridb.GetRetainedRoots()
+	if err != nil {
+		return false, err
+	}
+
+	roots := map[string]struct{}{utils.ConvertBigIntToHex(lastRoot): {}}
+	if err = gc.visit(eridb, utils.ScalarToRoot(lastRoot), nil); err != nil {
+		return false, err
+	}
+	for _, root := range retained {
+		roots[utils.ConvertBigIntToHex(root)] = struct{}{}
+		if err = gc.visit(eridb, utils.ScalarToRoot(root), nil); err != nil {
+			return false, err
+		}
+	}
+	gc.report.Roots = len(roots)
+
+	for {
+		n := gcPendingBatch
+		if limit > 0 && *budget < n {
+			n = *budget
+		}
+		if n <= 0 {
+			// out of budget, the marking is done if no branch is left
+			pending, err := eridb.HasGcPending()
+			return !pending && err == nil, err
+		}
+
+		keys, paths, err := eridb.PopGcPending(n)
+		if err != nil {
+			return false, err
+		}
+		if len(keys) == 0 {
+			return true, nil
+		}
+
+		for i, k := range keys {
+			select {
+			case <-ctx.Done():
+				return false, ctx.Err()
+			default:
+			}
+
+			if err = gc.markChildren(eridb, string(k), paths[i]); err != nil {
+				return false, err
+			}
+		}
+		gc.report.MarkedBranches += len(keys)
+		if limit > 0 {
+			*budget -= len(keys)
+		}
+	}
+}
+
+// markChildren marks the children of the live branch at the path
+func (gc *GarbageCollector) markChildren(eridb *db.EriDb, key string, path []byte) error {
+	v, err := eridb.Get(utils.ScalarToRoot(utils.ConvertHexToBigInt(key)))
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		child := utils.NodeKeyFromBigIntArray(v[i*4 : i*4+4])
+		childPath := make([]byte, len(path)+1)
+		copy(childPath, path)
+		childPath[len(path)] = byte(i)
+		if err = gc.visit(eridb, child, childPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// visit marks the node at the path live. A leaf is marked along with its value and full key, a branch marked for the
+// first time is left for its children to be marked.
+func (gc *GarbageCollector) visit(eridb *db.EriDb, k utils.NodeKey, path []byte) error {
+	if k.IsZero() {
+		return nil
+	}
+
+	v, err := eridb.Get(k)
+	if err != nil {
+		return err
+	}
+
+	key := []byte(utils.ConvertBigIntToHex(k.ToBigInt()))
+	if !v.IsFinalNode() {
+		added, err := eridb.MarkGcLive(db.TableGcLiveNodes, key)
+		if err != nil || !added {
+			return err
+		}
+		return eridb.PushGcPending(key, path)
+	}
+
+	if _, err = eridb.MarkGcLive(db.TableGcLiveNodes, key); err != nil {
+		return err
+	}
+	if _, err = eridb.MarkGcLive(db.TableGcLiveNodes, []byte(utils.ConvertBigIntToHex(v.Get4to8().ToBigInt()))); err != nil {
+		return err
+	}
+	usedBits := make([]int, len(path))
+	for i, bit := range path {
+		usedBits[i] = int(bit)
+	}
+	_, err = eridb.MarkGcLive(db.TableGcLiveKeys, []byte(utils.ConvertBigIntToHex(utils.JoinKey(usedBits, *v.Get0to4()).ToBigInt())))
+	return err
+}
+
+func (gc *GarbageCollector) isGarbage(eridb *db.EriDb, table string) func(k []byte) (bool, error) {
+	if table == db.TableSmt {
+		return func(k []byte) (bool, error) {
+			live, err := eridb.IsGcLive(db.TableGcLiveNodes, k)
+			return !live, err
+		}
+	}
+
+	// the hash keys are keyed by the leaf hash and the key sources by the full key of the leaf, both as bytes
+	marks := db.TableGcLiveNodes
+	if table == db.TableMetadata {
+		marks = db.TableGcLiveKeys
+	}
+	return func(k []byte) (bool, error) {
+		live, err := eridb.IsGcLive(marks, []byte(utils.ConvertBigIntToHex(new(big.Int).SetBytes(k))))
+		return !live, err
+	}
+}
@@ -0,0 +1,164 @@
+package smt
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/smt/pkg/db"
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+	"github.com/ledgerwatch/erigon/turbo/trie"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMT_GarbageCollector(t *testing.T) {
+	ctx := context.Background()
+	sdb, _, err := getTempMdbx()
+	require.NoError(t, err)
+	s := NewSMT(sdb, false)
+
+	// single inserts leave the replaced nodes behind
+	for round := int64(1); round <= 3; round++ {
+		for k := int64(1); k <= 20; k++ {
+			_, err = s.InsertBI(big.NewInt(k), big.NewInt(k*10+round))
+			require.NoError(t, err)
+		}
+		if round == 2 {
+			require.NoError(t, sdb.RetainRoot(2, s.LastRoot()))
+		}
+	}
+	for k := int64(15); k <= 20; k++ {
+		_, err = s.InsertBI(big.NewInt(k), big.NewInt(0))
+		require.NoError(t, err)
+	}
+	retainedRoot := mustRetainedRoot(t, sdb, 2)
+	lastRoot := s.LastRoot()
+	require.NoError(t, sdb.InsertKeySource(utils.ScalarToNodeKey(big.NewInt(1)), []byte{1}))
+	require.NoError(t, sdb.InsertKeySource(utils.ScalarToNodeKey(big.NewInt(16)), []byte{16}))
+	require.NoError(t, sdb.InsertKeySource(utils.ScalarToNodeKey(big.NewInt(99)), []byte{99}))
+	nodesBefore := countEntries(t, sdb, db.TableSmt)
+
+	gc := NewGarbageCollector()
+	var report GCReport
+	for chunks := 0; !report.Done; chunks++ {
+		require.Less(t, chunks, 1000)
+		report, err = gc.Collect(ctx, sdb, 7)
+		require.NoError(t, err)
+		require.LessOrEqual(t, report.ScannedEntries, (chunks+1)*7)
+	}
+	require.Equal(t, 2, report.Roots)
+	require.NotZero(t, report.DeletedNodes)
+	require.NotZero(t, report.DeletedHashKeys)
+	// the key deleted from the last tree is still in the retained one
+	require.Equal(t, 1, report.DeletedKeySources)
+	require.NotZero(t, report.ReclaimedBytes)
+	require.Equal(t, nodesBefore-report.DeletedNodes, countEntries(t, sdb, db.TableSmt))
+	require.Equal(t, report.LiveNodes, countEntries(t, sdb, db.TableSmt))
+
+	// both live trees are intact
+	valueAt := func(root *big.Int, k int64) int64 {
+		proofs, err := BuildProofs(NewRoSMTAtRoot(sdb, root), &trie.AlwaysTrueRetainDecider{}, ctx)
+		require.NoError(t, err)
+		key := utils.ScalarToNodeKey(big.NewInt(k))
+		val, err := VerifyAndGetVal(utils.ScalarToRoot(root), FilterProofs(proofs, key), key)
+		require.NoError(t, err)
+		return new(big.Int).SetBytes(val).Int64()
+	}
+	for k := int64(1); k <= 20; k++ {
+		require.Equal(t, k*10+2, valueAt(retainedRoot, k))
+		if k < 15 {
+			require.Equal(t, k*10+3, valueAt(lastRoot, k))
+		}
+	}
+	_, err = sdb.GetKeySource(utils.ScalarToNodeKey(big.NewInt(1)))
+	require.NoError(t, err)
+	_, err = sdb.GetKeySource(utils.ScalarToNodeKey(big.NewInt(16)))
+	require.NoError(t, err)
+	_, err = sdb.GetKeySource(utils.ScalarToNodeKey(big.NewInt(99)))
+	require.Error(t, err)
+
+	// the retained tree goes, the next cycle collects what only it used
+	_, err = sdb.PruneRetainedRoots(3)
+	require.NoError(t, err)
+	report, err = gc.Collect(ctx, sdb, 0)
+	require.NoError(t, err)
+	require.True(t, report.Done)
+	require.Equal(t, 1, report.Roots)
+	require.NotZero(t, report.DeletedNodes)
+	require.Equal(t, 1, report.DeletedKeySources)
+	require.Equal(t, report.LiveNodes, countEntries(t, sdb, db.TableSmt))
+	_, err = sdb.GetKeySource(utils.ScalarToNodeKey(big.NewInt(16)))
+	require.Error(t, err)
+	require.Equal(t, int64(143), valueAt(lastRoot, 14))
+	require.Equal(t, lastRoot, s.LastRoot())
+
+	report, err = gc.Collect(ctx, sdb, 0)
+	require.NoError(t, err)
+	require.Zero(t, report.DeletedNodes+report.DeletedHashKeys+report.DeletedKeySources)
+}
+
+func TestSMT_GarbageCollectorBoundedMark(t *testing.T) {
+	ctx := context.Background()
+	sdb, _, err := getTempMdbx()
+	require.NoError(t, err)
+	s := NewSMT(sdb, false)
+
+	for round := int64(1); round <= 2; round++ {
+		for k := int64(1); k <= 50; k++ {
+			_, err = s.InsertBI(big.NewInt(k), big.NewInt(k*10+round))
+			require.NoError(t, err)
+		}
+	}
+
+	// the first chunks only mark, the branches left to mark are kept in the db
+	gc := NewGarbageCollector()
+	report, err := gc.Collect(ctx, sdb, 5)
+	require.NoError(t, err)
+	require.False(t, report.Done)
+	require.Equal(t, 5, report.MarkedBranches)
+	require.Zero(t, report.ScannedEntries)
+	pending, err := sdb.HasGcPending()
+	require.NoError(t, err)
+	require.True(t, pending)
+
+	// the nodes written between chunks are marked from the new root, a restarted collector carries on from the marks
+	_, err = s.InsertBI(big.NewInt(51), big.NewInt(511))
+	require.NoError(t, err)
+	gc = NewGarbageCollector()
+	for chunks := 0; !report.Done; chunks++ {
+		require.Less(t, chunks, 1000)
+		report, err = gc.Collect(ctx, sdb, 5)
+		require.NoError(t, err)
+	}
+	require.NotZero(t, report.DeletedNodes)
+	require.Equal(t, report.LiveNodes, countEntries(t, sdb, db.TableSmt))
+	for _, table := range []string{db.TableGcLiveNodes, db.TableGcLiveKeys, db.TableGcPending} {
+		require.Zero(t, countEntries(t, sdb, table))
+	}
+
+	proofs, err := BuildProofs(NewRoSMTAtRoot(sdb, s.LastRoot()), &trie.AlwaysTrueRetainDecider{}, ctx)
+	require.NoError(t, err)
+	for k := int64(1); k <= 51; k++ {
+		key := utils.ScalarToNodeKey(big.NewInt(k))
+		val, err := VerifyAndGetVal(utils.ScalarToRoot(s.LastRoot()), FilterProofs(proofs, key), key)
+		require.NoError(t, err)
+		expected := k*10 + 2
+		if k == 51 {
+			expected = 511
+		}
+		require.Equal(t, expected, new(big.Int).SetBytes(val).Int64())
+	}
+}
+
+func mustRetainedRoot(t *testing.T, sdb *db.EriDb, block uint64) *big.Int {
+	root, err := sdb.GetRetainedRoot(block)
+	require.NoError(t, err)
+	require.NotNil(t, root)
+	return root
+}
+
+func countEntries(t *testing.T, sdb *db.EriDb, table string) int {
+	_, scanned, _, _, err := sdb.SweepTable(table, nil, 0, func([]byte) (bool, error) { return false, nil })
+	require.NoError(t, err)
+	return scanned
+}
@@ -0,0 +1,82 @@
+package smt
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ledgerwatch/erigon/smt/pkg/utils"
+	"github.com/ledgerwatch/erigon/turbo/trie"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMT_RetainedRoots(t *testing.T) {
+	ctx := context.Background()
+	sdb, _, err := getTempMdbx()
+	require.NoError(t, err)
+	sdb.SetRetainNodes(true)
+	s := NewSMT(sdb, false)
+	cfg := NewInsertBatchConfig(ctx, "", false)
+
+	insert := func(block uint64, values map[int64]int64) *big.Int {
+		keys := make([]*utils.NodeKey, 0, len(values))
+		vals := make([]*utils.NodeValue8, 0, len(values))
+		for k, v := range values {
+			key := utils.ScalarToNodeKey(big.NewInt(k))
+			val := utils.ScalarToNodeValue8(big.NewInt(v))
+			keys, vals = append(keys, &key), append(vals, &val)
+		}
+		_, err := s.InsertBatch(cfg, keys, vals, nil, nil)
+		require.NoError(t, err)
+		root := s.LastRoot()
+		require.NoError(t, sdb.RetainRoot(block, root))
+		return root
+	}
+
+	valueAt := func(root *big.Int, k int64) *big.Int {
+		proofs, err := BuildProofs(NewRoSMTAtRoot(sdb, root), &trie.AlwaysTrueRetainDecider{}, ctx)
+		require.NoError(t, err)
+		key := utils.ScalarToNodeKey(big.NewInt(k))
+		val, err := VerifyAndGetVal(utils.ScalarToRoot(root), FilterProofs(proofs, key), key)
+		require.NoError(t, err)
+		return new(big.Int).SetBytes(val)
+	}
+
+	root1 := insert(1, map[int64]int64{1: 10, 2: 20, 3: 30})
+	root2 := insert(2, map[int64]int64{1: 11, 2: 0})
+	// back to the value of block 1, its nodes are written again
+	root3 := insert(3, map[int64]int64{1: 10})
+
+	require.Equal(t, int64(10), valueAt(root1, 1).Int64())
+	require.Equal(t, int64(20), valueAt(root1, 2).Int64())
+	require.Equal(t, int64(11), valueAt(root2, 1).Int64())
+	require.Zero(t, valueAt(root2, 2).Int64())
+	require.Equal(t, int64(10), valueAt(root3, 1).Int64())
+
+	retained, err := sdb.GetRetainedRoot(2)
+	require.NoError(t, err)
+	require.Equal(t, root2, retained)
+
+	// the trees from block 2 are kept
+	pruned, err := sdb.PruneRetainedRoots(2)
+	require.NoError(t, err)
+	require.NotZero(t, pruned)
+	retained, err = sdb.GetRetainedRoot(1)
+	require.NoError(t, err)
+	require.Nil(t, retained)
+	require.Equal(t, int64(11), valueAt(root2, 1).Int64())
+	require.Equal(t, int64(30), valueAt(root2, 3).Int64())
+	require.Equal(t, int64(10), valueAt(root3, 1).Int64())
+
+	// only the last tree is kept, the nodes written again at block 3 survive
+	_, err = sdb.PruneRetainedRoots(3)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), valueAt(root3, 1).Int64())
+	require.Equal(t, int64(30), valueAt(root3, 3).Int64())
+
+	// the unwound roots are dropped
+	require.NoError(t, sdb.TruncateRetainedRoots(2))
+	retained, err = sdb.GetRetainedRoot(3)
+	require.NoError(t, err)
+	require.Nil(t, retained)
+}
@@ -165,6 +165,15 @@ func (s *SMT) getValueInBytes(nodeKey utils.NodeKey) ([]byte, error) {
 		}
 
 		if v.IsFinalNode() {
+			// the leaf on the path of the key belongs to another key when the key is not in the tree
+			usedBits := make([]int, len(prefix))
+			for i, b := range prefix {
+				usedBits[i] = int(b)
+			}
+			if !utils.JoinKey(usedBits, *v.Get0to4()).IsEqualTo(nodeKey) {
+				return false, nil
+			}
+
 			valHash := v.Get4to8()
 			v, err := s.Db.Get(*valHash)
 			if err != nil {
@@ -86,6 +86,7 @@ var DefaultFlags = []cli.Flag{
 	&utils.RpcLogsMaxRange,
 	&utils.AllowUnprotectedTxs,
 	&utils.RpcMaxGetProofRewindBlockCount,
+	&utils.RpcMaxGetMultiProofKeys,
 	&utils.RPCGlobalTxFeeCapFlag,
 	&utils.TxpoolApiAddrFlag,
 	&utils.TraceMaxtracesFlag,
@@ -178,7 +179,13 @@ var DefaultFlags = []cli.Flag{
 	&utils.L2RpcUrlFlag,
 	&utils.L2DataStreamerUrlFlag,
 	&utils.L2DataStreamerUseTLSFlag,
+	&utils.L2DataStreamerTLSCertFlag,
+	&utils.L2DataStreamerTLSKeyFlag,
+	&utils.L2DataStreamerTLSCAFlag,
 	&utils.L2DataStreamerTimeout,
+	&utils.L2DataStreamerFailoverUrlsFlag,
+	&utils.L2DataStreamerStallTimeout,
+	&utils.L2DataStreamerCrossCheckInterval,
 	&utils.L2ShortCircuitToVerifiedBatchFlag,
 	&utils.L1SyncStartBlock,
 	&utils.L1SyncStopBatch,
@@ -186,6 +193,8 @@ var DefaultFlags = []cli.Flag{
 	&utils.L1RpcUrlFlag,
 	&utils.L1CacheEnabledFlag,
 	&utils.L1CachePortFlag,
+	&utils.L1CacheMaxSizeFlag,
+	&utils.L1CacheLatestTTLFlag,
 	&utils.AddressSequencerFlag,
 	&utils.AddressAdminFlag,
 	&utils.AddressRollupFlag,
@@ -195,6 +204,8 @@ var DefaultFlags = []cli.Flag{
 	&utils.L1BlockRangeFlag,
 	&utils.L1QueryDelayFlag,
 	&utils.L1HighestBlockTypeFlag,
+	&utils.L1QuorumFlag,
+	&utils.L1ProviderMaxFaultsFlag,
 	&utils.L1MaticContractAddressFlag,
 	&utils.L1FirstBlockFlag,
 	&utils.L1FinalizedBlockRequirementFlag,
@@ -206,6 +217,9 @@ var DefaultFlags = []cli.Flag{
 	&utils.RebuildTreeAfterFlag,
 	&utils.IncrementTreeAlways,
 	&utils.SmtRegenerateInMemory,
+	&utils.SmtRetainBlocks,
+	&utils.SmtGcChunkSize,
+	&utils.SmtHashWorkers,
 	&utils.SequencerBlockSealTime,
 	&utils.SequencerBatchSealTime,
 	&utils.SequencerBatchVerificationTimeout,
@@ -218,6 +232,13 @@ var DefaultFlags = []cli.Flag{
 	&utils.ExecutorUrls,
 	&utils.ExecutorStrictMode,
 	&utils.ExecutorRequestTimeout,
+	&utils.ExecutorWeights,
+	&utils.ExecutorBreakerFailures,
+	&utils.ExecutorBreakerCooldown,
+	&utils.ExecutorHedgeDelay,
+	&utils.VerificationFailuresLimit,
+	&utils.LocalExecutorAddr,
+	&utils.LocalExecutorCompare,
 	&utils.DatastreamNewBlockTimeout,
 	&utils.WitnessMemdbSize,
 	&utils.WitnessUnwindLimit,
@@ -247,6 +268,14 @@ var DefaultFlags = []cli.Flag{
 	&utils.DataStreamWriteTimeout,
 	&utils.DataStreamInactivityTimeout,
 	&utils.DataStreamInactivityCheckInterval,
+	&utils.DataStreamTLSCert,
+	&utils.DataStreamTLSKey,
+	&utils.DataStreamTLSClientCA,
+	&utils.DataStreamAllowedIPs,
+	&utils.DataStreamAllowedCerts,
+	&utils.DataStreamInternalPort,
+	&utils.DataStreamGatewayAddr,
+	&utils.DataStreamArchiveDir,
 	&utils.WitnessFullFlag,
 	&utils.SyncLimit,
 	&utils.ExecutorPayloadOutput,
@@ -294,15 +323,25 @@ var DefaultFlags = []cli.Flag{
 	&SyncLoopPruneLimitFlag,
 	&utils.PoolManagerUrl,
 	&utils.TxPoolRejectSmartContractDeployments,
+	&utils.TxPoolSenderTxLimit,
+	&utils.TxPoolSenderGasLimit,
+	&utils.TxPoolSenderLimitWindow,
+	&utils.TxPoolSenderLimitACLExempt,
+	&utils.TxPoolOrdering,
 	&utils.DisableVirtualCounters,
 	&utils.DAUrl,
+	&utils.DACommitteeUrls,
+	&utils.DADataDir,
+	&utils.DACacheDir,
 	&utils.VirtualCountersSmtReduction,
 	&utils.BadBatches,
 	&utils.InitialBatchCfgFile,
 
 	&utils.ACLPrintHistory,
+	&utils.ACLRpcEnabled,
 	&utils.InfoTreeUpdateInterval,
 	&utils.SealBatchImmediatelyOnOverflow,
+	&utils.SequencerCounterAwarePacking,
 	&utils.VerifyZkProofForkid,
 	&utils.VerifyZkProofVerifier,
 	&utils.VerifyZkProofTrustedAggregator,
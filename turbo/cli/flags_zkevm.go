@@ -114,7 +114,19 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		panic("Effective gas price for contract deployment must be in interval [0; 1]")
 	}
 
+	var executorWeights []int
+	if weights := strings.ReplaceAll(ctx.String(utils.ExecutorWeights.Name), " ", ""); weights != "" {
+		for _, w := range strings.Split(weights, ",") {
+			weight, err := strconv.Atoi(w)
+			if err != nil || weight <= 0 {
+				panic(fmt.Sprintf("could not parse executor weight %s", w))
+			}
+			executorWeights = append(executorWeights, weight)
+		}
+	}
+
 	witnessMemSize := utils.DatasizeFlagValue(ctx, utils.WitnessMemdbSize.Name)
+	l1CacheMaxSize := utils.DatasizeFlagValue(ctx, utils.L1CacheMaxSizeFlag.Name)
 	witnessUnwindLimit := ctx.Uint64(utils.WitnessUnwindLimit.Name)
 
 	mcfg := cfg.Zk.Merlin
@@ -155,6 +167,10 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 	if globalPendingDynamicFactor < 0 || globalPendingDynamicFactor > 1 {
 		panic("Effective global pending dynamic factor must be in interval [0; 1]")
 	}
+	smtHashWorkers := ctx.Int(utils.SmtHashWorkers.Name)
+	if smtHashWorkers < 1 {
+		panic(fmt.Sprintf("State tree hash workers (%s) must be at least 1", utils.SmtHashWorkers.Name))
+	}
 	gpConf := &ethconfig.GasPriceConf{
 		Enable:                     ctx.Bool(utils.EnableGasPricer.Name),
 		DefaultGasPrice:            ctx.Uint64(utils.DefaultGasPrice.Name),
@@ -175,7 +191,13 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		L2RpcUrl:                               ctx.String(utils.L2RpcUrlFlag.Name),
 		L2DataStreamerUrl:                      ctx.String(utils.L2DataStreamerUrlFlag.Name),
 		L2DataStreamerUseTLS:                   ctx.Bool(utils.L2DataStreamerUseTLSFlag.Name),
+		L2DataStreamerTLSCert:                  ctx.String(utils.L2DataStreamerTLSCertFlag.Name),
+		L2DataStreamerTLSKey:                   ctx.String(utils.L2DataStreamerTLSKeyFlag.Name),
+		L2DataStreamerTLSCA:                    ctx.String(utils.L2DataStreamerTLSCAFlag.Name),
 		L2DataStreamerTimeout:                  l2DataStreamTimeout,
+		L2DataStreamerFailoverUrls:             splitListFlag(ctx.String(utils.L2DataStreamerFailoverUrlsFlag.Name)),
+		L2DataStreamerStallTimeout:             ctx.Duration(utils.L2DataStreamerStallTimeout.Name),
+		L2DataStreamerCrossCheckInterval:       ctx.Uint64(utils.L2DataStreamerCrossCheckInterval.Name),
 		L2ShortCircuitToVerifiedBatch:          l2ShortCircuitToVerifiedBatchVal,
 		L1SyncStartBlock:                       ctx.Uint64(utils.L1SyncStartBlock.Name),
 		L1SyncStopBatch:                        ctx.Uint64(utils.L1SyncStopBatch.Name),
@@ -183,6 +205,8 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		L1RpcUrl:                               ctx.String(utils.L1RpcUrlFlag.Name),
 		L1CacheEnabled:                         ctx.Bool(utils.L1CacheEnabledFlag.Name),
 		L1CachePort:                            ctx.Uint(utils.L1CachePortFlag.Name),
+		L1CacheMaxSize:                         *l1CacheMaxSize,
+		L1CacheLatestTTL:                       ctx.Duration(utils.L1CacheLatestTTLFlag.Name),
 		AddressSequencer:                       libcommon.HexToAddress(ctx.String(utils.AddressSequencerFlag.Name)),
 		AddressAdmin:                           libcommon.HexToAddress(ctx.String(utils.AddressAdminFlag.Name)),
 		AddressRollup:                          libcommon.HexToAddress(ctx.String(utils.AddressRollupFlag.Name)),
@@ -192,6 +216,8 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		L1BlockRange:                           ctx.Uint64(utils.L1BlockRangeFlag.Name),
 		L1QueryDelay:                           ctx.Uint64(utils.L1QueryDelayFlag.Name),
 		L1HighestBlockType:                     ctx.String(utils.L1HighestBlockTypeFlag.Name),
+		L1Quorum:                               ctx.Uint64(utils.L1QuorumFlag.Name),
+		L1ProviderMaxFaults:                    ctx.Uint64(utils.L1ProviderMaxFaultsFlag.Name),
 		L1MaticContractAddress:                 libcommon.HexToAddress(ctx.String(utils.L1MaticContractAddressFlag.Name)),
 		L1FirstBlock:                           ctx.Uint64(utils.L1FirstBlockFlag.Name),
 		L1FinalizedBlockRequirement:            ctx.Uint64(utils.L1FinalizedBlockRequirementFlag.Name),
@@ -202,6 +228,9 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		RebuildTreeAfter:                       ctx.Uint64(utils.RebuildTreeAfterFlag.Name),
 		IncrementTreeAlways:                    ctx.Bool(utils.IncrementTreeAlways.Name),
 		SmtRegenerateInMemory:                  ctx.Bool(utils.SmtRegenerateInMemory.Name),
+		SmtRetainBlocks:                        ctx.Uint64(utils.SmtRetainBlocks.Name),
+		SmtGcChunkSize:                         ctx.Uint64(utils.SmtGcChunkSize.Name),
+		SmtHashWorkers:                         smtHashWorkers,
 		SequencerBlockSealTime:                 sequencerBlockSealTime,
 		SequencerBatchSealTime:                 sequencerBatchSealTime,
 		SequencerBatchVerificationTimeout:      sequencerBatchVerificationTimeout,
@@ -214,6 +243,13 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		ExecutorUrls:                           strings.Split(strings.ReplaceAll(ctx.String(utils.ExecutorUrls.Name), " ", ""), ","),
 		ExecutorStrictMode:                     ctx.Bool(utils.ExecutorStrictMode.Name),
 		ExecutorRequestTimeout:                 ctx.Duration(utils.ExecutorRequestTimeout.Name),
+		ExecutorWeights:                        executorWeights,
+		ExecutorBreakerFailures:                ctx.Int(utils.ExecutorBreakerFailures.Name),
+		ExecutorBreakerCooldown:                ctx.Duration(utils.ExecutorBreakerCooldown.Name),
+		ExecutorHedgeDelay:                     ctx.Duration(utils.ExecutorHedgeDelay.Name),
+		VerificationFailuresLimit:              ctx.Int(utils.VerificationFailuresLimit.Name),
+		LocalExecutorAddr:                      ctx.String(utils.LocalExecutorAddr.Name),
+		LocalExecutorCompare:                   ctx.Bool(utils.LocalExecutorCompare.Name),
 		DatastreamNewBlockTimeout:              ctx.Duration(utils.DatastreamNewBlockTimeout.Name),
 		WitnessMemdbSize:                       *witnessMemSize,
 		WitnessUnwindLimit:                     witnessUnwindLimit,
@@ -239,20 +275,38 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		DebugStepAfter:                         ctx.Uint64(utils.DebugStepAfter.Name),
 		PoolManagerUrl:                         ctx.String(utils.PoolManagerUrl.Name),
 		TxPoolRejectSmartContractDeployments:   ctx.Bool(utils.TxPoolRejectSmartContractDeployments.Name),
+		TxPoolSenderTxLimit:                    ctx.Uint64(utils.TxPoolSenderTxLimit.Name),
+		TxPoolSenderGasLimit:                   ctx.Uint64(utils.TxPoolSenderGasLimit.Name),
+		TxPoolSenderLimitWindow:                ctx.Duration(utils.TxPoolSenderLimitWindow.Name),
+		TxPoolSenderLimitACLExempt:             ctx.Bool(utils.TxPoolSenderLimitACLExempt.Name),
+		TxPoolOrdering:                         ctx.String(utils.TxPoolOrdering.Name),
 		DisableVirtualCounters:                 ctx.Bool(utils.DisableVirtualCounters.Name),
 		ExecutorPayloadOutput:                  ctx.String(utils.ExecutorPayloadOutput.Name),
 		DAUrl:                                  ctx.String(utils.DAUrl.Name),
+		DACommitteeUrls:                        splitListFlag(ctx.String(utils.DACommitteeUrls.Name)),
+		DADataDir:                              ctx.String(utils.DADataDir.Name),
+		DACacheDir:                             ctx.String(utils.DACacheDir.Name),
 		DataStreamHost:                         ctx.String(utils.DataStreamHost.Name),
 		DataStreamPort:                         ctx.Uint(utils.DataStreamPort.Name),
 		DataStreamWriteTimeout:                 ctx.Duration(utils.DataStreamWriteTimeout.Name),
 		DataStreamInactivityTimeout:            ctx.Duration(utils.DataStreamInactivityTimeout.Name),
+		DataStreamTLSCert:                      ctx.String(utils.DataStreamTLSCert.Name),
+		DataStreamTLSKey:                       ctx.String(utils.DataStreamTLSKey.Name),
+		DataStreamTLSClientCA:                  ctx.String(utils.DataStreamTLSClientCA.Name),
+		DataStreamAllowedIPs:                   splitListFlag(ctx.String(utils.DataStreamAllowedIPs.Name)),
+		DataStreamAllowedCerts:                 splitListFlag(ctx.String(utils.DataStreamAllowedCerts.Name)),
+		DataStreamInternalPort:                 ctx.Uint(utils.DataStreamInternalPort.Name),
+		DataStreamGatewayAddr:                  ctx.String(utils.DataStreamGatewayAddr.Name),
+		DataStreamArchiveDir:                   ctx.String(utils.DataStreamArchiveDir.Name),
 		VirtualCountersSmtReduction:            ctx.Float64(utils.VirtualCountersSmtReduction.Name),
 		Merlin:                                 mcfg,
 		BadBatches:                             badBatches,
 		InitialBatchCfgFile:                    ctx.String(utils.InitialBatchCfgFile.Name),
 		ACLPrintHistory:                        ctx.Int(utils.ACLPrintHistory.Name),
+		ACLRpcEnabled:                          ctx.Bool(utils.ACLRpcEnabled.Name),
 		InfoTreeUpdateInterval:                 ctx.Duration(utils.InfoTreeUpdateInterval.Name),
 		SealBatchImmediatelyOnOverflow:         ctx.Bool(utils.SealBatchImmediatelyOnOverflow.Name),
+		SequencerCounterAwarePacking:           ctx.Bool(utils.SequencerCounterAwarePacking.Name),
 		MockWitnessGeneration:                  ctx.Bool(utils.MockWitnessGeneration.Name),
 		WitnessContractInclusion:               witnessInclusion,
 		BadTxAllowance:                         ctx.Uint64(utils.BadTxAllowance.Name),
@@ -287,6 +341,14 @@ func ApplyFlagsForZkConfig(ctx *cli.Context, cfg *ethconfig.Config) {
 		if len(cfg.ExecutorUrls) > 0 && cfg.ExecutorUrls[0] != "" && cfg.DisableVirtualCounters {
 			panic("You cannot disable virtual counters when running with executors")
 		}
+
+		if len(cfg.ExecutorWeights) > len(cfg.ExecutorUrls) {
+			panic("You cannot set more executor weights (zkevm.executor-weights) than executor urls")
+		}
+	}
+
+	if cfg.DADataDir != "" && (cfg.DAUrl != "" || len(cfg.DACommitteeUrls) > 0 || cfg.DACacheDir != "") {
+		panic("You cannot set the off chain data dir (zkevm.da-data-dir) along with the data availability urls or cache dir")
 	}
 
 	checkFlag(utils.AddressZkevmFlag.Name, cfg.AddressZkevm)
@@ -323,3 +385,14 @@ func parseVerifyZkProofConfigs(ctx *cli.Context) []*ethconfig.VerifyZkProofConfi
 	}
 	return confs
 }
+
+// splitListFlag splits a comma separated flag value, ignoring the empty entries
+func splitListFlag(value string) []string {
+	var res []string
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
@@ -0,0 +1,285 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	libcommon "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon-lib/kv"
+
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/zk/txpool"
+)
+
+// AclAPI the interface for the acl_ RPC commands.
+// Every change needs the actor doing it, so that it is recorded in the audit log together with the change.
+// The actor is a free-form name given by the caller, it is not an authenticated identity: the endpoint
+// only checks the JWT secret, so the audit log attributes changes on the word of whoever holds it.
+type AclAPI interface {
+	Mode(ctx context.Context) (string, error)
+	SetMode(ctx context.Context, actor string, mode string) error
+	ListPolicies(ctx context.Context, aclType string) (*AclPoliciesJson, error)
+	AddPolicy(ctx context.Context, actor string, aclType string, address libcommon.Address, policy string, scope *AclPolicyScopeJson) error
+	RemovePolicy(ctx context.Context, actor string, aclType string, address libcommon.Address, policy string, contract *libcommon.Address) error
+	LastPolicyTransactions(ctx context.Context, count int) ([]txpool.PolicyTransaction, error)
+	AuditLog(ctx context.Context, count int) ([]txpool.AuditEntry, error)
+}
+
+// AclAPIImpl data structure to store things needed for acl_ commands
+type AclAPIImpl struct {
+	aclDB kv.RwDB
+}
+
+// NewAclAPI returns AclAPIImpl instance
+func NewAclAPI(aclDB kv.RwDB) *AclAPIImpl {
+	return &AclAPIImpl{
+		aclDB: aclDB,
+	}
+}
+
+// AclAPIList returns the acl_ namespace, it changes the ACL of a running node so it is only
+// meant to be served on the JWT authenticated endpoint
+func AclAPIList(aclDB kv.RwDB) []rpc.API {
+	return []rpc.API{{
+		Namespace: "acl",
+		Public:    false,
+		Service:   AclAPI(NewAclAPI(aclDB)),
+		Version:   "1.0",
+	}}
+}
+
+type AclPolicyScopeJson struct {
+	Contract   *libcommon.Address `json:"contract,omitempty"`
+	ValidFrom  *hexutil.Uint64    `json:"validFrom,omitempty"`
+	ValidUntil *hexutil.Uint64    `json:"validUntil,omitempty"`
+}
+
+type AclAddressPoliciesJson struct {
+	Address  libcommon.Address `json:"address"`
+	Policies []string          `json:"policies"`
+}
+
+type AclScopedPolicyJson struct {
+	Address    libcommon.Address  `json:"address"`
+	Contract   *libcommon.Address `json:"contract,omitempty"`
+	Policy     string             `json:"policy"`
+	ValidFrom  *hexutil.Uint64    `json:"validFrom,omitempty"`
+	ValidUntil *hexutil.Uint64    `json:"validUntil,omitempty"`
+	Active     bool               `json:"active"`
+}
+
+type AclMethodRuleJson struct {
+	Contract libcommon.Address  `json:"contract"`
+	Selector hexutil.Bytes      `json:"selector"`
+	Sender   *libcommon.Address `json:"sender,omitempty"`
+	Action   string             `json:"action"`
+}
+
+type AclPoliciesJson struct {
+	Mode        string                   `json:"mode"`
+	Policies    []AclAddressPoliciesJson `json:"policies"`
+	Scoped      []AclScopedPolicyJson    `json:"scoped"`
+	MethodRules []AclMethodRuleJson      `json:"methodRules"`
+}
+
+func (api *AclAPIImpl) Mode(ctx context.Context) (string, error) {
+	mode, err := txpool.GetMode(ctx, api.aclDB)
+	if err != nil {
+		return "", err
+	}
+	if mode == "" {
+		return txpool.DisabledMode, nil
+	}
+	return string(mode), nil
+}
+
+func (api *AclAPIImpl) SetMode(ctx context.Context, actor string, mode string) error {
+	return api.audit(ctx, actor, "setMode", map[string]string{"mode": mode}, func(tx kv.RwTx) error {
+		return txpool.SetModeTx(tx, mode)
+	})
+}
+
+func (api *AclAPIImpl) ListPolicies(ctx context.Context, aclType string) (*AclPoliciesJson, error) {
+	mode, err := api.Mode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := txpool.ListPolicies(ctx, api.aclDB, aclType)
+	if err != nil {
+		return nil, err
+	}
+	scoped, err := txpool.ListScopedPolicies(ctx, api.aclDB, aclType)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := txpool.ListMethodRules(ctx, api.aclDB)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &AclPoliciesJson{
+		Mode:        mode,
+		Policies:    make([]AclAddressPoliciesJson, 0, len(list)),
+		Scoped:      make([]AclScopedPolicyJson, 0, len(scoped)),
+		MethodRules: make([]AclMethodRuleJson, 0, len(rules)),
+	}
+
+	for _, ap := range list {
+		policies := make([]string, 0, len(ap.Policies))
+		for _, p := range ap.Policies {
+			policies = append(policies, p.String())
+		}
+		res.Policies = append(res.Policies, AclAddressPoliciesJson{Address: ap.Addr, Policies: policies})
+	}
+
+	now := time.Now()
+	for _, sp := range scoped {
+		enc := AclScopedPolicyJson{
+			Address: sp.Addr,
+			Policy:  sp.Policy.String(),
+			Active:  sp.IsActive(now),
+		}
+		if sp.Contract != (libcommon.Address{}) {
+			contract := sp.Contract
+			enc.Contract = &contract
+		}
+		if !sp.ValidFrom.IsZero() {
+			validFrom := hexutil.Uint64(sp.ValidFrom.Unix())
+			enc.ValidFrom = &validFrom
+		}
+		if !sp.ValidUntil.IsZero() {
+			validUntil := hexutil.Uint64(sp.ValidUntil.Unix())
+			enc.ValidUntil = &validUntil
+		}
+		res.Scoped = append(res.Scoped, enc)
+	}
+
+	for _, r := range rules {
+		enc := AclMethodRuleJson{
+			Contract: r.Contract,
+			Selector: r.Selector[:],
+			Action:   r.Action.String(),
+		}
+		if r.Sender != (libcommon.Address{}) {
+			sender := r.Sender
+			enc.Sender = &sender
+		}
+		res.MethodRules = append(res.MethodRules, enc)
+	}
+
+	return res, nil
+}
+
+func (api *AclAPIImpl) AddPolicy(ctx context.Context, actor string, aclType string, address libcommon.Address, policy string, scope *AclPolicyScopeJson) error {
+	params := map[string]string{"type": aclType, "address": address.Hex(), "policy": policy}
+	if scope != nil {
+		if scope.Contract != nil {
+			params["contract"] = scope.Contract.Hex()
+		}
+		if scope.ValidFrom != nil {
+			params["validFrom"] = strconv.FormatUint(uint64(*scope.ValidFrom), 10)
+		}
+		if scope.ValidUntil != nil {
+			params["validUntil"] = strconv.FormatUint(uint64(*scope.ValidUntil), 10)
+		}
+	}
+
+	return api.audit(ctx, actor, "addPolicy", params, func(tx kv.RwTx) error {
+		p, err := txpool.ResolvePolicy(policy)
+		if err != nil {
+			return err
+		}
+
+		if scope == nil {
+			return txpool.AddPolicyTx(tx, aclType, address, p)
+		}
+
+		sp := txpool.ScopedPolicy{Addr: address, Policy: p}
+		if scope.Contract != nil {
+			sp.Contract = *scope.Contract
+		}
+		if scope.ValidFrom != nil {
+			sp.ValidFrom = time.Unix(int64(*scope.ValidFrom), 0)
+		}
+		if scope.ValidUntil != nil {
+			sp.ValidUntil = time.Unix(int64(*scope.ValidUntil), 0)
+		}
+		return txpool.AddScopedPolicyTx(tx, aclType, sp)
+	})
+}
+
+func (api *AclAPIImpl) RemovePolicy(ctx context.Context, actor string, aclType string, address libcommon.Address, policy string, contract *libcommon.Address) error {
+	params := map[string]string{"type": aclType, "address": address.Hex(), "policy": policy}
+	if contract != nil {
+		params["contract"] = contract.Hex()
+	}
+
+	return api.audit(ctx, actor, "removePolicy", params, func(tx kv.RwTx) error {
+		p, err := txpool.ResolvePolicy(policy)
+		if err != nil {
+			return err
+		}
+
+		if contract == nil {
+			return txpool.RemovePolicyTx(tx, aclType, address, p)
+		}
+		return txpool.RemoveScopedPolicyTx(tx, aclType, address, *contract, p)
+	})
+}
+
+func (api *AclAPIImpl) LastPolicyTransactions(ctx context.Context, count int) ([]txpool.PolicyTransaction, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("invalid count %d", count)
+	}
+	return txpool.LastPolicyTransactions(ctx, api.aclDB, count)
+}
+
+func (api *AclAPIImpl) AuditLog(ctx context.Context, count int) ([]txpool.AuditEntry, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("invalid count %d", count)
+	}
+	return txpool.LastAuditEntries(ctx, api.aclDB, count)
+}
+
+// audit runs the change and records it in the audit log within the same transaction, so a change is never
+// committed without its entry. A failed change is rolled back and the attempt is recorded on its own.
+func (api *AclAPIImpl) audit(ctx context.Context, actor string, action string, params map[string]string, change func(tx kv.RwTx) error) error {
+	actor = strings.TrimSpace(actor)
+	if actor == "" {
+		return errors.New("actor is required")
+	}
+
+	entry := txpool.AuditEntry{
+		Actor:  actor,
+		Action: action,
+		Params: params,
+		Time:   time.Now(),
+	}
+
+	var changeErr error
+	err := api.aclDB.Update(ctx, func(tx kv.RwTx) error {
+		if changeErr = change(tx); changeErr != nil {
+			return changeErr
+		}
+		return txpool.InsertAuditEntryTx(tx, entry)
+	})
+	if err == nil {
+		return nil
+	}
+	if changeErr == nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	entry.Error = changeErr.Error()
+	if err := txpool.InsertAuditEntry(ctx, api.aclDB, entry); err != nil {
+		return errors.Join(changeErr, fmt.Errorf("failed to record audit entry: %w", err))
+	}
+
+	return changeErr
+}
@@ -0,0 +1,96 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	libcommon "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/zk/txpool"
+)
+
+func TestAclAPI(t *testing.T) {
+	ctx := context.Background()
+
+	aclDB, err := txpool.OpenACLDB(ctx, t.TempDir())
+	require.NoError(t, err)
+	defer aclDB.Close()
+
+	api := NewAclAPI(aclDB)
+
+	addr := libcommon.HexToAddress("0x1234567890abcdef")
+	contract := libcommon.HexToAddress("0xabcdef1234567890")
+	validUntil := hexutil.Uint64(4070908800)
+
+	mode, err := api.Mode(ctx)
+	require.NoError(t, err)
+	require.Equal(t, txpool.DisabledMode, mode)
+
+	// changes without an actor are rejected and not recorded
+	require.Error(t, api.SetMode(ctx, "", txpool.AllowlistMode))
+
+	require.NoError(t, api.SetMode(ctx, "alice", txpool.AllowlistMode))
+	require.NoError(t, api.AddPolicy(ctx, "alice", "allowlist", addr, "deploy", nil))
+	require.NoError(t, api.AddPolicy(ctx, "bob", "allowlist", addr, "sendTx", &AclPolicyScopeJson{Contract: &contract, ValidUntil: &validUntil}))
+	require.ErrorContains(t, api.AddPolicy(ctx, "bob", "allowlist", addr, "unknown", nil), "unknown policy")
+
+	policies, err := api.ListPolicies(ctx, "allowlist")
+	require.NoError(t, err)
+	require.Equal(t, txpool.AllowlistMode, policies.Mode)
+	require.Equal(t, []AclAddressPoliciesJson{{Address: addr, Policies: []string{"deploy"}}}, policies.Policies)
+	require.Len(t, policies.Scoped, 1)
+	require.Equal(t, contract, *policies.Scoped[0].Contract)
+	require.Equal(t, validUntil, *policies.Scoped[0].ValidUntil)
+	require.True(t, policies.Scoped[0].Active)
+
+	require.NoError(t, api.RemovePolicy(ctx, "carol", "allowlist", addr, "sendTx", &contract))
+
+	policies, err = api.ListPolicies(ctx, "allowlist")
+	require.NoError(t, err)
+	require.Empty(t, policies.Scoped)
+
+	entries, err := api.AuditLog(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+
+	// newest first
+	require.Equal(t, "carol", entries[0].Actor)
+	require.Equal(t, "removePolicy", entries[0].Action)
+	require.Equal(t, contract.Hex(), entries[0].Params["contract"])
+	require.Equal(t, "bob", entries[1].Actor)
+	require.Equal(t, "unknown policy", entries[1].Error)
+	require.Equal(t, "alice", entries[4].Actor)
+	require.Equal(t, "setMode", entries[4].Action)
+	require.False(t, entries[4].Time.IsZero())
+
+	entries, err = api.AuditLog(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	pts, err := api.LastPolicyTransactions(ctx, 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, pts)
+
+	// a change failing half way is rolled back with its entry, only the failed attempt is recorded
+	other := libcommon.HexToAddress("0x9999")
+	err = api.audit(ctx, "dave", "addPolicy", nil, func(tx kv.RwTx) error {
+		if err := txpool.AddPolicyTx(tx, "allowlist", other, txpool.Deploy); err != nil {
+			return err
+		}
+		return errors.New("failed after the write")
+	})
+	require.ErrorContains(t, err, "failed after the write")
+
+	hasPolicy, err := txpool.DoesAccountHavePolicy(ctx, aclDB, other, txpool.Deploy)
+	require.NoError(t, err)
+	require.False(t, hasPolicy)
+
+	entries, err = api.AuditLog(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, "dave", entries[0].Actor)
+	require.Equal(t, "failed after the write", entries[0].Error)
+}
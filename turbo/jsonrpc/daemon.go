@@ -44,6 +44,9 @@ func APIList(ctx context.Context, db kv.RoDB, eth rpchelper.ApiBackend, txPool t
 	if sequencer.IsSequencer() && ethCfg.GasPriceCfg.Enable {
 		ethImpl.SetL2GasPricer(NewL2GasPricer(ctx, ethCfg.GasPriceCfg, ethImpl.BaseAPI, txPool, db))
 	}
+	if sequencer.IsSequencer() && ethCfg.SequencerCounterAwarePacking && rawPool != nil {
+		ethImpl.SetCounterEstimatesPool(rawPool)
+	}
 	erigonImpl := NewErigonAPI(base, db, eth)
 	txpoolImpl := NewTxPoolAPI(base, db, txPool, rawPool, rpcUrl)
 	netImpl := NewNetAPIImpl(eth)
@@ -74,6 +77,7 @@ func APIList(ctx context.Context, db kv.RoDB, eth rpchelper.ApiBackend, txPool t
 	gqlImpl := NewGraphQLAPI(base, db)
 	overlayImpl := NewOverlayAPI(base, db, cfg.Gascap, cfg.OverlayGetLogsTimeout, cfg.OverlayReplayBlockTimeout, otsImpl)
 	zkEvmImpl := NewZkEvmAPI(ethImpl, db, cfg.ReturnDataLimit, ethCfg, l1Syncer, rpcUrl, dataStreamServer)
+	zkEvmImpl.MaxGetMultiProofKeys = cfg.MaxGetMultiProofKeys
 	merlinAPIImpl := NewMerlinAPI(ethImpl, zkEvmImpl, ethCfg.Merlin, db, l1Syncer)
 
 	if cfg.GraphQLEnabled {
@@ -39,6 +39,7 @@ import (
 	"github.com/ledgerwatch/erigon/turbo/rpchelper"
 	"github.com/ledgerwatch/erigon/turbo/services"
 	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	txpool2 "github.com/ledgerwatch/erigon/zk/txpool"
 	"github.com/ledgerwatch/erigon/zk/utils"
 )
 
@@ -379,6 +380,10 @@ type APIImpl struct {
 	RejectLowGasPriceTransactions bool
 	BadTxAllowance                uint64
 	LogsMaxRange                  uint64
+
+	// the pool the counters of the sent transactions are estimated for, nil unless the sequencer packs by counters
+	counterEstimatesPool *txpool2.TxPool
+	counterEstimatesSem  chan struct{}
 }
 
 // NewEthAPI returns APIImpl instance
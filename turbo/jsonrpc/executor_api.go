@@ -0,0 +1,110 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon-lib/kv"
+
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/turbo/rpchelper"
+	"github.com/ledgerwatch/erigon/zk/legacy_executor_verifier"
+)
+
+// ExecutorAPI the interface for the admin_ RPC commands about the executors of the sequencer
+type ExecutorAPI interface {
+	Executors(ctx context.Context) ([]ExecutorStatusJson, error)
+	VerificationFailures(ctx context.Context, batchNumber *rpc.BlockNumber) ([]*legacy_executor_verifier.VerificationFailure, error)
+}
+
+// ExecutorAPIImpl data structure to store things needed for the executor admin_ commands
+type ExecutorAPIImpl struct {
+	verifier *legacy_executor_verifier.LegacyExecutorVerifier
+	failures *legacy_executor_verifier.FailureArchive
+	db       kv.RoDB
+}
+
+// NewExecutorAPI returns ExecutorAPIImpl instance, failures is nil when the failed verifications are not archived
+func NewExecutorAPI(verifier *legacy_executor_verifier.LegacyExecutorVerifier, failures *legacy_executor_verifier.FailureArchive, db kv.RoDB) *ExecutorAPIImpl {
+	return &ExecutorAPIImpl{
+		verifier: verifier,
+		failures: failures,
+		db:       db,
+	}
+}
+
+// ExecutorAPIList returns the admin_executors and admin_verificationFailures commands, they expose the executor urls
+// and responses so they are only meant to be served on the JWT authenticated endpoint
+func ExecutorAPIList(verifier *legacy_executor_verifier.LegacyExecutorVerifier, failures *legacy_executor_verifier.FailureArchive, db kv.RoDB) []rpc.API {
+	return []rpc.API{{
+		Namespace: "admin",
+		Public:    false,
+		Service:   ExecutorAPI(NewExecutorAPI(verifier, failures, db)),
+		Version:   "1.0",
+	}}
+}
+
+type ExecutorStatusJson struct {
+	Url                 string         `json:"url"`
+	Weight              hexutil.Uint64 `json:"weight"`
+	Online              bool           `json:"online"`
+	Breaker             string         `json:"breaker"`
+	OpenUntil           hexutil.Uint64 `json:"openUntil,omitempty"`
+	QueueLength         hexutil.Uint64 `json:"queueLength"`
+	Requests            hexutil.Uint64 `json:"requests"`
+	Failures            hexutil.Uint64 `json:"failures"`
+	ConsecutiveFailures hexutil.Uint64 `json:"consecutiveFailures"`
+	LatencyMs           hexutil.Uint64 `json:"latencyMs"`
+	LastError           string         `json:"lastError,omitempty"`
+}
+
+// Executors returns the health, the load and the circuit breaker state of every executor
+func (api *ExecutorAPIImpl) Executors(ctx context.Context) ([]ExecutorStatusJson, error) {
+	statuses := api.verifier.ExecutorStatuses()
+	res := make([]ExecutorStatusJson, 0, len(statuses))
+	for _, s := range statuses {
+		enc := ExecutorStatusJson{
+			Url:                 s.Url,
+			Weight:              hexutil.Uint64(s.Weight),
+			Online:              s.Online,
+			Breaker:             s.Breaker,
+			QueueLength:         hexutil.Uint64(s.QueueLength),
+			Requests:            hexutil.Uint64(s.Requests),
+			Failures:            hexutil.Uint64(s.Failures),
+			ConsecutiveFailures: hexutil.Uint64(s.ConsecutiveFailures),
+			LatencyMs:           hexutil.Uint64(s.Latency.Milliseconds()),
+			LastError:           s.LastError,
+		}
+		if !s.OpenUntil.IsZero() {
+			enc.OpenUntil = hexutil.Uint64(s.OpenUntil.Unix())
+		}
+		res = append(res, enc)
+	}
+	return res, nil
+}
+
+// VerificationFailures returns the failed verifications archived by the sequencer, the latest first, only those of the
+// batch if it is set
+func (api *ExecutorAPIImpl) VerificationFailures(ctx context.Context, rpcBatchNumber *rpc.BlockNumber) ([]*legacy_executor_verifier.VerificationFailure, error) {
+	if api.failures == nil {
+		return nil, errors.New("verification failures are only archived by a sequencer verifying its batches")
+	}
+
+	var batchNumber *uint64
+	if rpcBatchNumber != nil {
+		tx, err := api.db.BeginRo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		number, _, err := rpchelper.GetBatchNumber(*rpcBatchNumber, tx, nil)
+		if err != nil {
+			return nil, err
+		}
+		batchNumber = &number
+	}
+
+	return api.failures.List(ctx, batchNumber)
+}
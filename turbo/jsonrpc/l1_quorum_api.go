@@ -0,0 +1,106 @@
+package jsonrpc
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/zk/syncer"
+)
+
+// L1QuorumAPI the interface for the admin_ RPC commands about the l1 providers read with quorum
+type L1QuorumAPI interface {
+	L1Providers(ctx context.Context) (*L1QuorumReportJson, error)
+}
+
+// L1QuorumAPIImpl data structure to store things needed for the l1 quorum admin_ commands
+type L1QuorumAPIImpl struct {
+	quorum *syncer.Quorum
+}
+
+// NewL1QuorumAPI returns L1QuorumAPIImpl instance
+func NewL1QuorumAPI(quorum *syncer.Quorum) *L1QuorumAPIImpl {
+	return &L1QuorumAPIImpl{
+		quorum: quorum,
+	}
+}
+
+// L1QuorumAPIList returns the admin_l1Providers command, it is served on the JWT authenticated endpoint along with the
+// other admin commands
+func L1QuorumAPIList(quorum *syncer.Quorum) []rpc.API {
+	return []rpc.API{{
+		Namespace: "admin",
+		Public:    false,
+		Service:   L1QuorumAPI(NewL1QuorumAPI(quorum)),
+		Version:   "1.0",
+	}}
+}
+
+type L1ProviderJson struct {
+	Index         hexutil.Uint64 `json:"index"`
+	Score         int64          `json:"score"`
+	Agreements    hexutil.Uint64 `json:"agreements"`
+	Disagreements hexutil.Uint64 `json:"disagreements"`
+	Failures      hexutil.Uint64 `json:"failures"`
+	Evicted       bool           `json:"evicted"`
+	EvictedUntil  hexutil.Uint64 `json:"evictedUntil,omitempty"`
+}
+
+type L1DisagreementJson struct {
+	Time     hexutil.Uint64      `json:"time"`
+	Method   string              `json:"method"`
+	Results  map[int]common.Hash `json:"results"`
+	Errors   map[int]string      `json:"errors,omitempty"`
+	Accepted *common.Hash        `json:"accepted,omitempty"`
+	Quorum   bool                `json:"quorum"`
+}
+
+type L1QuorumReportJson struct {
+	Quorum        hexutil.Uint64       `json:"quorum"`
+	Providers     []L1ProviderJson     `json:"providers"`
+	Disagreements []L1DisagreementJson `json:"disagreements"`
+}
+
+// L1Providers returns the standing of the l1 providers, by their index in zkevm.l1-rpc-url, and the latest results
+// they disagreed on, oldest first
+func (api *L1QuorumAPIImpl) L1Providers(ctx context.Context) (*L1QuorumReportJson, error) {
+	report := api.quorum.Report()
+
+	res := &L1QuorumReportJson{
+		Quorum:        hexutil.Uint64(report.Quorum),
+		Providers:     make([]L1ProviderJson, 0, len(report.Providers)),
+		Disagreements: make([]L1DisagreementJson, 0, len(report.Disagreements)),
+	}
+	for _, p := range report.Providers {
+		enc := L1ProviderJson{
+			Index:         hexutil.Uint64(p.Index),
+			Score:         p.Score,
+			Agreements:    hexutil.Uint64(p.Agreements),
+			Disagreements: hexutil.Uint64(p.Disagreements),
+			Failures:      hexutil.Uint64(p.Failures),
+			Evicted:       p.Evicted,
+		}
+		if !p.EvictedUntil.IsZero() {
+			enc.EvictedUntil = hexutil.Uint64(p.EvictedUntil.Unix())
+		}
+		res.Providers = append(res.Providers, enc)
+	}
+	for _, d := range report.Disagreements {
+		enc := L1DisagreementJson{
+			Time:    hexutil.Uint64(d.Time.Unix()),
+			Method:  d.Method,
+			Results: d.Results,
+			Errors:  d.Errors,
+			Quorum:  d.Quorum,
+		}
+		if d.Quorum {
+			accepted := d.Accepted
+			enc.Accepted = &accepted
+		}
+		res.Disagreements = append(res.Disagreements, enc)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,106 @@
+package jsonrpc
+
+import (
+	"context"
+
+	libcommon "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon-lib/kv"
+
+	"github.com/ledgerwatch/erigon/rpc"
+	"github.com/ledgerwatch/erigon/zk/txpool"
+)
+
+// LimboAPI the interface for the limbo_ RPC commands
+type LimboAPI interface {
+	Blocks(ctx context.Context) (*LimboBlocksJson, error)
+	DropTransaction(ctx context.Context, hash libcommon.Hash) error
+	RequeueTransaction(ctx context.Context, hash libcommon.Hash) error
+}
+
+// LimboAPIImpl data structure to store things needed for limbo_ commands
+type LimboAPIImpl struct {
+	pool   *txpool.TxPool
+	poolDB kv.RwDB
+}
+
+// NewLimboAPI returns LimboAPIImpl instance
+func NewLimboAPI(pool *txpool.TxPool, poolDB kv.RwDB) *LimboAPIImpl {
+	return &LimboAPIImpl{
+		pool:   pool,
+		poolDB: poolDB,
+	}
+}
+
+// LimboAPIList returns the limbo_ namespace, it changes the pool of a running node so it is only
+// meant to be served on the JWT authenticated endpoint
+func LimboAPIList(pool *txpool.TxPool, poolDB kv.RwDB) []rpc.API {
+	return []rpc.API{{
+		Namespace: "limbo",
+		Public:    false,
+		Service:   LimboAPI(NewLimboAPI(pool, poolDB)),
+		Version:   "1.0",
+	}}
+}
+
+type LimboTransactionJson struct {
+	Hash    libcommon.Hash    `json:"hash"`
+	Sender  libcommon.Address `json:"sender"`
+	Root    libcommon.Hash    `json:"root"`
+	Failure string            `json:"failure,omitempty"`
+}
+
+type LimboBlockJson struct {
+	BlockNumber    hexutil.Uint64         `json:"blockNumber"`
+	BatchNumber    hexutil.Uint64         `json:"batchNumber"`
+	ForkId         hexutil.Uint64         `json:"forkId"`
+	BlockTimestamp hexutil.Uint64         `json:"timestamp"`
+	Transactions   []LimboTransactionJson `json:"transactions"`
+}
+
+type LimboBlocksJson struct {
+	Unchecked []LimboBlockJson `json:"unchecked"`
+	Invalid   []LimboBlockJson `json:"invalid"`
+}
+
+// Blocks returns the limbo blocks still waiting for the executor and the ones it found invalid
+func (api *LimboAPIImpl) Blocks(ctx context.Context) (*LimboBlocksJson, error) {
+	unchecked, invalid := api.pool.GetLimboBlocksDetailsCloned()
+	return &LimboBlocksJson{
+		Unchecked: limboBlocksToJson(unchecked),
+		Invalid:   limboBlocksToJson(invalid),
+	}, nil
+}
+
+// DropTransaction removes a transaction from the limbo without giving it back to the pool
+func (api *LimboAPIImpl) DropTransaction(ctx context.Context, hash libcommon.Hash) error {
+	return api.pool.DropLimboTransaction(ctx, api.poolDB, hash)
+}
+
+// RequeueTransaction removes a transaction from the limbo and gives it back to the pool
+func (api *LimboAPIImpl) RequeueTransaction(ctx context.Context, hash libcommon.Hash) error {
+	return api.pool.RequeueLimboTransaction(ctx, api.poolDB, hash)
+}
+
+func limboBlocksToJson(blocks []*txpool.LimboBlockDetails) []LimboBlockJson {
+	res := make([]LimboBlockJson, 0, len(blocks))
+	for _, b := range blocks {
+		enc := LimboBlockJson{
+			BlockNumber:    hexutil.Uint64(b.BlockNumber),
+			BatchNumber:    hexutil.Uint64(b.BatchNumber),
+			ForkId:         hexutil.Uint64(b.ForkId),
+			BlockTimestamp: hexutil.Uint64(b.BlockTimestamp),
+			Transactions:   make([]LimboTransactionJson, 0, len(b.Transactions)),
+		}
+		for _, t := range b.Transactions {
+			enc.Transactions = append(enc.Transactions, LimboTransactionJson{
+				Hash:    t.Hash,
+				Sender:  t.Sender,
+				Root:    t.Root,
+				Failure: t.Failure,
+			})
+		}
+		res = append(res, enc)
+	}
+	return res
+}
@@ -119,6 +119,9 @@ func (api *APIImpl) SendRawTransaction(ctx context.Context, encodedTx hexutility
 		return hash, fmt.Errorf("%s: %s", txPoolProto.ImportResult_name[int32(res.Imported[0])], res.Errors[0])
 	}
 
+	// [zkevm] - let the pool know the counters of the transaction before the sequencer takes it
+	api.estimatePoolTxCounters(txn)
+
 	return txn.Hash(), nil
 }
 
@@ -1,7 +1,9 @@
 package jsonrpc
 
 import (
+	"context"
 	"fmt"
+	"runtime"
 	"strings"
 
 	"math/big"
@@ -9,8 +11,12 @@ import (
 	zkchainconfig "github.com/ledgerwatch/erigon-lib/chain"
 	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/common/hexutility"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
 	"github.com/ledgerwatch/erigon/zk/sequencer"
+	txpool2 "github.com/ledgerwatch/erigon/zk/txpool"
 	"github.com/ledgerwatch/erigon/zkevm/jsonrpc/client"
+	"github.com/ledgerwatch/log/v3"
 )
 
 func (api *APIImpl) isPoolManagerAddressSet() bool {
@@ -37,3 +43,50 @@ func (api *APIImpl) sendTxZk(rpcUrl string, encodedTx hexutility.Bytes, chainId
 
 	return common.HexToHash(hashHex), nil
 }
+
+// SetCounterEstimatesPool makes the transactions sent to the sequencer dry run once they are in the pool, so that the pool
+// knows their counters before the sequencer first takes them
+func (api *APIImpl) SetCounterEstimatesPool(pool *txpool2.TxPool) {
+	api.counterEstimatesPool = pool
+	api.counterEstimatesSem = make(chan struct{}, runtime.NumCPU())
+}
+
+// estimatePoolTxCounters dry runs the transaction in the background and records its counters in the pool, the estimate is
+// best effort and skipped when as many dry runs as there are CPUs are already running
+func (api *APIImpl) estimatePoolTxCounters(txn types.Transaction) {
+	if api.counterEstimatesPool == nil {
+		return
+	}
+
+	select {
+	case api.counterEstimatesSem <- struct{}{}:
+	default:
+		return
+	}
+
+	go func() {
+		defer func() { <-api.counterEstimatesSem }()
+
+		ctx := context.Background()
+		if api.evmCallTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, api.evmCallTimeout)
+			defer cancel()
+		}
+
+		dbtx, err := api.db.BeginRo(ctx)
+		if err != nil {
+			log.Debug("Failed to estimate the counters of a sent transaction", "hash", txn.Hash(), "err", err)
+			return
+		}
+		defer dbtx.Rollback()
+
+		estimate, err := api.estimateCounters(ctx, dbtx, func(state.StateReader) (types.Transaction, error) { return txn, nil })
+		if err != nil {
+			log.Debug("Failed to estimate the counters of a sent transaction", "hash", txn.Hash(), "err", err)
+			return
+		}
+
+		api.counterEstimatesPool.SetEstimatedCounters(txn.Hash(), estimate.txCounters.CombineCounters().UsedAsArray())
+	}()
+}
@@ -1,6 +1,7 @@
 package jsonrpc
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -17,6 +18,7 @@ import (
 	zktypes "github.com/ledgerwatch/erigon/zk/types"
 
 	"math"
+	"sort"
 
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/common/hexutil"
@@ -83,6 +85,8 @@ type ZkEvmAPI interface {
 	GetRollupAddress(ctx context.Context) (res json.RawMessage, err error)
 	GetRollupManagerAddress(ctx context.Context) (res json.RawMessage, err error)
 	GetLatestDataStreamBlock(ctx context.Context) (hexutil.Uint64, error)
+	GetBatchOffChainData(ctx context.Context, batchNumber rpc.BlockNumber) (*ZkOffChainData, error)
+	GetMultiProof(ctx context.Context, accountSlots map[common.Address][]common.Hash, blockNrOrHash rpc.BlockNumberOrHash) (*accounts.SMTMultiProofResult, error)
 }
 
 const getBatchWitness = "getBatchWitness"
@@ -98,6 +102,9 @@ type ZkEvmAPIImpl struct {
 	l2SequencerUrl   string
 	semaphores       map[string]chan struct{}
 	datastreamServer server.DataStreamServer
+
+	// MaxGetMultiProofKeys caps the keys a GetMultiProof call proves
+	MaxGetMultiProofKeys int
 }
 
 func (api *ZkEvmAPIImpl) initializeSemaphores(functionLimits map[string]int) {
@@ -1664,30 +1671,11 @@ func (zkapi *ZkEvmAPIImpl) GetProof(ctx context.Context, address common.Address,
 		return nil, fmt.Errorf("block number is in the future latest=%d requested=%d", latestBlock, blockNr)
 	}
 
-	batch := membatchwithdb.NewMemoryBatch(tx, api.dirs.Tmp, api.logger)
-	defer batch.Rollback()
-	if err = zkUtils.PopulateMemoryMutationTables(batch); err != nil {
+	tx, retainedRoot, rollback, err := zkapi.smtTxAtBlock(ctx, tx, blockNr, latestBlock)
+	if err != nil {
 		return nil, err
 	}
-
-	if blockNr < latestBlock {
-		if latestBlock-blockNr > uint64(api.MaxGetProofRewindBlockCount) {
-			return nil, fmt.Errorf("requested block is too old, block must be within %d blocks of the head block number (currently %d)", api.MaxGetProofRewindBlockCount, latestBlock)
-		}
-		unwindState := &stagedsync.UnwindState{UnwindPoint: blockNr}
-		stageState := &stagedsync.StageState{BlockNumber: latestBlock}
-
-		interHashStageCfg := zkStages.StageZkInterHashesCfg(nil, true, true, false, api.dirs.Tmp, api._blockReader, nil, api.historyV3(tx), api._agg, nil)
-
-		if err = zkStages.UnwindZkIntermediateHashesStage(unwindState, stageState, batch, interHashStageCfg, ctx, true); err != nil {
-			return nil, fmt.Errorf("unwind intermediate hashes: %w", err)
-		}
-
-		if err != nil {
-			return nil, err
-		}
-		tx = batch
-	}
+	defer rollback()
 
 	reader, err := rpchelper.CreateStateReader(ctx, tx, blockNrOrHash, 0, api.filters, api.stateCache, api.historyV3(tx), "")
 	if err != nil {
@@ -1743,6 +1731,9 @@ func (zkapi *ZkEvmAPIImpl) GetProof(ctx context.Context, address common.Address,
 	}
 
 	smtTrie := smt.NewRoSMT(smtDb.NewRoEriDb(tx))
+	if retainedRoot != nil {
+		smtTrie = smt.NewRoSMTAtRoot(smtDb.NewRoEriDb(tx), retainedRoot)
+	}
 
 	proofs, err := smt.BuildProofs(smtTrie, rl, ctx)
 	if err != nil {
@@ -1824,6 +1815,165 @@ func (zkapi *ZkEvmAPIImpl) GetProof(ctx context.Context, address common.Address,
 	return accProof, nil
 }
 
+// GetMultiProof proves the accounts and the storage slots of each against the state root of the block in a single
+// multiproof, the nodes shared by the paths of the keys are in the proof once
+func (zkapi *ZkEvmAPIImpl) GetMultiProof(ctx context.Context, accountSlots map[common.Address][]common.Hash, blockNrOrHash rpc.BlockNumberOrHash) (*accounts.SMTMultiProofResult, error) {
+	api := zkapi.ethApi
+
+	// every key walks the tree and adds its path to the proof, rejected up front before any rewind
+	keysCount := 0
+	for _, slots := range accountSlots {
+		keysCount += 4 + len(slots)
+	}
+	if keysCount > zkapi.MaxGetMultiProofKeys {
+		return nil, fmt.Errorf("too many keys requested: %d, max %d (an account counts 4 keys and a storage slot 1)", keysCount, zkapi.MaxGetMultiProofKeys)
+	}
+
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if api.historyV3(tx) {
+		return nil, fmt.Errorf("not supported by Erigon3")
+	}
+
+	blockNr, _, _, err := rpchelper.GetBlockNumber_zkevm(blockNrOrHash, tx, api.filters)
+	if err != nil {
+		return nil, err
+	}
+
+	latestBlock, err := rpchelper.GetLatestFinishedBlockNumber(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if latestBlock < blockNr {
+		// shouldn't happen, but check anyway
+		return nil, fmt.Errorf("block number is in the future latest=%d requested=%d", latestBlock, blockNr)
+	}
+
+	header, err := api._blockReader.HeaderByNumber(ctx, tx, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("block %d not found", blockNr)
+	}
+
+	tx, retainedRoot, rollback, err := zkapi.smtTxAtBlock(ctx, tx, blockNr, latestBlock)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback()
+
+	addresses := make([]common.Address, 0, len(accountSlots))
+	for address := range accountSlots {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+	})
+
+	// the balance, nonce, code hash and code length of each account followed by its storage slots
+	keys := make([]smtUtils.NodeKey, 0, keysCount)
+	for _, address := range addresses {
+		keys = append(keys,
+			smtUtils.KeyEthAddrBalance(address.String()),
+			smtUtils.KeyEthAddrNonce(address.String()),
+			smtUtils.KeyContractCode(address.String()),
+			smtUtils.KeyContractLength(address.String()),
+		)
+		addressArrayBig := smtUtils.ScalarToArrayBig(smtUtils.ConvertHexToBigInt(address.String()))
+		for _, k := range accountSlots[address] {
+			keys = append(keys, smtUtils.KeyContractStorage(addressArrayBig, k.String()))
+		}
+	}
+
+	smtTrie := smt.NewRoSMT(smtDb.NewRoEriDb(tx))
+	if retainedRoot != nil {
+		smtTrie = smt.NewRoSMTAtRoot(smtDb.NewRoEriDb(tx), retainedRoot)
+	}
+
+	proof, err := smt.BuildMultiProof(smtTrie, keys, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stateRootNode := smtUtils.ScalarToRoot(new(big.Int).SetBytes(header.Root.Bytes()))
+	values, err := smt.VerifyMultiProof(stateRootNode, proof, keys)
+	if err != nil {
+		return nil, fmt.Errorf("multiproof verification failed: %w", err)
+	}
+
+	result := &accounts.SMTMultiProofResult{
+		StateRoot: header.Root,
+		Accounts:  make([]accounts.SMTMultiProofAccount, 0, len(addresses)),
+		Nodes:     proof.Nodes,
+		Values:    proof.Values,
+	}
+
+	i := 0
+	for _, address := range addresses {
+		account := accounts.SMTMultiProofAccount{
+			Address:    address,
+			Balance:    (*hexutil.Big)(new(big.Int).SetBytes(values[i])),
+			Nonce:      hexutil.Uint64(new(big.Int).SetBytes(values[i+1]).Uint64()),
+			CodeHash:   libcommon.BytesToHash(values[i+2]),
+			CodeLength: hexutil.Uint64(new(big.Int).SetBytes(values[i+3]).Uint64()),
+			Storage:    make([]accounts.SMTMultiProofStorageSlot, 0, len(accountSlots[address])),
+		}
+		i += 4
+		for _, k := range accountSlots[address] {
+			account.Storage = append(account.Storage, accounts.SMTMultiProofStorageSlot{
+				Key:   k,
+				Value: (*hexutil.Big)(new(big.Int).SetBytes(values[i])),
+			})
+			i++
+		}
+		result.Accounts = append(result.Accounts, account)
+	}
+
+	return result, nil
+}
+
+// smtTxAtBlock returns a tx holding the state tree of the block with the root to read the tree at, nil for the last
+// root of the tx. A retained tree is read as is, otherwise the tree is rewound in a memory batch that the returned
+// func rolls back.
+func (zkapi *ZkEvmAPIImpl) smtTxAtBlock(ctx context.Context, tx kv.Tx, blockNr, latestBlock uint64) (kv.Tx, *big.Int, func(), error) {
+	api := zkapi.ethApi
+
+	retainedRoot, err := zkUtils.GetRetainedRoot(ctx, tx, api._blockReader, blockNr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if blockNr >= latestBlock || retainedRoot != nil {
+		return tx, retainedRoot, func() {}, nil
+	}
+
+	if latestBlock-blockNr > uint64(api.MaxGetProofRewindBlockCount) {
+		return nil, nil, nil, fmt.Errorf("requested block is too old, block must be within %d blocks of the head block number (currently %d)", api.MaxGetProofRewindBlockCount, latestBlock)
+	}
+
+	batch := membatchwithdb.NewMemoryBatch(tx, api.dirs.Tmp, api.logger)
+	if err = zkUtils.PopulateMemoryMutationTables(batch); err != nil {
+		batch.Rollback()
+		return nil, nil, nil, err
+	}
+
+	unwindState := &stagedsync.UnwindState{UnwindPoint: blockNr}
+	stageState := &stagedsync.StageState{BlockNumber: latestBlock}
+
+	interHashStageCfg := zkStages.StageZkInterHashesCfg(nil, true, true, false, api.dirs.Tmp, api._blockReader, nil, api.historyV3(tx), api._agg, nil)
+
+	if err = zkStages.UnwindZkIntermediateHashesStage(unwindState, stageState, batch, interHashStageCfg, ctx, true); err != nil {
+		batch.Rollback()
+		return nil, nil, nil, fmt.Errorf("unwind intermediate hashes: %w", err)
+	}
+
+	return batch, nil, batch.Rollback, nil
+}
+
 // ForkId returns the network's current fork ID
 func (api *ZkEvmAPIImpl) GetForkId(ctx context.Context) (hexutil.Uint64, error) {
 	tx, err := api.db.BeginRo(ctx)
@@ -1948,3 +2098,36 @@ func (api *ZkEvmAPIImpl) GetLatestDataStreamBlock(ctx context.Context) (hexutil.
 
 	return hexutil.Uint64(latestBlock), nil
 }
+
+// GetBatchOffChainData returns the off chain data of a validium batch stored by the node, nil if it has none
+func (api *ZkEvmAPIImpl) GetBatchOffChainData(ctx context.Context, rpcBatchNumber rpc.BlockNumber) (*ZkOffChainData, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	batchNumber, _, err := rpchelper.GetBatchNumber(rpcBatchNumber, tx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	hermezDb := hermez_db.NewHermezDbReader(tx)
+	hash, found, err := hermezDb.GetBatchOffChainDataHash(batchNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	data, err := hermezDb.GetOffChainData(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ZkOffChainData{
+		BatchNumber: types.ArgUint64(batchNumber),
+		Hash:        hash,
+		Data:        data,
+	}, nil
+}
@@ -1522,3 +1522,53 @@ func TestGetRollupManagerAddress(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal(result, common.HexToAddress("0x1"))
 }
+
+func TestGetBatchOffChainData(t *testing.T) {
+	assert := assert.New(t)
+
+	//////////////
+	contractBackend := backends.NewTestSimulatedBackendWithConfig(t, gspec.Alloc, gspec.Config, gspec.GasLimit)
+	defer contractBackend.Close()
+	stateCache := kvcache.New(kvcache.DefaultCoherentConfig)
+	contractBackend.Commit()
+	///////////
+
+	db := contractBackend.DB()
+	agg := contractBackend.Agg()
+
+	baseApi := NewBaseApi(nil, stateCache, contractBackend.BlockReader(), agg, false, rpccfg.DefaultEvmCallTimeout, contractBackend.Engine(), datadir.New(t.TempDir()))
+	ethImpl := NewEthAPI(baseApi, db, nil, nil, nil, 5000000, 100_000, 100_000, &ethconfig.Defaults, false, 100, 100, log.New(), 1000)
+	var l1Syncer *syncer.L1Syncer
+	zkEvmImpl := NewZkEvmAPI(ethImpl, db, 100_000, &ethconfig.Defaults, l1Syncer, "", nil)
+	tx, err := db.BeginRw(ctx)
+	assert.NoError(err)
+	hDB := hermez_db.NewHermezDb(tx)
+
+	data := []byte("offchaindata")
+	hash := crypto.Keccak256Hash(data)
+	assert.NoError(hDB.WriteOffChainData(hash, data))
+	assert.NoError(hDB.WriteBatchOffChainDataHash(3, hash))
+	tx.Commit()
+
+	offChainData, err := zkEvmImpl.GetBatchOffChainData(ctx, 3)
+	assert.NoError(err)
+	assert.Equal(&ZkOffChainData{BatchNumber: 3, Hash: hash, Data: data}, offChainData)
+
+	offChainData, err = zkEvmImpl.GetBatchOffChainData(ctx, 4)
+	assert.NoError(err)
+	assert.Nil(offChainData)
+}
+
+func TestGetMultiProofMaxKeys(t *testing.T) {
+	assert := assert.New(t)
+	zkEvmImpl := NewZkEvmAPI(nil, nil, 100_000, &ethconfig.Defaults, nil, "", nil)
+	zkEvmImpl.MaxGetMultiProofKeys = 5
+
+	// the 4 keys of the account and its 2 storage slots, rejected before the db is read
+	accountSlots := map[common.Address][]common.Hash{
+		address: {common.HexToHash("0x1"), common.HexToHash("0x2")},
+	}
+	proof, err := zkEvmImpl.GetMultiProof(context.Background(), accountSlots, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	assert.ErrorContains(err, "too many keys requested: 6, max 5")
+	assert.Nil(proof)
+}
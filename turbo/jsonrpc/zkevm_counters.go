@@ -116,14 +116,36 @@ func (tx *zkevmRPCTransaction) Tx(sr state.StateReader) (types.Transaction, erro
 
 // EstimateGas implements eth_estimateGas. Returns an estimate of how much gas is necessary to allow the transaction to complete. The transaction will not be added to the blockchain.
 func (zkapi *ZkEvmAPIImpl) EstimateCounters(ctx context.Context, rpcTx *zkevmRPCTransaction) (json.RawMessage, error) {
-	api := zkapi.ethApi
-
-	dbtx, err := api.db.BeginRo(ctx)
+	dbtx, err := zkapi.ethApi.db.BeginRo(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer dbtx.Rollback()
 
+	estimate, err := zkapi.ethApi.estimateCounters(ctx, dbtx, rpcTx.Tx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := populateCounters(&estimate.collected, estimate.execResult, estimate.tx.GetGas(), estimate.oocError)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// counterEstimate is the outcome of executing a transaction alone on top of the latest block
+type counterEstimate struct {
+	tx         types.Transaction
+	txCounters *vm.TransactionCounter
+	collected  vm.Counters // the counters of a batch holding only the transaction
+	execResult *core.ExecutionResult
+	oocError   error
+}
+
+// estimateCounters executes the transaction returned by toTx on top of the latest block, regardless of its nonce, and collects its counters
+func (api *APIImpl) estimateCounters(ctx context.Context, dbtx kv.Tx, toTx func(sr state.StateReader) (types.Transaction, error)) (*counterEstimate, error) {
 	chainConfig, err := api.chainConfig(ctx, dbtx)
 	if err != nil {
 		return nil, err
@@ -161,7 +183,7 @@ func (zkapi *ZkEvmAPIImpl) EstimateCounters(ctx context.Context, rpcTx *zkevmRPC
 
 	signer := types.MakeSigner(chainConfig, header.Number.Uint64(), 0)
 
-	tx, err := rpcTx.Tx(stateReader)
+	tx, err := toTx(stateReader)
 	if err != nil {
 		return nil, err
 	}
@@ -187,8 +209,8 @@ func (zkapi *ZkEvmAPIImpl) EstimateCounters(ctx context.Context, rpcTx *zkevmRPC
 
 	smtDepth := smt.GetDepth()
 
-	txCounters := vm.NewTransactionCounter(tx, int(smtDepth), uint16(forkId), zkapi.config.Zk.VirtualCountersSmtReduction, false)
-	batchCounters := vm.NewBatchCounterCollector(int(smtDepth), uint16(forkId), zkapi.config.Zk.VirtualCountersSmtReduction, false, nil)
+	txCounters := vm.NewTransactionCounter(tx, int(smtDepth), uint16(forkId), api.VirtualCountersSmtReduction, false)
+	batchCounters := vm.NewBatchCounterCollector(int(smtDepth), uint16(forkId), api.VirtualCountersSmtReduction, false, nil)
 
 	_, err = batchCounters.AddNewTransactionCounters(txCounters)
 	if err != nil {
@@ -216,12 +238,13 @@ func (zkapi *ZkEvmAPIImpl) EstimateCounters(ctx context.Context, rpcTx *zkevmRPC
 		return nil, err
 	}
 
-	res, err := populateCounters(&collected, execResult, tx.GetGas(), oocError)
-	if err != nil {
-		return nil, err
-	}
-
-	return res, nil
+	return &counterEstimate{
+		tx:         tx,
+		txCounters: txCounters,
+		collected:  collected,
+		execResult: execResult,
+		oocError:   oocError,
+	}, nil
 }
 
 type countersResponse struct {
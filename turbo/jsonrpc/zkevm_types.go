@@ -11,3 +11,9 @@ type ZkExitRoots struct {
 	MainnetExitRoot common.Hash     `json:"mainnetExitRoot"`
 	RollupExitRoot  common.Hash     `json:"rollupExitRoot"`
 }
+
+type ZkOffChainData struct {
+	BatchNumber types.ArgUint64 `json:"batchNumber"`
+	Hash        common.Hash     `json:"hash"`
+	Data        types.ArgBytes  `json:"data"`
+}
@@ -0,0 +1,199 @@
+package da
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/log/v3"
+)
+
+var (
+	ErrDataNotFound   = errors.New("off chain data not found")
+	ErrHashMismatch   = errors.New("off chain data does not match its hash")
+	ErrConflictingDir = errors.New("the off chain data dir can not be set with the data availability urls or the cache dir")
+)
+
+// DABackend fetches the off chain data of validium batches by the hash of the data sequenced on L1, every backend
+// returns ErrHashMismatch rather than data not matching the hash
+type DABackend interface {
+	GetOffChainData(ctx context.Context, hash common.Hash) ([]byte, error)
+}
+
+// Config selects the backends built by NewBackend
+type Config struct {
+	// Urls are the data availability committee members, a single one is queried as is, several are queried in turn
+	// until one returns data matching the hash
+	Urls []string
+	// Dir is a directory of off chain data files named by hash, used instead of the committee, it can not be set
+	// along with Urls or CacheDir
+	Dir string
+	// CacheDir keeps the data fetched from the committee, it is read before querying the committee
+	CacheDir string
+}
+
+// NewBackend builds the backend of the config, nil if it has no source of data
+func NewBackend(cfg Config) (DABackend, error) {
+	var backend DABackend
+	switch {
+	case cfg.Dir != "":
+		if len(cfg.Urls) > 0 || cfg.CacheDir != "" {
+			return nil, ErrConflictingDir
+		}
+		return NewFileBackend(cfg.Dir), nil
+	case len(cfg.Urls) == 1:
+		backend = NewRPCBackend(cfg.Urls[0])
+	case len(cfg.Urls) > 1:
+		members := make([]DABackend, len(cfg.Urls))
+		for i, url := range cfg.Urls {
+			members[i] = NewRPCBackend(url)
+		}
+		backend = NewCommitteeBackend(members)
+	default:
+		return nil, nil
+	}
+
+	if cfg.CacheDir != "" {
+		backend = NewCachedBackend(backend, NewFileBackend(cfg.CacheDir))
+	}
+	return backend, nil
+}
+
+// VerifyOffChainData returns ErrHashMismatch when the keccak256 hash of the data is not the hash it was fetched by
+func VerifyOffChainData(hash common.Hash, data []byte) error {
+	if actual := crypto.Keccak256Hash(data); actual != hash {
+		return fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, hash, actual)
+	}
+	return nil
+}
+
+// FileBackend reads the data from a directory holding one file per hash, for replays and tests. It is also the
+// store of CachedBackend.
+type FileBackend struct {
+	dir string
+}
+
+func NewFileBackend(dir string) *FileBackend {
+	return &FileBackend{dir: dir}
+}
+
+func (b *FileBackend) path(hash common.Hash) string {
+	return filepath.Join(b.dir, hash.Hex())
+}
+
+func (b *FileBackend) GetOffChainData(_ context.Context, hash common.Hash) ([]byte, error) {
+	data, err := os.ReadFile(b.path(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s in %s", ErrDataNotFound, hash, b.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = VerifyOffChainData(hash, data); err != nil {
+		return nil, fmt.Errorf("%s: %w", b.path(hash), err)
+	}
+	return data, nil
+}
+
+// Put writes the data of the hash to the directory
+func (b *FileBackend) Put(hash common.Hash, data []byte) error {
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(b.dir, hash.Hex()+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), b.path(hash))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// CommitteeBackend queries the members of a data availability committee in turn, starting from the last one that
+// answered, until one of them returns data matching the hash
+type CommitteeBackend struct {
+	members []DABackend
+	next    atomic.Uint64
+}
+
+func NewCommitteeBackend(members []DABackend) *CommitteeBackend {
+	return &CommitteeBackend{members: members}
+}
+
+func (b *CommitteeBackend) GetOffChainData(ctx context.Context, hash common.Hash) ([]byte, error) {
+	if len(b.members) == 0 {
+		return nil, fmt.Errorf("%w: %s, no committee members", ErrDataNotFound, hash)
+	}
+
+	start := b.next.Load()
+	var errs []error
+	for i := range b.members {
+		idx := (start + uint64(i)) % uint64(len(b.members))
+		member := b.members[idx]
+
+		data, err := member.GetOffChainData(ctx, hash)
+		if err == nil {
+			// the members are expected to verify the data themselves, a custom one may not
+			err = VerifyOffChainData(hash, data)
+		}
+		if err != nil {
+			log.Debug("[da] Committee member failed to return the off chain data", "member", member, "hash", hash, "err", err)
+			errs = append(errs, fmt.Errorf("member %v: %w", member, err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		b.next.Store(idx)
+		return data, nil
+	}
+
+	return nil, fmt.Errorf("no committee member returned the off chain data of %s: %w", hash, errors.Join(errs...))
+}
+
+// CachedBackend keeps the data fetched from a backend in a FileBackend, and reads it from there on the next requests
+type CachedBackend struct {
+	backend DABackend
+	cache   *FileBackend
+}
+
+func NewCachedBackend(backend DABackend, cache *FileBackend) *CachedBackend {
+	return &CachedBackend{backend: backend, cache: cache}
+}
+
+func (b *CachedBackend) GetOffChainData(ctx context.Context, hash common.Hash) ([]byte, error) {
+	data, err := b.cache.GetOffChainData(ctx, hash)
+	if err == nil {
+		return data, nil
+	}
+	// a corrupted entry is fetched again and overwritten
+	if !errors.Is(err, ErrDataNotFound) {
+		log.Warn("[da] Failed to read the off chain data cache", "hash", hash, "err", err)
+	}
+
+	if data, err = b.backend.GetOffChainData(ctx, hash); err != nil {
+		return nil, err
+	}
+	if err = VerifyOffChainData(hash, data); err != nil {
+		return nil, err
+	}
+	if err := b.cache.Put(hash, data); err != nil {
+		log.Warn("[da] Failed to cache the off chain data", "hash", hash, "err", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,125 @@
+package da
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+type testBackend struct {
+	data  map[common.Hash][]byte
+	calls int
+}
+
+func (b *testBackend) GetOffChainData(_ context.Context, hash common.Hash) ([]byte, error) {
+	b.calls++
+	data, ok := b.data[hash]
+	if !ok {
+		return nil, errors.New("unknown hash")
+	}
+	return data, nil
+}
+
+func TestFileBackend(t *testing.T) {
+	data := []byte("offchaindata")
+	hash := crypto.Keccak256Hash(data)
+	backend := NewFileBackend(filepath.Join(t.TempDir(), "da"))
+
+	_, err := backend.GetOffChainData(context.Background(), hash)
+	require.ErrorIs(t, err, ErrDataNotFound)
+
+	require.NoError(t, backend.Put(hash, data))
+	got, err := backend.GetOffChainData(context.Background(), hash)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	// no temporary file is left behind
+	entries, err := os.ReadDir(backend.dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, hash.Hex(), entries[0].Name())
+
+	require.NoError(t, os.WriteFile(backend.path(hash), []byte("corrupted"), 0644))
+	_, err = backend.GetOffChainData(context.Background(), hash)
+	require.ErrorIs(t, err, ErrHashMismatch)
+}
+
+func TestCommitteeBackend(t *testing.T) {
+	data := []byte("offchaindata")
+	hash := crypto.Keccak256Hash(data)
+
+	missing := &testBackend{}
+	wrong := &testBackend{data: map[common.Hash][]byte{hash: []byte("wrong")}}
+	honest := &testBackend{data: map[common.Hash][]byte{hash: data}}
+	backend := NewCommitteeBackend([]DABackend{missing, wrong, honest})
+
+	got, err := backend.GetOffChainData(context.Background(), hash)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	// the member that answered is queried first next time
+	got, err = backend.GetOffChainData(context.Background(), hash)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+	require.Equal(t, 1, missing.calls)
+	require.Equal(t, 1, wrong.calls)
+	require.Equal(t, 2, honest.calls)
+
+	_, err = NewCommitteeBackend([]DABackend{missing, wrong}).GetOffChainData(context.Background(), hash)
+	require.ErrorIs(t, err, ErrHashMismatch)
+}
+
+func TestCachedBackend(t *testing.T) {
+	data := []byte("offchaindata")
+	hash := crypto.Keccak256Hash(data)
+	wrongHash := common.Hash{1}
+
+	upstream := &testBackend{data: map[common.Hash][]byte{hash: data, wrongHash: []byte("wrong")}}
+	cache := NewFileBackend(t.TempDir())
+	backend := NewCachedBackend(upstream, cache)
+
+	for i := 0; i < 2; i++ {
+		got, err := backend.GetOffChainData(context.Background(), hash)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+	}
+	require.Equal(t, 1, upstream.calls)
+
+	// data not matching its hash is refused and never cached
+	_, err := backend.GetOffChainData(context.Background(), wrongHash)
+	require.ErrorIs(t, err, ErrHashMismatch)
+	_, err = cache.GetOffChainData(context.Background(), wrongHash)
+	require.ErrorIs(t, err, ErrDataNotFound)
+
+	// a corrupted cache entry is fetched again
+	require.NoError(t, os.WriteFile(cache.path(hash), []byte("corrupted"), 0644))
+	got, err := backend.GetOffChainData(context.Background(), hash)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+	require.Equal(t, 3, upstream.calls)
+}
+
+func TestNewBackend(t *testing.T) {
+	newBackend := func(cfg Config) DABackend {
+		backend, err := NewBackend(cfg)
+		require.NoError(t, err)
+		return backend
+	}
+
+	require.Nil(t, newBackend(Config{}))
+	require.IsType(t, &FileBackend{}, newBackend(Config{Dir: t.TempDir()}))
+	require.IsType(t, &RPCBackend{}, newBackend(Config{Urls: []string{"http://localhost"}}))
+	require.IsType(t, &CommitteeBackend{}, newBackend(Config{Urls: []string{"http://a", "http://b"}}))
+	require.IsType(t, &CachedBackend{}, newBackend(Config{Urls: []string{"http://localhost"}, CacheDir: t.TempDir()}))
+
+	_, err := NewBackend(Config{Dir: t.TempDir(), Urls: []string{"http://localhost"}})
+	require.ErrorIs(t, err, ErrConflictingDir)
+	_, err = NewBackend(Config{Dir: t.TempDir(), CacheDir: t.TempDir()})
+	require.ErrorIs(t, err, ErrConflictingDir)
+}
@@ -15,14 +15,41 @@ import (
 const maxAttempts = 10
 const retryDelay = 500 * time.Millisecond
 
-func GetOffChainData(ctx context.Context, url string, hash common.Hash) ([]byte, error) {
+// RPCBackend fetches the data from a data availability committee member with sync_getOffChainData, retrying while
+// the member is rate limiting. The data is checked against the hash, the member is not trusted.
+type RPCBackend struct {
+	url         string
+	maxAttempts int
+	retryDelay  time.Duration
+}
+
+func NewRPCBackend(url string) *RPCBackend {
+	return &RPCBackend{url: url, maxAttempts: maxAttempts, retryDelay: retryDelay}
+}
+
+// WithRetry sets the attempts made and the delay between them while the member answers 429
+func (b *RPCBackend) WithRetry(attempts int, delay time.Duration) *RPCBackend {
+	b.maxAttempts = attempts
+	b.retryDelay = delay
+	return b
+}
+
+func (b *RPCBackend) String() string {
+	return b.url
+}
+
+func (b *RPCBackend) GetOffChainData(ctx context.Context, hash common.Hash) ([]byte, error) {
 	attemp := 0
 
-	for attemp < maxAttempts {
-		response, err := client.JSONRPCCall(url, "sync_getOffChainData", hash)
+	for attemp < b.maxAttempts {
+		response, err := client.JSONRPCCall(b.url, "sync_getOffChainData", hash)
 
 		if httpErr, ok := err.(*client.HTTPError); ok && httpErr.StatusCode == http.StatusTooManyRequests {
-			time.Sleep(retryDelay)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(b.retryDelay):
+			}
 			attemp += 1
 			continue
 		}
@@ -35,8 +62,19 @@ func GetOffChainData(ctx context.Context, url string, hash common.Hash) ([]byte,
 			return nil, fmt.Errorf("%v %v", response.Error.Code, response.Error.Message)
 		}
 
-		return hexutil.Decode(strings.Trim(string(response.Result), "\""))
+		data, err := hexutil.Decode(strings.Trim(string(response.Result), "\""))
+		if err != nil {
+			return nil, err
+		}
+		if err = VerifyOffChainData(hash, data); err != nil {
+			return nil, fmt.Errorf("DA url %s: %w", b.url, err)
+		}
+		return data, nil
 	}
 
-	return nil, fmt.Errorf("max attempts of data fetching reached, attempts: %v, DA url: %s", maxAttempts, url)
+	return nil, fmt.Errorf("max attempts of data fetching reached, attempts: %v, DA url: %s", b.maxAttempts, b.url)
+}
+
+func GetOffChainData(ctx context.Context, url string, hash common.Hash) ([]byte, error) {
+	return NewRPCBackend(url).GetOffChainData(ctx, hash)
 }
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/crypto"
 	"github.com/ledgerwatch/erigon/zkevm/jsonrpc/types"
 	"github.com/stretchr/testify/require"
 )
@@ -25,10 +26,16 @@ func TestClient_GetOffChainData(t *testing.T) {
 	}{
 		{
 			name:   "successfully got offhcain data",
-			hash:   common.BytesToHash([]byte("hash")),
+			hash:   crypto.Keccak256Hash([]byte("offchaindata")),
 			result: fmt.Sprintf(`{"result":"0x%s"}`, hex.EncodeToString([]byte("offchaindata"))),
 			data:   []byte("offchaindata"),
 		},
+		{
+			name:   "offchain data not matching the hash returned by server",
+			hash:   common.BytesToHash([]byte("hash")),
+			result: fmt.Sprintf(`{"result":"0x%s"}`, hex.EncodeToString([]byte("offchaindata"))),
+			err:    "off chain data does not match its hash",
+		},
 		{
 			name:   "error returned by server",
 			hash:   common.BytesToHash([]byte("hash")),
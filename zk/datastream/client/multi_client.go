@@ -0,0 +1,564 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	libcommon "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+	"github.com/ledgerwatch/log/v3"
+)
+
+const (
+	upstreamBackoff    = 10 * time.Second
+	upstreamMaxBackoff = 5 * time.Minute
+)
+
+var (
+	ErrNoUpstream = errors.New("no datastream upstream available")
+	// ErrUpstreamsSplit halts the stream when the upstreams disagree on a block hash without a strict majority
+	ErrUpstreamsSplit = errors.New("datastream upstreams disagree on a block hash without a majority")
+
+	errUpstreamStalled     = errors.New("upstream stalled while other upstreams have newer blocks")
+	errUpstreamDiverged    = errors.New("upstream block hash differs from the other upstreams")
+	errUpstreamInterrupted = errors.New("upstream streaming interrupted")
+)
+
+// UpstreamHealth is the state of an upstream of a MultiStreamClient
+type UpstreamHealth struct {
+	Server      string
+	Active      bool
+	LatestBlock uint64
+	// Progress is the block after which the upstream last started streaming
+	Progress    uint64
+	LastWritten time.Time
+	Failures    uint64
+	Divergences uint64
+	// Until is the end of the backoff of an upstream that failed, stalled or diverged
+	Until time.Time
+}
+
+type upstream struct {
+	client *StreamClient
+	// serializes the use of the connection between the streaming and the cross-check queries
+	mtx sync.Mutex
+
+	latestBlock    atomic.Uint64
+	failures       atomic.Uint64
+	divergences    atomic.Uint64
+	unhealthyUntil atomic.Int64
+}
+
+func (u *upstream) healthy() bool {
+	return time.Now().UnixNano() >= u.unhealthyUntil.Load()
+}
+
+func (u *upstream) markUnhealthy(err error) {
+	failures := u.failures.Add(1)
+	backoff := min(time.Duration(failures)*upstreamBackoff, upstreamMaxBackoff)
+	u.unhealthyUntil.Store(time.Now().Add(backoff).UnixNano())
+	log.Warn("[Datastream client] Upstream marked unhealthy", "server", u.client.server, "backoff", backoff, "err", err)
+}
+
+// reconnect drops the connection of the upstream, it might have been closed while streaming, and opens a new one
+func (u *upstream) reconnect() error {
+	c := u.client
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	c.setStreaming(false)
+	if err := c.Start(); err != nil {
+		c.lastError = err
+		return err
+	}
+	c.started = true
+	c.lastError = nil
+	return nil
+}
+
+// ensureConnected reconnects the upstream after an error, or when the server is still streaming to the connection
+// since the last query or read, as the entries streamed meanwhile would be read as the reply of the next command
+func (u *upstream) ensureConnected() error {
+	c := u.client
+	c.mtxStreaming.Lock()
+	streaming := c.streaming
+	c.mtxStreaming.Unlock()
+	if c.lastError != nil || c.conn == nil || streaming {
+		return u.reconnect()
+	}
+	return nil
+}
+
+func (u *upstream) getLatestL2Block() (*types.FullL2Block, error) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	if err := u.ensureConnected(); err != nil {
+		return nil, err
+	}
+	block, err := u.client.GetLatestL2Block()
+	if err != nil {
+		return nil, err
+	}
+	u.latestBlock.Store(block.L2BlockNumber)
+	return block, nil
+}
+
+func (u *upstream) getL2BlockByNumber(blockNum uint64) (*types.FullL2Block, error) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	if err := u.ensureConnected(); err != nil {
+		return nil, err
+	}
+	return u.client.GetL2BlockByNumber(blockNum)
+}
+
+// MultiStreamClient reads the stream from the healthiest of several datastream upstreams. It fails over to another
+// upstream when the one streaming returns an error, stalls while the others have newer blocks, or serves a block
+// whose hash differs from the one served by a strict majority of the upstreams.
+type MultiStreamClient struct {
+	ctx       context.Context
+	upstreams []*upstream
+	active    atomic.Pointer[upstream]
+
+	// stallTimeout is how long the streaming upstream can go without an entry before failing over, 0 never fails over
+	stallTimeout time.Duration
+	// crossCheckInterval is the number of blocks between the checks of the hashes with the other upstreams, 0 disables
+	crossCheckInterval uint64
+	// lastChecked is the last block whose hash another upstream agreed on
+	lastChecked atomic.Uint64
+
+	entryChan            chan interface{}
+	progress             atomic.Uint64
+	lastWrittenTime      atomic.Int64
+	stopReadingToChannel atomic.Bool
+	started              bool
+}
+
+// NewMultiStreamClient creates a client reading from the upstream clients, in order of preference
+func NewMultiStreamClient(ctx context.Context, clients []*StreamClient, stallTimeout time.Duration, crossCheckInterval uint64) *MultiStreamClient {
+	m := &MultiStreamClient{
+		ctx:                ctx,
+		upstreams:          make([]*upstream, len(clients)),
+		stallTimeout:       stallTimeout,
+		crossCheckInterval: crossCheckInterval,
+		entryChan:          make(chan interface{}, entryChannelSize),
+	}
+	for i, c := range clients {
+		m.upstreams[i] = &upstream{client: c}
+	}
+	return m
+}
+
+func (m *MultiStreamClient) GetEntryChan() *chan interface{} {
+	return &m.entryChan
+}
+
+func (m *MultiStreamClient) GetProgressAtomic() *atomic.Uint64 {
+	return &m.progress
+}
+
+func (m *MultiStreamClient) GetLastWrittenTimeAtomic() *atomic.Int64 {
+	return &m.lastWrittenTime
+}
+
+// Health reports the state of every upstream, in order of preference
+func (m *MultiStreamClient) Health() []UpstreamHealth {
+	active := m.active.Load()
+	health := make([]UpstreamHealth, len(m.upstreams))
+	for i, u := range m.upstreams {
+		health[i] = UpstreamHealth{
+			Server:      u.client.server,
+			Active:      u == active,
+			LatestBlock: u.latestBlock.Load(),
+			Progress:    u.client.GetProgressAtomic().Load(),
+			LastWritten: time.Unix(0, u.client.GetLastWrittenTimeAtomic().Load()),
+			Failures:    u.failures.Load(),
+			Divergences: u.divergences.Load(),
+			Until:       time.Unix(0, u.unhealthyUntil.Load()),
+		}
+	}
+	return health
+}
+
+// Start connects to every upstream, it fails only if none can be reached
+func (m *MultiStreamClient) Start() error {
+	var errs []error
+	for _, u := range m.upstreams {
+		if err := u.reconnect(); err != nil {
+			u.markUnhealthy(err)
+			errs = append(errs, fmt.Errorf("%s: %w", u.client.server, err))
+		}
+	}
+	if len(errs) == len(m.upstreams) {
+		return fmt.Errorf("%w: %w", ErrNoUpstream, errors.Join(errs...))
+	}
+	m.started = true
+	return nil
+}
+
+func (m *MultiStreamClient) Stop() error {
+	var errs []error
+	for _, u := range m.upstreams {
+		if err := u.client.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", u.client.server, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HandleStart connects on the first call, the upstreams that failed are reconnected when they are selected again
+func (m *MultiStreamClient) HandleStart() error {
+	if !m.started {
+		log.Info("[Datastream client] Starting datastream multi upstream client from cold", "upstreams", len(m.upstreams))
+		return m.Start()
+	}
+	return nil
+}
+
+// GetL2BlockByNumber queries the block from the active upstream, or the first healthy one
+func (m *MultiStreamClient) GetL2BlockByNumber(blockNum uint64) (*types.FullL2Block, error) {
+	u := m.active.Load()
+	if u == nil || !u.healthy() {
+		var err error
+		if u, err = m.selectUpstream(); err != nil {
+			return nil, err
+		}
+	}
+	block, err := u.getL2BlockByNumber(blockNum)
+	if err != nil {
+		u.markUnhealthy(err)
+		return nil, err
+	}
+	return block, nil
+}
+
+// GetLatestL2Block returns the newest latest block of the healthy upstreams
+func (m *MultiStreamClient) GetLatestL2Block() (*types.FullL2Block, error) {
+	var latest *types.FullL2Block
+	var errs []error
+	for _, u := range m.upstreams {
+		if !u.healthy() {
+			continue
+		}
+		block, err := u.getLatestL2Block()
+		if err != nil {
+			u.markUnhealthy(err)
+			errs = append(errs, fmt.Errorf("%s: %w", u.client.server, err))
+			continue
+		}
+		if latest == nil || block.L2BlockNumber > latest.L2BlockNumber {
+			latest = block
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoUpstream, errors.Join(errs...))
+	}
+	return latest, nil
+}
+
+// selectUpstream refreshes the latest block of the healthy upstreams and makes the one with the newest block the
+// active one, keeping the current one or the preferred one on a tie. When every upstream is backing off, the one
+// whose backoff ends first is tried.
+func (m *MultiStreamClient) selectUpstream() (*upstream, error) {
+	candidates := make([]*upstream, 0, len(m.upstreams))
+	for _, u := range m.upstreams {
+		if u.healthy() {
+			candidates = append(candidates, u)
+		}
+	}
+	if len(candidates) == 0 {
+		var first *upstream
+		for _, u := range m.upstreams {
+			if first == nil || u.unhealthyUntil.Load() < first.unhealthyUntil.Load() {
+				first = u
+			}
+		}
+		candidates = append(candidates, first)
+	}
+
+	current := m.active.Load()
+	var best *upstream
+	var errs []error
+	for _, u := range candidates {
+		if _, err := u.getLatestL2Block(); err != nil {
+			u.markUnhealthy(err)
+			errs = append(errs, fmt.Errorf("%s: %w", u.client.server, err))
+			continue
+		}
+		if best == nil || u.latestBlock.Load() > best.latestBlock.Load() ||
+			(u == current && u.latestBlock.Load() == best.latestBlock.Load()) {
+			best = u
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoUpstream, errors.Join(errs...))
+	}
+
+	if best != current {
+		log.Info("[Datastream client] Streaming from upstream", "server", best.client.server, "latestBlock", best.latestBlock.Load())
+		m.active.Store(best)
+	}
+	return best, nil
+}
+
+// close old entry chan and read all elements before opening a new one
+func (m *MultiStreamClient) RenewEntryChannel() {
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Warn("[datastream_client] Channel is already closed")
+			}
+		}()
+		close(m.entryChan)
+	}()
+	for range m.entryChan {
+	}
+	m.entryChan = make(chan interface{}, entryChannelSize)
+}
+
+func (m *MultiStreamClient) StopReadingToChannel() {
+	m.stopReadingToChannel.Store(true)
+	if u := m.active.Load(); u != nil {
+		u.client.StopReadingToChannel()
+	}
+}
+
+// ReadAllEntriesToChannel reads the entries to the end of the stream of the active upstream, failing over to
+// another upstream until one of them reaches its end
+func (m *MultiStreamClient) ReadAllEntriesToChannel() error {
+	m.stopReadingToChannel.Store(false)
+	// the first block not sent yet, the entries in the channel are not processed yet. A progress of 0 streams from
+	// the start of the stream, as StreamClient does.
+	next := m.progress.Load()
+	if next > 0 {
+		next++
+	}
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return fmt.Errorf("context done - stopping")
+		default:
+		}
+
+		u, err := m.selectUpstream()
+		if err != nil {
+			return err
+		}
+
+		if next, err = m.readFromUpstream(u, next); err == nil || m.stopReadingToChannel.Load() {
+			return nil
+		}
+		if errors.Is(err, ErrUpstreamsSplit) {
+			return err
+		}
+		u.markUnhealthy(err)
+		log.Warn("[Datastream client] Failing over to another upstream", "server", u.client.server, "nextBlock", next, "err", err)
+	}
+}
+
+// readFromUpstream streams from the block next on, until the end of the stream of the upstream. It returns the
+// first block not sent to the channel, or when the upstream diverged the block after the last one checked, so the
+// blocks it sent since are sent again by the next upstream and the stage unwinds them.
+func (m *MultiStreamClient) readFromUpstream(u *upstream, next uint64) (uint64, error) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	c := u.client
+	if err := u.ensureConnected(); err != nil {
+		return next, err
+	}
+	c.progress.Store(max(next, 1) - 1)
+	c.RenewEntryChannel()
+	entryChan := c.entryChan
+
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- c.ReadAllEntriesToChannel()
+	}()
+	readDone := false
+	// interrupts the reading and waits for it, so the connection can be reopened
+	stop := func() {
+		if !readDone {
+			c.StopReadingToChannel()
+			if c.conn != nil {
+				c.conn.Close()
+			}
+			<-readErr
+		}
+		c.lastError = errUpstreamInterrupted
+	}
+
+	var stallCheck <-chan time.Time
+	if m.stallTimeout > 0 && len(m.upstreams) > 1 {
+		ticker := time.NewTicker(max(m.stallTimeout/4, minimumCheckTimeout))
+		defer ticker.Stop()
+		stallCheck = ticker.C
+	}
+	readStart := time.Now().UnixNano()
+
+	for {
+		if readDone && len(entryChan) == 0 {
+			// stopped before the end of the stream
+			return next, nil
+		}
+		if m.stopReadingToChannel.Load() {
+			c.StopReadingToChannel()
+		}
+
+		select {
+		case <-m.ctx.Done():
+			stop()
+			return next, m.ctx.Err()
+		case err := <-readErrChan(readErr, readDone):
+			if err != nil {
+				return next, err
+			}
+			readDone = true
+		case <-stallCheck:
+			lastWritten := max(c.lastWrittenTime.Load(), readStart)
+			if time.Since(time.Unix(0, lastWritten)) > m.stallTimeout && m.othersAhead(u, next) {
+				stop()
+				return next, errUpstreamStalled
+			}
+		case entry := <-entryChan:
+			if block, isBlock := entry.(*types.FullL2Block); isBlock {
+				if block.L2BlockNumber < next {
+					// already sent before the failover
+					continue
+				}
+				if err := m.crossCheck(u, block); err != nil {
+					stop()
+					if errors.Is(err, errUpstreamDiverged) {
+						next = m.resendFrom(block.L2BlockNumber)
+					}
+					return next, err
+				}
+				next = block.L2BlockNumber + 1
+			}
+
+			m.lastWrittenTime.Store(time.Now().UnixNano())
+			select {
+			case m.entryChan <- entry:
+			case <-m.ctx.Done():
+				stop()
+				return next, m.ctx.Err()
+			}
+
+			if entry == nil {
+				// the end of the stream of the upstream
+				if !readDone {
+					if err := <-readErr; err != nil {
+						return next, err
+					}
+				}
+				return next, nil
+			}
+		}
+	}
+}
+
+// readErrChan disables the select case of the reading result once it was received
+func readErrChan(readErr chan error, readDone bool) chan error {
+	if readDone {
+		return nil
+	}
+	return readErr
+}
+
+// othersAhead is true if another healthy upstream has the block next
+func (m *MultiStreamClient) othersAhead(active *upstream, next uint64) bool {
+	for _, u := range m.upstreams {
+		if u == active || !u.healthy() {
+			continue
+		}
+		if _, err := u.getLatestL2Block(); err != nil {
+			u.markUnhealthy(err)
+			continue
+		}
+		if u.latestBlock.Load() >= next {
+			return true
+		}
+	}
+	return false
+}
+
+// crossCheck compares the hash of the block with the other healthy upstreams having it, every crossCheckInterval
+// blocks. The upstreams compared, the active one included, vote for the hash they serve and the ones not serving the
+// hash of a strict majority diverged. Without a strict majority, e.g. with one upstream against another, it can not be
+// told which one diverged so the stream halts instead of failing over.
+func (m *MultiStreamClient) crossCheck(active *upstream, block *types.FullL2Block) error {
+	if m.crossCheckInterval == 0 || len(m.upstreams) < 2 || block.L2BlockNumber%m.crossCheckInterval != 0 {
+		return nil
+	}
+
+	votes := map[libcommon.Hash][]*upstream{block.L2Blockhash: {active}}
+	voters := 1
+	for _, u := range m.upstreams {
+		if u == active || !u.healthy() || u.latestBlock.Load() < block.L2BlockNumber {
+			continue
+		}
+		other, err := u.getL2BlockByNumber(block.L2BlockNumber)
+		if err != nil {
+			u.markUnhealthy(err)
+			continue
+		}
+		votes[other.L2Blockhash] = append(votes[other.L2Blockhash], u)
+		voters++
+	}
+	if len(votes) == 1 {
+		if voters > 1 {
+			m.lastChecked.Store(block.L2BlockNumber)
+		}
+		return nil
+	}
+
+	var majority libcommon.Hash
+	found := false
+	for hash, us := range votes {
+		if 2*len(us) > voters {
+			majority, found = hash, true
+		}
+	}
+	if !found {
+		for _, us := range votes {
+			for _, u := range us {
+				u.divergences.Add(1)
+			}
+		}
+		log.Error("[Datastream client] Upstreams disagree on a block hash without a majority, halting the stream", "block", block.L2BlockNumber, "hashes", len(votes), "upstreams", voters)
+		return fmt.Errorf("%w: block %d", ErrUpstreamsSplit, block.L2BlockNumber)
+	}
+
+	for hash, us := range votes {
+		if hash == majority {
+			continue
+		}
+		for _, u := range us {
+			u.divergences.Add(1)
+			if u != active {
+				u.markUnhealthy(fmt.Errorf("%w: block %d", errUpstreamDiverged, block.L2BlockNumber))
+			}
+		}
+	}
+	if majority != block.L2Blockhash {
+		return fmt.Errorf("%w: block %d hash %s", errUpstreamDiverged, block.L2BlockNumber, block.L2Blockhash)
+	}
+	m.lastChecked.Store(block.L2BlockNumber)
+	return nil
+}
+
+// resendFrom returns the block after the last one checked before the diverged block. Without a check since the
+// start, the previous check point is taken as checked by the last run.
+func (m *MultiStreamClient) resendFrom(diverged uint64) uint64 {
+	checked := m.lastChecked.Load()
+	if checked == 0 || checked >= diverged {
+		checked = max(diverged, m.crossCheckInterval) - m.crossCheckInterval
+	}
+	return checked + 1
+}
@@ -0,0 +1,199 @@
+package client_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	"github.com/ledgerwatch/erigon/zk/datastream/proto/github.com/0xPolygonHermez/zkevm-node/state/datastream"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+	"github.com/stretchr/testify/require"
+)
+
+type marshaller interface {
+	Marshal() ([]byte, error)
+	Type() types.EntryType
+}
+
+// startTestUpstream serves a stream with one block per batch, from the block 0 to the lastBlock. The hash of a
+// block is its number, except for the blocks in forks.
+func startTestUpstream(t *testing.T, lastBlock uint64, forks map[uint64]byte) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	require.NoError(t, ln.Close())
+
+	srv, err := datastreamer.NewServer(uint16(port), 3, 1, datastreamer.StreamType(1), filepath.Join(t.TempDir(), "data-stream"), time.Second, time.Minute, time.Minute, nil)
+	require.NoError(t, err)
+	require.NoError(t, srv.Start())
+
+	require.NoError(t, srv.StartAtomicOp())
+	for blockNo := uint64(0); blockNo <= lastBlock; blockNo++ {
+		hash := common.Hash{byte(blockNo)}
+		if fork, ok := forks[blockNo]; ok {
+			hash = common.Hash{fork}
+		}
+		for _, entry := range []marshaller{
+			types.NewBookmarkProto(blockNo, datastream.BookmarkType_BOOKMARK_TYPE_BATCH),
+			&types.BatchStartProto{BatchStart: &datastream.BatchStart{Number: blockNo, ForkId: 9, ChainId: 1}},
+			types.NewBookmarkProto(blockNo, datastream.BookmarkType_BOOKMARK_TYPE_L2_BLOCK),
+			&types.L2BlockProto{L2Block: &datastream.L2Block{Number: blockNo, BatchNumber: blockNo, Hash: hash.Bytes()}},
+			&types.L2BlockEndProto{Number: blockNo},
+			&types.BatchEndProto{BatchEnd: &datastream.BatchEnd{Number: blockNo}},
+		} {
+			data, err := entry.Marshal()
+			require.NoError(t, err)
+			if entry.Type() == types.BookmarkEntryType {
+				_, err = srv.AddStreamBookmark(data)
+			} else {
+				_, err = srv.AddStreamEntry(datastreamer.EntryType(entry.Type()), data)
+			}
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, srv.CommitAtomicOp())
+
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
+
+func newTestMultiClient(t *testing.T, crossCheckInterval uint64, servers ...string) *client.MultiStreamClient {
+	clients := make([]*client.StreamClient, len(servers))
+	for i, server := range servers {
+		clients[i] = client.NewClient(context.Background(), server, false, 3, time.Second, 0)
+	}
+	m := client.NewMultiStreamClient(context.Background(), clients, time.Minute, crossCheckInterval)
+	require.NoError(t, m.HandleStart())
+	t.Cleanup(func() { m.Stop() })
+	return m
+}
+
+// readTestBlocks reads the stream to its end and returns the hashes of the blocks read by number
+func readTestBlocks(t *testing.T, m *client.MultiStreamClient) map[uint64]common.Hash {
+	m.RenewEntryChannel()
+	require.NoError(t, m.ReadAllEntriesToChannel())
+
+	blocks := map[uint64]common.Hash{}
+	for {
+		select {
+		case entry := <-*m.GetEntryChan():
+			if entry == nil {
+				return blocks
+			}
+			if block, ok := entry.(*types.FullL2Block); ok {
+				blocks[block.L2BlockNumber] = block.L2Blockhash
+			}
+		default:
+			t.Fatal("the stream did not end")
+		}
+	}
+}
+
+func TestMultiStreamClientFailover(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachable := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	behind := startTestUpstream(t, 3, nil)
+	ahead := startTestUpstream(t, 5, nil)
+	m := newTestMultiClient(t, 0, unreachable, behind, ahead)
+
+	latest, err := m.GetLatestL2Block()
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), latest.L2BlockNumber)
+
+	// the upstream with the newest block is streamed from
+	blocks := readTestBlocks(t, m)
+	require.Len(t, blocks, 6)
+	require.Equal(t, common.Hash{5}, blocks[5])
+
+	health := m.Health()
+	require.Equal(t, uint64(1), health[0].Failures)
+	require.False(t, health[1].Active)
+	require.True(t, health[2].Active)
+	require.Equal(t, uint64(5), health[2].LatestBlock)
+
+	// resumes after the progress
+	m.GetProgressAtomic().Store(3)
+	blocks = readTestBlocks(t, m)
+	require.Len(t, blocks, 2)
+	require.Contains(t, blocks, uint64(4))
+	require.Contains(t, blocks, uint64(5))
+}
+
+func TestMultiStreamClientDivergence(t *testing.T) {
+	diverged := startTestUpstream(t, 5, map[uint64]byte{4: 0xff, 5: 0xfe})
+	first := startTestUpstream(t, 5, nil)
+	second := startTestUpstream(t, 5, nil)
+	m := newTestMultiClient(t, 2, diverged, first, second)
+
+	// the diverged upstream is preferred, its block 4 is checked against the others and refused
+	blocks := readTestBlocks(t, m)
+	require.Len(t, blocks, 6)
+	for blockNo, hash := range blocks {
+		require.Equal(t, common.Hash{byte(blockNo)}, hash)
+	}
+
+	health := m.Health()
+	require.Equal(t, uint64(1), health[0].Divergences)
+	require.False(t, health[0].Active)
+	require.True(t, health[1].Active)
+}
+
+func TestMultiStreamClientDivergenceResendsCheckedBlocks(t *testing.T) {
+	diverged := startTestUpstream(t, 5, map[uint64]byte{3: 0xff, 4: 0xfe, 5: 0xfd})
+	first := startTestUpstream(t, 5, nil)
+	second := startTestUpstream(t, 5, nil)
+	m := newTestMultiClient(t, 2, diverged, first, second)
+
+	// the block 3 of the diverged upstream is sent before the check of the block 4 fails, the blocks after the
+	// block 2 checked last are sent again by the next upstream
+	m.RenewEntryChannel()
+	require.NoError(t, m.ReadAllEntriesToChannel())
+
+	var blocks []uint64
+	hashes := map[uint64]common.Hash{}
+	for len(*m.GetEntryChan()) > 0 {
+		if block, ok := (<-*m.GetEntryChan()).(*types.FullL2Block); ok {
+			blocks = append(blocks, block.L2BlockNumber)
+			hashes[block.L2BlockNumber] = block.L2Blockhash
+		}
+	}
+	require.Equal(t, []uint64{0, 1, 2, 3, 3, 4, 5}, blocks)
+	for blockNo, hash := range hashes {
+		require.Equal(t, common.Hash{byte(blockNo)}, hash)
+	}
+}
+
+func TestMultiStreamClientDivergenceWithoutMajority(t *testing.T) {
+	healthy := startTestUpstream(t, 5, nil)
+	faulty := startTestUpstream(t, 5, map[uint64]byte{4: 0xff, 5: 0xfe})
+	m := newTestMultiClient(t, 2, healthy, faulty)
+
+	// one upstream against the other can not tell which one diverged, the stream halts before the block 4
+	// instead of failing over to the faulty upstream
+	m.RenewEntryChannel()
+	require.ErrorIs(t, m.ReadAllEntriesToChannel(), client.ErrUpstreamsSplit)
+
+	var blocks []uint64
+	for len(*m.GetEntryChan()) > 0 {
+		if block, ok := (<-*m.GetEntryChan()).(*types.FullL2Block); ok {
+			require.Equal(t, common.Hash{byte(block.L2BlockNumber)}, block.L2Blockhash)
+			blocks = append(blocks, block.L2BlockNumber)
+		}
+	}
+	require.Equal(t, []uint64{0, 1, 2, 3}, blocks)
+
+	health := m.Health()
+	require.True(t, health[0].Active)
+	require.Zero(t, health[0].Failures)
+	require.Zero(t, health[1].Failures)
+	require.Equal(t, uint64(1), health[0].Divergences)
+	require.Equal(t, uint64(1), health[1].Divergences)
+}
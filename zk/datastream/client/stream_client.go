@@ -71,8 +71,11 @@ type StreamClient struct {
 	lastError error
 	started   bool
 
-	useTLS    bool
-	tlsConfig *tls.Config
+	useTLS      bool
+	tlsConfig   *tls.Config
+	tlsCertFile string
+	tlsKeyFile  string
+	tlsCAFile   string
 }
 
 const (
@@ -301,6 +304,9 @@ func (c *StreamClient) GetProgressAtomic() *atomic.Uint64 {
 func (c *StreamClient) Start() error {
 	var err error
 	if c.useTLS {
+		if err = c.loadTLSFiles(); err != nil {
+			return fmt.Errorf("tls config: %w", err)
+		}
 		c.conn, err = tls.Dial("tcp", c.server, c.tlsConfig)
 	} else {
 		c.conn, err = net.Dial("tcp", c.server)
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// LoadCertPool reads the PEM encoded certificates of a CA file into a pool
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", caFile)
+	}
+	return pool, nil
+}
+
+// SetTLSFiles configures the TLS connection of the client. When certFile and keyFile are set the client presents
+// that certificate to servers requiring mutual TLS, when caFile is set the server certificate is verified against
+// that CA instead of the system roots. The files are loaded when the client connects, it has no effect if the
+// client does not use TLS.
+func (c *StreamClient) SetTLSFiles(certFile, keyFile, caFile string) {
+	c.tlsCertFile, c.tlsKeyFile, c.tlsCAFile = certFile, keyFile, caFile
+}
+
+// loadTLSFiles adds the files set with SetTLSFiles to the TLS config of the client
+func (c *StreamClient) loadTLSFiles() error {
+	if c.tlsCertFile != "" || c.tlsKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.tlsCertFile, c.tlsKeyFile)
+		if err != nil {
+			return fmt.Errorf("load client certificate: %w", err)
+		}
+		c.tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if c.tlsCAFile != "" {
+		pool, err := LoadCertPool(c.tlsCAFile)
+		if err != nil {
+			return err
+		}
+		c.tlsConfig.RootCAs = pool
+	}
+	return nil
+}
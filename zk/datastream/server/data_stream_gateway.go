@@ -0,0 +1,410 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	libcommon "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutility"
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	"github.com/ledgerwatch/erigon/zk/datastream/proto/github.com/0xPolygonHermez/zkevm-node/state/datastream"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+	"github.com/ledgerwatch/log/v3"
+)
+
+const (
+	GatewayBatchStart = "batchStart"
+	GatewayBatchEnd   = "batchEnd"
+	GatewayL2Block    = "l2Block"
+	GatewayGerUpdate  = "gerUpdate"
+	GatewayError      = "error"
+
+	gatewayWriteTimeout        = 10 * time.Second
+	defaultGatewayPollInterval = 500 * time.Millisecond
+)
+
+var (
+	errGatewayEndOfStream = errors.New("end of the committed stream")
+	errGatewayUnwound     = errors.New("the stream was unwound, resume from a batch or block")
+)
+
+// GatewayEntry is a stream entry as sent by the gateway, one per websocket message
+type GatewayEntry struct {
+	// Entry is the number of the last stream entry read for this one, a client resumes with ?entry=Entry+1.
+	// It is not set on the error sent before the gateway closes the connection.
+	Entry uint64      `json:"entry"`
+	Type  string      `json:"type"`
+	Data  interface{} `json:"data,omitempty"`
+	Error string      `json:"error,omitempty"`
+	// Archived is set on the entries read from an archived segment, their entry numbers are those of the stream
+	// the segment was archived from and can not be used to resume
+	Archived bool `json:"archived,omitempty"`
+}
+
+type GatewayBatchStartJson struct {
+	Number    uint64 `json:"number"`
+	BatchType uint32 `json:"batchType"`
+	ForkId    uint64 `json:"forkId"`
+	ChainId   uint64 `json:"chainId"`
+}
+
+type GatewayBatchEndJson struct {
+	Number uint64 `json:"number"`
+}
+
+type GatewayTransactionJson struct {
+	Index                       uint64           `json:"index"`
+	IsValid                     bool             `json:"isValid"`
+	Encoded                     hexutility.Bytes `json:"encoded"`
+	EffectiveGasPricePercentage uint8            `json:"effectiveGasPricePercentage"`
+	IntermediateStateRoot       libcommon.Hash   `json:"intermediateStateRoot"`
+}
+
+type GatewayL2BlockJson struct {
+	Number          uint64                   `json:"number"`
+	BatchNumber     uint64                   `json:"batchNumber"`
+	Timestamp       int64                    `json:"timestamp"`
+	DeltaTimestamp  uint32                   `json:"deltaTimestamp"`
+	L1InfoTreeIndex uint32                   `json:"l1InfoTreeIndex"`
+	GlobalExitRoot  libcommon.Hash           `json:"globalExitRoot"`
+	Coinbase        libcommon.Address        `json:"coinbase"`
+	L1BlockHash     libcommon.Hash           `json:"l1BlockHash"`
+	Hash            libcommon.Hash           `json:"hash"`
+	StateRoot       libcommon.Hash           `json:"stateRoot"`
+	BlockGasLimit   uint64                   `json:"blockGasLimit"`
+	BlockInfoRoot   libcommon.Hash           `json:"blockInfoRoot"`
+	Transactions    []GatewayTransactionJson `json:"transactions"`
+}
+
+type GatewayGerUpdateJson struct {
+	BatchNumber    uint64            `json:"batchNumber"`
+	Timestamp      uint64            `json:"timestamp"`
+	GlobalExitRoot libcommon.Hash    `json:"globalExitRoot"`
+	Coinbase       libcommon.Address `json:"coinbase"`
+	ForkId         uint16            `json:"forkId"`
+	ChainId        uint32            `json:"chainId"`
+	StateRoot      libcommon.Hash    `json:"stateRoot"`
+}
+
+// DataStreamGateway re-serves the data stream as JSON entries over websocket, for consumers that can not speak the
+// binary datastreamer protocol. The start of the stream is chosen with one of the query parameters:
+//   - batch=N starts at the batch N
+//   - block=N starts at the L2 block N
+//   - entry=N resumes at the stream entry N, the entry of the last message received plus one
+//
+// Without any the stream is served from the start. Once the end of the stream is reached new entries are sent as
+// they are committed.
+type DataStreamGateway struct {
+	stream       StreamServer
+	archive      *SegmentStore
+	pollInterval time.Duration
+	upgrader     websocket.Upgrader
+}
+
+// NewDataStreamGateway creates a gateway serving the stream. The batches and blocks no longer in the stream are
+// served from the archive when it is set.
+func NewDataStreamGateway(stream StreamServer, archive *SegmentStore, pollInterval time.Duration) *DataStreamGateway {
+	if pollInterval <= 0 {
+		pollInterval = defaultGatewayPollInterval
+	}
+	return &DataStreamGateway{
+		stream:       stream,
+		archive:      archive,
+		pollInterval: pollInterval,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			// the stream is public data, the access is restricted by the allowlists of the listener
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+func (g *DataStreamGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	from, archived, err := g.startEntry(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		if archived != nil {
+			archived.Close()
+		}
+		log.Debug("[dataStream gateway] upgrade failed", "remote", r.RemoteAddr, "err", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// the client is not expected to send anything, reading only handles the close and ping messages
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if archived != nil {
+		from, err = g.serveArchive(ctx, conn, archived)
+	}
+	if err == nil {
+		err = g.serve(ctx, conn, from)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Debug("[dataStream gateway] stopped serving the stream", "remote", r.RemoteAddr, "err", err)
+		conn.SetWriteDeadline(time.Now().Add(gatewayWriteTimeout))
+		conn.WriteJSON(&GatewayEntry{Type: GatewayError, Error: err.Error()})
+	}
+}
+
+// startEntry is the number of the first stream entry to serve to the request, or the archived segment to serve
+// first when the requested bookmark is no longer in the stream
+func (g *DataStreamGateway) startEntry(r *http.Request) (uint64, *SegmentIterator, error) {
+	query := r.URL.Query()
+	parse := func(name string) (uint64, bool, error) {
+		value := query.Get(name)
+		if value == "" {
+			return 0, false, nil
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid %s %q", name, value)
+		}
+		return n, true, nil
+	}
+
+	if entry, ok, err := parse("entry"); err != nil || ok {
+		return entry, nil, err
+	}
+
+	for _, bookmark := range []struct {
+		name         string
+		bookmarkType datastream.BookmarkType
+	}{
+		{"batch", datastream.BookmarkType_BOOKMARK_TYPE_BATCH},
+		{"block", datastream.BookmarkType_BOOKMARK_TYPE_L2_BLOCK},
+	} {
+		n, ok, err := parse(bookmark.name)
+		if err != nil {
+			return 0, nil, err
+		}
+		if !ok {
+			continue
+		}
+		proto := types.NewBookmarkProto(n, bookmark.bookmarkType)
+		marshalled, err := proto.Marshal()
+		if err != nil {
+			return 0, nil, err
+		}
+		entry, err := g.stream.GetBookmark(marshalled)
+		if err == nil {
+			return entry, nil, nil
+		}
+		if g.archive != nil {
+			archived, err := g.archive.IteratorFromBookmark(proto)
+			if err == nil {
+				return 0, archived, nil
+			}
+			if !errors.Is(err, ErrSegmentNotFound) {
+				log.Warn("[dataStream gateway] failed to open the archive", "bookmark", proto, "err", err)
+			}
+		}
+		return 0, nil, fmt.Errorf("%s %d not found in the stream", bookmark.name, n)
+	}
+
+	return 0, nil, nil
+}
+
+// serveArchive writes the entries of the archived segments from the iterator on, until the batch following the
+// last segment served is found in the stream. It returns the stream entry of that batch. The iterators are closed
+// by serveArchive.
+func (g *DataStreamGateway) serveArchive(ctx context.Context, conn *websocket.Conn, iterator *SegmentIterator) (uint64, error) {
+	defer func() { iterator.Close() }()
+	for {
+		for {
+			parsed, entryNum, err := client.ReadParsedProto(iterator)
+			if err != nil {
+				return 0, err
+			}
+			if parsed == nil {
+				break
+			}
+
+			entry := newGatewayEntry(parsed, entryNum)
+			if entry == nil {
+				continue
+			}
+			entry.Archived = true
+			conn.SetWriteDeadline(time.Now().Add(gatewayWriteTimeout))
+			if err = conn.WriteJSON(entry); err != nil {
+				return 0, err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		nextBatch := iterator.Info.ToBatch + 1
+		if entry, err := g.stream.GetBookmark(marshalBatchBookmark(nextBatch)); err == nil {
+			return entry, nil
+		}
+
+		next, err := g.archive.IteratorFromBookmark(newBatchBookmarkEntryProto(nextBatch))
+		if err != nil {
+			return 0, fmt.Errorf("batch %d not found in the stream nor in the archive: %w", nextBatch, err)
+		}
+		iterator.Close()
+		iterator = next
+	}
+}
+
+// serve writes the entries of the stream from the given entry until the context is done
+func (g *DataStreamGateway) serve(ctx context.Context, conn *websocket.Conn, from uint64) error {
+	next := from
+	lastTotal := uint64(0)
+	for {
+		total := g.stream.GetHeader().TotalEntries
+		if total < lastTotal || next > total {
+			return errGatewayUnwound
+		}
+		lastTotal = total
+
+		if next < total {
+			iterator := &gatewayIterator{newDataStreamServerIterator(g.stream, next)}
+			for {
+				parsed, entryNum, err := client.ReadParsedProto(iterator)
+				if errors.Is(err, errGatewayEndOfStream) {
+					// a partially committed block is read again once it is complete
+					break
+				}
+				if err != nil {
+					return err
+				}
+				next = entryNum + 1
+
+				entry := newGatewayEntry(parsed, entryNum)
+				if entry == nil {
+					continue
+				}
+				conn.SetWriteDeadline(time.Now().Add(gatewayWriteTimeout))
+				if err = conn.WriteJSON(entry); err != nil {
+					return err
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(g.pollInterval):
+		}
+	}
+}
+
+// gatewayIterator reports the end of the stream as an error, so that a block cut by the end of the committed
+// entries is not mistaken for a complete one
+type gatewayIterator struct {
+	*dataStreamServerIterator
+}
+
+func (it *gatewayIterator) NextFileEntry() (*types.FileEntry, error) {
+	entry, err := it.dataStreamServerIterator.NextFileEntry()
+	if err == nil && entry == nil {
+		return nil, errGatewayEndOfStream
+	}
+	return entry, err
+}
+
+// newGatewayEntry converts a parsed stream entry, the bookmarks and block ends are not sent
+func newGatewayEntry(parsed interface{}, entryNum uint64) *GatewayEntry {
+	switch parsed := parsed.(type) {
+	case *types.BatchStart:
+		return &GatewayEntry{Entry: entryNum, Type: GatewayBatchStart, Data: &GatewayBatchStartJson{
+			Number:    parsed.Number,
+			BatchType: uint32(parsed.BatchType),
+			ForkId:    parsed.ForkId,
+			ChainId:   parsed.ChainId,
+		}}
+	case *types.BatchEnd:
+		return &GatewayEntry{Entry: entryNum, Type: GatewayBatchEnd, Data: &GatewayBatchEndJson{Number: parsed.Number}}
+	case *types.FullL2Block:
+		txs := make([]GatewayTransactionJson, len(parsed.L2Txs))
+		for i, tx := range parsed.L2Txs {
+			txs[i] = GatewayTransactionJson{
+				Index:                       tx.Index,
+				IsValid:                     tx.IsValid,
+				Encoded:                     tx.Encoded,
+				EffectiveGasPricePercentage: tx.EffectiveGasPricePercentage,
+				IntermediateStateRoot:       tx.IntermediateStateRoot,
+			}
+		}
+		return &GatewayEntry{Entry: entryNum, Type: GatewayL2Block, Data: &GatewayL2BlockJson{
+			Number:          parsed.L2BlockNumber,
+			BatchNumber:     parsed.BatchNumber,
+			Timestamp:       parsed.Timestamp,
+			DeltaTimestamp:  parsed.DeltaTimestamp,
+			L1InfoTreeIndex: parsed.L1InfoTreeIndex,
+			GlobalExitRoot:  parsed.GlobalExitRoot,
+			Coinbase:        parsed.Coinbase,
+			L1BlockHash:     parsed.L1BlockHash,
+			Hash:            parsed.L2Blockhash,
+			StateRoot:       parsed.StateRoot,
+			BlockGasLimit:   parsed.BlockGasLimit,
+			BlockInfoRoot:   parsed.BlockInfoRoot,
+			Transactions:    txs,
+		}}
+	case *types.GerUpdate:
+		return &GatewayEntry{Entry: entryNum, Type: GatewayGerUpdate, Data: &GatewayGerUpdateJson{
+			BatchNumber:    parsed.BatchNumber,
+			Timestamp:      parsed.Timestamp,
+			GlobalExitRoot: parsed.GlobalExitRoot,
+			Coinbase:       parsed.Coinbase,
+			ForkId:         parsed.ForkId,
+			ChainId:        parsed.ChainId,
+			StateRoot:      parsed.StateRoot,
+		}}
+	default:
+		return nil
+	}
+}
+
+// ServeDataStreamGateway serves the gateway on the address until the context is done. The IP and certificate
+// allowlists and the TLS of the security config apply to the gateway as they do to the stream server.
+func ServeDataStreamGateway(ctx context.Context, addr string, gateway *DataStreamGateway, security *StreamSecurityConfig) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if security.Enabled() {
+		guard, err := security.guard()
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		ln = &guardedListener{Listener: ln, guard: guard}
+	}
+
+	srv := &http.Server{Handler: gateway, ReadHeaderTimeout: gatewayWriteTimeout}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	log.Info("[dataStream gateway] serving the stream as JSON over websocket", "addr", ln.Addr())
+	if err = srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
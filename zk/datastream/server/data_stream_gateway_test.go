@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/gorilla/websocket"
+	libcommon "github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/zk/datastream/proto/github.com/0xPolygonHermez/zkevm-node/state/datastream"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+	"github.com/stretchr/testify/require"
+)
+
+func newGatewayTestStream(t *testing.T) *ZkEVMDataStreamServer {
+	stream, err := NewZkEVMDataStreamServerFactory().CreateStreamServer(freePort(t), 3, 1, datastreamer.StreamType(1), filepath.Join(t.TempDir(), "data-stream"), time.Second, time.Minute, time.Minute, nil)
+	require.NoError(t, err)
+	require.NoError(t, stream.Start())
+	return NewZkEVMDataStreamServerFactory().CreateDataStreamServer(stream, 1).(*ZkEVMDataStreamServer)
+}
+
+// writeGatewayTestBatch commits a batch with one block per number, each block with a single transaction
+func writeGatewayTestBatch(t *testing.T, srv *ZkEVMDataStreamServer, batchNo uint64, blockNos ...uint64) {
+	entries := []DataStreamEntryProto{
+		newBatchBookmarkEntryProto(batchNo),
+		newBatchStartProto(batchNo, 1, 9, datastream.BatchType_BATCH_TYPE_REGULAR),
+	}
+	for _, blockNo := range blockNos {
+		entries = append(entries,
+			newL2BlockBookmarkEntryProto(blockNo),
+			&types.L2BlockProto{L2Block: &datastream.L2Block{Number: blockNo, BatchNumber: batchNo, Hash: libcommon.Hash{byte(blockNo)}.Bytes()}},
+			&types.TxProto{Transaction: &datastream.Transaction{L2BlockNumber: blockNo, IsValid: true, Encoded: []byte{byte(blockNo)}}},
+			newL2BlockEndProto(blockNo),
+		)
+	}
+	entries = append(entries, newBatchEndProto(libcommon.Hash{}, libcommon.Hash{}, batchNo))
+
+	require.NoError(t, srv.streamServer.StartAtomicOp())
+	require.NoError(t, srv.commitEntriesToStreamProto(entries))
+	require.NoError(t, srv.streamServer.CommitAtomicOp())
+}
+
+type gatewayTestEntry struct {
+	GatewayEntry
+	Data json.RawMessage `json:"data"`
+}
+
+func readGatewayEntries(t *testing.T, conn *websocket.Conn, n int) []gatewayTestEntry {
+	entries := make([]gatewayTestEntry, n)
+	for i := range entries {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		require.NoError(t, conn.ReadJSON(&entries[i]))
+	}
+	return entries
+}
+
+func dialGateway(t *testing.T, url, query string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/?"+query, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestDataStreamGateway(t *testing.T) {
+	srv := newGatewayTestStream(t)
+	writeGatewayTestBatch(t, srv, 1, 1, 2)
+
+	httpSrv := httptest.NewServer(NewDataStreamGateway(srv.streamServer, nil, 10*time.Millisecond))
+	defer httpSrv.Close()
+
+	// from the start
+	conn := dialGateway(t, httpSrv.URL, "")
+	entries := readGatewayEntries(t, conn, 4)
+	got := make([]string, len(entries))
+	for i, e := range entries {
+		got[i] = e.Type
+	}
+	require.Equal(t, []string{GatewayBatchStart, GatewayL2Block, GatewayL2Block, GatewayBatchEnd}, got)
+
+	var block GatewayL2BlockJson
+	require.NoError(t, json.Unmarshal(entries[2].Data, &block))
+	require.Equal(t, uint64(2), block.Number)
+	require.Equal(t, uint64(1), block.BatchNumber)
+	require.Equal(t, libcommon.Hash{2}, block.Hash)
+	require.Len(t, block.Transactions, 1)
+	require.Equal(t, []byte{2}, []byte(block.Transactions[0].Encoded))
+
+	// entries committed later are followed
+	writeGatewayTestBatch(t, srv, 2, 3)
+	entries = readGatewayEntries(t, conn, 3)
+	require.Equal(t, GatewayBatchStart, entries[0].Type)
+	require.Equal(t, GatewayL2Block, entries[1].Type)
+	require.NoError(t, json.Unmarshal(entries[1].Data, &block))
+	require.Equal(t, uint64(3), block.Number)
+
+	// from a block bookmark
+	conn = dialGateway(t, httpSrv.URL, "block=2")
+	entries = readGatewayEntries(t, conn, 1)
+	require.Equal(t, GatewayL2Block, entries[0].Type)
+	require.NoError(t, json.Unmarshal(entries[0].Data, &block))
+	require.Equal(t, uint64(2), block.Number)
+	resumeFrom := entries[0].Entry + 1
+
+	// from a batch bookmark
+	conn = dialGateway(t, httpSrv.URL, "batch=2")
+	entries = readGatewayEntries(t, conn, 1)
+	require.Equal(t, GatewayBatchStart, entries[0].Type)
+	var batch GatewayBatchStartJson
+	require.NoError(t, json.Unmarshal(entries[0].Data, &batch))
+	require.Equal(t, uint64(2), batch.Number)
+
+	// resumed after the block 2, the next entry is the end of its batch
+	conn = dialGateway(t, httpSrv.URL, "entry="+strconv.FormatUint(resumeFrom, 10))
+	entries = readGatewayEntries(t, conn, 1)
+	require.Equal(t, GatewayBatchEnd, entries[0].Type)
+
+	// unknown bookmarks are refused before the upgrade
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpSrv.URL, "http")+"/?block=100", nil)
+	require.Error(t, err)
+	require.Equal(t, 400, resp.StatusCode)
+}
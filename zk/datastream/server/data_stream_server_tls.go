@@ -0,0 +1,320 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	dslog "github.com/0xPolygonHermez/zkevm-data-streamer/log"
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// StreamSecurityConfig restricts who can connect to the data stream server
+type StreamSecurityConfig struct {
+	// CertFile and KeyFile enable TLS on the stream
+	CertFile string
+	KeyFile  string
+	// ClientCAFile requires the clients to present a certificate signed by this CA
+	ClientCAFile string
+	// AllowedIPs are the IPs or CIDRs the clients can connect from, empty allows any
+	AllowedIPs []string
+	// AllowedCerts are the SHA-256 fingerprints, in hex, of the client certificates allowed to connect. Without
+	// ClientCAFile any certificate with an allowed fingerprint is accepted, self signed ones included.
+	AllowedCerts []string
+	// InternalPort is the port of the stream server behind the guard, it is bound to the loopback only and the guard
+	// forwards the allowed clients to it
+	InternalPort uint16
+}
+
+// Enabled reports if the stream has to be served on anything else than a plain TCP socket
+func (c *StreamSecurityConfig) Enabled() bool {
+	return c != nil && (c.CertFile != "" || c.KeyFile != "" || c.ClientCAFile != "" || len(c.AllowedIPs) > 0 || len(c.AllowedCerts) > 0)
+}
+
+// streamGuard checks the connections accepted by the data stream server
+type streamGuard struct {
+	allowedNets []*net.IPNet
+	tlsConfig   *tls.Config
+}
+
+func (c *StreamSecurityConfig) guard() (*streamGuard, error) {
+	g := &streamGuard{}
+
+	for _, entry := range c.AllowedIPs {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid data stream allowed IP %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			g.allowedNets = append(g.allowedNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data stream allowed IP %q: %w", entry, err)
+		}
+		g.allowedNets = append(g.allowedNets, ipNet)
+	}
+
+	if c.CertFile == "" && c.KeyFile == "" {
+		if c.ClientCAFile != "" || len(c.AllowedCerts) > 0 {
+			return nil, errors.New("data stream client certificates require the TLS certificate and key of the server")
+		}
+		return g, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load data stream certificate: %w", err)
+	}
+	g.tlsConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.ClientCAFile != "" {
+		pool, err := client.LoadCertPool(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		g.tlsConfig.ClientCAs = pool
+		g.tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if len(c.AllowedCerts) > 0 {
+		allowed := make(map[string]struct{}, len(c.AllowedCerts))
+		for _, fingerprint := range c.AllowedCerts {
+			fingerprint = strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(strings.TrimSpace(fingerprint), "0x"), ":", ""))
+			if b, err := hex.DecodeString(fingerprint); err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("invalid data stream certificate fingerprint %q", fingerprint)
+			}
+			allowed[fingerprint] = struct{}{}
+		}
+		if g.tlsConfig.ClientAuth != tls.RequireAndVerifyClientCert {
+			g.tlsConfig.ClientAuth = tls.RequireAnyClientCert
+		}
+		g.tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("no client certificate")
+			}
+			fingerprint := sha256.Sum256(cs.PeerCertificates[0].Raw)
+			if _, ok := allowed[hex.EncodeToString(fingerprint[:])]; !ok {
+				return fmt.Errorf("client certificate %x is not allowed", fingerprint)
+			}
+			return nil
+		}
+	}
+
+	return g, nil
+}
+
+func (g *streamGuard) allowed(addr net.Addr) bool {
+	if len(g.allowedNets) == 0 {
+		return true
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, ipNet := range g.allowedNets {
+		if ipNet.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// guardedListener drops the connections from addresses that are not allowed and serves TLS on the others.
+// The TLS handshake, and so the client certificate check, happens before the connection is forwarded.
+type guardedListener struct {
+	net.Listener
+	guard *streamGuard
+}
+
+func (l *guardedListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if !l.guard.allowed(conn.RemoteAddr()) {
+			log.Warn("[dataStream] rejected connection from an address that is not allowed", "remote", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		if l.guard.tlsConfig != nil {
+			return tls.Server(conn, l.guard.tlsConfig), nil
+		}
+		return conn, nil
+	}
+}
+
+// secureStreamServer is a datastreamer stream server that only serves the clients allowed by its StreamSecurityConfig.
+// The datastreamer server has no hook for its listener, so it runs on the internal port of the loopback and the guard
+// listens on the stream port in front of it, forwarding the allowed connections.
+type secureStreamServer struct {
+	*datastreamer.StreamServer
+	port         uint16
+	internalPort uint16
+	guard        *streamGuard
+	idleTimeout  time.Duration
+}
+
+const (
+	secureStreamHandshakeTimeout = 10 * time.Second
+	secureStreamInternalHost     = "127.0.0.1"
+)
+
+func (s *secureStreamServer) Start() error {
+	if err := s.StreamServer.Start(); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(int(s.port)))
+	if err != nil {
+		return err
+	}
+	go s.serve(&guardedListener{Listener: ln, guard: s.guard})
+
+	log.Info("[dataStream] serving the stream with restricted access", "port", s.port, "internalPort", s.internalPort, "tls", s.guard.tlsConfig != nil, "allowedNets", len(s.guard.allowedNets))
+	return nil
+}
+
+func (s *secureStreamServer) serve(ln net.Listener) {
+	defer ln.Close()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Warn("[dataStream] failed to accept a connection", "err", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		go s.forward(conn)
+	}
+}
+
+// forward copies the stream between an allowed client and the stream server, the TLS handshake is done first so the
+// clients without an allowed certificate never reach the stream server
+func (s *secureStreamServer) forward(conn net.Conn) {
+	defer conn.Close()
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.SetDeadline(time.Now().Add(secureStreamHandshakeTimeout)); err != nil {
+			return
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			log.Warn("[dataStream] rejected connection that failed the TLS handshake", "remote", conn.RemoteAddr(), "err", err)
+			return
+		}
+		if err := tlsConn.SetDeadline(time.Time{}); err != nil {
+			return
+		}
+	}
+
+	upstream, err := net.DialTimeout("tcp", net.JoinHostPort(secureStreamInternalHost, strconv.Itoa(int(s.internalPort))), secureStreamHandshakeTimeout)
+	if err != nil {
+		log.Warn("[dataStream] failed to reach the stream server", "internalPort", s.internalPort, "err", err)
+		return
+	}
+	defer upstream.Close()
+
+	// a connection with no bytes in either direction for the idle timeout is dropped
+	idle := &idleConns{conns: [2]net.Conn{conn, upstream}, timeout: s.idleTimeout}
+	if err = idle.touch(); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, &idleConn{Conn: conn, idle: idle})
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, &idleConn{Conn: upstream, idle: idle})
+		done <- struct{}{}
+	}()
+	// either side closing ends the connection, the deferred closes stop the other copy
+	<-done
+}
+
+// idleConns are the two sides of a forwarded connection, whose deadlines are pushed back on every read of either
+type idleConns struct {
+	conns   [2]net.Conn
+	timeout time.Duration
+}
+
+func (c *idleConns) touch() error {
+	if c.timeout <= 0 {
+		return nil
+	}
+	deadline := time.Now().Add(c.timeout)
+	for _, conn := range c.conns {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// idleConn is a side of a forwarded connection, the read bytes keep both sides alive
+type idleConn struct {
+	net.Conn
+	idle *idleConns
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		if touchErr := c.idle.touch(); err == nil {
+			err = touchErr
+		}
+	}
+	return n, err
+}
+
+// CreateSecureStreamServer is CreateStreamServer with the access to the stream restricted by the security config.
+// Without any security configured it returns the plain server. The forwarded connections are dropped once idle for
+// the inactivity timeout.
+func (f *ZkEVMDataStreamServerFactory) CreateSecureStreamServer(port uint16, version uint8, systemID uint64, streamType datastreamer.StreamType, fileName string, writeTimeout time.Duration, inactivityTimeout time.Duration, inactivityCheckInterval time.Duration, cfg *dslog.Config, security *StreamSecurityConfig) (StreamServer, error) {
+	if !security.Enabled() {
+		return f.CreateStreamServer(port, version, systemID, streamType, fileName, writeTimeout, inactivityTimeout, inactivityCheckInterval, cfg)
+	}
+
+	guard, err := security.guard()
+	if err != nil {
+		return nil, err
+	}
+	if security.InternalPort == 0 || security.InternalPort == port {
+		return nil, errors.New("restricted data stream access requires an internal port for the stream server, other than the data stream port")
+	}
+
+	srv, err := datastreamer.NewServer(security.InternalPort, version, systemID, streamType, fileName, writeTimeout, inactivityTimeout, inactivityCheckInterval, cfg)
+	if err != nil {
+		return nil, err
+	}
+	// only the guard can reach the stream server
+	srv.SetListenHost(secureStreamInternalHost)
+
+	return &secureStreamServer{StreamServer: srv, port: port, internalPort: security.InternalPort, guard: guard, idleTimeout: inactivityTimeout}, nil
+}
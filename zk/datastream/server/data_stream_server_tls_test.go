@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-data-streamer/datastreamer"
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+func (c *testCert) fingerprint() string {
+	fingerprint := sha256.Sum256(c.cert.Raw)
+	return hex.EncodeToString(fingerprint[:])
+}
+
+// newTestCert generates a certificate signed by the parent, or self signed without one, and writes it to the dir
+func newTestCert(t *testing.T, dir, name string, parent *testCert, isCA bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:         isCA,
+
+		BasicConstraintsValid: true,
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	c := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+	require.NoError(t, os.WriteFile(c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return c
+}
+
+func freePort(t *testing.T) uint16 {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return uint16(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func startSecureTestServer(t *testing.T, security *StreamSecurityConfig) string {
+	port := freePort(t)
+	security.InternalPort = freePort(t)
+	srv, err := NewZkEVMDataStreamServerFactory().CreateSecureStreamServer(port, 3, 1, datastreamer.StreamType(1), filepath.Join(t.TempDir(), "data-stream"), time.Second, time.Minute, time.Minute, nil, security)
+	require.NoError(t, err)
+	require.NoError(t, srv.Start())
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port)))
+}
+
+func TestSecureStreamServer(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", nil, true)
+	serverCert := newTestCert(t, dir, "server", ca, false)
+	allowedClient := newTestCert(t, dir, "allowed", ca, false)
+	otherClient := newTestCert(t, dir, "other", ca, false)
+	selfSigned := newTestCert(t, dir, "self-signed", nil, false)
+
+	addr := startSecureTestServer(t, &StreamSecurityConfig{
+		CertFile:     serverCert.certFile,
+		KeyFile:      serverCert.keyFile,
+		ClientCAFile: ca.certFile,
+		AllowedIPs:   []string{"127.0.0.0/8"},
+		AllowedCerts: []string{allowedClient.fingerprint()},
+	})
+
+	getHeader := func(useTLS bool, cert *testCert) error {
+		c := client.NewClient(context.Background(), addr, useTLS, 3, 5*time.Second, 0)
+		if cert != nil {
+			c.SetTLSFiles(cert.certFile, cert.keyFile, ca.certFile)
+		} else {
+			c.SetTLSFiles("", "", ca.certFile)
+		}
+		if err := c.Start(); err != nil {
+			return err
+		}
+		_, err := c.GetHeader()
+		return err
+	}
+
+	t.Run("allowed client certificate", func(t *testing.T) {
+		require.NoError(t, getHeader(true, allowedClient))
+	})
+	t.Run("plain connection", func(t *testing.T) {
+		require.Error(t, getHeader(false, nil))
+	})
+	t.Run("no client certificate", func(t *testing.T) {
+		require.Error(t, getHeader(true, nil))
+	})
+	t.Run("client certificate not in the allowlist", func(t *testing.T) {
+		require.Error(t, getHeader(true, otherClient))
+	})
+	t.Run("client certificate not signed by the CA", func(t *testing.T) {
+		require.Error(t, getHeader(true, selfSigned))
+	})
+
+	t.Run("address in the allowlist", func(t *testing.T) {
+		addr := startSecureTestServer(t, &StreamSecurityConfig{AllowedIPs: []string{"127.0.0.1"}})
+		c := client.NewClient(context.Background(), addr, false, 3, 5*time.Second, 0)
+		require.NoError(t, c.Start())
+		_, err := c.GetHeader()
+		require.NoError(t, err)
+	})
+	t.Run("address not in the allowlist", func(t *testing.T) {
+		addr := startSecureTestServer(t, &StreamSecurityConfig{AllowedIPs: []string{"10.0.0.0/8"}})
+		c := client.NewClient(context.Background(), addr, false, 3, 5*time.Second, 0)
+		require.NoError(t, c.Start())
+		_, err := c.GetHeader()
+		require.Error(t, err)
+	})
+}
+
+func TestStreamSecurityConfigValidation(t *testing.T) {
+	_, err := (&StreamSecurityConfig{ClientCAFile: "ca.crt"}).guard()
+	require.Error(t, err)
+	_, err = (&StreamSecurityConfig{AllowedIPs: []string{"not an ip"}}).guard()
+	require.Error(t, err)
+
+	g, err := (&StreamSecurityConfig{AllowedIPs: []string{"192.168.1.10", "10.0.0.0/8"}}).guard()
+	require.NoError(t, err)
+	require.True(t, g.allowed(&net.TCPAddr{IP: net.ParseIP("192.168.1.10")}))
+	require.False(t, g.allowed(&net.TCPAddr{IP: net.ParseIP("192.168.1.11")}))
+	require.True(t, g.allowed(&net.TCPAddr{IP: net.ParseIP("10.1.2.3")}))
+
+	// the guard needs a port of its own in front of the stream server
+	_, err = NewZkEVMDataStreamServerFactory().CreateSecureStreamServer(6900, 3, 1, datastreamer.StreamType(1), filepath.Join(t.TempDir(), "data-stream"), time.Second, time.Minute, time.Minute, nil, &StreamSecurityConfig{AllowedIPs: []string{"127.0.0.1"}})
+	require.Error(t, err)
+
+	require.False(t, (&StreamSecurityConfig{}).Enabled())
+	require.False(t, (*StreamSecurityConfig)(nil).Enabled())
+}
+
+func TestSecureStreamServerInternalPort(t *testing.T) {
+	port := freePort(t)
+	security := &StreamSecurityConfig{AllowedIPs: []string{"127.0.0.1"}, InternalPort: freePort(t)}
+	srv, err := NewZkEVMDataStreamServerFactory().CreateSecureStreamServer(port, 3, 1, datastreamer.StreamType(1), filepath.Join(t.TempDir(), "data-stream"), time.Second, 200*time.Millisecond, time.Minute, nil, security)
+	require.NoError(t, err)
+	require.NoError(t, srv.Start())
+
+	// the stream server is only bound to the loopback
+	addrs, err := net.InterfaceAddrs()
+	require.NoError(t, err)
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() {
+			continue
+		}
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ipNet.IP.String(), strconv.Itoa(int(security.InternalPort))), time.Second)
+		if err == nil {
+			conn.Close()
+		}
+		require.Error(t, err, ipNet.IP.String())
+	}
+
+	// an idle forwarded connection is dropped
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port))))
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,535 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	"github.com/ledgerwatch/erigon/zk/datastream/proto/github.com/0xPolygonHermez/zkevm-node/state/datastream"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+)
+
+const (
+	segmentMagic      = "ZKDSSEG1"
+	segmentHeaderSize = len(segmentMagic) + 6*8 + sha256.Size
+	segmentFileExt    = ".seg"
+	// segmentEntryHeaderSize is the entry type, number and data length before the data of every archived entry
+	segmentEntryHeaderSize = 4 + 8 + 4
+
+	// DefaultSegmentBatches is the number of batches archived per segment
+	DefaultSegmentBatches = 1000
+)
+
+var (
+	ErrSegmentNotFound = errors.New("no archived segment holds the bookmark")
+	ErrSegmentChecksum = errors.New("segment checksum mismatch")
+)
+
+// SegmentInfo describes a segment file: whole batches of the data stream archived as compressed, checksummed entries
+type SegmentInfo struct {
+	Path      string
+	FromBatch uint64
+	ToBatch   uint64
+	FromBlock uint64
+	ToBlock   uint64
+	// FirstEntry is the number of the first archived entry in the stream it was archived from
+	FirstEntry uint64
+	Entries    uint64
+	// Checksum is the SHA-256 of the compressed entries
+	Checksum [sha256.Size]byte
+}
+
+func (s *SegmentInfo) holds(bookmark *types.BookmarkProto) bool {
+	switch bookmark.BookmarkType() {
+	case datastream.BookmarkType_BOOKMARK_TYPE_BATCH:
+		return bookmark.Value >= s.FromBatch && bookmark.Value <= s.ToBatch
+	case datastream.BookmarkType_BOOKMARK_TYPE_L2_BLOCK:
+		return s.Entries > 0 && bookmark.Value >= s.FromBlock && bookmark.Value <= s.ToBlock
+	default:
+		return false
+	}
+}
+
+func (s *SegmentInfo) encodeHeader() []byte {
+	b := make([]byte, 0, segmentHeaderSize)
+	b = append(b, segmentMagic...)
+	for _, v := range []uint64{s.FromBatch, s.ToBatch, s.FromBlock, s.ToBlock, s.FirstEntry, s.Entries} {
+		b = binary.BigEndian.AppendUint64(b, v)
+	}
+	return append(b, s.Checksum[:]...)
+}
+
+func decodeSegmentHeader(path string, b []byte) (*SegmentInfo, error) {
+	if len(b) != segmentHeaderSize || string(b[:len(segmentMagic)]) != segmentMagic {
+		return nil, fmt.Errorf("%s is not a data stream segment", path)
+	}
+	b = b[len(segmentMagic):]
+	values := make([]uint64, 6)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint64(b[i*8:])
+	}
+	s := &SegmentInfo{
+		Path:       path,
+		FromBatch:  values[0],
+		ToBatch:    values[1],
+		FromBlock:  values[2],
+		ToBlock:    values[3],
+		FirstEntry: values[4],
+		Entries:    values[5],
+	}
+	copy(s.Checksum[:], b[6*8:])
+	return s, nil
+}
+
+// SegmentFileName is the name of the segment archiving the batches [fromBatch, toBatch]
+func SegmentFileName(fromBatch, toBatch uint64) string {
+	return fmt.Sprintf("batches-%012d-%012d%s", fromBatch, toBatch, segmentFileExt)
+}
+
+func marshalBatchBookmark(batchNo uint64) []byte {
+	// marshalling a bookmark can not fail
+	b, _ := newBatchBookmarkEntryProto(batchNo).Marshal()
+	return b
+}
+
+// WriteSegment archives the batches [fromBatch, toBatch] of the stream into a segment file in the dir, replacing
+// any segment of the same batches. The batch after toBatch must have been started in the stream, so that the
+// archived batches are complete.
+func WriteSegment(stream StreamServer, dir string, fromBatch, toBatch uint64) (*SegmentInfo, error) {
+	start, err := stream.GetBookmark(marshalBatchBookmark(fromBatch))
+	if err != nil {
+		return nil, fmt.Errorf("batch %d not found in the stream: %w", fromBatch, err)
+	}
+	end, err := stream.GetBookmark(marshalBatchBookmark(toBatch + 1))
+	if err != nil {
+		return nil, fmt.Errorf("batch %d is not followed by another batch in the stream: %w", toBatch, err)
+	}
+	if end <= start {
+		return nil, fmt.Errorf("invalid entries [%d, %d) for the batches [%d, %d]", start, end, fromBatch, toBatch)
+	}
+
+	iterator := &dataStreamServerIterator{stream: stream, curEntryNum: start, header: end - 1}
+	return writeSegment(dir, fromBatch, toBatch, iterator)
+}
+
+func writeSegment(dir string, fromBatch, toBatch uint64, iterator client.FileEntryIterator) (info *SegmentInfo, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(dir, "*"+segmentFileExt+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	info = &SegmentInfo{FromBatch: fromBatch, ToBatch: toBatch}
+	if _, err = file.Write(info.encodeHeader()); err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	buffered := bufio.NewWriter(io.MultiWriter(file, hash))
+	gz := gzip.NewWriter(buffered)
+
+	entryHeader := make([]byte, segmentEntryHeaderSize)
+	for {
+		entry, err := iterator.NextFileEntry()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+
+		if info.Entries == 0 {
+			if entry.EntryType != types.BookmarkEntryType || !bytes.Equal(entry.Data, marshalBatchBookmark(fromBatch)) {
+				return nil, fmt.Errorf("the entries of the batch %d do not start with its bookmark", fromBatch)
+			}
+			info.FirstEntry = entry.EntryNum
+		} else if entry.EntryNum != info.FirstEntry+info.Entries {
+			return nil, fmt.Errorf("entry %d found after the entry %d", entry.EntryNum, info.FirstEntry+info.Entries-1)
+		}
+
+		if entry.EntryType == types.BookmarkEntryType {
+			bookmark, err := types.UnmarshalBookmark(entry.Data)
+			if err != nil {
+				return nil, err
+			}
+			if bookmark.BookmarkType() == datastream.BookmarkType_BOOKMARK_TYPE_L2_BLOCK {
+				if info.FromBlock == 0 && info.ToBlock == 0 {
+					info.FromBlock = bookmark.Value
+				}
+				info.ToBlock = bookmark.Value
+			}
+		}
+
+		binary.BigEndian.PutUint32(entryHeader, uint32(entry.EntryType))
+		binary.BigEndian.PutUint64(entryHeader[4:], entry.EntryNum)
+		binary.BigEndian.PutUint32(entryHeader[12:], uint32(len(entry.Data)))
+		if _, err = gz.Write(entryHeader); err != nil {
+			return nil, err
+		}
+		if _, err = gz.Write(entry.Data); err != nil {
+			return nil, err
+		}
+		info.Entries++
+	}
+
+	if err = gz.Close(); err != nil {
+		return nil, err
+	}
+	if err = buffered.Flush(); err != nil {
+		return nil, err
+	}
+	copy(info.Checksum[:], hash.Sum(nil))
+
+	// the header is written again now that the segment is known
+	if _, err = file.WriteAt(info.encodeHeader(), 0); err != nil {
+		return nil, err
+	}
+	if err = file.Sync(); err != nil {
+		return nil, err
+	}
+	if err = file.Close(); err != nil {
+		return nil, err
+	}
+
+	info.Path = filepath.Join(dir, SegmentFileName(fromBatch, toBatch))
+	if err = os.Rename(file.Name(), info.Path); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ReadSegmentInfo reads the header of a segment file, without checking its entries
+func ReadSegmentInfo(path string) (*SegmentInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header := make([]byte, segmentHeaderSize)
+	if _, err = io.ReadFull(file, header); err != nil {
+		return nil, fmt.Errorf("%s is not a data stream segment: %w", path, err)
+	}
+	return decodeSegmentHeader(path, header)
+}
+
+// SegmentIterator reads the entries of a segment in order, as they were read from the stream
+type SegmentIterator struct {
+	Info *SegmentInfo
+
+	file    *os.File
+	gz      *gzip.Reader
+	read    uint64
+	pending *types.FileEntry
+}
+
+// OpenSegment checks the checksum of the segment file and opens it for reading
+func OpenSegment(path string) (*SegmentIterator, error) {
+	info, err := ReadSegmentInfo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	if _, err = file.Seek(int64(segmentHeaderSize), io.SeekStart); err == nil {
+		_, err = io.Copy(hash, file)
+	}
+	if err == nil && !bytes.Equal(hash.Sum(nil), info.Checksum[:]) {
+		err = fmt.Errorf("%w: %s", ErrSegmentChecksum, path)
+	}
+	if err == nil {
+		_, err = file.Seek(int64(segmentHeaderSize), io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &SegmentIterator{Info: info, file: file, gz: gz}, nil
+}
+
+func (it *SegmentIterator) GetEntryNumberLimit() uint64 {
+	return it.Info.FirstEntry + it.Info.Entries
+}
+
+// NextFileEntry returns the next entry of the segment, nil once all of them were read
+func (it *SegmentIterator) NextFileEntry() (*types.FileEntry, error) {
+	if it.pending != nil {
+		entry := it.pending
+		it.pending = nil
+		return entry, nil
+	}
+	if it.read == it.Info.Entries {
+		return nil, nil
+	}
+
+	entryHeader := make([]byte, segmentEntryHeaderSize)
+	if _, err := io.ReadFull(it.gz, entryHeader); err != nil {
+		return nil, fmt.Errorf("read entry %d of %s: %w", it.read, it.Info.Path, err)
+	}
+	data := make([]byte, binary.BigEndian.Uint32(entryHeader[12:]))
+	if _, err := io.ReadFull(it.gz, data); err != nil {
+		return nil, fmt.Errorf("read entry %d of %s: %w", it.read, it.Info.Path, err)
+	}
+	it.read++
+
+	return &types.FileEntry{
+		PacketType: client.PtData,
+		Length:     uint32(segmentEntryHeaderSize + len(data)),
+		EntryType:  types.EntryType(binary.BigEndian.Uint32(entryHeader)),
+		EntryNum:   binary.BigEndian.Uint64(entryHeader[4:]),
+		Data:       data,
+	}, nil
+}
+
+// seekBookmark skips the entries before the bookmark, the next entry returned is the bookmark itself
+func (it *SegmentIterator) seekBookmark(bookmark *types.BookmarkProto) error {
+	marshalled, err := bookmark.Marshal()
+	if err != nil {
+		return err
+	}
+	for {
+		entry, err := it.NextFileEntry()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return ErrSegmentNotFound
+		}
+		if entry.EntryType == types.BookmarkEntryType && bytes.Equal(entry.Data, marshalled) {
+			it.pending = entry
+			return nil
+		}
+	}
+}
+
+func (it *SegmentIterator) Close() error {
+	it.gz.Close()
+	return it.file.Close()
+}
+
+// VerifySegment checks the checksum of the segment file and that it holds every one of its batches, in order
+func VerifySegment(path string) (*SegmentInfo, error) {
+	it, err := OpenSegment(path)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	nextBatch := it.Info.FromBatch
+	for {
+		entry, err := it.NextFileEntry()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.EntryNum != it.Info.FirstEntry+it.read-1 {
+			return nil, fmt.Errorf("%s: entry %d found at the position %d", path, entry.EntryNum, it.read-1)
+		}
+		if entry.EntryType != types.BookmarkEntryType {
+			continue
+		}
+		bookmark, err := types.UnmarshalBookmark(entry.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", path, entry.EntryNum, err)
+		}
+		if bookmark.BookmarkType() != datastream.BookmarkType_BOOKMARK_TYPE_BATCH {
+			continue
+		}
+		if bookmark.Value != nextBatch {
+			return nil, fmt.Errorf("%s: batch %d found where the batch %d was expected", path, bookmark.Value, nextBatch)
+		}
+		nextBatch++
+	}
+
+	if nextBatch != it.Info.ToBatch+1 {
+		return nil, fmt.Errorf("%s: holds the batches up to %d instead of %d", path, nextBatch-1, it.Info.ToBatch)
+	}
+	if _, err = io.Copy(io.Discard, it.gz); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return it.Info, nil
+}
+
+// SegmentStore is a directory of segment files
+type SegmentStore struct {
+	dir string
+}
+
+func NewSegmentStore(dir string) *SegmentStore {
+	return &SegmentStore{dir: dir}
+}
+
+func (s *SegmentStore) Dir() string {
+	return s.dir
+}
+
+// Segments lists the segments of the store by batch
+func (s *SegmentStore) Segments() ([]*SegmentInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*"+segmentFileExt))
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]*SegmentInfo, 0, len(paths))
+	for _, path := range paths {
+		info, err := ReadSegmentInfo(path)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, info)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].FromBatch < segments[j].FromBatch })
+	return segments, nil
+}
+
+// IteratorFromBookmark opens the segment holding the batch or block bookmark, the first entry returned by the
+// iterator is the bookmark
+func (s *SegmentStore) IteratorFromBookmark(bookmark *types.BookmarkProto) (*SegmentIterator, error) {
+	segments, err := s.Segments()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range segments {
+		if !info.holds(bookmark) {
+			continue
+		}
+		it, err := OpenSegment(info.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err = it.seekBookmark(bookmark); err != nil {
+			it.Close()
+			return nil, err
+		}
+		return it, nil
+	}
+
+	return nil, ErrSegmentNotFound
+}
+
+// ArchiveStream archives the batches of the stream before keepFromBatch in segments of segmentBatches batches,
+// skipping the segments already in the store. It returns the segments written.
+func ArchiveStream(stream StreamServer, store *SegmentStore, keepFromBatch, segmentBatches uint64) ([]*SegmentInfo, error) {
+	if segmentBatches == 0 {
+		segmentBatches = DefaultSegmentBatches
+	}
+
+	first, err := stream.GetEntry(0)
+	if err != nil {
+		return nil, err
+	}
+	if types.EntryType(first.Type) != types.BookmarkEntryType {
+		return nil, errors.New("the stream does not start with a batch bookmark")
+	}
+	bookmark, err := types.UnmarshalBookmark(first.Data)
+	if err != nil {
+		return nil, err
+	}
+	if bookmark.BookmarkType() != datastream.BookmarkType_BOOKMARK_TYPE_BATCH {
+		return nil, errors.New("the stream does not start with a batch bookmark")
+	}
+
+	existing, err := store.Segments()
+	if err != nil {
+		return nil, err
+	}
+	archived := make(map[[2]uint64]struct{}, len(existing))
+	for _, info := range existing {
+		archived[[2]uint64{info.FromBatch, info.ToBatch}] = struct{}{}
+	}
+
+	var written []*SegmentInfo
+	for from := bookmark.Value; from < keepFromBatch; from += segmentBatches {
+		to := min(from+segmentBatches-1, keepFromBatch-1)
+		if _, ok := archived[[2]uint64{from, to}]; ok {
+			continue
+		}
+		info, err := WriteSegment(stream, store.Dir(), from, to)
+		if err != nil {
+			return written, err
+		}
+		written = append(written, info)
+	}
+
+	return written, nil
+}
+
+// CopyStream appends the entries of the stream from the batch fromBatch on to the target stream, which must be
+// started. It returns the number of entries copied.
+func CopyStream(stream StreamServer, fromBatch uint64, target StreamServer) (copied uint64, err error) {
+	start, err := stream.GetBookmark(marshalBatchBookmark(fromBatch))
+	if err != nil {
+		return 0, fmt.Errorf("batch %d not found in the stream: %w", fromBatch, err)
+	}
+	total := stream.GetHeader().TotalEntries
+
+	if err = target.StartAtomicOp(); err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			target.RollbackAtomicOp()
+		}
+	}()
+
+	for entryNum := start; entryNum < total; entryNum++ {
+		entry, err := stream.GetEntry(entryNum)
+		if err != nil {
+			return copied, err
+		}
+		if types.EntryType(entry.Type) == types.BookmarkEntryType {
+			_, err = target.AddStreamBookmark(entry.Data)
+		} else {
+			_, err = target.AddStreamEntry(entry.Type, entry.Data)
+		}
+		if err != nil {
+			return copied, err
+		}
+		copied++
+
+		if copied%commitEntryCountLimit == 0 {
+			if err = target.CommitAtomicOp(); err != nil {
+				return copied, err
+			}
+			if err = target.StartAtomicOp(); err != nil {
+				return copied, err
+			}
+		}
+	}
+
+	return copied, target.CommitAtomicOp()
+}
+
+var _ client.FileEntryIterator = (*SegmentIterator)(nil)
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	"github.com/ledgerwatch/erigon/zk/datastream/proto/github.com/0xPolygonHermez/zkevm-node/state/datastream"
+	"github.com/ledgerwatch/erigon/zk/datastream/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataStreamSegments(t *testing.T) {
+	srv := newGatewayTestStream(t)
+	writeGatewayTestBatch(t, srv, 1, 1, 2)
+	writeGatewayTestBatch(t, srv, 2, 3)
+	writeGatewayTestBatch(t, srv, 3, 4, 5)
+	writeGatewayTestBatch(t, srv, 4, 6)
+
+	store := NewSegmentStore(t.TempDir())
+
+	// the last batch can not be archived until the next one starts
+	_, err := WriteSegment(srv.streamServer, store.Dir(), 4, 4)
+	require.Error(t, err)
+
+	written, err := ArchiveStream(srv.streamServer, store, 4, 2)
+	require.NoError(t, err)
+	require.Len(t, written, 2)
+	require.Equal(t, filepath.Join(store.Dir(), SegmentFileName(1, 2)), written[0].Path)
+	require.Equal(t, uint64(1), written[0].FromBlock)
+	require.Equal(t, uint64(3), written[0].ToBlock)
+	require.Equal(t, uint64(3), written[1].FromBatch)
+	require.Equal(t, uint64(3), written[1].ToBatch)
+
+	// archived segments are not written again
+	written, err = ArchiveStream(srv.streamServer, store, 4, 2)
+	require.NoError(t, err)
+	require.Empty(t, written)
+
+	segments, err := store.Segments()
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+	for _, segment := range segments {
+		_, err := VerifySegment(segment.Path)
+		require.NoError(t, err)
+	}
+
+	// the archived entries are those of the stream
+	it, err := OpenSegment(segments[0].Path)
+	require.NoError(t, err)
+	for entryNum := uint64(0); entryNum < segments[0].Entries; entryNum++ {
+		entry, err := it.NextFileEntry()
+		require.NoError(t, err)
+		expected, err := srv.streamServer.GetEntry(entryNum)
+		require.NoError(t, err)
+		require.Equal(t, entryNum, entry.EntryNum)
+		require.Equal(t, types.EntryType(expected.Type), entry.EntryType)
+		require.Equal(t, expected.Data, entry.Data)
+	}
+	entry, err := it.NextFileEntry()
+	require.NoError(t, err)
+	require.Nil(t, entry)
+	require.NoError(t, it.Close())
+
+	// a bookmark is served from the segment holding it
+	it, err = store.IteratorFromBookmark(newL2BlockBookmarkEntryProto(3))
+	require.NoError(t, err)
+	parsed, _, err := client.ReadParsedProto(it)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), parsed.(*types.BookmarkProto).Value)
+	parsed, _, err = client.ReadParsedProto(it)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), parsed.(*types.FullL2Block).L2BlockNumber)
+	require.NoError(t, it.Close())
+
+	_, err = store.IteratorFromBookmark(newBatchBookmarkEntryProto(4))
+	require.ErrorIs(t, err, ErrSegmentNotFound)
+
+	// a corrupted segment is refused
+	b, err := os.ReadFile(segments[1].Path)
+	require.NoError(t, err)
+	b[len(b)-1] ^= 0xff
+	corrupted := filepath.Join(t.TempDir(), SegmentFileName(3, 3))
+	require.NoError(t, os.WriteFile(corrupted, b, 0644))
+	_, err = VerifySegment(corrupted)
+	require.ErrorIs(t, err, ErrSegmentChecksum)
+}
+
+func TestCompactedStreamGateway(t *testing.T) {
+	srv := newGatewayTestStream(t)
+	writeGatewayTestBatch(t, srv, 1, 1, 2)
+	writeGatewayTestBatch(t, srv, 2, 3)
+	writeGatewayTestBatch(t, srv, 3, 4)
+
+	store := NewSegmentStore(t.TempDir())
+	_, err := ArchiveStream(srv.streamServer, store, 3, 1)
+	require.NoError(t, err)
+
+	// only the batch 3 is kept in the compacted stream
+	compacted := newGatewayTestStream(t)
+	copied, err := CopyStream(srv.streamServer, 3, compacted.streamServer)
+	require.NoError(t, err)
+	require.Equal(t, compacted.streamServer.GetHeader().TotalEntries, copied)
+	_, err = compacted.streamServer.GetBookmark(marshalBatchBookmark(2))
+	require.Error(t, err)
+	highest, err := compacted.GetHighestBlockNumber()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), highest)
+
+	httpSrv := httptest.NewServer(NewDataStreamGateway(compacted.streamServer, store, 10*time.Millisecond))
+	defer httpSrv.Close()
+
+	// the archived batches are served before the stream
+	conn := dialGateway(t, httpSrv.URL, "block=2")
+	entries := readGatewayEntries(t, conn, 8)
+	var blocks []uint64
+	for _, e := range entries {
+		if e.Type != GatewayL2Block {
+			continue
+		}
+		var block GatewayL2BlockJson
+		require.NoError(t, json.Unmarshal(e.Data, &block))
+		blocks = append(blocks, block.Number)
+		require.Equal(t, block.Number < 4, e.Archived)
+	}
+	require.Equal(t, []uint64{2, 3, 4}, blocks)
+	require.Equal(t, GatewayBatchEnd, entries[7].Type)
+
+	// batches neither archived nor in the stream are refused
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpSrv.URL, "http")+"/?batch=10", nil)
+	require.Error(t, err)
+	require.Equal(t, 400, resp.StatusCode)
+}
+
+func TestSegmentStoreBookmarkTypes(t *testing.T) {
+	info := &SegmentInfo{FromBatch: 2, ToBatch: 3, FromBlock: 5, ToBlock: 9, Entries: 10}
+	require.True(t, info.holds(newBatchBookmarkEntryProto(3)))
+	require.False(t, info.holds(newBatchBookmarkEntryProto(4)))
+	require.True(t, info.holds(newL2BlockBookmarkEntryProto(5)))
+	require.False(t, info.holds(newL2BlockBookmarkEntryProto(10)))
+	require.False(t, info.holds(types.NewBookmarkProto(3, datastream.BookmarkType_BOOKMARK_TYPE_UNSPECIFIED)))
+}
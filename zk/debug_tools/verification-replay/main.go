@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/zk/legacy_executor_verifier"
+)
+
+var (
+	datadir  string
+	endpoint string
+	id       uint64
+	batch    int64
+	timeout  time.Duration
+)
+
+// lists the failed verifications archived by a sequencer, or replays one of them against an executor to check whether
+// it reproduces
+func main() {
+	flag.StringVar(&datadir, "datadir", "", "datadir of the sequencer")
+	flag.StringVar(&endpoint, "endpoint", "", "grpc address of the executor to replay the failure against")
+	flag.Uint64Var(&id, "id", 0, "id of the failure to replay, the failures are listed when not set")
+	flag.Int64Var(&batch, "batch", -1, "only list the failures of this batch")
+	flag.DurationVar(&timeout, "timeout", 10*time.Second, "timeout to connect to the executor")
+	flag.Parse()
+
+	if err := run(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if datadir == "" {
+		return fmt.Errorf("datadir is required")
+	}
+
+	ctx := context.Background()
+	archive, err := legacy_executor_verifier.OpenFailureArchive(ctx, datadir, 0)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	if id == 0 {
+		var batchNumber *uint64
+		if batch >= 0 {
+			b := uint64(batch)
+			batchNumber = &b
+		}
+		failures, err := archive.List(ctx, batchNumber)
+		if err != nil {
+			return err
+		}
+		for _, f := range failures {
+			fmt.Printf("id: %d batch: %d time: %s executor: %s tx-diffs: %d reasons: %v\n", f.Id, f.BatchNumber, f.Time.Format(time.RFC3339), f.ExecutorUrl, len(f.TxDiffs), f.Reasons)
+		}
+		return nil
+	}
+
+	failure, payload, err := archive.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if failure == nil {
+		return fmt.Errorf("failure %d not found", id)
+	}
+
+	asJson, err := json.MarshalIndent(failure, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println("---------------------------------------")
+	fmt.Printf("archived failure: %s\n", string(asJson))
+
+	if endpoint == "" {
+		return nil
+	}
+
+	e := legacy_executor_verifier.NewExecutor(endpoint, timeout, 1, "")
+	defer e.Close()
+
+	request := legacy_executor_verifier.NewVerifierRequest(failure.ForkId, failure.BatchNumber, failure.BlockNumbers, failure.StateRoot, failure.Counters)
+	ok, resp, executorErr, err := e.Verify(payload, request, failure.OldStateRoot)
+	if err != nil {
+		return err
+	}
+
+	newRoot := common.BytesToHash(resp.NewStateRoot)
+	fmt.Println("---------------------------------------")
+	fmt.Printf("verified: %t\n", ok)
+	fmt.Printf("executor error: %v\n", executorErr)
+	fmt.Printf("erigon root: %s\n", failure.StateRoot)
+	fmt.Printf("archived executor root: %s\n", failure.ExecutorStateRoot)
+	fmt.Printf("replayed executor root: %s\n", newRoot)
+	fmt.Printf("reproduced: %t\n", !ok && newRoot == failure.ExecutorStateRoot)
+	return nil
+}
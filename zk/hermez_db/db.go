@@ -1,6 +1,7 @@
 package hermez_db
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math"
@@ -53,6 +54,11 @@ const PLAIN_STATE_VERSION = "plain_state_version"                       // batch
 const ERIGON_VERSIONS = "erigon_versions"                               // erigon version -> timestamp of startup
 const BATCH_ENDS = "batch_ends"                                         //
 const BAD_TX_HASHES = "bad_tx_hashes"                                   // tx hash -> integer counter
+const OFF_CHAIN_DATA = "off_chain_data"                                 // data hash -> validium batch data
+const OFF_CHAIN_DATA_BATCHES = "off_chain_data_batches"                 // data hash + batch number -> nil
+const BATCH_OFF_CHAIN_DATA = "batch_off_chain_data"                     // batch number -> data hash
+const L1_BLOCK_HASHES = "l1_block_hashes"                               // l1 block number -> l1 block hash
+const L1_BATCH_DATA_BLOCKS = "l1_batch_data_blocks"                     // batch number -> l1 block number the batch was sequenced in
 
 var HermezDbTables = []string{
 	L1VERIFICATIONS,
@@ -90,6 +96,11 @@ var HermezDbTables = []string{
 	ERIGON_VERSIONS,
 	BATCH_ENDS,
 	BAD_TX_HASHES,
+	OFF_CHAIN_DATA,
+	OFF_CHAIN_DATA_BATCHES,
+	BATCH_OFF_CHAIN_DATA,
+	L1_BLOCK_HASHES,
+	L1_BATCH_DATA_BLOCKS,
 }
 
 type HermezDb struct {
@@ -673,6 +684,40 @@ func (db *HermezDb) TruncateVerifications(l2BlockNo uint64) error {
 	return nil
 }
 
+// DeleteSequencesFromL1Block deletes the sequences logged in the l1 block and the blocks after it
+func (db *HermezDb) DeleteSequencesFromL1Block(l1BlockNo uint64) error {
+	return db.deleteFromKey(L1SEQUENCES, ConcatKey(l1BlockNo, 0))
+}
+
+// DeleteVerificationsFromL1Block deletes the verifications logged in the l1 block and the blocks after it
+func (db *HermezDb) DeleteVerificationsFromL1Block(l1BlockNo uint64) error {
+	return db.deleteFromKey(L1VERIFICATIONS, ConcatKey(l1BlockNo, 0))
+}
+
+// deleteFromKey deletes the entries of the table from the key to the end
+func (db *HermezDb) deleteFromKey(table string, from []byte) error {
+	c, err := db.tx.Cursor(table)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var keys [][]byte
+	for k, _, err := c.Seek(from); k != nil; k, _, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		keys = append(keys, common.Copy(k))
+	}
+
+	for _, k := range keys {
+		if err = db.tx.Delete(table, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (db *HermezDb) WriteBlockBatch(l2BlockNo, batchNo uint64) error {
 	// first store the block -> batch record
 	err := db.tx.Put(BLOCKBATCHES, Uint64ToBytes(l2BlockNo), Uint64ToBytes(batchNo))
@@ -1285,6 +1330,78 @@ func (db *HermezDbReader) GetLatestL1InfoTreeUpdate() (*types.L1InfoTreeUpdate,
 	return result, nil
 }
 
+// DeleteL1InfoTreeUpdatesFromL1Block deletes the info tree updates logged in the l1 block and the blocks after it,
+// along with their leaves and roots. It returns the lowest index deleted.
+func (db *HermezDb) DeleteL1InfoTreeUpdatesFromL1Block(l1BlockNo uint64) (uint64, bool, error) {
+	var fromIndex uint64
+	deleted := false
+	for {
+		update, err := db.GetLatestL1InfoTreeUpdate()
+		if err != nil {
+			return 0, false, err
+		}
+		if update == nil || update.BlockNumber < l1BlockNo {
+			break
+		}
+
+		if err = db.tx.Delete(L1_INFO_TREE_UPDATES, Uint64ToBytes(update.Index)); err != nil {
+			return 0, false, err
+		}
+		if err = db.tx.Delete(L1_INFO_TREE_UPDATES_BY_GER, update.GER.Bytes()); err != nil {
+			return 0, false, err
+		}
+		if err = db.tx.Delete(L1_INFO_LEAVES, Uint64ToBytes(update.Index)); err != nil {
+			return 0, false, err
+		}
+		fromIndex, deleted = update.Index, true
+	}
+	if !deleted {
+		return 0, false, nil
+	}
+
+	indexToRoots, err := db.GetL1InfoTreeIndexToRoots()
+	if err != nil {
+		return 0, false, err
+	}
+	for index, root := range indexToRoots {
+		if index < fromIndex {
+			continue
+		}
+		if err = db.tx.Delete(L1_INFO_ROOTS, root.Bytes()); err != nil {
+			return 0, false, err
+		}
+	}
+
+	return fromIndex, true, nil
+}
+
+// GetFirstBlockWithL1InfoTreeIndexFrom returns the first l2 block using the info tree index or a later one. A block
+// can not use an index lower than the ones used before it, so the blocks are walked down from the last one until one
+// uses a lower index, the blocks with index 0 use none.
+func (db *HermezDbReader) GetFirstBlockWithL1InfoTreeIndexFrom(l1Index uint64) (uint64, bool, error) {
+	c, err := db.tx.Cursor(BLOCK_L1_INFO_TREE_INDEX)
+	if err != nil {
+		return 0, false, err
+	}
+	defer c.Close()
+
+	var blockNo uint64
+	found := false
+	for k, v, err := c.Last(); k != nil; k, v, err = c.Prev() {
+		if err != nil {
+			return 0, false, err
+		}
+		index := BytesToUint64(v)
+		if index >= l1Index {
+			blockNo, found = BytesToUint64(k), true
+		} else if index != 0 {
+			break
+		}
+	}
+
+	return blockNo, found, nil
+}
+
 func (db *HermezDb) WriteBlockL1InfoTreeIndex(blockNumber uint64, l1Index uint64) error {
 	k := Uint64ToBytes(blockNumber)
 	v := Uint64ToBytes(l1Index)
@@ -1478,6 +1595,20 @@ func (db *HermezDbReader) GetL1BatchData(batchNumber uint64) ([]byte, error) {
 	return db.tx.GetOne(L1_BATCH_DATA, k)
 }
 
+// WriteL1BatchDataBlock stores the l1 block the batch of the l1 batch data was sequenced in
+func (db *HermezDb) WriteL1BatchDataBlock(batchNumber, l1BlockNumber uint64) error {
+	return db.tx.Put(L1_BATCH_DATA_BLOCKS, Uint64ToBytes(batchNumber), Uint64ToBytes(l1BlockNumber))
+}
+
+// GetL1BatchDataBlock returns the l1 block the batch was sequenced in, false if it is not stored
+func (db *HermezDbReader) GetL1BatchDataBlock(batchNumber uint64) (uint64, bool, error) {
+	v, err := db.tx.GetOne(L1_BATCH_DATA_BLOCKS, Uint64ToBytes(batchNumber))
+	if err != nil || len(v) == 0 {
+		return 0, false, err
+	}
+	return BytesToUint64(v), true, nil
+}
+
 func (db *HermezDbReader) GetLastL1BatchData() (uint64, error) {
 	c, err := db.tx.Cursor(L1_BATCH_DATA)
 	if err != nil {
@@ -1493,6 +1624,114 @@ func (db *HermezDbReader) GetLastL1BatchData() (uint64, error) {
 	return BytesToUint64(k), nil
 }
 
+// WriteOffChainData stores the validium batch data fetched from the data availability service under its hash
+func (db *HermezDb) WriteOffChainData(hash common.Hash, data []byte) error {
+	return db.tx.Put(OFF_CHAIN_DATA, hash.Bytes(), data)
+}
+
+// GetOffChainData returns the validium batch data stored under the hash, nil if it was never fetched
+func (db *HermezDbReader) GetOffChainData(hash common.Hash) ([]byte, error) {
+	return db.tx.GetOne(OFF_CHAIN_DATA, hash.Bytes())
+}
+
+// WriteBatchOffChainDataHash links the batch to the hash of its off chain data, unlinking the data the batch had
+// before
+func (db *HermezDb) WriteBatchOffChainDataHash(batchNumber uint64, hash common.Hash) error {
+	previous, found, err := db.GetBatchOffChainDataHash(batchNumber)
+	if err != nil {
+		return err
+	}
+	if found && previous != hash {
+		if err = db.unlinkOffChainData(batchNumber, previous); err != nil {
+			return err
+		}
+	}
+
+	if err = db.tx.Put(BATCH_OFF_CHAIN_DATA, Uint64ToBytes(batchNumber), hash.Bytes()); err != nil {
+		return err
+	}
+	return db.tx.Put(OFF_CHAIN_DATA_BATCHES, append(hash.Bytes(), Uint64ToBytes(batchNumber)...), []byte{})
+}
+
+func (db *HermezDbReader) GetBatchOffChainDataHash(batchNumber uint64) (common.Hash, bool, error) {
+	v, err := db.tx.GetOne(BATCH_OFF_CHAIN_DATA, Uint64ToBytes(batchNumber))
+	if err != nil {
+		return common.Hash{}, false, err
+	}
+	if len(v) == 0 {
+		return common.Hash{}, false, nil
+	}
+	return common.BytesToHash(v), true, nil
+}
+
+// DeleteBatchOffChainData unlinks the batches from their off chain data, the data no other batch is linked to is
+// deleted
+func (db *HermezDb) DeleteBatchOffChainData(fromBatchNum, toBatchNum uint64) error {
+	for batchNum := fromBatchNum; batchNum <= toBatchNum; batchNum++ {
+		hash, found, err := db.GetBatchOffChainDataHash(batchNum)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		if err = db.tx.Delete(BATCH_OFF_CHAIN_DATA, Uint64ToBytes(batchNum)); err != nil {
+			return err
+		}
+		if err = db.unlinkOffChainData(batchNum, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteL1BatchDataFrom deletes the l1 data of the batch and of the batches after it, along with their l1 blocks and
+// their off chain data no earlier batch is linked to
+func (db *HermezDb) DeleteL1BatchDataFrom(fromBatchNum uint64) error {
+	if err := db.deleteFromKey(L1_BATCH_DATA, Uint64ToBytes(fromBatchNum)); err != nil {
+		return err
+	}
+	if err := db.deleteFromKey(L1_BATCH_DATA_BLOCKS, Uint64ToBytes(fromBatchNum)); err != nil {
+		return err
+	}
+
+	c, err := db.tx.Cursor(BATCH_OFF_CHAIN_DATA)
+	if err != nil {
+		return err
+	}
+	k, _, err := c.Last()
+	c.Close()
+	if err != nil {
+		return err
+	}
+	if k == nil || BytesToUint64(k) < fromBatchNum {
+		return nil
+	}
+
+	return db.DeleteBatchOffChainData(fromBatchNum, BytesToUint64(k))
+}
+
+func (db *HermezDb) unlinkOffChainData(batchNumber uint64, hash common.Hash) error {
+	if err := db.tx.Delete(OFF_CHAIN_DATA_BATCHES, append(hash.Bytes(), Uint64ToBytes(batchNumber)...)); err != nil {
+		return err
+	}
+
+	c, err := db.tx.Cursor(OFF_CHAIN_DATA_BATCHES)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	k, _, err := c.Seek(hash.Bytes())
+	if err != nil {
+		return err
+	}
+	if k != nil && bytes.HasPrefix(k, hash.Bytes()) {
+		return nil
+	}
+	return db.tx.Delete(OFF_CHAIN_DATA, hash.Bytes())
+}
+
 func (db *HermezDb) WriteLatestUsedGer(blockNumber uint64, ger common.Hash) error {
 	return db.tx.Put(LATEST_USED_GER, Uint64ToBytes(blockNumber), ger.Bytes())
 }
@@ -1904,3 +2143,48 @@ func (db *HermezDbReader) GetBadTxHashCounter(txHash common.Hash) (uint64, error
 	}
 	return BytesToUint64(v), nil
 }
+
+// WriteL1BlockHash stores the hash of an l1 block the l1 data was read from, to detect the l1 reorgs
+func (db *HermezDb) WriteL1BlockHash(l1BlockNo uint64, hash common.Hash) error {
+	return db.tx.Put(L1_BLOCK_HASHES, Uint64ToBytes(l1BlockNo), hash.Bytes())
+}
+
+func (db *HermezDbReader) GetL1BlockHash(l1BlockNo uint64) (common.Hash, bool, error) {
+	v, err := db.tx.GetOne(L1_BLOCK_HASHES, Uint64ToBytes(l1BlockNo))
+	if err != nil {
+		return common.Hash{}, false, err
+	}
+	return common.BytesToHash(v), len(v) > 0, nil
+}
+
+// GetL1BlockHashBefore returns the highest l1 block stored before the given one and its hash
+func (db *HermezDbReader) GetL1BlockHashBefore(l1BlockNo uint64) (uint64, common.Hash, bool, error) {
+	c, err := db.tx.Cursor(L1_BLOCK_HASHES)
+	if err != nil {
+		return 0, common.Hash{}, false, err
+	}
+	defer c.Close()
+
+	k, v, err := c.Seek(Uint64ToBytes(l1BlockNo))
+	if err != nil {
+		return 0, common.Hash{}, false, err
+	}
+	if k == nil {
+		k, v, err = c.Last()
+	} else {
+		k, v, err = c.Prev()
+	}
+	if err != nil {
+		return 0, common.Hash{}, false, err
+	}
+	if k == nil {
+		return 0, common.Hash{}, false, nil
+	}
+
+	return BytesToUint64(k), common.BytesToHash(v), true, nil
+}
+
+// DeleteL1BlockHashesFrom deletes the hashes of the l1 block and the blocks after it
+func (db *HermezDb) DeleteL1BlockHashesFrom(l1BlockNo uint64) error {
+	return db.deleteFromKey(L1_BLOCK_HASHES, Uint64ToBytes(l1BlockNo))
+}
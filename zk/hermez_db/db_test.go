@@ -9,6 +9,7 @@ import (
 	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/erigon/zk/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -622,3 +623,123 @@ func TestDeleteForkId(t *testing.T) {
 		})
 	}
 }
+
+func TestBatchOffChainData(t *testing.T) {
+	tx, cleanup := GetDbTx()
+	defer cleanup()
+	db := NewHermezDb(tx)
+
+	shared, own := common.HexToHash("0x1"), common.HexToHash("0x2")
+	require.NoError(t, db.WriteOffChainData(shared, []byte("shared")))
+	require.NoError(t, db.WriteOffChainData(own, []byte("own")))
+	for batchNo := uint64(1); batchNo <= 3; batchNo++ {
+		require.NoError(t, db.WriteBatchOffChainDataHash(batchNo, shared))
+	}
+	require.NoError(t, db.WriteBatchOffChainDataHash(4, own))
+
+	hash, found, err := db.GetBatchOffChainDataHash(2)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, shared, hash)
+
+	// the data still linked to the batch 1 is kept
+	require.NoError(t, db.DeleteBatchOffChainData(2, 4))
+	_, found, err = db.GetBatchOffChainDataHash(3)
+	require.NoError(t, err)
+	require.False(t, found)
+	data, err := db.GetOffChainData(shared)
+	require.NoError(t, err)
+	require.Equal(t, []byte("shared"), data)
+	data, err = db.GetOffChainData(own)
+	require.NoError(t, err)
+	require.Nil(t, data)
+
+	// linking the batch 1 to other data unlinks the data it had
+	require.NoError(t, db.WriteOffChainData(own, []byte("own")))
+	require.NoError(t, db.WriteBatchOffChainDataHash(1, own))
+	data, err = db.GetOffChainData(shared)
+	require.NoError(t, err)
+	require.Nil(t, data)
+	data, err = db.GetOffChainData(own)
+	require.NoError(t, err)
+	require.Equal(t, []byte("own"), data)
+}
+
+func TestDeleteL1DataFromL1Block(t *testing.T) {
+	tx, cleanup := GetDbTx()
+	defer cleanup()
+	db := NewHermezDb(tx)
+
+	for i := uint64(1); i <= 3; i++ {
+		l1BlockNo := i * 10
+		require.NoError(t, db.WriteSequence(l1BlockNo, i, common.Hash{}, common.Hash{}, common.Hash{}))
+		require.NoError(t, db.WriteVerification(l1BlockNo, i, common.Hash{}, common.Hash{byte(i)}))
+		require.NoError(t, db.WriteL1BlockHash(l1BlockNo, common.Hash{byte(i)}))
+
+		update := &types.L1InfoTreeUpdate{Index: i - 1, GER: common.Hash{byte(i)}, BlockNumber: l1BlockNo}
+		require.NoError(t, db.WriteL1InfoTreeUpdate(update))
+		require.NoError(t, db.WriteL1InfoTreeUpdateToGer(update))
+		require.NoError(t, db.WriteL1InfoTreeLeaf(update.Index, common.Hash{byte(i)}))
+		require.NoError(t, db.WriteL1InfoTreeRoot(common.Hash{byte(i + 10)}, update.Index))
+		require.NoError(t, db.WriteBlockL1InfoTreeIndex(i*100, update.Index))
+		// the blocks in between use no info tree update
+		require.NoError(t, db.WriteBlockL1InfoTreeIndex(i*100+50, 0))
+	}
+
+	blockNo, hash, found, err := db.GetL1BlockHashBefore(30)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, uint64(20), blockNo)
+	require.Equal(t, common.Hash{2}, hash)
+	blockNo, _, found, err = db.GetL1BlockHashBefore(math.MaxUint64)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, uint64(30), blockNo)
+	_, _, found, err = db.GetL1BlockHashBefore(10)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, db.DeleteSequencesFromL1Block(20))
+	require.NoError(t, db.DeleteVerificationsFromL1Block(20))
+	require.NoError(t, db.DeleteL1BlockHashesFrom(20))
+	fromIndex, deleted, err := db.DeleteL1InfoTreeUpdatesFromL1Block(20)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	require.Equal(t, uint64(1), fromIndex)
+
+	sequence, err := db.GetLatestSequence()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), sequence.BatchNo)
+	verification, err := db.GetLatestVerification()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), verification.BatchNo)
+	_, found, err = db.GetL1BlockHash(20)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	update, err := db.GetLatestL1InfoTreeUpdate()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), update.Index)
+	update, err = db.GetL1InfoTreeUpdateByGer(common.Hash{2})
+	require.NoError(t, err)
+	require.Nil(t, update)
+	leaves, err := db.GetAllL1InfoTreeLeaves()
+	require.NoError(t, err)
+	require.Len(t, leaves, 1)
+	roots, err := db.GetL1InfoTreeIndexToRoots()
+	require.NoError(t, err)
+	require.Equal(t, map[uint64]common.Hash{0: {11}}, roots)
+
+	l2BlockNo, found, err := db.GetFirstBlockWithL1InfoTreeIndexFrom(fromIndex)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, uint64(200), l2BlockNo)
+	_, found, err = db.GetFirstBlockWithL1InfoTreeIndexFrom(3)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// nothing left to delete
+	_, deleted, err = db.DeleteL1InfoTreeUpdatesFromL1Block(20)
+	require.NoError(t, err)
+	require.False(t, deleted)
+}
@@ -3,162 +3,507 @@ package l1_cache
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"errors"
-
+	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
 	"github.com/ledgerwatch/log/v3"
 )
 
 const (
-	bucketName   = "Cache"
-	expiryBucket = "Expiry"
+	responsesBucket = "Responses" // cache key -> expiry + access sequence + result
+	orderBucket     = "Order"     // access sequence -> cache key, the least recently used first
+
+	// buckets of the previous layout, caching whole responses with no size bound
+	legacyCacheBucket  = "Cache"
+	legacyExpiryBucket = "Expiry"
+
+	entryHeaderLen = 16
 )
 
-// methods we don't cache
-var methodsToIgnore = map[string]struct{}{}
+type Config struct {
+	// MaxSize bounds the bytes of the results kept, the least recently used are evicted past it. 0 keeps everything.
+	MaxSize uint64
+	// LatestTTL is how long the results about blocks which are not finalized yet are kept, 0 does not cache them
+	LatestTTL time.Duration
+}
+
+// L1Cache caches the responses of the L1 RPC. It is used in process by the etherman clients through Transport, and
+// can be served to other nodes by Serve.
+type L1Cache struct {
+	ctx context.Context
+	db  kv.RwDB
+	cfg Config
 
-// methods we configure expiry for
-var methodsToExpire = map[string]time.Duration{
-	//"eth_getBlockByNumber": 1 * time.Minute, // example here but currently we don't want any methods to expire
+	mu   sync.Mutex
+	size uint64
+	seq  atomic.Uint64
+
+	// the hits are only kept in memory and written with the next put, which is when the recency is needed to evict.
+	// Every key is given the sequence of its last hit, so the order of the hits in between is kept.
+	touchedMu sync.Mutex
+	touched   map[string]uint64
+
+	finalizedMu sync.RWMutex
+	finalized   map[string]uint64 // chain id -> highest finalized l1 block seen
 }
 
-// params that trigger expiration
-var paramsToExpire = map[string]struct{}{
-	"latest":    {},
-	"finalized": {},
+type rpcRequest struct {
+	JsonRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id,omitempty"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params,omitempty"`
 }
 
-type L1Cache struct {
-	server *http.Server
-	db     kv.RwDB
+type rpcResponse struct {
+	JsonRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
 }
 
-func NewL1Cache(ctx context.Context, dbPath string, port uint) (*L1Cache, error) {
+// forwardFunc sends a request body to the l1 and returns the response body and status
+type forwardFunc func(ctx context.Context, body []byte) ([]byte, int, error)
+
+func NewL1Cache(ctx context.Context, dbPath string, cfg Config) (*L1Cache, error) {
 	db := mdbx.NewMDBX(log.New()).Path(dbPath).MustOpen()
 
-	tx, err := db.BeginRw(ctx)
-	if err != nil {
-		return nil, err
+	c := &L1Cache{
+		ctx:       ctx,
+		db:        db,
+		cfg:       cfg,
+		touched:   make(map[string]uint64),
+		finalized: make(map[string]uint64),
 	}
-	defer tx.Rollback()
-
-	if err := tx.CreateBucket(bucketName); err != nil {
+	if err := c.open(); err != nil {
+		db.Close()
 		return nil, err
 	}
-	if err := tx.CreateBucket(expiryBucket); err != nil {
-		return nil, err
+
+	go func() {
+		<-ctx.Done()
+		db.Close()
+	}()
+
+	return c, nil
+}
+
+// open creates the buckets, drops the ones of the previous layout and loads the size and sequence of the entries
+func (c *L1Cache) open() error {
+	return c.db.Update(c.ctx, func(tx kv.RwTx) error {
+		for _, bucket := range []string{legacyCacheBucket, legacyExpiryBucket} {
+			exists, err := tx.ExistsBucket(bucket)
+			if err != nil {
+				return err
+			}
+			if exists {
+				if err = tx.DropBucket(bucket); err != nil {
+					return err
+				}
+			}
+		}
+		for _, bucket := range []string{responsesBucket, orderBucket} {
+			if err := tx.CreateBucket(bucket); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.ForEach(responsesBucket, nil, func(k, v []byte) error {
+			c.size += uint64(len(v) - entryHeaderLen)
+			return nil
+		}); err != nil {
+			return err
+		}
+		last, _, err := lastOrder(tx)
+		if err != nil {
+			return err
+		}
+		c.seq.Store(last)
+
+		sizeGauge.SetUint64(c.size)
+		return nil
+	})
+}
+
+func lastOrder(tx kv.RwTx) (uint64, []byte, error) {
+	cursor, err := tx.Cursor(orderBucket)
+	if err != nil {
+		return 0, nil, err
 	}
-	if err := tx.Commit(); err != nil {
-		return nil, err
+	defer cursor.Close()
+	k, v, err := cursor.Last()
+	if err != nil || k == nil {
+		return 0, nil, err
 	}
+	return binary.BigEndian.Uint64(k), v, nil
+}
 
-	http.HandleFunc("/", handleRequest(db))
-	addr := fmt.Sprintf(":%d", port)
-	server := &http.Server{
-		Addr:           addr,
-		Handler:        nil,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
+func cacheKey(chainID string, request *rpcRequest) (string, error) {
+	params, err := json.Marshal(request.Params)
+	if err != nil {
+		return "", err
 	}
+	return fmt.Sprintf("%s_%s_%s", chainID, request.Method, params), nil
+}
 
-	go func() {
-		log.Info("Starting L1 Cache Server on port:", "port", port)
-		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-			log.Error("L1 Cache Server stopped", "error", err)
+// get returns the cached result of the key within a read transaction, the hit is recorded in memory and moves the
+// result to the most recently used with the next put
+func (c *L1Cache) get(key string) (json.RawMessage, bool) {
+	var result json.RawMessage
+	if err := c.db.View(c.ctx, func(tx kv.Tx) error {
+		entry, err := tx.GetOne(responsesBucket, []byte(key))
+		if err != nil || len(entry) < entryHeaderLen {
+			return err
 		}
-	}()
 
-	go func() {
-		<-ctx.Done()
-		log.Info("Shutting down L1 Cache Server...")
-		if err := server.Shutdown(context.Background()); err != nil {
-			log.Error("Failed to shutdown L1 Cache Server", "error", err)
+		// an expired result is a miss, it is replaced by the put of the response
+		expiresAt := int64(binary.BigEndian.Uint64(entry[:8]))
+		if expiresAt > 0 && time.Now().UnixNano() > expiresAt {
+			return nil
 		}
-		db.Close()
-	}()
 
-	return &L1Cache{
-		server: server,
-		db:     db,
-	}, nil
+		result = common.Copy(entry[entryHeaderLen:])
+		return nil
+	}); err != nil {
+		log.Warn("[l1-cache] Failed to read the cache", "err", err)
+		return nil, false
+	}
+
+	if result != nil {
+		c.touch(key)
+	}
+	return result, result != nil
 }
 
-func fetchFromCache(tx kv.RwTx, key string) ([]byte, bool) {
-	data, err := tx.GetOne(bucketName, []byte(key))
-	if err != nil || data == nil {
-		return nil, false
+// touch records a hit of the key, it is only needed to evict so nothing is recorded without a max size
+func (c *L1Cache) touch(key string) {
+	if c.cfg.MaxSize == 0 {
+		return
 	}
 
-	expiry, err := tx.GetOne(expiryBucket, []byte(key))
-	if err == nil && expiry != nil {
-		expiryTime, err := time.Parse(time.RFC3339, string(expiry))
-		if err == nil && time.Now().After(expiryTime) {
-			// Cache entry has expired
-			evictFromCache(tx, key)
-			return nil, false
+	c.touchedMu.Lock()
+	c.touched[key] = c.seq.Add(1)
+	c.touchedMu.Unlock()
+}
+
+// flushTouched moves the results hit since the last put to the sequence of their last hit, and returns the hits
+// written, to be forgotten once the transaction is committed
+func (c *L1Cache) flushTouched(tx kv.RwTx) (map[string]uint64, error) {
+	c.touchedMu.Lock()
+	touched := make(map[string]uint64, len(c.touched))
+	for key, seq := range c.touched {
+		touched[key] = seq
+	}
+	c.touchedMu.Unlock()
+
+	seqBytes := make([]byte, 8)
+	for key, seq := range touched {
+		entry, err := tx.GetOne(responsesBucket, []byte(key))
+		if err != nil {
+			return nil, err
+		}
+		// evicted, or put again since the hit
+		if len(entry) < entryHeaderLen || binary.BigEndian.Uint64(entry[8:16]) >= seq {
+			continue
+		}
+
+		if err = tx.Delete(orderBucket, entry[8:16]); err != nil {
+			return nil, err
+		}
+		updated := common.Copy(entry)
+		binary.BigEndian.PutUint64(seqBytes, seq)
+		copy(updated[8:16], seqBytes)
+		if err = tx.Put(orderBucket, seqBytes, []byte(key)); err != nil {
+			return nil, err
+		}
+		if err = tx.Put(responsesBucket, []byte(key), updated); err != nil {
+			return nil, err
 		}
 	}
 
-	// Check if the cached response contains an error
-	var jsonResponse map[string]interface{}
-	if err := json.Unmarshal(data, &jsonResponse); err == nil {
-		if _, hasError := jsonResponse["error"]; hasError {
-			// Cache entry is an error, evict it
-			evictFromCache(tx, key)
-			return nil, false
+	return touched, nil
+}
+
+// forgetTouched drops the hits written by a committed put, keeping the ones hit again since
+func (c *L1Cache) forgetTouched(written map[string]uint64) {
+	c.touchedMu.Lock()
+	defer c.touchedMu.Unlock()
+	for key, seq := range written {
+		if c.touched[key] == seq {
+			delete(c.touched, key)
 		}
 	}
+}
+
+// put caches the result of the key, evicting the least recently used results past the max size
+func (c *L1Cache) put(key string, result json.RawMessage, exp expiry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	return data, true
+	if c.cfg.MaxSize > 0 && uint64(len(result)) > c.cfg.MaxSize {
+		return
+	}
+
+	// the sequence is not rolled back on failure, the hits not yet written hold sequences past it
+	size := c.size
+	var written map[string]uint64
+	if err := c.db.Update(c.ctx, func(tx kv.RwTx) error {
+		var err error
+		if written, err = c.flushTouched(tx); err != nil {
+			return err
+		}
+
+		previous, err := tx.GetOne(responsesBucket, []byte(key))
+		if err != nil {
+			return err
+		}
+		if len(previous) >= entryHeaderLen {
+			if err = c.delete(tx, []byte(key), previous); err != nil {
+				return err
+			}
+		}
+
+		entry := make([]byte, entryHeaderLen+len(result))
+		if !exp.immutable {
+			binary.BigEndian.PutUint64(entry[:8], uint64(time.Now().Add(exp.ttl).UnixNano()))
+		}
+		binary.BigEndian.PutUint64(entry[8:16], c.seq.Add(1))
+		copy(entry[entryHeaderLen:], result)
+		if err = tx.Put(responsesBucket, []byte(key), entry); err != nil {
+			return err
+		}
+		if err = tx.Put(orderBucket, entry[8:16], []byte(key)); err != nil {
+			return err
+		}
+		c.size += uint64(len(result))
+
+		return c.evict(tx)
+	}); err != nil {
+		log.Warn("[l1-cache] Failed to write the cache", "err", err)
+		c.size = size
+	} else {
+		c.forgetTouched(written)
+	}
+	sizeGauge.SetUint64(c.size)
 }
 
-func evictFromCache(tx kv.RwTx, key string) {
-	if err := tx.Delete(bucketName, []byte(key)); err != nil {
-		log.Warn("Failed to evict from cache", "error", err)
+func (c *L1Cache) delete(tx kv.RwTx, key, entry []byte) error {
+	if err := tx.Delete(orderBucket, entry[8:16]); err != nil {
+		return err
 	}
-	if err := tx.Delete(expiryBucket, []byte(key)); err != nil {
-		log.Warn("Failed to evict from cache", "error", err)
+	if err := tx.Delete(responsesBucket, key); err != nil {
+		return err
 	}
+	c.size -= uint64(len(entry) - entryHeaderLen)
+	return nil
 }
 
-func saveToCache(tx kv.RwTx, key string, response []byte, duration time.Duration) error {
-	if err := tx.Put(bucketName, []byte(key), response); err != nil {
+// evict deletes the least recently used results until the cache fits its max size
+func (c *L1Cache) evict(tx kv.RwTx) error {
+	if c.cfg.MaxSize == 0 {
+		return nil
+	}
+
+	cursor, err := tx.RwCursor(orderBucket)
+	if err != nil {
 		return err
 	}
-	// Only set expiry if duration is not zero (indicating that it should expire)
-	if duration > 0 {
-		expiryTime := time.Now().Add(duration).Format(time.RFC3339)
-		if err := tx.Put(expiryBucket, []byte(key), []byte(expiryTime)); err != nil {
+	defer cursor.Close()
+
+	for c.size > c.cfg.MaxSize {
+		seq, key, err := cursor.First()
+		if err != nil {
+			return err
+		}
+		if seq == nil {
+			return nil
+		}
+		key = append([]byte(nil), key...)
+		entry, err := tx.GetOne(responsesBucket, key)
+		if err != nil {
+			return err
+		}
+		if err = cursor.DeleteCurrent(); err != nil {
 			return err
 		}
+		if err = tx.Delete(responsesBucket, key); err != nil {
+			return err
+		}
+		if len(entry) >= entryHeaderLen {
+			c.size -= uint64(len(entry) - entryHeaderLen)
+		}
+		evictionsCounter.Inc()
 	}
 	return nil
 }
 
-func generateCacheKey(chainID string, body []byte) (string, error) {
-	var request map[string]interface{}
-	err := json.Unmarshal(body, &request)
-	if err != nil {
-		return "", err
+func (c *L1Cache) getFinalized(chainID string) uint64 {
+	c.finalizedMu.RLock()
+	defer c.finalizedMu.RUnlock()
+	return c.finalized[chainID]
+}
+
+func (c *L1Cache) setFinalized(chainID string, blockNo uint64) {
+	c.finalizedMu.Lock()
+	defer c.finalizedMu.Unlock()
+	if blockNo > c.finalized[chainID] {
+		c.finalized[chainID] = blockNo
 	}
-	delete(request, "id")
-	modifiedBody, err := json.Marshal(request)
-	if err != nil {
-		return "", err
+}
+
+// handle serves a single or batched JSON-RPC request body from the cache, forwarding the requests it does not have
+func (c *L1Cache) handle(ctx context.Context, chainID string, body []byte, forward forwardFunc) ([]byte, int, error) {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return c.handleBatch(ctx, chainID, body, forward)
 	}
-	return fmt.Sprintf("%s_%s", chainID, modifiedBody), nil
+
+	var request rpcRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid JSON-RPC request: %w", err)
+	}
+
+	return c.serve(ctx, chainID, []*rpcRequest{&request}, func(ctx context.Context, _ []*rpcRequest) ([]byte, int, error) {
+		// the single request is forwarded as it was received
+		return forward(ctx, body)
+	}, false)
 }
 
-func handleRequest(db kv.RwDB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+func (c *L1Cache) handleBatch(ctx context.Context, chainID string, body []byte, forward forwardFunc) ([]byte, int, error) {
+	var requests []*rpcRequest
+	if err := json.Unmarshal(body, &requests); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid JSON-RPC batch: %w", err)
+	}
+
+	return c.serve(ctx, chainID, requests, func(ctx context.Context, misses []*rpcRequest) ([]byte, int, error) {
+		missesBody, err := json.Marshal(misses)
+		if err != nil {
+			return nil, 0, err
+		}
+		return forward(ctx, missesBody)
+	}, true)
+}
+
+// serve answers the requests from the cache and forwards the misses, caching the results of their responses. The
+// upstream response is returned as is when it is not a successful JSON-RPC response.
+func (c *L1Cache) serve(ctx context.Context, chainID string, requests []*rpcRequest, forward func(context.Context, []*rpcRequest) ([]byte, int, error), batch bool) ([]byte, int, error) {
+	responses := make([]*rpcResponse, len(requests))
+	keys := make([]string, len(requests))
+	var misses []*rpcRequest
+	var missIdx []int
+
+	for i, request := range requests {
+		if _, cached := methodPolicies[request.Method]; cached && request.ID != nil {
+			key, err := cacheKey(chainID, request)
+			if err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+			keys[i] = key
+			if result, found := c.get(key); found {
+				methodCounter(request.Method, true).Inc()
+				responses[i] = &rpcResponse{JsonRPC: "2.0", ID: request.ID, Result: result}
+				continue
+			}
+			methodCounter(request.Method, false).Inc()
+		}
+		misses = append(misses, request)
+		missIdx = append(missIdx, i)
+	}
+
+	if len(misses) > 0 {
+		upstream, status, err := forward(ctx, misses)
+		if err != nil {
+			return nil, http.StatusBadGateway, err
+		}
+		if status != http.StatusOK {
+			return upstream, status, nil
+		}
+
+		var upstreamResponses []*rpcResponse
+		if batch {
+			if err = json.Unmarshal(upstream, &upstreamResponses); err != nil {
+				return upstream, status, nil
+			}
+		} else {
+			var response rpcResponse
+			if err = json.Unmarshal(upstream, &response); err != nil {
+				return upstream, status, nil
+			}
+			upstreamResponses = []*rpcResponse{&response}
+		}
+
+		// the responses of a batch come in any order, they are matched by id
+		byID := make(map[string]*rpcResponse, len(upstreamResponses))
+		for _, response := range upstreamResponses {
+			byID[string(response.ID)] = response
+		}
+		for _, i := range missIdx {
+			request := requests[i]
+			response := byID[string(request.ID)]
+			if !batch {
+				response = upstreamResponses[0]
+			}
+			if response == nil {
+				continue
+			}
+			responses[i] = response
+			c.cacheResponse(chainID, keys[i], request, response)
+		}
+
+		if !batch {
+			return upstream, status, nil
+		}
+	}
+
+	if !batch {
+		out, err := json.Marshal(responses[0])
+		return out, http.StatusOK, err
+	}
+
+	answered := make([]*rpcResponse, 0, len(responses))
+	for _, response := range responses {
+		if response != nil {
+			answered = append(answered, response)
+		}
+	}
+	out, err := json.Marshal(answered)
+	return out, http.StatusOK, err
+}
+
+func (c *L1Cache) cacheResponse(chainID, key string, request *rpcRequest, response *rpcResponse) {
+	if len(response.Error) > 0 || len(response.Result) == 0 || key == "" {
+		return
+	}
+	if blockNo, ok := finalizedBlockNo(request.Method, request.Params, response.Result); ok {
+		c.setFinalized(chainID, blockNo)
+	}
+	// a null result is an unknown block or transaction, it may be known later
+	if string(response.Result) == "null" {
+		return
+	}
+
+	policy := methodPolicies[request.Method]
+	exp, ok := policy(request.Params, response.Result, c.getFinalized(chainID), c.cfg.LatestTTL)
+	if !ok {
+		return
+	}
+	c.put(key, response.Result, exp)
+}
+
+// Handler serves the cache to other nodes, the l1 endpoint and the chain id keying the cache are passed in the
+// query, e.g. http://localhost:6969?endpoint=http%3A%2F%2Fsepolia-rpc.com&chainid=2440
+func (c *L1Cache) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		endpoint := r.URL.Query().Get("endpoint")
 		chainID := r.URL.Query().Get("chainid")
 		if endpoint == "" || chainID == "" {
@@ -173,97 +518,127 @@ func handleRequest(db kv.RwDB) http.HandlerFunc {
 		}
 		defer r.Body.Close()
 
-		var request map[string]interface{}
-		if err := json.Unmarshal(body, &request); err != nil {
-			http.Error(w, "Invalid JSON-RPC request", http.StatusBadRequest)
+		response, status, err := c.handle(r.Context(), chainID, body, func(ctx context.Context, body []byte) ([]byte, int, error) {
+			request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+			if err != nil {
+				return nil, 0, err
+			}
+			request.Header.Set("Content-Type", "application/json")
+			return roundTrip(http.DefaultTransport, request)
+		})
+		if err != nil {
+			http.Error(w, err.Error(), status)
 			return
 		}
 
-		method, ok := request["method"].(string)
-		if !ok {
-			http.Error(w, "Invalid JSON-RPC method", http.StatusBadRequest)
-			return
-		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(response)
+	})
+}
 
-		cacheKey, err := generateCacheKey(chainID, body)
-		if err != nil {
-			http.Error(w, "Failed to generate cache key", http.StatusInternalServerError)
-			return
-		}
+// Serve starts the cache server on the port, it is shut down with the context of the cache
+func (c *L1Cache) Serve(port uint) {
+	mux := http.NewServeMux()
+	mux.Handle("/", c.Handler())
+	server := &http.Server{
+		Addr:           fmt.Sprintf(":%d", port),
+		Handler:        mux,
+		ReadTimeout:    10 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
 
-		if _, ignore := methodsToIgnore[method]; !ignore {
-			tx, err := db.BeginRw(r.Context())
-			if err != nil {
-				http.Error(w, "Failed to begin transaction", http.StatusInternalServerError)
-				return
-			}
-			if cachedResponse, found := fetchFromCache(tx, cacheKey); found {
-				w.Header().Set("Content-Type", "application/json")
-				w.Header().Set("X-Cache-Status", "HIT")
-				w.Write(cachedResponse)
-				tx.Commit()
-				return
-			}
-			tx.Rollback()
+	go func() {
+		log.Info("Starting L1 Cache Server on port:", "port", port)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Error("L1 Cache Server stopped", "error", err)
 		}
+	}()
 
-		resp, err := http.Post(endpoint, "application/json", bytes.NewBuffer(body))
-		if err != nil {
-			http.Error(w, "Failed to fetch from upstream", http.StatusInternalServerError)
-			return
+	go func() {
+		<-c.ctx.Done()
+		log.Info("Shutting down L1 Cache Server...")
+		if err := server.Shutdown(context.Background()); err != nil {
+			log.Error("Failed to shutdown L1 Cache Server", "error", err)
 		}
-		defer resp.Body.Close()
+	}()
+}
 
-		responseBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			http.Error(w, "Failed to read upstream response", http.StatusInternalServerError)
-			return
-		}
+// Transport returns a round tripper serving the JSON-RPC requests sent through it from the cache, the misses are sent
+// with next
+func (c *L1Cache) Transport(chainID uint64, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{cache: c, chainID: strconv.FormatUint(chainID, 10), next: next}
+}
 
-		if resp.StatusCode == http.StatusOK {
-			// Check if the response contains a JSON-RPC error
-			var jsonResponse map[string]interface{}
-			if err := json.Unmarshal(responseBody, &jsonResponse); err == nil {
-				if _, hasError := jsonResponse["error"]; hasError {
-					log.Warn("Received error response from upstream, not caching", "error", jsonResponse["error"])
-				} else {
-					if _, ignore := methodsToIgnore[method]; !ignore {
-						cacheDuration := time.Duration(0)
-						if duration, found := methodsToExpire[method]; found {
-							if method == "eth_getBlockByNumber" {
-								params, ok := request["params"].([]interface{})
-								if ok && len(params) > 0 {
-									param, ok := params[0].(string)
-									if ok {
-										if _, shouldExpire := paramsToExpire[param]; shouldExpire {
-											cacheDuration = duration
-										}
-									}
-								}
-							} else {
-								cacheDuration = duration
-							}
-						}
-						tx, err := db.BeginRw(r.Context())
-						if err != nil {
-							http.Error(w, "Failed to begin transaction", http.StatusInternalServerError)
-							return
-						}
-						defer tx.Rollback()
-						if err := saveToCache(tx, cacheKey, responseBody, cacheDuration); err != nil {
-							http.Error(w, "Failed to save to cache", http.StatusInternalServerError)
-							return
-						}
-						tx.Commit()
-					}
-				}
-			} else {
-				fmt.Println("Failed to parse upstream response, not caching")
-			}
+// EndpointTransport is a Transport keeping the results of every endpoint apart, so the clients of different endpoints
+// sharing the cache still get the answer of their own endpoint, as a quorum of them needs
+func (c *L1Cache) EndpointTransport(chainID uint64, next http.RoundTripper) http.RoundTripper {
+	t := c.Transport(chainID, next).(*transport)
+	t.perEndpoint = true
+	return t
+}
+
+type transport struct {
+	cache       *L1Cache
+	chainID     string
+	perEndpoint bool
+	next        http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method != http.MethodPost || r.Body == nil {
+		return t.next.RoundTrip(r)
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := t.chainID
+	if t.perEndpoint {
+		namespace = fmt.Sprintf("%s@%s%s", t.chainID, r.URL.Host, r.URL.Path)
+	}
+
+	response, status, err := t.cache.handle(r.Context(), namespace, body, func(ctx context.Context, body []byte) ([]byte, int, error) {
+		forwarded := r.Clone(ctx)
+		forwarded.Body = io.NopCloser(bytes.NewReader(body))
+		forwarded.ContentLength = int64(len(body))
+		forwarded.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
 		}
+		return roundTrip(t.next, forwarded)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("X-Cache-Status", "MISS")
-		w.Write(responseBody)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(response)),
+		ContentLength: int64(len(response)),
+		Request:       r,
+	}, nil
+}
+
+func roundTrip(rt http.RoundTripper, r *http.Request) ([]byte, int, error) {
+	response, err := rt.RoundTrip(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, 0, err
 	}
+	return body, response.StatusCode, nil
 }
@@ -0,0 +1,309 @@
+package l1_cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/stretchr/testify/require"
+)
+
+// upstream answers the requests with results set per method, counting the requests it receives
+type upstream struct {
+	mu      sync.Mutex
+	results map[string]string
+	calls   map[string]int
+	batches int
+}
+
+func newUpstream(results map[string]string) *upstream {
+	return &upstream{results: results, calls: make(map[string]int)}
+}
+
+func (u *upstream) answer(request rpcRequest) rpcResponse {
+	u.calls[request.Method]++
+	result, ok := u.results[request.Method]
+	if !ok {
+		return rpcResponse{JsonRPC: "2.0", ID: request.ID, Error: json.RawMessage(`{"code":-32601,"message":"method not found"}`)}
+	}
+	return rpcResponse{JsonRPC: "2.0", ID: request.ID, Result: json.RawMessage(result)}
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	var out []byte
+	if bytes.HasPrefix(body, []byte("[")) {
+		u.batches++
+		var requests []rpcRequest
+		_ = json.Unmarshal(body, &requests)
+		// the responses are sent in reverse order, they are matched by id
+		responses := make([]rpcResponse, len(requests))
+		for i, request := range requests {
+			responses[len(requests)-1-i] = u.answer(request)
+		}
+		out, _ = json.Marshal(responses)
+	} else {
+		var request rpcRequest
+		_ = json.Unmarshal(body, &request)
+		out, _ = json.Marshal(u.answer(request))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
+
+func (u *upstream) callsOf(method string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.calls[method]
+}
+
+func newTestCache(t *testing.T, cfg Config) *L1Cache {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	cache, err := NewL1Cache(ctx, t.TempDir(), cfg)
+	require.NoError(t, err)
+	return cache
+}
+
+func post(t *testing.T, client *http.Client, url string, body string) string {
+	response, err := client.Post(url, "application/json", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	out, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestTransport(t *testing.T) {
+	up := newUpstream(map[string]string{
+		"eth_chainId":               `"0x1"`,
+		"eth_blockNumber":           `"0x20"`,
+		"eth_getBlockByNumber":      `{"number":"0x10"}`,
+		"eth_getTransactionReceipt": `{"blockNumber":"0x5"}`,
+		"eth_getLogs":               `[]`,
+	})
+	server := httptest.NewServer(up)
+	defer server.Close()
+
+	cache := newTestCache(t, Config{})
+	client := &http.Client{Transport: cache.Transport(1, nil)}
+
+	// the cached response is returned with the id of the request
+	post(t, client, server.URL, `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":2,"result":"0x1"}`, post(t, client, server.URL, `{"jsonrpc":"2.0","id":2,"method":"eth_chainId","params":[]}`))
+	require.Equal(t, 1, up.callsOf("eth_chainId"))
+
+	// the data of the blocks which are not finalized is not cached without a latest ttl
+	receipt := `{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionReceipt","params":["0x01"]}`
+	post(t, client, server.URL, receipt)
+	post(t, client, server.URL, receipt)
+	require.Equal(t, 2, up.callsOf("eth_getTransactionReceipt"))
+
+	// once the block is known finalized, its receipts are immutable
+	post(t, client, server.URL, `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["finalized",false]}`)
+	post(t, client, server.URL, receipt)
+	post(t, client, server.URL, receipt)
+	require.Equal(t, 3, up.callsOf("eth_getTransactionReceipt"))
+
+	logs := `{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"fromBlock":"0x1","toBlock":"0x10"}]}`
+	post(t, client, server.URL, logs)
+	post(t, client, server.URL, logs)
+	require.Equal(t, 1, up.callsOf("eth_getLogs"))
+
+	// only the misses of a batch are forwarded, the responses keep the order of the requests
+	batch := `[{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]},{"jsonrpc":"2.0","id":2,"method":"eth_chainId","params":[]},{"jsonrpc":"2.0","id":3,"method":"eth_getLogs","params":[{"fromBlock":"0x1","toBlock":"0x10"}]}]`
+	require.JSONEq(t,
+		`[{"jsonrpc":"2.0","id":1,"result":"0x20"},{"jsonrpc":"2.0","id":2,"result":"0x1"},{"jsonrpc":"2.0","id":3,"result":[]}]`,
+		post(t, client, server.URL, batch),
+	)
+	require.Equal(t, 1, up.callsOf("eth_blockNumber"))
+	require.Equal(t, 1, up.callsOf("eth_chainId"))
+	require.Equal(t, 1, up.callsOf("eth_getLogs"))
+
+	// errors are never cached
+	unknown := `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByHash","params":["0x01",false]}`
+	post(t, client, server.URL, unknown)
+	post(t, client, server.URL, unknown)
+	require.Equal(t, 2, up.callsOf("eth_getBlockByHash"))
+}
+
+func TestLatestTTL(t *testing.T) {
+	up := newUpstream(map[string]string{"eth_blockNumber": `"0x20"`})
+	server := httptest.NewServer(up)
+	defer server.Close()
+
+	cache := newTestCache(t, Config{LatestTTL: 50 * time.Millisecond})
+	client := &http.Client{Transport: cache.Transport(1, nil)}
+
+	request := `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`
+	post(t, client, server.URL, request)
+	post(t, client, server.URL, request)
+	require.Equal(t, 1, up.callsOf("eth_blockNumber"))
+
+	time.Sleep(100 * time.Millisecond)
+	post(t, client, server.URL, request)
+	require.Equal(t, 2, up.callsOf("eth_blockNumber"))
+}
+
+func TestEviction(t *testing.T) {
+	up := newUpstream(map[string]string{"eth_getBlockByHash": `{"number":"0x1"}`})
+	server := httptest.NewServer(up)
+	defer server.Close()
+
+	result := `{"number":"0x1"}`
+	cache := newTestCache(t, Config{MaxSize: uint64(2 * len(result))})
+	client := &http.Client{Transport: cache.Transport(1, nil)}
+
+	request := func(hash string) string {
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByHash","params":["%s",false]}`, hash)
+	}
+	post(t, client, server.URL, request("0x01"))
+	post(t, client, server.URL, request("0x02"))
+	// reading the first one makes the second one the least recently used
+	post(t, client, server.URL, request("0x01"))
+	post(t, client, server.URL, request("0x03"))
+	require.Equal(t, 3, up.callsOf("eth_getBlockByHash"))
+	require.Equal(t, uint64(2*len(result)), cache.size)
+
+	post(t, client, server.URL, request("0x01"))
+	require.Equal(t, 3, up.callsOf("eth_getBlockByHash"))
+	post(t, client, server.URL, request("0x02"))
+	require.Equal(t, 4, up.callsOf("eth_getBlockByHash"))
+}
+
+func TestHitsWrittenWithPut(t *testing.T) {
+	cache := newTestCache(t, Config{MaxSize: 1 << 10})
+	immutable := expiry{immutable: true}
+
+	entrySeq := func(key string) uint64 {
+		var seq uint64
+		require.NoError(t, cache.db.View(context.Background(), func(tx kv.Tx) error {
+			entry, err := tx.GetOne(responsesBucket, []byte(key))
+			if err == nil && len(entry) >= entryHeaderLen {
+				seq = binary.BigEndian.Uint64(entry[8:16])
+			}
+			return err
+		}))
+		return seq
+	}
+
+	cache.put("a", json.RawMessage(`"a"`), immutable)
+	before := entrySeq("a")
+
+	// a hit only reads the database
+	result, found := cache.get("a")
+	require.True(t, found)
+	require.Equal(t, `"a"`, string(result))
+	require.Equal(t, before, entrySeq("a"))
+	require.Len(t, cache.touched, 1)
+
+	cache.put("b", json.RawMessage(`"b"`), immutable)
+	require.Greater(t, entrySeq("a"), before)
+	require.Less(t, entrySeq("a"), entrySeq("b"))
+	require.Empty(t, cache.touched)
+}
+
+func TestHandler(t *testing.T) {
+	up := newUpstream(map[string]string{"eth_chainId": `"0x1"`})
+	upServer := httptest.NewServer(up)
+	defer upServer.Close()
+
+	cache := newTestCache(t, Config{})
+	server := httptest.NewServer(cache.Handler())
+	defer server.Close()
+
+	cacheURL := fmt.Sprintf("%s?endpoint=%s&chainid=1", server.URL, url.QueryEscape(upServer.URL))
+	for id := 1; id <= 2; id++ {
+		require.JSONEq(t,
+			fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":"0x1"}`, id),
+			post(t, http.DefaultClient, cacheURL, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"eth_chainId","params":[]}`, id)),
+		)
+	}
+	require.Equal(t, 1, up.callsOf("eth_chainId"))
+
+	response, err := http.Post(server.URL, "application/json", bytes.NewBufferString(`{}`))
+	require.NoError(t, err)
+	response.Body.Close()
+	require.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestPolicies(t *testing.T) {
+	params := func(raw ...string) []json.RawMessage {
+		out := make([]json.RawMessage, len(raw))
+		for i, r := range raw {
+			out[i] = json.RawMessage(r)
+		}
+		return out
+	}
+	latest := expiry{ttl: time.Second}
+	immutable := expiry{immutable: true}
+
+	tests := []struct {
+		method string
+		params []json.RawMessage
+		result string
+		exp    expiry
+		cached bool
+	}{
+		{"eth_getBlockByNumber", params(`"latest"`, `false`), `{}`, latest, true},
+		{"eth_getBlockByNumber", params(`"pending"`, `false`), `{}`, expiry{}, false},
+		{"eth_getBlockByNumber", params(`"0xa"`, `false`), `{}`, immutable, true},
+		{"eth_getBlockByNumber", params(`"0x65"`, `false`), `{}`, latest, true},
+		{"eth_call", params(`{}`), `"0x"`, latest, true},
+		{"eth_call", params(`{}`, `{"blockHash":"0x01"}`), `"0x"`, immutable, true},
+		{"eth_getStorageAt", params(`"0x01"`, `"0x0"`, `"0x5"`), `"0x"`, immutable, true},
+		{"eth_getLogs", params(`{"blockHash":"0x01"}`), `[]`, immutable, true},
+		{"eth_getLogs", params(`{"fromBlock":"0x1"}`), `[]`, latest, true},
+		{"eth_getTransactionReceipt", params(`"0x01"`), `{"blockNumber":null}`, expiry{}, false},
+		{"eth_getTransactionByHash", params(`"0x01"`), `{"blockNumber":"0x64"}`, immutable, true},
+		{"eth_chainId", nil, `"0x1"`, immutable, true},
+	}
+	for _, tt := range tests {
+		exp, cached := methodPolicies[tt.method](tt.params, json.RawMessage(tt.result), 100, time.Second)
+		require.Equal(t, tt.cached, cached, "%s %s", tt.method, tt.params)
+		if cached {
+			require.Equal(t, tt.exp, exp, "%s %s", tt.method, tt.params)
+		}
+	}
+}
+
+func TestEndpointTransport(t *testing.T) {
+	first := newUpstream(map[string]string{"eth_chainId": `"0x1"`})
+	firstServer := httptest.NewServer(first)
+	defer firstServer.Close()
+	second := newUpstream(map[string]string{"eth_chainId": `"0x2"`})
+	secondServer := httptest.NewServer(second)
+	defer secondServer.Close()
+
+	request := `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`
+	cache := newTestCache(t, Config{})
+
+	// the endpoints share the cached answer through the same transport
+	shared := &http.Client{Transport: cache.Transport(1, nil)}
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, post(t, shared, firstServer.URL, request))
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, post(t, shared, secondServer.URL, request))
+	require.Equal(t, 0, second.callsOf("eth_chainId"))
+
+	// every endpoint gets its own answer through the endpoint transport, and it is cached
+	perEndpoint := &http.Client{Transport: cache.EndpointTransport(1, nil)}
+	for i := 0; i < 2; i++ {
+		require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, post(t, perEndpoint, firstServer.URL, request))
+		require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x2"}`, post(t, perEndpoint, secondServer.URL, request))
+	}
+	require.Equal(t, 2, first.callsOf("eth_chainId"))
+	require.Equal(t, 1, second.callsOf("eth_chainId"))
+}
@@ -0,0 +1,21 @@
+package l1_cache
+
+import (
+	"fmt"
+
+	"github.com/ledgerwatch/erigon-lib/metrics"
+)
+
+var (
+	sizeGauge        = metrics.GetOrCreateGauge(`l1_cache_size_bytes`)
+	evictionsCounter = metrics.GetOrCreateCounter(`l1_cache_evictions`)
+)
+
+// methodCounter counts the hits or the misses of the method
+func methodCounter(method string, hit bool) metrics.Counter {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	return metrics.GetOrCreateCounter(fmt.Sprintf(`l1_cache_requests{method="%s",result="%s"}`, method, result))
+}
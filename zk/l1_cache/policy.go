@@ -0,0 +1,175 @@
+package l1_cache
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tagLatest    = "latest"
+	tagPending   = "pending"
+	tagSafe      = "safe"
+	tagFinalized = "finalized"
+	tagEarliest  = "earliest"
+)
+
+// expiry is how long a response is kept, the immutable responses never expire
+type expiry struct {
+	ttl       time.Duration
+	immutable bool
+}
+
+// cachePolicy decides from the params and the result of a request whether and how long its response is cached,
+// finalized being the highest finalized l1 block known
+type cachePolicy func(params []json.RawMessage, result json.RawMessage, finalized uint64, latestTTL time.Duration) (expiry, bool)
+
+// methodPolicies lists the methods cached, the other methods always reach the l1
+var methodPolicies = map[string]cachePolicy{
+	"eth_chainId":               immutablePolicy,
+	"net_version":               immutablePolicy,
+	"eth_getBlockByHash":        immutablePolicy,
+	"eth_getTransactionByHash":  resultBlockPolicy,
+	"eth_getTransactionReceipt": resultBlockPolicy,
+	"eth_getBlockByNumber":      blockParamPolicy(0),
+	"eth_getLogs":               logsPolicy,
+	"eth_call":                  blockParamPolicy(1),
+	"eth_getBalance":            blockParamPolicy(1),
+	"eth_getCode":               blockParamPolicy(1),
+	"eth_getTransactionCount":   blockParamPolicy(1),
+	"eth_getStorageAt":          blockParamPolicy(2),
+	"eth_blockNumber":           latestPolicy,
+	"eth_gasPrice":              latestPolicy,
+	"eth_maxPriorityFeePerGas":  latestPolicy,
+}
+
+func immutablePolicy(_ []json.RawMessage, _ json.RawMessage, _ uint64, _ time.Duration) (expiry, bool) {
+	return expiry{immutable: true}, true
+}
+
+func latestPolicy(_ []json.RawMessage, _ json.RawMessage, _ uint64, latestTTL time.Duration) (expiry, bool) {
+	return expiry{ttl: latestTTL}, latestTTL > 0
+}
+
+// blockExpiry keeps the data of the finalized blocks for good and the data of the other blocks for the latest ttl
+func blockExpiry(blockNo, finalized uint64, latestTTL time.Duration) (expiry, bool) {
+	if finalized > 0 && blockNo <= finalized {
+		return expiry{immutable: true}, true
+	}
+	return expiry{ttl: latestTTL}, latestTTL > 0
+}
+
+// blockParamPolicy caches the requests by the block param at the index, which defaults to latest when it is omitted
+func blockParamPolicy(index int) cachePolicy {
+	return func(params []json.RawMessage, _ json.RawMessage, finalized uint64, latestTTL time.Duration) (expiry, bool) {
+		if index >= len(params) {
+			return latestPolicy(nil, nil, finalized, latestTTL)
+		}
+
+		// a block hash or a block object of EIP-1898
+		var blockRef struct {
+			BlockHash   *string `json:"blockHash"`
+			BlockNumber *string `json:"blockNumber"`
+		}
+		if err := json.Unmarshal(params[index], &blockRef); err == nil {
+			if blockRef.BlockHash != nil {
+				return expiry{immutable: true}, true
+			}
+			if blockRef.BlockNumber != nil {
+				return blockTagExpiry(*blockRef.BlockNumber, finalized, latestTTL)
+			}
+			return expiry{}, false
+		}
+
+		var tag string
+		if err := json.Unmarshal(params[index], &tag); err != nil {
+			return expiry{}, false
+		}
+		return blockTagExpiry(tag, finalized, latestTTL)
+	}
+}
+
+func blockTagExpiry(tag string, finalized uint64, latestTTL time.Duration) (expiry, bool) {
+	switch tag {
+	case tagPending:
+		return expiry{}, false
+	case tagLatest, tagSafe, tagFinalized, "":
+		return latestPolicy(nil, nil, finalized, latestTTL)
+	case tagEarliest:
+		return expiry{immutable: true}, true
+	}
+
+	blockNo, ok := parseHexUint64(tag)
+	if !ok {
+		// a block hash
+		if len(tag) == 66 {
+			return expiry{immutable: true}, true
+		}
+		return expiry{}, false
+	}
+	return blockExpiry(blockNo, finalized, latestTTL)
+}
+
+// resultBlockPolicy caches the transactions and receipts by the block they are included in, they are not cached
+// while they are pending
+func resultBlockPolicy(_ []json.RawMessage, result json.RawMessage, finalized uint64, latestTTL time.Duration) (expiry, bool) {
+	var included struct {
+		BlockNumber *string `json:"blockNumber"`
+	}
+	if err := json.Unmarshal(result, &included); err != nil || included.BlockNumber == nil {
+		return expiry{}, false
+	}
+	blockNo, ok := parseHexUint64(*included.BlockNumber)
+	if !ok {
+		return expiry{}, false
+	}
+	return blockExpiry(blockNo, finalized, latestTTL)
+}
+
+// logsPolicy caches the logs of a block hash or of a range ending at a finalized block for good
+func logsPolicy(params []json.RawMessage, _ json.RawMessage, finalized uint64, latestTTL time.Duration) (expiry, bool) {
+	if len(params) == 0 {
+		return expiry{}, false
+	}
+	var filter struct {
+		BlockHash *string `json:"blockHash"`
+		ToBlock   *string `json:"toBlock"`
+	}
+	if err := json.Unmarshal(params[0], &filter); err != nil {
+		return expiry{}, false
+	}
+	if filter.BlockHash != nil {
+		return expiry{immutable: true}, true
+	}
+	if filter.ToBlock == nil {
+		return latestPolicy(nil, nil, finalized, latestTTL)
+	}
+	return blockTagExpiry(*filter.ToBlock, finalized, latestTTL)
+}
+
+func parseHexUint64(s string) (uint64, bool) {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[2:], 16, 64)
+	return n, err == nil
+}
+
+// finalizedBlockNo returns the number of the block returned for the finalized tag
+func finalizedBlockNo(method string, params []json.RawMessage, result json.RawMessage) (uint64, bool) {
+	if method != "eth_getBlockByNumber" || len(params) == 0 {
+		return 0, false
+	}
+	var tag string
+	if err := json.Unmarshal(params[0], &tag); err != nil || tag != tagFinalized {
+		return 0, false
+	}
+	var block struct {
+		Number string `json:"number"`
+	}
+	if err := json.Unmarshal(result, &block); err != nil {
+		return 0, false
+	}
+	return parseHexUint64(block.Number)
+}
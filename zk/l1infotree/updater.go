@@ -24,6 +24,8 @@ type Syncer interface {
 	GetLogsChan() chan []types.Log
 	GetProgressMessageChan() chan string
 	IsDownloading() bool
+	IsReorgDetected() bool
+	GetLastCheckedL1BlockHash() (uint64, common.Hash, bool)
 	GetHeader(blockNumber uint64) (*types.Header, error)
 	L1QueryHeaders(logs []types.Log) (map[uint64]*types.Header, error)
 	StopQueryBlocks()
@@ -72,6 +74,14 @@ func (u *Updater) WarmUp(tx kv.RwTx) (err error) {
 		progress = u.cfg.L1FirstBlock - 1
 	}
 
+	// the l1 syncer stage lowers the progress when it unwinds an l1 reorg, the logs queued are from the reorged blocks
+	if u.syncer.IsSyncStarted() && (progress < u.progress || u.syncer.IsReorgDetected()) {
+		log.Warn("L1 info tree syncer restarting after an L1 reorg", "progress", progress)
+		u.syncer.StopQueryBlocks()
+		u.syncer.ConsumeQueryBlocks()
+		u.syncer.WaitQueryBlocksToFinish()
+	}
+
 	u.progress = progress
 
 	latestUpdate, err := hermezDb.GetLatestL1InfoTreeUpdate()
@@ -171,6 +181,9 @@ LOOP:
 				if err != nil {
 					return nil, err
 				}
+				if err = hermezDb.WriteL1BlockHash(l.BlockNumber, header.Hash()); err != nil {
+					return nil, err
+				}
 
 				leafHash := HashLeafData(tmpUpdate.GER, tmpUpdate.ParentHash, tmpUpdate.Timestamp)
 				if tree.LeafExists(leafHash) {
@@ -220,6 +233,11 @@ LOOP:
 	if err = stages.SaveStageProgress(tx, stages.L1InfoTree, u.progress); err != nil {
 		return nil, err
 	}
+	if blockNo, hash, ok := u.syncer.GetLastCheckedL1BlockHash(); ok {
+		if err = hermezDb.WriteL1BlockHash(blockNo, hash); err != nil {
+			return nil, err
+		}
+	}
 
 	return allLogs, nil
 }
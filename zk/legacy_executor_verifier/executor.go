@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/dustin/go-humanize"
@@ -31,6 +33,13 @@ type Config struct {
 	Timeout               time.Duration
 	MaxConcurrentRequests int
 	OutputLocation        string
+	// Weights of the executors in the order of GrpcUrls for routing, missing weights default to 1
+	Weights []int
+	// BreakerFailures consecutive failures take an executor out of rotation for BreakerCooldown
+	BreakerFailures int
+	BreakerCooldown time.Duration
+	// LocalExecutor, when set, re-executes every batch sent to the executors to find where they diverge from erigon
+	LocalExecutor *LocalExecutor
 }
 
 type Payload struct {
@@ -57,20 +66,34 @@ type RpcPayload struct {
 
 type Executor struct {
 	grpcUrl    string
-	conn       *grpc.ClientConn
 	connCancel context.CancelFunc
-	client     executor.ExecutorServiceClient
 	semaphore  chan struct{}
 
+	// the connection is dialed again by CheckOnline while the status is read, mtxConn guards it and the client
+	mtxConn sync.Mutex
+	conn    *grpc.ClientConn
+	client  executor.ExecutorServiceClient
+
 	// if not empty then the executor will write the payload to this location before sending it to the
 	// remote executor
 	outputLocation string
+
+	// if set then every batch is also re-executed locally and the divergences from the remote executor are logged
+	localExecutor *LocalExecutor
+
+	health *executorHealth
 }
 
 func NewExecutors(cfg Config) []*Executor {
 	executors := make([]*Executor, len(cfg.GrpcUrls))
 	for i, grpcUrl := range cfg.GrpcUrls {
 		executors[i] = NewExecutor(grpcUrl, cfg.Timeout, cfg.MaxConcurrentRequests, cfg.OutputLocation)
+		executors[i].localExecutor = cfg.LocalExecutor
+		weight := 1
+		if i < len(cfg.Weights) {
+			weight = cfg.Weights[i]
+		}
+		executors[i].health = newExecutorHealth(grpcUrl, weight, cfg.BreakerFailures, cfg.BreakerCooldown)
 	}
 	return executors
 }
@@ -95,22 +118,34 @@ func NewExecutor(grpcUrl string, timeout time.Duration, maxConcurrentRequests in
 		client:         client,
 		semaphore:      make(chan struct{}, maxConcurrentRequests),
 		outputLocation: outputLocation,
+		health:         newExecutorHealth(grpcUrl, 1, DefaultBreakerFailures, DefaultBreakerCooldown),
 	}
 
 	return e
 }
 
 func (e *Executor) Close() {
-	if e == nil || e.conn == nil {
+	if e == nil {
+		return
+	}
+	conn, _ := e.connection()
+	if conn == nil {
 		return
 	}
 	e.connCancel()
-	err := e.conn.Close()
+	err := conn.Close()
 	if err != nil {
 		log.Warn("Failed to close grpc connection", err)
 	}
 }
 
+// connection returns the connection to the executor and its client, the connection is nil until a dial succeeds
+func (e *Executor) connection() (*grpc.ClientConn, executor.ExecutorServiceClient) {
+	e.mtxConn.Lock()
+	defer e.mtxConn.Unlock()
+	return e.conn, e.client
+}
+
 // QueueLength check 'how busy' the executor is
 func (e *Executor) QueueLength() int {
 	return len(e.semaphore)
@@ -126,7 +161,8 @@ func (e *Executor) ReleaseAccess() {
 
 func (e *Executor) CheckOnline() bool {
 	// first ensure there is a connection to work with
-	if e.conn == nil {
+	conn, _ := e.connection()
+	if conn == nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
 		conn, err := grpc.DialContext(ctx, e.grpcUrl, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
@@ -134,21 +170,31 @@ func (e *Executor) CheckOnline() bool {
 			log.Error("Failed to dial grpc", "grpcUrl", e.grpcUrl, "error", err)
 			return false
 		}
-		e.conn = conn
-		e.client = executor.NewExecutorServiceClient(conn)
+
+		e.mtxConn.Lock()
+		if e.conn == nil {
+			e.conn = conn
+			e.client = executor.NewExecutorServiceClient(conn)
+			conn = nil
+		}
+		e.mtxConn.Unlock()
+		// another check connected first
+		if conn != nil {
+			conn.Close()
+		}
 
 		// no point in checking the state if we just connected so just return ok
 		return true
 	}
 
-	state := e.conn.GetState()
+	state := conn.GetState()
 
 	if state == connectivity.TransientFailure || state == connectivity.Shutdown {
 		log.Info("Executor reconnecting to grpc server", "grpcUrl", e.grpcUrl)
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
 
-		if !e.conn.WaitForStateChange(ctx, state) {
+		if !conn.WaitForStateChange(ctx, state) {
 			return false
 		} else {
 			log.Info("Executor reconnected to grpc server", "grpcUrl", e.grpcUrl)
@@ -217,24 +263,20 @@ func (e *Executor) Verify(p *Payload, request *VerifierRequest, oldStateRoot com
 		}
 	}
 
-	resp, err := e.client.ProcessStatelessBatchV2(ctx, grpcRequest, grpc.MaxCallSendMsgSize(size), grpc.MaxCallRecvMsgSize(size))
+	start := time.Now()
+	_, client := e.connection()
+	resp, err := client.ProcessStatelessBatchV2(ctx, grpcRequest, grpc.MaxCallSendMsgSize(size), grpc.MaxCallRecvMsgSize(size))
 	if err != nil {
+		e.health.recordFailure(err)
 		return false, nil, nil, fmt.Errorf("failed to process stateless batch: %w", err)
 	}
 	if resp == nil {
-		return false, nil, nil, fmt.Errorf("nil response")
+		e.health.recordFailure(ErrExecutorNilResponse)
+		return false, nil, nil, ErrExecutorNilResponse
 	}
+	e.health.recordSuccess(time.Since(start))
 
-	counters := map[string]int{
-		"SHA": int(resp.CntSha256Hashes),
-		"A":   int(resp.CntArithmetics),
-		"B":   int(resp.CntBinaries),
-		"K":   int(resp.CntKeccakHashes),
-		"M":   int(resp.CntMemAligns),
-		"P":   int(resp.CntPoseidonHashes),
-		"S":   int(resp.CntSteps),
-		"D":   int(resp.CntPoseidonPaddings),
-	}
+	counters := countersOf(resp)
 
 	match := bytes.Equal(resp.NewStateRoot, request.StateRoot.Bytes())
 
@@ -278,6 +320,10 @@ func (e *Executor) Verify(p *Payload, request *VerifierRequest, oldStateRoot com
 
 	counterUndershootCheck(counters, request.Counters, request.BatchNumber)
 
+	if e.localExecutor != nil {
+		e.compareWithLocalExecutor(grpcRequest, resp, request.BatchNumber)
+	}
+
 	log.Debug("Received response from executor", "grpcUrl", e.grpcUrl, "response", resp)
 
 	ok, executorResponse, executorErr := responseCheck(resp, request)
@@ -315,9 +361,33 @@ func responseCheck(resp *executor.ProcessBatchResponseV2, request *VerifierReque
 }
 
 func counterUndershootCheck(respCounters, counters map[string]int, batchNo uint64) {
+	for _, k := range counterUndershoots(respCounters, counters) {
+		log.Warn("Counter undershoot", "counter", k, "erigon", counters[k], "legacy", respCounters[k], "batch", batchNo)
+	}
+}
+
+// counterUndershoots returns the counters, in order, that erigon estimated below the executor
+func counterUndershoots(respCounters, counters map[string]int) []string {
+	var undershoots []string
 	for k, legacy := range respCounters {
 		if counters[k] < legacy {
-			log.Warn("Counter undershoot", "counter", k, "erigon", counters[k], "legacy", legacy, "batch", batchNo)
+			undershoots = append(undershoots, k)
 		}
 	}
+	sort.Strings(undershoots)
+	return undershoots
+}
+
+// countersOf returns the counters used by the batch according to the executor
+func countersOf(resp *executor.ProcessBatchResponseV2) map[string]int {
+	return map[string]int{
+		"SHA": int(resp.CntSha256Hashes),
+		"A":   int(resp.CntArithmetics),
+		"B":   int(resp.CntBinaries),
+		"K":   int(resp.CntKeccakHashes),
+		"M":   int(resp.CntMemAligns),
+		"P":   int(resp.CntPoseidonHashes),
+		"S":   int(resp.CntSteps),
+		"D":   int(resp.CntPoseidonPaddings),
+	}
 }
@@ -0,0 +1,371 @@
+package legacy_executor_verifier
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/metrics"
+	"github.com/ledgerwatch/erigon/zk/legacy_executor_verifier/proto/github.com/0xPolygonHermez/zkevm-node/state/runtime/executor"
+	"github.com/ledgerwatch/log/v3"
+	"google.golang.org/grpc/connectivity"
+)
+
+const (
+	// DefaultBreakerFailures is the number of consecutive failures that takes an executor out of rotation
+	DefaultBreakerFailures = 3
+	// DefaultBreakerCooldown is how long an executor stays out of rotation before it is probed again
+	DefaultBreakerCooldown = 30 * time.Second
+
+	// maxBreakerCooldown caps the cooldown of an executor that keeps failing its probes
+	maxBreakerCooldown = 10 * time.Minute
+	// defaultExecutorLatency is assumed for executors that did not answer yet
+	defaultExecutorLatency = time.Second
+	// latencySmoothing is the weight of the latest latency in the moving average
+	latencySmoothing = 0.2
+)
+
+var (
+	ErrExecutorOffline     = errors.New("executor offline")
+	ErrExecutorNilResponse = errors.New("nil response")
+
+	executorHedgedRequests = metrics.GetOrCreateCounter(`executor_hedged_requests`)
+	executorHedgeWins      = metrics.GetOrCreateCounter(`executor_hedge_wins`)
+)
+
+// breakerState is the state of the circuit breaker of an executor
+type breakerState int
+
+const (
+	// breakerClosed lets the requests through
+	breakerClosed breakerState = iota
+	// breakerOpen keeps the executor out of rotation until its cooldown is over
+	breakerOpen
+	// breakerHalfOpen lets a single probe request through to decide whether to close or to open again
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// executorHealth tracks the latency and the failures of an executor and trips its circuit breaker when the
+// executor keeps failing. The cooldown doubles every time a probe fails so a flapping executor stays out of
+// rotation for longer and longer
+type executorHealth struct {
+	weight           int
+	failureThreshold int
+	baseCooldown     time.Duration
+
+	mtx                 sync.Mutex
+	latency             time.Duration
+	requests            uint64
+	failures            uint64
+	consecutiveFailures int
+	state               breakerState
+	openedAt            time.Time
+	cooldown            time.Duration
+	probeStartedAt      time.Time
+	lastError           string
+
+	successCounter metrics.Counter
+	failureCounter metrics.Counter
+	tripCounter    metrics.Counter
+	latencySummary metrics.Summary
+	breakerGauge   metrics.Gauge
+}
+
+func newExecutorHealth(grpcUrl string, weight, failureThreshold int, cooldown time.Duration) *executorHealth {
+	if weight <= 0 {
+		weight = 1
+	}
+	if failureThreshold <= 0 {
+		failureThreshold = DefaultBreakerFailures
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	return &executorHealth{
+		weight:           weight,
+		failureThreshold: failureThreshold,
+		baseCooldown:     cooldown,
+		cooldown:         cooldown,
+		successCounter:   metrics.GetOrCreateCounter(fmt.Sprintf(`executor_requests{url="%s",result="success"}`, grpcUrl)),
+		failureCounter:   metrics.GetOrCreateCounter(fmt.Sprintf(`executor_requests{url="%s",result="failure"}`, grpcUrl)),
+		tripCounter:      metrics.GetOrCreateCounter(fmt.Sprintf(`executor_breaker_trips{url="%s"}`, grpcUrl)),
+		latencySummary:   metrics.GetOrCreateSummary(fmt.Sprintf(`executor_latency_seconds{url="%s"}`, grpcUrl)),
+		breakerGauge:     metrics.GetOrCreateGauge(fmt.Sprintf(`executor_breaker_state{url="%s"}`, grpcUrl)),
+	}
+}
+
+// allow reports whether a request can be sent to the executor, once the cooldown of an open breaker is over it
+// lets a single probe through
+func (h *executorHealth) allow(now time.Time) bool {
+	if h == nil {
+		return true
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	switch h.state {
+	case breakerOpen:
+		if now.Sub(h.openedAt) < h.cooldown {
+			return false
+		}
+		h.setState(breakerHalfOpen)
+		h.probeStartedAt = now
+		return true
+	case breakerHalfOpen:
+		// a probe that never reported back does not keep the executor out of rotation forever
+		if now.Sub(h.probeStartedAt) < h.cooldown {
+			return false
+		}
+		h.probeStartedAt = now
+		return true
+	default:
+		return true
+	}
+}
+
+func (h *executorHealth) recordSuccess(latency time.Duration) {
+	if h == nil {
+		return
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.requests++
+	h.consecutiveFailures = 0
+	if h.latency == 0 {
+		h.latency = latency
+	} else {
+		h.latency = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(h.latency))
+	}
+	if h.state != breakerClosed {
+		h.cooldown = h.baseCooldown
+		h.setState(breakerClosed)
+	}
+
+	h.successCounter.Inc()
+	h.latencySummary.Observe(latency.Seconds())
+}
+
+func (h *executorHealth) recordFailure(err error) {
+	if h == nil {
+		return
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.requests++
+	h.failures++
+	h.consecutiveFailures++
+	h.lastError = err.Error()
+	h.failureCounter.Inc()
+
+	switch h.state {
+	case breakerHalfOpen:
+		// the probe failed, the executor is flapping
+		h.cooldown *= 2
+		if h.cooldown > maxBreakerCooldown {
+			h.cooldown = maxBreakerCooldown
+		}
+		h.trip()
+	case breakerClosed:
+		if h.consecutiveFailures >= h.failureThreshold {
+			h.trip()
+		}
+	}
+}
+
+func (h *executorHealth) trip() {
+	h.openedAt = time.Now()
+	h.setState(breakerOpen)
+	h.tripCounter.Inc()
+}
+
+func (h *executorHealth) setState(state breakerState) {
+	h.state = state
+	h.breakerGauge.SetInt(int(state))
+}
+
+// score is the expected wait for a request on the executor, the lower the better
+func (h *executorHealth) score(queueLength int) float64 {
+	latency, weight := defaultExecutorLatency, 1
+	if h != nil {
+		h.mtx.Lock()
+		if h.latency > 0 {
+			latency = h.latency
+		}
+		weight = h.weight
+		h.mtx.Unlock()
+	}
+	return float64(queueLength+1) * float64(latency) / float64(weight)
+}
+
+// ExecutorStatus is the health of an executor as seen by the sequencer
+type ExecutorStatus struct {
+	Url                 string
+	Weight              int
+	Online              bool
+	Breaker             string
+	OpenUntil           time.Time
+	QueueLength         int
+	Requests            uint64
+	Failures            uint64
+	ConsecutiveFailures int
+	Latency             time.Duration
+	LastError           string
+}
+
+// Status returns the health of the executor without dialing it
+func (e *Executor) Status() ExecutorStatus {
+	status := ExecutorStatus{
+		Url:         e.grpcUrl,
+		Weight:      1,
+		Breaker:     breakerClosed.String(),
+		QueueLength: e.QueueLength(),
+	}
+	if conn, _ := e.connection(); conn != nil {
+		state := conn.GetState()
+		status.Online = state != connectivity.TransientFailure && state != connectivity.Shutdown
+	}
+
+	if h := e.health; h != nil {
+		h.mtx.Lock()
+		defer h.mtx.Unlock()
+		status.Weight = h.weight
+		status.Breaker = h.state.String()
+		if h.state != breakerClosed {
+			status.OpenUntil = h.openedAt.Add(h.cooldown)
+		}
+		status.Requests = h.requests
+		status.Failures = h.failures
+		status.ConsecutiveFailures = h.consecutiveFailures
+		status.Latency = h.latency
+		status.LastError = h.lastError
+	}
+	return status
+}
+
+// rankExecutors orders the executors by their expected wait, starting from start so that executors with the same
+// score take turns
+func rankExecutors(executors []*Executor, start int, exclude *Executor) []*Executor {
+	ranked := make([]*Executor, 0, len(executors))
+	scores := make(map[*Executor]float64, len(executors))
+	for i := range executors {
+		e := executors[(start+i)%len(executors)]
+		if e == exclude {
+			continue
+		}
+		ranked = append(ranked, e)
+		scores[e] = e.health.score(e.QueueLength())
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i]] < scores[ranked[j]]
+	})
+	return ranked
+}
+
+// ExecutorStatuses returns the health of every executor
+func (v *LegacyExecutorVerifier) ExecutorStatuses() []ExecutorStatus {
+	statuses := make([]ExecutorStatus, 0, len(v.executors))
+	for _, e := range v.executors {
+		statuses = append(statuses, e.Status())
+	}
+	return statuses
+}
+
+type verifyResult struct {
+	executor    *Executor
+	ok          bool
+	response    *executor.ProcessBatchResponseV2
+	executorErr error
+	err         error
+}
+
+// verifyWithHedging sends the request to the best executor available and, once the request is overdue or the hedge
+// delay is over without an answer, to a second executor as well. The first answer wins, an executor error such as a
+// root mismatch is an answer too
+func (v *LegacyExecutorVerifier) verifyWithHedging(payload *Payload, request *VerifierRequest, oldStateRoot common.Hash) (bool, *executor.ProcessBatchResponseV2, error, error) {
+	primary := v.GetNextOnlineAvailableExecutor()
+	if primary == nil {
+		return false, nil, nil, ErrNoExecutorAvailable
+	}
+
+	results := make(chan verifyResult, 2)
+	send := func(e *Executor) {
+		go func() {
+			e.AquireAccess()
+			defer e.ReleaseAccess()
+			if v.cancelAllVerifications.Load() {
+				results <- verifyResult{executor: e, err: ErrPromiseCancelled}
+				return
+			}
+			ok, response, executorErr, err := e.Verify(payload, request, oldStateRoot)
+			results <- verifyResult{executor: e, ok: ok, response: response, executorErr: executorErr, err: err}
+		}()
+	}
+	send(primary)
+
+	var hedge <-chan time.Time
+	if after, ok := v.hedgeAfter(request); ok {
+		timer := time.NewTimer(after)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	var hedged *Executor
+	var last verifyResult
+	for pending := 1; pending > 0; {
+		select {
+		case result := <-results:
+			pending--
+			last = result
+			if result.err == nil || errors.Is(result.err, ErrPromiseCancelled) {
+				if hedged != nil && result.executor == hedged {
+					executorHedgeWins.Inc()
+				}
+				if result.err == nil {
+					v.archiveAnswer(payload, request, oldStateRoot, result)
+				}
+				return result.ok, result.response, result.executorErr, result.err
+			}
+		case <-hedge:
+			hedge = nil
+			if hedged = v.getNextOnlineAvailableExecutor(primary); hedged != nil {
+				log.Info("[Verifier] Hedging the verification request", "batch", request.BatchNumber, "primary", primary.grpcUrl, "hedge", hedged.grpcUrl)
+				executorHedgedRequests.Inc()
+				pending++
+				send(hedged)
+			}
+		}
+	}
+
+	return last.ok, last.response, last.executorErr, last.err
+}
+
+// hedgeAfter returns how long to wait for an answer before hedging the request, a request is never hedged with a
+// single executor
+func (v *LegacyExecutorVerifier) hedgeAfter(request *VerifierRequest) (time.Duration, bool) {
+	if len(v.executors) < 2 {
+		return 0, false
+	}
+	after, ok := request.overdueIn()
+	if delay := v.cfg.ExecutorHedgeDelay; delay > 0 && (!ok || delay < after) {
+		return delay, true
+	}
+	return after, ok
+}
@@ -0,0 +1,178 @@
+package legacy_executor_verifier
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/zk/legacy_executor_verifier/proto/github.com/0xPolygonHermez/zkevm-node/state/runtime/executor"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// delayedExecutorServer answers every batch with the same root after a delay
+type delayedExecutorServer struct {
+	executor.UnimplementedExecutorServiceServer
+	delay time.Duration
+	root  common.Hash
+}
+
+func (s *delayedExecutorServer) ProcessStatelessBatchV2(ctx context.Context, _ *executor.ProcessStatelessBatchRequestV2) (*executor.ProcessBatchResponseV2, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &executor.ProcessBatchResponseV2{NewStateRoot: s.root.Bytes()}, nil
+}
+
+func newDelayedExecutor(t *testing.T, delay time.Duration, root common.Hash) *Executor {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	executor.RegisterExecutorServiceServer(server, &delayedExecutorServer{delay: delay, root: root})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	e := NewExecutor(listener.Addr().String(), 5*time.Second, 2, "")
+	t.Cleanup(e.Close)
+	return e
+}
+
+func TestExecutorHealthBreaker(t *testing.T) {
+	h := newExecutorHealth("breaker-test", 1, 2, 20*time.Millisecond)
+	failure := errors.New("failure")
+
+	h.recordFailure(failure)
+	require.True(t, h.allow(time.Now()))
+	h.recordFailure(failure)
+	require.Equal(t, breakerOpen, h.state)
+	require.False(t, h.allow(time.Now()))
+
+	// a single probe once the cooldown is over, its failure doubles the cooldown
+	time.Sleep(20 * time.Millisecond)
+	require.True(t, h.allow(time.Now()))
+	require.Equal(t, breakerHalfOpen, h.state)
+	require.False(t, h.allow(time.Now()))
+	h.recordFailure(failure)
+	require.Equal(t, breakerOpen, h.state)
+	require.Equal(t, 40*time.Millisecond, h.cooldown)
+
+	time.Sleep(20 * time.Millisecond)
+	require.False(t, h.allow(time.Now()))
+	time.Sleep(20 * time.Millisecond)
+	require.True(t, h.allow(time.Now()))
+
+	// a successful probe closes the breaker and resets the cooldown
+	h.recordSuccess(100 * time.Millisecond)
+	require.Equal(t, breakerClosed, h.state)
+	require.Equal(t, 20*time.Millisecond, h.cooldown)
+	require.True(t, h.allow(time.Now()))
+	require.Equal(t, uint64(3), h.failures)
+	require.Equal(t, uint64(4), h.requests)
+	require.Equal(t, "failure", h.lastError)
+}
+
+func TestRankExecutors(t *testing.T) {
+	slow := &Executor{grpcUrl: "slow", semaphore: make(chan struct{}, 2), health: newExecutorHealth("slow", 1, 1, time.Second)}
+	fast := &Executor{grpcUrl: "fast", semaphore: make(chan struct{}, 2), health: newExecutorHealth("fast", 1, 1, time.Second)}
+	heavy := &Executor{grpcUrl: "heavy", semaphore: make(chan struct{}, 2), health: newExecutorHealth("heavy", 2, 1, time.Second)}
+	slow.health.recordSuccess(4 * time.Second)
+	fast.health.recordSuccess(time.Second)
+	heavy.health.recordSuccess(time.Second)
+	executors := []*Executor{slow, fast, heavy}
+
+	require.Equal(t, []*Executor{heavy, fast, slow}, rankExecutors(executors, 0, nil))
+
+	// the weight is worth one request in the queue of the heavy executor
+	heavy.AquireAccess()
+	require.Equal(t, []*Executor{fast, heavy, slow}, rankExecutors(executors, 0, nil))
+	require.Equal(t, []*Executor{heavy, fast, slow}, rankExecutors(executors, 2, nil))
+	heavy.AquireAccess()
+	require.Equal(t, []*Executor{fast, heavy, slow}, rankExecutors(executors, 0, nil))
+	require.Equal(t, []*Executor{heavy, slow}, rankExecutors(executors, 0, fast))
+}
+
+func TestVerifyWithHedging(t *testing.T) {
+	root := common.HexToHash("0x01")
+	fast := newDelayedExecutor(t, 0, root)
+	slow := newDelayedExecutor(t, 2*time.Second, root)
+
+	// executors with the same score take turns from the second one so the slow executor is the primary
+	verifier := NewLegacyExecutorVerifier(ethconfig.Zk{ExecutorHedgeDelay: 50 * time.Millisecond}, []*Executor{fast, slow}, nil, nil, nil, nil)
+	request := NewVerifierRequest(8, 1, []uint64{1}, root, nil)
+
+	hedged, wins := executorHedgedRequests.GetValue(), executorHedgeWins.GetValue()
+	start := time.Now()
+	ok, _, executorErr, err := verifier.verifyWithHedging(&Payload{}, request, common.Hash{})
+	require.NoError(t, err)
+	require.NoError(t, executorErr)
+	require.True(t, ok)
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, hedged+1, executorHedgedRequests.GetValue())
+	require.Equal(t, wins+1, executorHedgeWins.GetValue())
+
+	// an overdue request is hedged straight away
+	fast, slow = newDelayedExecutor(t, 0, root), newDelayedExecutor(t, 2*time.Second, root)
+	verifier = NewLegacyExecutorVerifier(ethconfig.Zk{}, []*Executor{fast, slow}, nil, nil, nil, nil)
+	request = NewVerifierRequestWithLimits(8, 1, []uint64{1}, root, nil, time.Nanosecond, -1)
+	start = time.Now()
+	ok, _, _, err = verifier.verifyWithHedging(&Payload{}, request, common.Hash{})
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, hedged+2, executorHedgedRequests.GetValue())
+
+	// never with a single executor
+	verifier = NewLegacyExecutorVerifier(ethconfig.Zk{ExecutorHedgeDelay: time.Millisecond}, []*Executor{fast}, nil, nil, nil, nil)
+	_, hedge := verifier.hedgeAfter(request)
+	require.False(t, hedge)
+}
+
+func TestExecutorStatus(t *testing.T) {
+	e := newDelayedExecutor(t, 0, common.Hash{})
+	verifier := NewLegacyExecutorVerifier(ethconfig.Zk{}, []*Executor{e}, nil, nil, nil, nil)
+
+	_, _, _, err := e.Verify(&Payload{}, &VerifierRequest{}, common.Hash{})
+	require.NoError(t, err)
+
+	statuses := verifier.ExecutorStatuses()
+	require.Len(t, statuses, 1)
+	require.True(t, statuses[0].Online)
+	require.Equal(t, "closed", statuses[0].Breaker)
+	require.Equal(t, uint64(1), statuses[0].Requests)
+	require.Zero(t, statuses[0].Failures)
+	require.True(t, statuses[0].OpenUntil.IsZero())
+}
+
+func TestExecutorStatusWhileConnecting(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	executor.RegisterExecutorServiceServer(server, &delayedExecutorServer{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	// an executor that never connected is dialed by the verifier while its status is served
+	e := &Executor{grpcUrl: listener.Addr().String(), connCancel: func() {}, semaphore: make(chan struct{}, 1)}
+	t.Cleanup(e.Close)
+	require.False(t, e.Status().Online)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			e.CheckOnline()
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		e.Status()
+	}
+	<-done
+
+	require.Eventually(t, func() bool { return e.Status().Online }, 5*time.Second, 10*time.Millisecond)
+}
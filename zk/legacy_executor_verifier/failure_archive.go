@@ -0,0 +1,359 @@
+package legacy_executor_verifier
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/erigon/core/rawdb"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/ledgerwatch/erigon/zk/legacy_executor_verifier/proto/github.com/0xPolygonHermez/zkevm-node/state/runtime/executor"
+	"github.com/ledgerwatch/log/v3"
+)
+
+const (
+	// VerificationFailuresDB is the label of the database of the failed verifications
+	VerificationFailuresDB kv.Label = 254
+
+	verificationFailuresFolder = "verification_failures"
+
+	// failuresTable holds the failures by id, failurePayloadsTable the payload sent to the executor for each of them
+	failuresTable        = "VerificationFailures"
+	failurePayloadsTable = "VerificationFailurePayloads"
+)
+
+var verificationFailuresTablesCfg = kv.TableCfg{
+	kv.Sequence:          kv.TableCfgItem{},
+	failuresTable:        kv.TableCfgItem{},
+	failurePayloadsTable: kv.TableCfgItem{},
+}
+
+// VerificationFailure is a batch the executor did not verify as erigon built it, with what is needed to diagnose
+// and to replay it
+type VerificationFailure struct {
+	Id           uint64    `json:"id"`
+	Time         time.Time `json:"time"`
+	BatchNumber  uint64    `json:"batchNumber"`
+	BlockNumbers []uint64  `json:"blockNumbers"`
+	ForkId       uint64    `json:"forkId"`
+	ExecutorUrl  string    `json:"executorUrl"`
+	// Reasons are the error of the executor response check and the counters erigon undershot
+	Reasons     []string    `json:"reasons"`
+	WitnessHash common.Hash `json:"witnessHash"`
+
+	OldStateRoot common.Hash    `json:"oldStateRoot"`
+	StateRoot    common.Hash    `json:"stateRoot"`
+	Counters     map[string]int `json:"counters"`
+
+	ExecutorOldStateRoot common.Hash    `json:"executorOldStateRoot"`
+	ExecutorStateRoot    common.Hash    `json:"executorStateRoot"`
+	ExecutorCounters     map[string]int `json:"executorCounters"`
+	ExecutorError        string         `json:"executorError,omitempty"`
+	ExecutorRomError     string         `json:"executorRomError,omitempty"`
+	ExecutorErrorLog     string         `json:"executorErrorLog,omitempty"`
+
+	// TxDiffs are the transactions the executor processed differently from erigon
+	TxDiffs []TxDiff `json:"txDiffs"`
+}
+
+// TxDiff is a transaction with a different outcome in erigon and in the executor, Missing names the side that
+// does not have the transaction at all
+type TxDiff struct {
+	BlockNumber       uint64      `json:"blockNumber"`
+	Index             int         `json:"index"`
+	TxHash            common.Hash `json:"txHash"`
+	Missing           string      `json:"missing,omitempty"`
+	Status            uint64      `json:"status"`
+	ExecutorStatus    uint64      `json:"executorStatus"`
+	GasUsed           uint64      `json:"gasUsed"`
+	ExecutorGasUsed   uint64      `json:"executorGasUsed"`
+	StateRoot         common.Hash `json:"stateRoot"`
+	ExecutorStateRoot common.Hash `json:"executorStateRoot"`
+	ExecutorError     string      `json:"executorError,omitempty"`
+}
+
+// FailureArchive stores the last failed verifications in a dedicated database
+type FailureArchive struct {
+	db    kv.RwDB
+	limit int
+}
+
+// OpenFailureArchive opens the archive of the failed verifications in dbDir, it keeps the last limit failures or all
+// of them when limit is not positive
+func OpenFailureArchive(ctx context.Context, dbDir string, limit int) (*FailureArchive, error) {
+	path := dbDir
+	if !strings.HasSuffix(filepath.ToSlash(dbDir), "/"+verificationFailuresFolder) {
+		path = filepath.Join(dbDir, verificationFailuresFolder)
+	}
+
+	db, err := mdbx.NewMDBX(log.New()).Label(VerificationFailuresDB).Path(path).
+		WithTableCfg(func(defaultBuckets kv.TableCfg) kv.TableCfg { return verificationFailuresTablesCfg }).
+		GrowthStep(16 * datasize.MB).
+		Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FailureArchive{db: db, limit: limit}, nil
+}
+
+func (a *FailureArchive) Close() {
+	a.db.Close()
+}
+
+// Put stores the failure and the payload sent to the executor, then drops the oldest failures above the limit
+func (a *FailureArchive) Put(ctx context.Context, failure *VerificationFailure, payload *Payload) error {
+	return a.db.Update(ctx, func(tx kv.RwTx) error {
+		// the ids start from 1
+		id, err := tx.IncrementSequence(failuresTable, 1)
+		if err != nil {
+			return err
+		}
+		id++
+		failure.Id = id
+
+		encodedFailure, err := json.Marshal(failure)
+		if err != nil {
+			return err
+		}
+		encodedPayload, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		key := failureKey(id)
+		if err = tx.Put(failuresTable, key, encodedFailure); err != nil {
+			return err
+		}
+		if err = tx.Put(failurePayloadsTable, key, encodedPayload); err != nil {
+			return err
+		}
+
+		c, err := tx.RwCursor(failuresTable)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		count, err := c.Count()
+		if err != nil {
+			return err
+		}
+		for ; a.limit > 0 && count > uint64(a.limit); count-- {
+			k, _, err := c.First()
+			if err != nil {
+				return err
+			}
+			if err = tx.Delete(failurePayloadsTable, k); err != nil {
+				return err
+			}
+			if err = c.DeleteCurrent(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// List returns the failures stored, the latest first, only those of the batch if batchNumber is set
+func (a *FailureArchive) List(ctx context.Context, batchNumber *uint64) ([]*VerificationFailure, error) {
+	var failures []*VerificationFailure
+	err := a.db.View(ctx, func(tx kv.Tx) error {
+		c, err := tx.Cursor(failuresTable)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		for k, v, err := c.Last(); k != nil; k, v, err = c.Prev() {
+			if err != nil {
+				return err
+			}
+			failure := &VerificationFailure{}
+			if err = json.Unmarshal(v, failure); err != nil {
+				return err
+			}
+			if batchNumber != nil && failure.BatchNumber != *batchNumber {
+				continue
+			}
+			failures = append(failures, failure)
+		}
+		return nil
+	})
+	return failures, err
+}
+
+// Get returns the failure with the id and the payload sent to the executor, nil if it is not stored
+func (a *FailureArchive) Get(ctx context.Context, id uint64) (*VerificationFailure, *Payload, error) {
+	var failure *VerificationFailure
+	var payload *Payload
+	err := a.db.View(ctx, func(tx kv.Tx) error {
+		encodedFailure, err := tx.GetOne(failuresTable, failureKey(id))
+		if err != nil || encodedFailure == nil {
+			return err
+		}
+		encodedPayload, err := tx.GetOne(failurePayloadsTable, failureKey(id))
+		if err != nil {
+			return err
+		}
+
+		failure, payload = &VerificationFailure{}, &Payload{}
+		if err = json.Unmarshal(encodedFailure, failure); err != nil {
+			return err
+		}
+		return json.Unmarshal(encodedPayload, payload)
+	})
+	return failure, payload, err
+}
+
+func failureKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// archiveAnswer archives the answer verifyWithHedging picked, the blocks of the batch are read in a tx of its own
+func (v *LegacyExecutorVerifier) archiveAnswer(payload *Payload, request *VerifierRequest, oldStateRoot common.Hash, result verifyResult) {
+	if v.failures == nil || result.response == nil {
+		return
+	}
+
+	tx, err := v.db.BeginRo(context.Background())
+	if err != nil {
+		log.Warn("[Verifier] Failed to archive the failed verification", "batch", request.BatchNumber, "err", err)
+		return
+	}
+	defer tx.Rollback()
+
+	v.archiveFailure(tx, payload, request, oldStateRoot, result)
+}
+
+// archiveFailure stores the verification in the archive when the executor failed it or erigon undershot its counters
+func (v *LegacyExecutorVerifier) archiveFailure(tx kv.Tx, payload *Payload, request *VerifierRequest, oldStateRoot common.Hash, result verifyResult) {
+	if v.failures == nil || result.response == nil {
+		return
+	}
+
+	resp := result.response
+	executorCounters := countersOf(resp)
+	var reasons []string
+	if result.executorErr != nil {
+		reasons = append(reasons, result.executorErr.Error())
+	}
+	if undershoots := counterUndershoots(executorCounters, request.Counters); len(undershoots) > 0 {
+		reasons = append(reasons, fmt.Sprintf("counters undershoot: %s", strings.Join(undershoots, ", ")))
+	}
+	if len(reasons) == 0 {
+		return
+	}
+
+	failure := &VerificationFailure{
+		Time:                 time.Now(),
+		BatchNumber:          request.BatchNumber,
+		BlockNumbers:         request.BlockNumbers,
+		ForkId:               request.ForkId,
+		ExecutorUrl:          result.executor.grpcUrl,
+		Reasons:              reasons,
+		WitnessHash:          crypto.Keccak256Hash(payload.Witness),
+		OldStateRoot:         oldStateRoot,
+		StateRoot:            request.StateRoot,
+		Counters:             request.Counters,
+		ExecutorOldStateRoot: common.BytesToHash(resp.OldStateRoot),
+		ExecutorStateRoot:    common.BytesToHash(resp.NewStateRoot),
+		ExecutorCounters:     executorCounters,
+	}
+	if resp.Error != executor.ExecutorError_EXECUTOR_ERROR_UNSPECIFIED && resp.Error != executor.ExecutorError_EXECUTOR_ERROR_NO_ERROR {
+		failure.ExecutorError = resp.Error.String()
+	}
+	if resp.ErrorRom != executor.RomError_ROM_ERROR_UNSPECIFIED && resp.ErrorRom != executor.RomError_ROM_ERROR_NO_ERROR {
+		failure.ExecutorRomError = resp.ErrorRom.String()
+	}
+	if resp.Debug != nil {
+		failure.ExecutorErrorLog = resp.Debug.ErrorLog
+	}
+
+	txDiffs, err := diffTransactions(tx, request.BlockNumbers, resp)
+	if err != nil {
+		log.Warn("[Verifier] Failed to diff the transactions of the failed verification", "batch", request.BatchNumber, "err", err)
+	}
+	failure.TxDiffs = txDiffs
+
+	if err = v.failures.Put(context.Background(), failure, payload); err != nil {
+		log.Warn("[Verifier] Failed to archive the failed verification", "batch", request.BatchNumber, "err", err)
+		return
+	}
+	log.Info("[Verifier] Archived the failed verification", "batch", request.BatchNumber, "id", failure.Id, "tx-diffs", len(failure.TxDiffs))
+}
+
+// diffTransactions compares the outcome of every transaction of the blocks in erigon with the executor response
+func diffTransactions(tx kv.Tx, blockNumbers []uint64, resp *executor.ProcessBatchResponseV2) ([]TxDiff, error) {
+	hermezDb := hermez_db.NewHermezDbReader(tx)
+	var diffs []TxDiff
+
+	for i, blockNumber := range blockNumbers {
+		block, err := rawdb.ReadBlockByNumber(tx, blockNumber)
+		if err != nil {
+			return diffs, err
+		}
+		if block == nil {
+			return diffs, fmt.Errorf("block %d not found", blockNumber)
+		}
+		receipts := rawdb.ReadRawReceipts(tx, blockNumber)
+
+		var executorTxs []*executor.ProcessTransactionResponseV2
+		if i < len(resp.BlockResponses) {
+			executorTxs = resp.BlockResponses[i].Responses
+		}
+
+		var cumulativeGasUsed uint64
+		for j, transaction := range block.Transactions() {
+			diff := TxDiff{BlockNumber: blockNumber, Index: j, TxHash: transaction.Hash()}
+			if j < len(receipts) {
+				diff.Status = receipts[j].Status
+				diff.GasUsed = receipts[j].CumulativeGasUsed - cumulativeGasUsed
+				cumulativeGasUsed = receipts[j].CumulativeGasUsed
+			}
+			if diff.StateRoot, err = hermezDb.GetIntermediateTxStateRoot(blockNumber, diff.TxHash); err != nil {
+				return diffs, err
+			}
+
+			if j >= len(executorTxs) {
+				diff.Missing = "executor"
+				diffs = append(diffs, diff)
+				continue
+			}
+			executorTx := executorTxs[j]
+			diff.ExecutorStatus = uint64(executorTx.Status)
+			diff.ExecutorGasUsed = executorTx.GasUsed
+			diff.ExecutorStateRoot = common.BytesToHash(executorTx.StateRoot)
+			if executorTx.Error != executor.RomError_ROM_ERROR_UNSPECIFIED && executorTx.Error != executor.RomError_ROM_ERROR_NO_ERROR {
+				diff.ExecutorError = executorTx.Error.String()
+			}
+			if diff.Status != diff.ExecutorStatus || diff.GasUsed != diff.ExecutorGasUsed || diff.StateRoot != diff.ExecutorStateRoot {
+				diffs = append(diffs, diff)
+			}
+		}
+
+		for j := len(block.Transactions()); j < len(executorTxs); j++ {
+			executorTx := executorTxs[j]
+			diffs = append(diffs, TxDiff{
+				BlockNumber:       blockNumber,
+				Index:             j,
+				TxHash:            common.BytesToHash(executorTx.TxHash),
+				Missing:           "erigon",
+				ExecutorStatus:    uint64(executorTx.Status),
+				ExecutorGasUsed:   executorTx.GasUsed,
+				ExecutorStateRoot: common.BytesToHash(executorTx.StateRoot),
+			})
+		}
+	}
+
+	return diffs, nil
+}
@@ -0,0 +1,81 @@
+package legacy_executor_verifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/zk/legacy_executor_verifier/proto/github.com/0xPolygonHermez/zkevm-node/state/runtime/executor"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureArchive(t *testing.T) {
+	ctx := context.Background()
+	archive, err := OpenFailureArchive(ctx, t.TempDir(), 2)
+	require.NoError(t, err)
+	defer archive.Close()
+
+	for batch := uint64(1); batch <= 3; batch++ {
+		payload := &Payload{Witness: []byte{byte(batch)}, Coinbase: "0x01"}
+		require.NoError(t, archive.Put(ctx, &VerificationFailure{BatchNumber: batch, Reasons: []string{"mismatch"}}, payload))
+	}
+
+	// the oldest failure is dropped above the limit
+	failures, err := archive.List(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, failures, 2)
+	require.Equal(t, uint64(3), failures[0].Id)
+	require.Equal(t, uint64(3), failures[0].BatchNumber)
+	require.Equal(t, uint64(2), failures[1].BatchNumber)
+
+	batch := uint64(2)
+	failures, err = archive.List(ctx, &batch)
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	require.Equal(t, uint64(2), failures[0].Id)
+
+	failure, payload, err := archive.Get(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"mismatch"}, failure.Reasons)
+	require.Equal(t, []byte{2}, payload.Witness)
+	require.Equal(t, "0x01", payload.Coinbase)
+
+	failure, payload, err = archive.Get(ctx, 1)
+	require.NoError(t, err)
+	require.Nil(t, failure)
+	require.Nil(t, payload)
+}
+
+func TestArchiveFailure(t *testing.T) {
+	ctx := context.Background()
+	archive, err := OpenFailureArchive(ctx, t.TempDir(), 10)
+	require.NoError(t, err)
+	defer archive.Close()
+	_, tx := memdb.NewTestTx(t)
+
+	e := &Executor{grpcUrl: "executor"}
+	verifier := NewLegacyExecutorVerifier(ethconfig.Zk{}, []*Executor{e}, nil, nil, nil, archive)
+	payload := &Payload{Witness: []byte{1}}
+	request := NewVerifierRequest(8, 1, nil, common.HexToHash("0x01"), map[string]int{"S": 10, "K": 5})
+
+	// a verified batch within its counters is not archived
+	resp := &executor.ProcessBatchResponseV2{NewStateRoot: common.HexToHash("0x01").Bytes(), CntSteps: 10, CntKeccakHashes: 5}
+	verifier.archiveFailure(tx, payload, request, common.Hash{}, verifyResult{executor: e, ok: true, response: resp})
+	failures, err := archive.List(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, failures)
+
+	resp = &executor.ProcessBatchResponseV2{NewStateRoot: common.HexToHash("0x02").Bytes(), CntSteps: 11, CntKeccakHashes: 6, ErrorRom: executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_STEP}
+	verifier.archiveFailure(tx, payload, request, common.Hash{}, verifyResult{executor: e, response: resp, executorErr: errors.New("root mismatch")})
+	failures, err = archive.List(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	require.Equal(t, []string{"root mismatch", "counters undershoot: K, S"}, failures[0].Reasons)
+	require.Equal(t, "executor", failures[0].ExecutorUrl)
+	require.Equal(t, common.HexToHash("0x02"), failures[0].ExecutorStateRoot)
+	require.Equal(t, 11, failures[0].ExecutorCounters["S"])
+	require.Equal(t, executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_STEP.String(), failures[0].ExecutorRomError)
+}
@@ -61,6 +61,15 @@ func (vr *VerifierRequest) IsOverdue() bool {
 	return time.Since(vr.creationTime) > vr.timeout
 }
 
+// overdueIn returns the time left before the request is overdue, negative once it is, and false if it never is
+func (vr *VerifierRequest) overdueIn() (time.Duration, bool) {
+	if vr.timeout == 0 {
+		return 0, false
+	}
+
+	return vr.timeout - time.Since(vr.creationTime), true
+}
+
 func (vr *VerifierRequest) IncrementAndValidateRetries() bool {
 	if vr.retries == -1 {
 		return true
@@ -119,11 +128,15 @@ type LegacyExecutorVerifier struct {
 	cfg                    ethconfig.Zk
 	executors              []*Executor
 	executorNumber         int
+	mtxExecutors           *sync.Mutex
 	cancelAllVerifications atomic.Bool
 
 	streamServer     server.DataStreamServer
 	WitnessGenerator WitnessGenerator
 
+	// failures archives the failed verifications when set
+	failures *FailureArchive
+
 	promises    []*Promise[*VerifierBundle]
 	mtxPromises *sync.Mutex
 }
@@ -134,15 +147,18 @@ func NewLegacyExecutorVerifier(
 	db kv.RwDB,
 	witnessGenerator WitnessGenerator,
 	streamServer server.DataStreamServer,
+	failures *FailureArchive,
 ) *LegacyExecutorVerifier {
 	return &LegacyExecutorVerifier{
 		db:                     db,
 		cfg:                    cfg,
 		executors:              executors,
 		executorNumber:         0,
+		mtxExecutors:           &sync.Mutex{},
 		cancelAllVerifications: atomic.Bool{},
 		streamServer:           streamServer,
 		WitnessGenerator:       witnessGenerator,
+		failures:               failures,
 		promises:               make([]*Promise[*VerifierBundle], 0),
 		mtxPromises:            &sync.Mutex{},
 	}
@@ -209,10 +225,11 @@ func (v *LegacyExecutorVerifier) VerifySync(tx kv.Tx, request *VerifierRequest,
 		return err
 	}
 
-	_, _, executorErr, generalErr := e.Verify(payload, request, previousBlock.Root())
+	ok, executorResponse, executorErr, generalErr := e.Verify(payload, request, previousBlock.Root())
 	if generalErr != nil {
 		return generalErr
 	}
+	v.archiveFailure(tx, payload, request, previousBlock.Root(), verifyResult{executor: e, ok: ok, response: executorResponse, executorErr: executorErr})
 	return executorErr
 }
 
@@ -282,21 +299,13 @@ func (v *LegacyExecutorVerifier) VerifyAsync(request *VerifierRequest) *Promise[
 
 		verifierBundle.markAsreadyForSendingRequest()
 
-		e := v.GetNextOnlineAvailableExecutor()
-		if e == nil {
-			return verifierBundle, ErrNoExecutorAvailable
-		}
-
 		t := utils.StartTimer("legacy-executor-verifier", "verify-async")
 		defer t.LogTimer()
 
-		e.AquireAccess()
-		defer e.ReleaseAccess()
-		if v.cancelAllVerifications.Load() {
-			return nil, ErrPromiseCancelled
+		ok, executorResponse, executorErr, generalErr := v.verifyWithHedging(payload, request, previousBlock.Root())
+		if errors.Is(generalErr, ErrPromiseCancelled) {
+			return nil, generalErr
 		}
-
-		ok, executorResponse, executorErr, generalErr := e.Verify(payload, request, previousBlock.Root())
 		if generalErr != nil {
 			return verifierBundle, generalErr
 		}
@@ -429,24 +438,35 @@ func (v *LegacyExecutorVerifier) CancelAllRequests() {
 }
 
 func (v *LegacyExecutorVerifier) GetNextOnlineAvailableExecutor() *Executor {
-	var exec *Executor
-
-	// TODO: find executors with spare capacity
+	return v.getNextOnlineAvailableExecutor(nil)
+}
 
-	// attempt to find an executor that is online amongst them all
-	for i := 0; i < len(v.executors); i++ {
-		v.executorNumber++
-		if v.executorNumber >= len(v.executors) {
-			v.executorNumber = 0
+// getNextOnlineAvailableExecutor returns the online executor with the shortest expected wait amongst the ones whose
+// circuit breaker lets the request through, exclude is left out. The executors are probed outside of the lock as a
+// dial can take seconds
+func (v *LegacyExecutorVerifier) getNextOnlineAvailableExecutor(exclude *Executor) *Executor {
+	v.mtxExecutors.Lock()
+	if len(v.executors) == 0 {
+		v.mtxExecutors.Unlock()
+		return nil
+	}
+	v.executorNumber = (v.executorNumber + 1) % len(v.executors)
+	ranked := rankExecutors(v.executors, v.executorNumber, exclude)
+	v.mtxExecutors.Unlock()
+
+	now := time.Now()
+	for _, e := range ranked {
+		if !e.health.allow(now) {
+			continue
 		}
-		temp := v.executors[v.executorNumber]
-		if temp.CheckOnline() {
-			exec = temp
-			break
+		if !e.CheckOnline() {
+			e.health.recordFailure(ErrExecutorOffline)
+			continue
 		}
+		return e
 	}
 
-	return exec
+	return nil
 }
 
 func (v *LegacyExecutorVerifier) GetWholeBatchStreamBytes(
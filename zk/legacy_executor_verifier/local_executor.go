@@ -0,0 +1,810 @@
+package legacy_executor_verifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/chain"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/metrics"
+	"github.com/ledgerwatch/erigon/core"
+	"github.com/ledgerwatch/erigon/core/state"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/types/accounts"
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/core/vm/evmtypes"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/smt/pkg/blockinfo"
+	"github.com/ledgerwatch/erigon/smt/pkg/smt"
+	"github.com/ledgerwatch/erigon/turbo/trie"
+	"github.com/ledgerwatch/erigon/zk/datastream/client"
+	dstypes "github.com/ledgerwatch/erigon/zk/datastream/types"
+	"github.com/ledgerwatch/erigon/zk/legacy_executor_verifier/proto/github.com/0xPolygonHermez/zkevm-node/state/runtime/executor"
+	zktx "github.com/ledgerwatch/erigon/zk/tx"
+	"github.com/ledgerwatch/erigon/zk/utils"
+	"github.com/ledgerwatch/log/v3"
+	"google.golang.org/grpc"
+)
+
+const (
+	localExecutorLogPrefix = "local executor"
+	// the time a comparison with the local executor is given, it runs apart from the verification
+	localExecutorCompareTimeout = 5 * time.Minute
+	// the comparisons running at once, the batches verified while they are all busy are not compared
+	localExecutorMaxComparisons = 2
+)
+
+// the first fork the local executor can re-execute, the batches of the earlier forks are not in the blocks data
+// stream format
+const localExecutorMinForkId = uint64(chain.ForkID7Etrog)
+
+var (
+	emptyCodeHash = crypto.Keccak256Hash(nil)
+
+	localExecutorDivergences        = metrics.GetOrCreateCounter(`executor_local_divergences`)
+	localExecutorSkippedComparisons = metrics.GetOrCreateCounter(`executor_local_skipped_comparisons`)
+
+	// the rom error of an overflow of every counter, in the order of vm.CounterKeyNames
+	outOfCountersRomErrors = []executor.RomError{
+		vm.S:   executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_STEP,
+		vm.A:   executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_ARITH,
+		vm.B:   executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_BINARY,
+		vm.M:   executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_MEM,
+		vm.K:   executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_KECCAK,
+		vm.D:   executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_PADDING,
+		vm.P:   executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_POSEIDON,
+		vm.SHA: executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_SHA,
+	}
+)
+
+// LocalExecutor implements the executor service by re-executing the batch with the zk EVM of erigon on the state
+// of the witness. It stands in for the prover executor to run a sequencer and its verifier locally, or next to it
+// to find where erigon and the executor diverge.
+type LocalExecutor struct {
+	executor.UnimplementedExecutorServiceServer
+	chainConfig  *chain.Config
+	mcpReduction float64
+	comparisons  chan struct{}
+}
+
+func NewLocalExecutor(chainConfig *chain.Config, mcpReduction float64) *LocalExecutor {
+	return &LocalExecutor{
+		chainConfig:  chainConfig,
+		mcpReduction: mcpReduction,
+		comparisons:  make(chan struct{}, localExecutorMaxComparisons),
+	}
+}
+
+// Serve serves the executor service on the address until the context is done and returns the address listened on
+func (l *LocalExecutor) Serve(ctx context.Context, addr string) (net.Addr, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	size := 1024 * 1024 * 256 // same maximum size as the requests sent by the executor client
+	server := grpc.NewServer(grpc.MaxRecvMsgSize(size), grpc.MaxSendMsgSize(size))
+	executor.RegisterExecutorServiceServer(server, l)
+
+	go func() {
+		<-ctx.Done()
+		server.Stop()
+	}()
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Error("Local executor stopped", "addr", lis.Addr(), "error", err)
+		}
+	}()
+
+	log.Info("Local executor serving", "addr", lis.Addr())
+	return lis.Addr(), nil
+}
+
+// executorFailure is an error the response is returned with
+type executorFailure struct {
+	executorErr executor.ExecutorError
+	romErr      executor.RomError
+	err         error
+}
+
+func (f *executorFailure) Error() string {
+	return f.err.Error()
+}
+
+func failExecutor(executorErr executor.ExecutorError, format string, args ...any) error {
+	return &executorFailure{executorErr: executorErr, romErr: executor.RomError_ROM_ERROR_NO_ERROR, err: fmt.Errorf(format, args...)}
+}
+
+func failRom(romErr executor.RomError, format string, args ...any) error {
+	return &executorFailure{executorErr: executor.ExecutorError_EXECUTOR_ERROR_NO_ERROR, romErr: romErr, err: fmt.Errorf(format, args...)}
+}
+
+func (l *LocalExecutor) ProcessStatelessBatchV2(ctx context.Context, request *executor.ProcessStatelessBatchRequestV2) (*executor.ProcessBatchResponseV2, error) {
+	start := time.Now()
+
+	resp, err := l.processBatch(ctx, request)
+	if err != nil {
+		var failure *executorFailure
+		if !errors.As(err, &failure) {
+			return nil, err
+		}
+		resp.Error = failure.executorErr
+		resp.ErrorRom = failure.romErr
+		resp.Debug = &executor.ResponseDebug{ErrorLog: failure.Error()}
+		log.Warn("Local executor failed the batch", "context", request.ContextId, "error", err)
+		return resp, nil
+	}
+
+	log.Info("Local executor processed the batch",
+		"context", request.ContextId,
+		"blocks", len(resp.BlockResponses),
+		"old-root", common.BytesToHash(resp.OldStateRoot),
+		"new-root", common.BytesToHash(resp.NewStateRoot),
+		"elapsed", time.Since(start))
+
+	return resp, nil
+}
+
+func (l *LocalExecutor) processBatch(ctx context.Context, request *executor.ProcessStatelessBatchRequestV2) (*executor.ProcessBatchResponseV2, error) {
+	resp := &executor.ProcessBatchResponseV2{
+		Error:              executor.ExecutorError_EXECUTOR_ERROR_NO_ERROR,
+		ErrorRom:           executor.RomError_ROM_ERROR_NO_ERROR,
+		ReadWriteAddresses: make(map[string]*executor.InfoReadWriteV2),
+	}
+
+	witness, err := trie.NewWitnessFromReader(bytes.NewReader(request.Witness), false)
+	if err != nil {
+		return resp, failExecutor(executor.ExecutorError_EXECUTOR_ERROR_INVALID_WITNESS, "parse witness: %v", err)
+	}
+	tree, err := smt.BuildSMTfromWitness(witness)
+	if err != nil {
+		return resp, failExecutor(executor.ExecutorError_EXECUTOR_ERROR_INVALID_WITNESS, "build smt from witness: %v", err)
+	}
+	oldRoot := common.BigToHash(tree.LastRoot())
+	resp.OldStateRoot = oldRoot.Bytes()
+	resp.NewStateRoot = oldRoot.Bytes()
+
+	batchStart, blocks, err := decodeBatchDataStream(request.DataStream)
+	if err != nil {
+		return resp, failExecutor(executor.ExecutorError_EXECUTOR_ERROR_INVALID_DATA_STREAM, "decode data stream: %v", err)
+	}
+	forkId := batchStart.ForkId
+	resp.ForkId = forkId
+	resp.NewBatchNum = batchStart.Number
+	if forkId < localExecutorMinForkId {
+		return resp, failExecutor(executor.ExecutorError_EXECUTOR_ERROR_UNSUPPORTED_FORK_ID, "fork %d is not supported", forkId)
+	}
+	if len(blocks) == 0 {
+		return resp, nil
+	}
+
+	// the forks up to the one of the batch are active from its first block, as in the l1 recovery
+	chainConfig := *l.chainConfig
+	if err = utils.RecoverySetBlockConfigForks(blocks[0].L2BlockNumber, forkId, &chainConfig, localExecutorLogPrefix); err != nil {
+		return resp, err
+	}
+
+	reader := newWitnessStateReader(tree)
+	writer := newSmtChangesWriter(tree)
+	smtDepth := tree.GetDepth()
+	batchCounters := vm.NewBatchCounterCollector(smtDepth, uint16(forkId), l.mcpReduction, false, nil)
+
+	prevRoot := oldRoot
+	var l1InfoIndex uint32
+	for _, block := range blocks {
+		select {
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		default:
+		}
+
+		timestamp := uint64(block.Timestamp)
+		if request.TimestampLimit != 0 && timestamp > request.TimestampLimit {
+			return resp, failRom(executor.RomError_ROM_ERROR_INVALID_TX_CHANGE_L2_BLOCK_LIMIT_TIMESTAMP, "block %d timestamp %d above the limit %d", block.L2BlockNumber, timestamp, request.TimestampLimit)
+		}
+		l1InfoIndex = block.L1InfoTreeIndex
+		if minTimestamp, ok := request.L1InfoTreeIndexMinTimestamp[uint64(l1InfoIndex)]; ok && l1InfoIndex > 0 && timestamp < minTimestamp {
+			return resp, failRom(executor.RomError_ROM_ERROR_INVALID_TX_CHANGE_L2_BLOCK_MIN_TIMESTAMP, "block %d timestamp %d below the l1 info tree index %d timestamp %d", block.L2BlockNumber, timestamp, l1InfoIndex, minTimestamp)
+		}
+
+		overflow, err := batchCounters.StartNewBlock(l1InfoIndex != 0)
+		if err != nil {
+			return resp, err
+		}
+		if overflow {
+			return resp, outOfCounters(batchCounters, l1InfoIndex != 0)
+		}
+
+		blockResp, err := l.processBlock(&chainConfig, reader, writer, batchCounters, block, forkId, smtDepth, prevRoot)
+		if err != nil {
+			return resp, err
+		}
+		resp.BlockResponses = append(resp.BlockResponses, blockResp)
+		resp.GasUsed += blockResp.GasUsed
+		prevRoot = common.BytesToHash(blockResp.BlockHash)
+	}
+	resp.NewStateRoot = prevRoot.Bytes()
+
+	collected, err := batchCounters.CombineCollectors(l1InfoIndex != 0)
+	if err != nil {
+		return resp, err
+	}
+	resp.CntKeccakHashes = uint32(collected.GetKeccakHashes().Used())
+	resp.CntPoseidonHashes = uint32(collected.GetPoseidonHashes().Used())
+	resp.CntPoseidonPaddings = uint32(collected.GetPoseidonPaddings().Used())
+	resp.CntMemAligns = uint32(collected.GetMemAligns().Used())
+	resp.CntArithmetics = uint32(collected.GetArithmetics().Used())
+	resp.CntBinaries = uint32(collected.GetBinaries().Used())
+	resp.CntSteps = uint32(collected.GetSteps().Used())
+	resp.CntSha256Hashes = uint32(collected.GetSHA256Hashes().Used())
+
+	writer.readWriteAddresses(resp.ReadWriteAddresses)
+
+	return resp, nil
+}
+
+// processBlock executes the block the way the sequencer does and returns its response, the block hash in the
+// response is the state root after the block as with the executor
+func (l *LocalExecutor) processBlock(
+	chainConfig *chain.Config,
+	reader *witnessStateReader,
+	writer *smtChangesWriter,
+	batchCounters *vm.BatchCounterCollector,
+	block *dstypes.FullL2Block,
+	forkId uint64,
+	smtDepth int,
+	prevRoot common.Hash,
+) (*executor.ProcessBlockResponseV2, error) {
+	blockNum := block.L2BlockNumber
+	timestamp := uint64(block.Timestamp)
+	gasLimit := block.BlockGasLimit
+	if gasLimit == 0 {
+		gasLimit = utils.GetBlockGasLimitForFork(forkId)
+	}
+
+	header := &types.Header{
+		Number:     new(big.Int).SetUint64(blockNum),
+		Time:       timestamp,
+		Coinbase:   block.Coinbase,
+		GasLimit:   gasLimit,
+		Difficulty: new(big.Int),
+		BaseFee:    new(big.Int),
+	}
+	ibs := state.New(reader)
+	rules := chainConfig.Rules(blockNum, timestamp)
+
+	ibs.PreExecuteStateSet(chainConfig, blockNum, timestamp, &prevRoot)
+	if block.L1InfoTreeIndex > 0 {
+		// the ger is written to the contract only the first time it is used
+		if ibs.ReadGerManagerL1BlockHash(block.GlobalExitRoot) == (common.Hash{}) {
+			ibs.WriteGerManagerL1BlockHash(block.GlobalExitRoot, block.L1BlockHash)
+		}
+	}
+
+	blockResp := &executor.ProcessBlockResponseV2{
+		ParentHash:  prevRoot.Bytes(),
+		Coinbase:    block.Coinbase.String(),
+		GasLimit:    gasLimit,
+		BlockNumber: blockNum,
+		Timestamp:   timestamp,
+		Ger:         block.GlobalExitRoot.Bytes(),
+		BlockHashL1: block.L1BlockHash.Bytes(),
+		Error:       executor.RomError_ROM_ERROR_NO_ERROR,
+	}
+
+	verifyMerkleProof := block.L1InfoTreeIndex != 0
+	blockContext := core.NewEVMBlockContext(header, func(uint64) common.Hash { return common.Hash{} }, nil, &block.Coinbase)
+	signer := types.MakeSigner(chainConfig, blockNum, timestamp)
+	txInfos := make([]blockinfo.ExecutedTxInfo, 0, len(block.L2Txs))
+	var gasUsed uint64
+
+	for i, l2Tx := range block.L2Txs {
+		tx, _, err := zktx.DecodeTx(l2Tx.Encoded, l2Tx.EffectiveGasPricePercentage, forkId)
+		if err != nil {
+			return nil, failExecutor(executor.ExecutorError_EXECUTOR_ERROR_INVALID_BATCH_L2_DATA, "decode tx %d of block %d: %v", i, blockNum, err)
+		}
+		sender, err := tx.Sender(*signer)
+		if err != nil {
+			return nil, failRom(executor.RomError_ROM_ERROR_INTRINSIC_INVALID_SIGNATURE, "recover the sender of tx %s: %v", tx.Hash(), err)
+		}
+
+		txCounters := vm.NewTransactionCounter(tx, smtDepth, uint16(forkId), l.mcpReduction, false)
+		overflow, err := batchCounters.AddNewTransactionCounters(txCounters)
+		if err != nil {
+			return nil, err
+		}
+		if overflow {
+			return nil, outOfCounters(batchCounters, verifyMerkleProof)
+		}
+
+		var rlpTx bytes.Buffer
+		if err = tx.MarshalBinary(&rlpTx); err != nil {
+			return nil, err
+		}
+
+		ibs.Init(tx.Hash(), common.Hash{}, i)
+		evm := vm.NewZkEVM(blockContext, evmtypes.TxContext{}, ibs, chainConfig, vm.ZkConfig{
+			Config:           vm.Config{NoBaseFee: true},
+			CounterCollector: txCounters.ExecutionCounters(),
+		})
+		gasPool := new(core.GasPool).AddGas(gasLimit)
+
+		snapshot := ibs.Snapshot()
+		receipt, execResult, err := core.ApplyTransaction_zkevm(chainConfig, nil, evm, gasPool, ibs, writer, header, tx, &gasUsed, l2Tx.EffectiveGasPricePercentage, true)
+		if err != nil {
+			// as with the executor a transaction failing the intrinsic checks changes no state and the batch goes on
+			romErr, ok := intrinsicRomErrorOf(err)
+			if !ok {
+				return nil, failExecutor(executor.ExecutorError_EXECUTOR_ERROR_SM_MAIN_INVALID_TX_STATUS_ERROR, "apply tx %s: %v", tx.Hash(), err)
+			}
+			ibs.RevertToSnapshot(snapshot)
+			blockResp.Responses = append(blockResp.Responses, &executor.ProcessTransactionResponseV2{
+				TxHash:              tx.Hash().Bytes(),
+				RlpTx:               rlpTx.Bytes(),
+				BlockNumber:         blockNum,
+				Type:                uint32(tx.Type()),
+				GasLeft:             tx.GetGas(),
+				CumulativeGasUsed:   gasUsed,
+				Error:               romErr,
+				StateRoot:           common.BigToHash(writer.tree.LastRoot()).Bytes(),
+				EffectivePercentage: uint32(l2Tx.EffectiveGasPricePercentage),
+			})
+			continue
+		}
+		if err = txCounters.ProcessTx(ibs, execResult.ReturnData); err != nil {
+			return nil, err
+		}
+		batchCounters.UpdateExecutionAndProcessingCountersCache(txCounters)
+		if overflow, err = batchCounters.CheckForOverflow(verifyMerkleProof); err != nil {
+			return nil, err
+		}
+		if overflow {
+			return nil, outOfCounters(batchCounters, verifyMerkleProof)
+		}
+
+		txRoot, err := writer.flush()
+		if err != nil {
+			return nil, err
+		}
+
+		txResp := &executor.ProcessTransactionResponseV2{
+			TxHash:              tx.Hash().Bytes(),
+			RlpTx:               rlpTx.Bytes(),
+			BlockNumber:         blockNum,
+			Type:                uint32(tx.Type()),
+			ReturnValue:         execResult.ReturnData,
+			GasLeft:             tx.GetGas() - execResult.UsedGas,
+			GasUsed:             execResult.UsedGas,
+			CumulativeGasUsed:   gasUsed,
+			Error:               executor.RomError_ROM_ERROR_NO_ERROR,
+			StateRoot:           txRoot.Bytes(),
+			EffectivePercentage: uint32(l2Tx.EffectiveGasPricePercentage),
+			Status:              uint32(receipt.Status),
+		}
+		if execResult.Err != nil {
+			txResp.Error = romErrorOf(execResult.Err)
+		}
+		if receipt.ContractAddress != (common.Address{}) {
+			txResp.CreateAddress = receipt.ContractAddress.String()
+		}
+		blockResp.Responses = append(blockResp.Responses, txResp)
+
+		txInfos = append(txInfos, blockinfo.ExecutedTxInfo{
+			Tx:                tx,
+			Receipt:           core.CreateReceiptForBlockInfoTree(receipt, chainConfig, blockNum, execResult),
+			EffectiveGasPrice: l2Tx.EffectiveGasPricePercentage,
+			Signer:            &sender,
+		})
+	}
+
+	blockInfoRoot, err := blockinfo.BuildBlockInfoTree(&block.Coinbase, blockNum, timestamp, gasLimit, gasUsed, block.GlobalExitRoot, block.L1BlockHash, prevRoot, &txInfos)
+	if err != nil {
+		return nil, err
+	}
+	ibs.PostExecuteStateSet(chainConfig, blockNum, blockInfoRoot)
+	if err = ibs.FinalizeTx(rules, writer); err != nil {
+		return nil, err
+	}
+	root, err := writer.flush()
+	if err != nil {
+		return nil, err
+	}
+
+	blockResp.GasUsed = gasUsed
+	blockResp.BlockInfoRoot = blockInfoRoot.Bytes()
+	blockResp.BlockHash = root.Bytes()
+	return blockResp, nil
+}
+
+// outOfCounters returns the failure of the first counter the batch overflows
+func outOfCounters(batchCounters *vm.BatchCounterCollector, verifyMerkleProof bool) error {
+	remaining, err := batchCounters.RemainingCounters(verifyMerkleProof)
+	if err != nil {
+		return err
+	}
+	for i, r := range remaining {
+		if r < 0 && i < len(outOfCountersRomErrors) {
+			return failRom(outOfCountersRomErrors[i], "out of counters %s: %d", vm.CounterKeyNames[i], r)
+		}
+	}
+	return failRom(executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_STEP, "out of counters")
+}
+
+// intrinsicRomErrorOf returns the rom error of a transaction failing the checks done before its execution, false if
+// the error is not one of them
+func intrinsicRomErrorOf(err error) (executor.RomError, bool) {
+	switch {
+	case errors.Is(err, core.ErrNonceTooLow), errors.Is(err, core.ErrNonceTooHigh), errors.Is(err, core.ErrNonceMax):
+		return executor.RomError_ROM_ERROR_INTRINSIC_INVALID_NONCE, true
+	case errors.Is(err, core.ErrInsufficientFunds):
+		return executor.RomError_ROM_ERROR_INTRINSIC_INVALID_BALANCE, true
+	case errors.Is(err, core.ErrIntrinsicGas):
+		return executor.RomError_ROM_ERROR_INTRINSIC_INVALID_GAS_LIMIT, true
+	case errors.Is(err, core.ErrGasLimitReached):
+		return executor.RomError_ROM_ERROR_INTRINSIC_INVALID_BATCH_GAS_LIMIT, true
+	case errors.Is(err, core.ErrSenderNoEOA):
+		return executor.RomError_ROM_ERROR_INTRINSIC_INVALID_SENDER_CODE, true
+	case errors.Is(err, core.ErrGasUintOverflow):
+		return executor.RomError_ROM_ERROR_INTRINSIC_TX_GAS_OVERFLOW, true
+	}
+	return executor.RomError_ROM_ERROR_UNSPECIFIED, false
+}
+
+// compareWithLocalExecutor re-executes the request locally in the background and logs where the response of the
+// executor diverges. The comparison has its own timeout so it neither delays the verification nor is cut short by
+// it, the batches verified while all the comparisons are busy are not compared.
+func (e *Executor) compareWithLocalExecutor(request *executor.ProcessStatelessBatchRequestV2, resp *executor.ProcessBatchResponseV2, batchNo uint64) {
+	select {
+	case e.localExecutor.comparisons <- struct{}{}:
+	default:
+		localExecutorSkippedComparisons.Inc()
+		log.Debug("Local executor busy, the batch is not compared", "batch", batchNo)
+		return
+	}
+
+	go func() {
+		defer func() { <-e.localExecutor.comparisons }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), localExecutorCompareTimeout)
+		defer cancel()
+
+		local, err := e.localExecutor.ProcessStatelessBatchV2(ctx, request)
+		if err != nil {
+			log.Warn("Local executor could not process the batch", "batch", batchNo, "error", err)
+			return
+		}
+
+		differences := compareResponses(resp, local)
+		if len(differences) == 0 {
+			log.Debug("Local executor agrees with the executor", "batch", batchNo, "grpcUrl", e.grpcUrl)
+			return
+		}
+		localExecutorDivergences.Inc()
+		log.Warn("Local executor diverges from the executor", "batch", batchNo, "grpcUrl", e.grpcUrl, "differences", differences)
+	}()
+}
+
+// compareResponses returns the differences between the response of the executor and the one of the local executor.
+// The counters of erigon are estimates so only those below the ones of the executor are differences.
+func compareResponses(remote, local *executor.ProcessBatchResponseV2) []string {
+	var differences []string
+	differ := func(format string, args ...any) {
+		differences = append(differences, fmt.Sprintf(format, args...))
+	}
+
+	if remote.Error != local.Error {
+		differ("error: executor %s, local %s", remote.Error, local.Error)
+	}
+	if remote.ErrorRom != local.ErrorRom {
+		differ("rom error: executor %s, local %s", remote.ErrorRom, local.ErrorRom)
+	}
+	if !bytes.Equal(remote.NewStateRoot, local.NewStateRoot) {
+		differ("new state root: executor %s, local %s", common.BytesToHash(remote.NewStateRoot), common.BytesToHash(local.NewStateRoot))
+	}
+
+	counters := []struct {
+		name          string
+		remote, local uint32
+	}{
+		{"SHA", remote.CntSha256Hashes, local.CntSha256Hashes},
+		{"A", remote.CntArithmetics, local.CntArithmetics},
+		{"B", remote.CntBinaries, local.CntBinaries},
+		{"K", remote.CntKeccakHashes, local.CntKeccakHashes},
+		{"M", remote.CntMemAligns, local.CntMemAligns},
+		{"P", remote.CntPoseidonHashes, local.CntPoseidonHashes},
+		{"S", remote.CntSteps, local.CntSteps},
+		{"D", remote.CntPoseidonPaddings, local.CntPoseidonPaddings},
+	}
+	for _, c := range counters {
+		if c.local < c.remote {
+			differ("counter %s undershoot: executor %d, local %d", c.name, c.remote, c.local)
+		}
+	}
+
+	if len(remote.BlockResponses) != len(local.BlockResponses) {
+		differ("blocks: executor %d, local %d", len(remote.BlockResponses), len(local.BlockResponses))
+		return differences
+	}
+	for i, remoteBlock := range remote.BlockResponses {
+		localBlock := local.BlockResponses[i]
+		if remoteBlock.GasUsed != localBlock.GasUsed {
+			differ("block %d gas used: executor %d, local %d", remoteBlock.BlockNumber, remoteBlock.GasUsed, localBlock.GasUsed)
+		}
+		if !bytes.Equal(remoteBlock.BlockInfoRoot, localBlock.BlockInfoRoot) {
+			differ("block %d info root: executor %s, local %s", remoteBlock.BlockNumber, common.BytesToHash(remoteBlock.BlockInfoRoot), common.BytesToHash(localBlock.BlockInfoRoot))
+		}
+		if len(remoteBlock.Responses) != len(localBlock.Responses) {
+			differ("block %d transactions: executor %d, local %d", remoteBlock.BlockNumber, len(remoteBlock.Responses), len(localBlock.Responses))
+			continue
+		}
+		for j, remoteTx := range remoteBlock.Responses {
+			localTx := localBlock.Responses[j]
+			if remoteTx.Status != localTx.Status || remoteTx.GasUsed != localTx.GasUsed {
+				differ("tx %s: executor status %d gas %d, local status %d gas %d", common.BytesToHash(remoteTx.TxHash), remoteTx.Status, remoteTx.GasUsed, localTx.Status, localTx.GasUsed)
+			}
+		}
+	}
+
+	return differences
+}
+
+func romErrorOf(err error) executor.RomError {
+	switch {
+	case errors.Is(err, vm.ErrExecutionReverted):
+		return executor.RomError_ROM_ERROR_EXECUTION_REVERTED
+	case errors.Is(err, vm.ErrOutOfGas), errors.Is(err, vm.ErrCodeStoreOutOfGas):
+		return executor.RomError_ROM_ERROR_OUT_OF_GAS
+	case errors.Is(err, vm.ErrInvalidJump):
+		return executor.RomError_ROM_ERROR_INVALID_JUMP
+	case errors.Is(err, vm.ErrWriteProtection):
+		return executor.RomError_ROM_ERROR_INVALID_STATIC
+	case errors.Is(err, vm.ErrContractAddressCollision):
+		return executor.RomError_ROM_ERROR_CONTRACT_ADDRESS_COLLISION
+	case errors.Is(err, vm.ErrMaxCodeSizeExceeded):
+		return executor.RomError_ROM_ERROR_MAX_CODE_SIZE_EXCEEDED
+	case errors.Is(err, vm.ErrInvalidCode):
+		return executor.RomError_ROM_ERROR_INVALID_BYTECODE_STARTS_EF
+	}
+	var stackUnderflow *vm.ErrStackUnderflow
+	var stackOverflow *vm.ErrStackOverflow
+	var invalidOpCode *vm.ErrInvalidOpCode
+	switch {
+	case errors.As(err, &stackUnderflow):
+		return executor.RomError_ROM_ERROR_STACK_UNDERFLOW
+	case errors.As(err, &stackOverflow):
+		return executor.RomError_ROM_ERROR_STACK_OVERFLOW
+	case errors.As(err, &invalidOpCode):
+		return executor.RomError_ROM_ERROR_INVALID_OPCODE
+	}
+	return executor.RomError_ROM_ERROR_UNSPECIFIED
+}
+
+// batchDataStream iterates the entries of the data stream sent with a batch, the entries are not numbered so the
+// entry number limit is never reached
+type batchDataStream struct {
+	data []byte
+}
+
+func (s *batchDataStream) NextFileEntry() (*dstypes.FileEntry, error) {
+	if len(s.data) < int(dstypes.FileEntryMinSize) {
+		return nil, fmt.Errorf("unexpected end of the data stream")
+	}
+	length := binary.BigEndian.Uint32(s.data[1:5])
+	if length < dstypes.FileEntryMinSize || int(length) > len(s.data) {
+		return nil, fmt.Errorf("invalid entry length %d with %d bytes left", length, len(s.data))
+	}
+	entry, err := dstypes.DecodeFileEntry(s.data[:length])
+	if err != nil {
+		return nil, err
+	}
+	s.data = s.data[length:]
+	return entry, nil
+}
+
+func (s *batchDataStream) GetEntryNumberLimit() uint64 {
+	return math.MaxUint64
+}
+
+// decodeBatchDataStream returns the start of the batch and its blocks with their transactions
+func decodeBatchDataStream(data []byte) (*dstypes.BatchStart, []*dstypes.FullL2Block, error) {
+	stream := &batchDataStream{data: data}
+	var batchStart *dstypes.BatchStart
+	var blocks []*dstypes.FullL2Block
+	for len(stream.data) > 0 {
+		entry, _, err := client.ReadParsedProto(stream)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch e := entry.(type) {
+		case *dstypes.BatchStart:
+			if batchStart != nil {
+				return nil, nil, fmt.Errorf("more than one batch in the data stream")
+			}
+			batchStart = e
+		case *dstypes.FullL2Block:
+			if batchStart == nil {
+				return nil, nil, fmt.Errorf("block %d before the batch start", e.L2BlockNumber)
+			}
+			blocks = append(blocks, e)
+		}
+	}
+	if batchStart == nil {
+		return nil, nil, fmt.Errorf("no batch start in the data stream")
+	}
+	return batchStart, blocks, nil
+}
+
+// witnessStateReader reads the state from the smt of the witness the way erigon stores it: an account with no
+// balance, nonce or code does not exist and the code hash is the keccak hash of the code
+type witnessStateReader struct {
+	tree *smt.SMT
+	// codes is the code of the accounts read or written by keccak hash
+	codes map[common.Hash][]byte
+}
+
+func newWitnessStateReader(tree *smt.SMT) *witnessStateReader {
+	return &witnessStateReader{tree: tree, codes: make(map[common.Hash][]byte)}
+}
+
+func (r *witnessStateReader) ReadAccountData(address common.Address) (*accounts.Account, error) {
+	account, err := r.tree.ReadAccountData(address)
+	if err != nil {
+		return nil, err
+	}
+	// the intra block state drops the balance of an account read that is not initialised
+	account.Initialised = true
+	if account.CodeHash == (common.Hash{}) {
+		if account.Balance.IsZero() && account.Nonce == 0 {
+			return nil, nil
+		}
+		account.CodeHash = emptyCodeHash
+		return account, nil
+	}
+
+	// the smt holds the poseidon hash of the code
+	code, err := r.tree.Db.GetCode(account.CodeHash.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("code of %s not in the witness: %w", address, err)
+	}
+	account.CodeHash = crypto.Keccak256Hash(code)
+	r.codes[account.CodeHash] = code
+	return account, nil
+}
+
+func (r *witnessStateReader) ReadAccountStorage(address common.Address, incarnation uint64, key *common.Hash) ([]byte, error) {
+	return r.tree.ReadAccountStorage(address, incarnation, key)
+}
+
+func (r *witnessStateReader) ReadAccountCode(_ common.Address, _ uint64, codeHash common.Hash) ([]byte, error) {
+	if codeHash == emptyCodeHash {
+		return nil, nil
+	}
+	code, ok := r.codes[codeHash]
+	if !ok {
+		return nil, fmt.Errorf("unknown code hash %s", codeHash)
+	}
+	return code, nil
+}
+
+func (r *witnessStateReader) ReadAccountCodeSize(address common.Address, incarnation uint64, codeHash common.Hash) (int, error) {
+	code, err := r.ReadAccountCode(address, incarnation, codeHash)
+	return len(code), err
+}
+
+func (r *witnessStateReader) ReadAccountIncarnation(common.Address) (uint64, error) {
+	return 0, nil
+}
+
+// smtChangesWriter collects the changes written by the intra block state and applies them to the smt of the
+// witness on flush, it keeps the latest values written to every address for the response
+type smtChangesWriter struct {
+	tree *smt.SMT
+
+	accChanges     map[common.Address]*accounts.Account
+	codeChanges    map[common.Address]string
+	storageChanges map[common.Address]map[string]string
+
+	written map[common.Address]*executor.InfoReadWriteV2
+}
+
+func newSmtChangesWriter(tree *smt.SMT) *smtChangesWriter {
+	w := &smtChangesWriter{
+		tree:    tree,
+		written: make(map[common.Address]*executor.InfoReadWriteV2),
+	}
+	w.reset()
+	return w
+}
+
+func (w *smtChangesWriter) reset() {
+	w.accChanges = make(map[common.Address]*accounts.Account)
+	w.codeChanges = make(map[common.Address]string)
+	w.storageChanges = make(map[common.Address]map[string]string)
+}
+
+func (w *smtChangesWriter) info(address common.Address) *executor.InfoReadWriteV2 {
+	info, ok := w.written[address]
+	if !ok {
+		info = &executor.InfoReadWriteV2{}
+		w.written[address] = info
+	}
+	return info
+}
+
+func (w *smtChangesWriter) UpdateAccountData(address common.Address, _, account *accounts.Account) error {
+	w.accChanges[address] = account.SelfCopy()
+	info := w.info(address)
+	info.Nonce = fmt.Sprintf("%d", account.Nonce)
+	info.Balance = account.Balance.ToBig().String()
+	return nil
+}
+
+func (w *smtChangesWriter) UpdateAccountCode(address common.Address, _ uint64, _ common.Hash, code []byte) error {
+	if len(code) == 0 {
+		w.codeChanges[address] = ""
+	} else {
+		if err := w.tree.Db.AddCode(code); err != nil {
+			return err
+		}
+		w.codeChanges[address] = "0x" + hex.EncodeToString(code)
+	}
+	info := w.info(address)
+	info.ScCode = w.codeChanges[address]
+	info.ScLength = fmt.Sprintf("%d", len(code))
+	return nil
+}
+
+func (w *smtChangesWriter) DeleteAccount(address common.Address, _ *accounts.Account) error {
+	w.accChanges[address] = new(accounts.Account)
+	w.codeChanges[address] = ""
+	info := w.info(address)
+	info.Nonce = "0"
+	info.Balance = "0"
+	info.ScCode = ""
+	info.ScLength = "0"
+	return nil
+}
+
+func (w *smtChangesWriter) WriteAccountStorage(address common.Address, _ uint64, key *common.Hash, _, value *uint256.Int) error {
+	if w.storageChanges[address] == nil {
+		w.storageChanges[address] = make(map[string]string)
+	}
+	k := fmt.Sprintf("0x%032x", *key)
+	w.storageChanges[address][k] = fmt.Sprintf("0x%032x", common.Hash(value.Bytes32()))
+
+	info := w.info(address)
+	if info.ScStorage == nil {
+		info.ScStorage = make(map[string]string)
+	}
+	info.ScStorage[k] = value.Hex()
+	return nil
+}
+
+func (w *smtChangesWriter) CreateContract(common.Address) error {
+	return nil
+}
+
+// flush applies the changes collected to the smt and returns its new root
+func (w *smtChangesWriter) flush() (common.Hash, error) {
+	if _, _, err := w.tree.SetStorage(context.Background(), localExecutorLogPrefix, w.accChanges, w.codeChanges, w.storageChanges); err != nil {
+		return common.Hash{}, failExecutor(executor.ExecutorError_EXECUTOR_ERROR_INVALID_UPDATE_MERKLE_TREE, "update the smt: %v", err)
+	}
+	w.reset()
+	return common.BigToHash(w.tree.LastRoot()), nil
+}
+
+// readWriteAddresses adds the latest values written to every address to the response
+func (w *smtChangesWriter) readWriteAddresses(out map[string]*executor.InfoReadWriteV2) {
+	for address, info := range w.written {
+		out[address.String()] = info
+	}
+}
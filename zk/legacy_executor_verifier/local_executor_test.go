@@ -0,0 +1,217 @@
+package legacy_executor_verifier
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/core/vm"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/smt/pkg/smt"
+	"github.com/ledgerwatch/erigon/zk/datastream/proto/github.com/0xPolygonHermez/zkevm-node/state/datastream"
+	"github.com/ledgerwatch/erigon/zk/datastream/server"
+	dstypes "github.com/ledgerwatch/erigon/zk/datastream/types"
+	"github.com/ledgerwatch/erigon/zk/legacy_executor_verifier/proto/github.com/0xPolygonHermez/zkevm-node/state/runtime/executor"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testReceiver = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testCoinbase = common.HexToAddress("0x2000000000000000000000000000000000000002")
+)
+
+// newLocalExecutorPayload returns a witness of a state with a funded sender and coinbase and the data stream of a batch with a
+// block transferring value from the sender
+func newLocalExecutorPayload(t *testing.T, forkId uint64) (*Payload, *executor.ProcessStatelessBatchRequestV2) {
+	return newLocalExecutorPayloadWithNonce(t, forkId, 1)
+}
+
+// newLocalExecutorPayloadWithNonce is newLocalExecutorPayload with the transfer sent with the given nonce, the sender
+// account is at nonce 1
+func newLocalExecutorPayloadWithNonce(t *testing.T, forkId, nonce uint64) (*Payload, *executor.ProcessStatelessBatchRequestV2) {
+	chainConfig := params.ChainConfigByChainName("hermez-dev")
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	tree := smt.NewSMT(nil, false)
+	_, err = tree.SetAccountState(sender.String(), big.NewInt(1e18), big.NewInt(1))
+	require.NoError(t, err)
+	_, err = tree.SetAccountState(testCoinbase.String(), big.NewInt(1e18), big.NewInt(1))
+	require.NoError(t, err)
+	witness, err := smt.BuildWitness(tree, nil, context.Background())
+	require.NoError(t, err)
+	var witnessBytes bytes.Buffer
+	_, err = witness.WriteInto(&witnessBytes, false)
+	require.NoError(t, err)
+
+	tx, err := types.SignTx(
+		types.NewTransaction(nonce, testReceiver, uint256.NewInt(1000), 21000, uint256.NewInt(1), nil),
+		*types.LatestSignerForChainID(chainConfig.ChainID),
+		key,
+	)
+	require.NoError(t, err)
+	var encoded bytes.Buffer
+	require.NoError(t, tx.EncodeRLP(&encoded))
+
+	entries := server.NewDataStreamEntries(7)
+	entries.Add(dstypes.NewBookmarkProto(1, datastream.BookmarkType_BOOKMARK_TYPE_BATCH))
+	entries.Add(&dstypes.BatchStartProto{BatchStart: &datastream.BatchStart{Number: 1, ForkId: forkId, ChainId: chainConfig.ChainID.Uint64()}})
+	entries.Add(dstypes.NewBookmarkProto(1, datastream.BookmarkType_BOOKMARK_TYPE_L2_BLOCK))
+	entries.Add(&dstypes.L2BlockProto{L2Block: &datastream.L2Block{Number: 1, BatchNumber: 1, Timestamp: 100, Coinbase: testCoinbase.Bytes()}})
+	entries.Add(&dstypes.TxProto{Transaction: &datastream.Transaction{L2BlockNumber: 1, IsValid: true, Encoded: encoded.Bytes(), EffectiveGasPricePercentage: 255}})
+	entries.Add(&dstypes.L2BlockEndProto{Number: 1})
+	entries.Add(&dstypes.BatchEndProto{BatchEnd: &datastream.BatchEnd{Number: 1}})
+	dataStream, err := entries.Marshal()
+	require.NoError(t, err)
+
+	payload := &Payload{
+		Witness:    witnessBytes.Bytes(),
+		DataStream: dataStream,
+		Coinbase:   testCoinbase.String(),
+		ContextId:  "cdk-erigon-test",
+	}
+	request := &executor.ProcessStatelessBatchRequestV2{
+		Witness:    payload.Witness,
+		DataStream: payload.DataStream,
+		Coinbase:   payload.Coinbase,
+		ContextId:  payload.ContextId,
+	}
+	return payload, request
+}
+
+func TestLocalExecutor(t *testing.T) {
+	local := NewLocalExecutor(params.ChainConfigByChainName("hermez-dev"), 0.6)
+	payload, request := newLocalExecutorPayload(t, 8)
+
+	resp, err := local.ProcessStatelessBatchV2(context.Background(), request)
+	require.NoError(t, err)
+	require.Nil(t, resp.Debug)
+	require.Equal(t, executor.ExecutorError_EXECUTOR_ERROR_NO_ERROR, resp.Error)
+	require.Equal(t, uint64(8), resp.ForkId)
+	require.NotEqual(t, resp.OldStateRoot, resp.NewStateRoot)
+	require.NotZero(t, resp.CntSteps)
+	require.NotZero(t, resp.CntPoseidonHashes)
+
+	require.Len(t, resp.BlockResponses, 1)
+	block := resp.BlockResponses[0]
+	require.Equal(t, uint64(21000), block.GasUsed)
+	require.Equal(t, resp.NewStateRoot, block.BlockHash)
+	require.Len(t, block.Responses, 1)
+	require.Equal(t, uint32(1), block.Responses[0].Status)
+	require.Equal(t, "1000", resp.ReadWriteAddresses[testReceiver.String()].Balance)
+	require.Equal(t, "1000000000000021000", resp.ReadWriteAddresses[testCoinbase.String()].Balance)
+
+	// the re-execution is deterministic
+	again, err := local.ProcessStatelessBatchV2(context.Background(), request)
+	require.NoError(t, err)
+	require.Equal(t, resp.NewStateRoot, again.NewStateRoot)
+	require.Empty(t, compareResponses(resp, again))
+
+	// served over grpc it verifies the batch for the executor client
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, err := local.Serve(ctx, "127.0.0.1:0")
+	require.NoError(t, err)
+	e := NewExecutor(addr.String(), 5*time.Second, 1, "")
+	defer e.Close()
+
+	verifierRequest := &VerifierRequest{ForkId: 8, BatchNumber: 1, StateRoot: common.BytesToHash(resp.NewStateRoot)}
+	ok, _, executorErr, err := e.Verify(payload, verifierRequest, common.BytesToHash(resp.OldStateRoot))
+	require.NoError(t, err)
+	require.NoError(t, executorErr)
+	require.True(t, ok)
+
+	verifierRequest.StateRoot = common.Hash{1}
+	ok, _, executorErr, err = e.Verify(payload, verifierRequest, common.BytesToHash(resp.OldStateRoot))
+	require.NoError(t, err)
+	require.ErrorIs(t, executorErr, ErrExecutorStateRootMismatch)
+	require.False(t, ok)
+}
+
+func TestLocalExecutorFailures(t *testing.T) {
+	local := NewLocalExecutor(params.ChainConfigByChainName("hermez-dev"), 0.6)
+
+	_, request := newLocalExecutorPayload(t, 6)
+	resp, err := local.ProcessStatelessBatchV2(context.Background(), request)
+	require.NoError(t, err)
+	require.Equal(t, executor.ExecutorError_EXECUTOR_ERROR_UNSUPPORTED_FORK_ID, resp.Error)
+
+	_, request = newLocalExecutorPayload(t, 8)
+	request.TimestampLimit = 99
+	resp, err = local.ProcessStatelessBatchV2(context.Background(), request)
+	require.NoError(t, err)
+	require.Equal(t, executor.RomError_ROM_ERROR_INVALID_TX_CHANGE_L2_BLOCK_LIMIT_TIMESTAMP, resp.ErrorRom)
+	require.NotEmpty(t, resp.Debug.ErrorLog)
+
+	request.TimestampLimit = 0
+	// a witness of an unknown version
+	request.Witness = []byte{0xff}
+	resp, err = local.ProcessStatelessBatchV2(context.Background(), request)
+	require.NoError(t, err)
+	require.Equal(t, executor.ExecutorError_EXECUTOR_ERROR_INVALID_WITNESS, resp.Error)
+}
+
+func TestLocalExecutorIntrinsicTxError(t *testing.T) {
+	local := NewLocalExecutor(params.ChainConfigByChainName("hermez-dev"), 0.6)
+
+	// as with the executor the transaction fails alone and the batch is processed
+	_, request := newLocalExecutorPayloadWithNonce(t, 8, 5)
+	resp, err := local.ProcessStatelessBatchV2(context.Background(), request)
+	require.NoError(t, err)
+	require.Equal(t, executor.ExecutorError_EXECUTOR_ERROR_NO_ERROR, resp.Error)
+	require.Equal(t, executor.RomError_ROM_ERROR_NO_ERROR, resp.ErrorRom)
+
+	require.Len(t, resp.BlockResponses, 1)
+	block := resp.BlockResponses[0]
+	require.Zero(t, block.GasUsed)
+	require.Len(t, block.Responses, 1)
+	require.Equal(t, executor.RomError_ROM_ERROR_INTRINSIC_INVALID_NONCE, block.Responses[0].Error)
+	require.Zero(t, block.Responses[0].Status)
+	require.Zero(t, block.Responses[0].GasUsed)
+	require.Nil(t, resp.ReadWriteAddresses[testReceiver.String()])
+}
+
+func TestLocalExecutorOutOfCounters(t *testing.T) {
+	// the counters already used in the batch overflow the keccaks
+	used := vm.NewCounterCollector(64, 8)
+	used.Deduct(vm.K, 1<<30)
+	addon := used.Counters()
+	batchCounters := vm.NewBatchCounterCollector(64, 8, 0.6, false, &addon)
+
+	err := outOfCounters(batchCounters, false)
+	var failure *executorFailure
+	require.ErrorAs(t, err, &failure)
+	require.Equal(t, executor.RomError_ROM_ERROR_OUT_OF_COUNTERS_KECCAK, failure.romErr)
+}
+
+func TestCompareResponses(t *testing.T) {
+	remote := &executor.ProcessBatchResponseV2{
+		NewStateRoot: common.Hash{1}.Bytes(),
+		CntSteps:     100,
+		CntBinaries:  10,
+		BlockResponses: []*executor.ProcessBlockResponseV2{
+			{BlockNumber: 1, GasUsed: 21000, Responses: []*executor.ProcessTransactionResponseV2{{Status: 1, GasUsed: 21000}}},
+		},
+	}
+	local := &executor.ProcessBatchResponseV2{
+		NewStateRoot: common.Hash{2}.Bytes(),
+		CntSteps:     90,
+		CntBinaries:  20,
+		BlockResponses: []*executor.ProcessBlockResponseV2{
+			{BlockNumber: 1, GasUsed: 21000, Responses: []*executor.ProcessTransactionResponseV2{{Status: 0, GasUsed: 21000}}},
+		},
+	}
+
+	differences := compareResponses(remote, local)
+	require.Len(t, differences, 3)
+	require.Contains(t, differences[0], "new state root")
+	require.Contains(t, differences[1], "counter S undershoot")
+	require.Contains(t, differences[2], "status")
+}
@@ -653,7 +653,7 @@ func rollback(
 	tx kv.RwTx,
 	u stagedsync.Unwinder,
 ) (uint64, error) {
-	dsClient := buildNewStreamClient(ctx, cfg, latestFork)
+	dsClient := NewDatastreamClient(ctx, cfg.zkCfg, latestFork)
 	if err := dsClient.Start(); err != nil {
 		return 0, err
 	}
@@ -819,7 +819,7 @@ func getHighestDSL2Block(ctx context.Context, batchCfg BatchesCfg, latestFork ui
 	// but we're going to open a new connection rather than use the one for syncing blocks.
 	// This is so we can keep the logic simple and just dispose of the connection when we're done
 	// greatly simplifying state juggling of the connection if it errors
-	dsClient := buildNewStreamClient(ctx, batchCfg, latestFork)
+	dsClient := NewDatastreamClient(ctx, batchCfg.zkCfg, latestFork)
 	if err = dsClient.Start(); err != nil {
 		return 0, err
 	}
@@ -836,7 +836,23 @@ func getHighestDSL2Block(ctx context.Context, batchCfg BatchesCfg, latestFork ui
 	return fullBlock.L2BlockNumber, nil
 }
 
-func buildNewStreamClient(ctx context.Context, batchesCfg BatchesCfg, latestFork uint16) *client.StreamClient {
-	cfg := batchesCfg.zkCfg
-	return client.NewClient(ctx, cfg.L2DataStreamerUrl, cfg.L2DataStreamerUseTLS, cfg.DatastreamVersion, cfg.L2DataStreamerTimeout, latestFork)
+// NewDatastreamClient creates a client of the L2 datastreamer, reading from the failover endpoints too when they
+// are configured
+func NewDatastreamClient(ctx context.Context, cfg *ethconfig.Zk, latestFork uint16) DatastreamClient {
+	newClient := func(url string) *client.StreamClient {
+		c := client.NewClient(ctx, url, cfg.L2DataStreamerUseTLS, cfg.DatastreamVersion, cfg.L2DataStreamerTimeout, latestFork)
+		c.SetTLSFiles(cfg.L2DataStreamerTLSCert, cfg.L2DataStreamerTLSKey, cfg.L2DataStreamerTLSCA)
+		return c
+	}
+
+	clients := []*client.StreamClient{newClient(cfg.L2DataStreamerUrl)}
+	for _, url := range cfg.L2DataStreamerFailoverUrls {
+		if url != cfg.L2DataStreamerUrl {
+			clients = append(clients, newClient(url))
+		}
+	}
+	if len(clients) == 1 {
+		return clients[0]
+	}
+	return client.NewMultiStreamClient(ctx, clients, cfg.L2DataStreamerStallTimeout, cfg.L2DataStreamerCrossCheckInterval)
 }
@@ -54,6 +54,9 @@ type ZkInterHashesCfg struct {
 	historyV3 bool
 	agg       *state.Aggregator
 	zk        *ethconfig.Zk
+
+	// gc sweeps the unreachable nodes of the tree in chunks while pruning, nil when disabled
+	gc *smt.GarbageCollector
 }
 
 func StageZkInterHashesCfg(
@@ -66,6 +69,11 @@ func StageZkInterHashesCfg(
 	agg *state.Aggregator,
 	zk *ethconfig.Zk,
 ) ZkInterHashesCfg {
+	var gc *smt.GarbageCollector
+	if zk != nil && zk.SmtGcChunkSize > 0 {
+		gc = smt.NewGarbageCollector()
+	}
+
 	return ZkInterHashesCfg{
 		db:                db,
 		checkRoot:         checkRoot,
@@ -78,6 +86,7 @@ func StageZkInterHashesCfg(
 		historyV3: historyV3,
 		agg:       agg,
 		zk:        zk,
+		gc:        gc,
 	}
 }
 
@@ -133,6 +142,7 @@ func SpawnZkIntermediateHashesStage(s *stagedsync.StageState, u stagedsync.Unwin
 
 	eridb := db2.NewEriDb(tx)
 	smt := smt.NewSMT(eridb, false)
+	smt.SetHashWorkers(cfg.zk.SmtHashWorkers)
 
 	if cfg.zk.SmtRegenerateInMemory {
 		log.Info(fmt.Sprintf("[%s] SMT using mapmutation", logPrefix))
@@ -145,13 +155,16 @@ func SpawnZkIntermediateHashesStage(s *stagedsync.StageState, u stagedsync.Unwin
 		if shouldIncrementBecauseOfAFlag {
 			log.Debug(fmt.Sprintf("[%s] IncrementTreeAlways true - incrementing tree", logPrefix), "previousRootHeight", s.BlockNumber, "calculatingRootHeight", to)
 		}
-		if root, err = zkIncrementIntermediateHashes(ctx, logPrefix, s, tx, eridb, smt, s.BlockNumber, to); err != nil {
+		if root, err = zkIncrementIntermediateHashes(ctx, logPrefix, s, tx, eridb, smt, s.BlockNumber, to, cfg.zk.SmtRetainBlocks); err != nil {
 			return trie.EmptyRoot, err
 		}
 	} else {
 		if root, err = regenerateIntermediateHashes(ctx, logPrefix, tx, eridb, smt, to); err != nil {
 			return trie.EmptyRoot, err
 		}
+		if err = retainSmtRoot(logPrefix, eridb, smt, to, cfg.zk.SmtRetainBlocks); err != nil {
+			return trie.EmptyRoot, err
+		}
 	}
 
 	log.Info(fmt.Sprintf("[%s] Trie root", logPrefix), "hash", root.Hex())
@@ -221,7 +234,12 @@ func UnwindZkIntermediateHashesStage(u *stagedsync.UnwindState, s *stagedsync.St
 		expectedRootHash = syncHeadHeader.Root
 	}
 
-	root, err := unwindZkSMT(ctx, s.LogPrefix(), s.BlockNumber, u.UnwindPoint, tx, cfg.checkRoot, &expectedRootHash, silent, quit)
+	var retainBlocks uint64
+	if cfg.zk != nil {
+		retainBlocks = cfg.zk.SmtRetainBlocks
+	}
+
+	root, err := unwindZkSMT(ctx, s.LogPrefix(), s.BlockNumber, u.UnwindPoint, tx, cfg.checkRoot, &expectedRootHash, silent, quit, retainBlocks)
 	if err != nil {
 		return err
 	}
@@ -243,6 +261,46 @@ func UnwindZkIntermediateHashesStage(u *stagedsync.UnwindState, s *stagedsync.St
 	return nil
 }
 
+// PruneZkIntermediateHashesStage runs the next chunk of the garbage collection of the tree
+func PruneZkIntermediateHashesStage(p *stagedsync.PruneState, tx kv.RwTx, cfg ZkInterHashesCfg, ctx context.Context) (err error) {
+	if cfg.gc == nil {
+		return nil
+	}
+
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		tx, err = cfg.db.BeginRw(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	report, err := cfg.gc.Collect(ctx, db2.NewEriDb(tx), int(cfg.zk.SmtGcChunkSize))
+	if err != nil {
+		return err
+	}
+	if report.Done {
+		log.Info(fmt.Sprintf("[%s] State tree garbage collected", p.LogPrefix()),
+			"roots", report.Roots,
+			"liveNodes", report.LiveNodes,
+			"marked", report.MarkedBranches,
+			"scanned", report.ScannedEntries,
+			"nodes", report.DeletedNodes,
+			"hashKeys", report.DeletedHashKeys,
+			"keySources", report.DeletedKeySources,
+			"reclaimed", libcommon.ByteCount(report.ReclaimedBytes),
+		)
+	}
+
+	if !useExternalTx {
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func regenerateIntermediateHashes(ctx context.Context, logPrefix string, db kv.RwTx, eridb *db2.EriDb, smtIn *smt.SMT, toBlock uint64) (common.Hash, error) {
 	log.Info(fmt.Sprintf("[%s] Regeneration trie hashes started", logPrefix))
 	defer log.Info(fmt.Sprintf("[%s] Regeneration ended", logPrefix))
@@ -347,10 +405,14 @@ func regenerateIntermediateHashes(ctx context.Context, logPrefix string, db kv.R
 	return common.BigToHash(root), nil
 }
 
-func zkIncrementIntermediateHashes(ctx context.Context, logPrefix string, s *stagedsync.StageState, db kv.RwTx, eridb *db2.EriDb, dbSmt *smt.SMT, from, to uint64) (common.Hash, error) {
+// zkIncrementIntermediateHashes applies the state changes of the blocks to the tree. With retainBlocks set, every block
+// within the retention window gets its own tree whose root is retained
+func zkIncrementIntermediateHashes(ctx context.Context, logPrefix string, s *stagedsync.StageState, db kv.RwTx, eridb *db2.EriDb, dbSmt *smt.SMT, from, to, retainBlocks uint64) (common.Hash, error) {
 	log.Info(fmt.Sprintf("[%s] Increment trie hashes started", logPrefix), "previousRootHeight", s.BlockNumber, "calculatingRootHeight", to)
 	defer log.Info(fmt.Sprintf("[%s] Increment ended", logPrefix))
 
+	eridb.SetRetainNodes(retainBlocks > 0)
+
 	ac, err := db.CursorDupSort(kv.AccountChangeSet)
 	if err != nil {
 		return trie.EmptyRoot, err
@@ -368,6 +430,16 @@ func zkIncrementIntermediateHashes(ctx context.Context, logPrefix string, s *sta
 	codeChanges := make(map[common.Address]string)
 	storageChanges := make(map[common.Address]map[string]string)
 
+	applyChanges := func(blockNumber uint64) error {
+		if _, _, err := dbSmt.SetStorage(ctx, logPrefix, accChanges, codeChanges, storageChanges); err != nil {
+			return err
+		}
+		accChanges = make(map[common.Address]*accounts.Account)
+		codeChanges = make(map[common.Address]string)
+		storageChanges = make(map[common.Address]map[string]string)
+		return retainSmtRoot(logPrefix, eridb, dbSmt, blockNumber, retainBlocks)
+	}
+
 	// case when we are incrementing from block 1
 	// we chould include the 0 block which is the genesis data
 	if from != 0 {
@@ -435,9 +507,16 @@ func zkIncrementIntermediateHashes(ctx context.Context, logPrefix string, s *sta
 		if err != nil {
 			return trie.EmptyRoot, err
 		}
+
+		// the blocks within the retention window get their own tree
+		if i < to && to-i < retainBlocks {
+			if err = applyChanges(i); err != nil {
+				return trie.EmptyRoot, err
+			}
+		}
 	}
 
-	if _, _, err := dbSmt.SetStorage(ctx, logPrefix, accChanges, codeChanges, storageChanges); err != nil {
+	if err := applyChanges(to); err != nil {
 		return trie.EmptyRoot, err
 	}
 
@@ -456,7 +535,7 @@ func zkIncrementIntermediateHashes(ctx context.Context, logPrefix string, s *sta
 	return hash, nil
 }
 
-func unwindZkSMT(ctx context.Context, logPrefix string, from, to uint64, db kv.RwTx, checkRoot bool, expectedRootHash *common.Hash, quiet bool, quit <-chan struct{}) (common.Hash, error) {
+func unwindZkSMT(ctx context.Context, logPrefix string, from, to uint64, db kv.RwTx, checkRoot bool, expectedRootHash *common.Hash, quiet bool, quit <-chan struct{}, retainBlocks uint64) (common.Hash, error) {
 	if !quiet {
 		log.Info(fmt.Sprintf("[%s] Unwind trie hashes started", logPrefix))
 		defer log.Info(fmt.Sprintf("[%s] Unwind ended", logPrefix))
@@ -464,6 +543,8 @@ func unwindZkSMT(ctx context.Context, logPrefix string, from, to uint64, db kv.R
 
 	eridb := db2.NewEriDb(db)
 	dbSmt := smt.NewSMT(eridb, false)
+	// the nodes of the unwound trees become stale at the unwind point
+	eridb.SetRetainNodes(retainBlocks > 0)
 
 	if !quiet {
 		log.Info(fmt.Sprintf("[%s]", logPrefix), "last root", common.BigToHash(dbSmt.LastRoot()))
@@ -625,6 +706,15 @@ func unwindZkSMT(ctx context.Context, logPrefix string, from, to uint64, db kv.R
 		return trie.EmptyRoot, err
 	}
 
+	if err := eridb.TruncateRetainedRoots(to); err != nil {
+		return trie.EmptyRoot, err
+	}
+	if retainBlocks > 0 {
+		if err := retainSmtRoot(logPrefix, eridb, dbSmt, to, retainBlocks); err != nil {
+			return trie.EmptyRoot, err
+		}
+	}
+
 	if err := eridb.CommitBatch(); err != nil {
 		return trie.EmptyRoot, err
 	}
@@ -635,6 +725,31 @@ func unwindZkSMT(ctx context.Context, logPrefix string, from, to uint64, db kv.R
 	return hash, nil
 }
 
+// retainSmtRoot retains the root of the tree at the block and prunes the trees that left the retention window. Without
+// retention, the trees retained before are dropped as their nodes are no longer kept
+func retainSmtRoot(logPrefix string, eridb *db2.EriDb, dbSmt *smt.SMT, blockNumber, retainBlocks uint64) error {
+	if retainBlocks == 0 {
+		_, err := eridb.PruneRetainedRoots(math.MaxUint64)
+		return err
+	}
+
+	if err := eridb.RetainRoot(blockNumber, dbSmt.LastRoot()); err != nil {
+		return err
+	}
+	if blockNumber+1 <= retainBlocks {
+		return nil
+	}
+
+	pruned, err := eridb.PruneRetainedRoots(blockNumber + 1 - retainBlocks)
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		log.Debug(fmt.Sprintf("[%s] Pruned the nodes of the trees out of the retention window", logPrefix), "nodes", pruned, "block", blockNumber)
+	}
+	return nil
+}
+
 func verifyLastHash(dbSmt *smt.SMT, expectedRootHash *common.Hash, checkRoot bool, logPrefix string, quiet bool) error {
 	hash := common.BigToHash(dbSmt.LastRoot())
 
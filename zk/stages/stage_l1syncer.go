@@ -9,6 +9,7 @@ import (
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/log/v3"
 
+	"math"
 	"math/big"
 
 	"github.com/ledgerwatch/erigon-lib/common"
@@ -30,6 +31,8 @@ type IL1Syncer interface {
 	IsSyncStarted() bool
 	IsDownloading() bool
 	GetLastCheckedL1Block() uint64
+	GetLastCheckedL1BlockHash() (uint64, common.Hash, bool)
+	IsReorgDetected() bool
 
 	// Channels
 	GetLogsChan() chan []ethTypes.Log
@@ -103,6 +106,26 @@ func SpawnStageL1Syncer(
 	// pass tx to the hermezdb
 	hermezDb := hermez_db.NewHermezDb(tx)
 
+	// the hashes of the l1 blocks the data was read from are checked against the l1 every cycle, on a reorg the data
+	// read from the reorged blocks is deleted and the syncer queries again from the fork point
+	forkPoint, reorged, err := findL1ForkPoint(hermezDb, cfg.syncer)
+	if err != nil {
+		return fmt.Errorf("failed to check for l1 reorgs, %w", err)
+	}
+	if reorged || cfg.syncer.IsReorgDetected() {
+		if cfg.syncer.IsSyncStarted() {
+			cfg.syncer.StopQueryBlocks()
+			cfg.syncer.ConsumeQueryBlocks()
+			cfg.syncer.WaitQueryBlocksToFinish()
+		}
+	}
+	if reorged {
+		log.Warn(fmt.Sprintf("[%s] L1 reorg detected, unwinding the L1 data", logPrefix), "forkPoint", forkPoint)
+		if err := unwindL1Data(tx, hermezDb, forkPoint, u, logPrefix); err != nil {
+			return fmt.Errorf("failed to unwind the l1 data, %w", err)
+		}
+	}
+
 	// get l1 block progress from this stage's progress
 	l1BlockProgress, err := stages.GetStageProgress(tx, stages.L1Syncer)
 	if err != nil {
@@ -139,6 +162,10 @@ Loop:
 		case logs := <-logsChan:
 			for _, l := range logs {
 				l := l
+				if err := hermezDb.WriteL1BlockHash(l.BlockNumber, l.BlockHash); err != nil {
+					funcErr = fmt.Errorf("failed to write l1 block hash, %w", err)
+					return funcErr
+				}
 				info, batchLogType := parseLogType(cfg.zkCfg.L1RollupId, &l)
 				switch batchLogType {
 				case logSequence:
@@ -199,6 +226,12 @@ Loop:
 	}
 
 	latestCheckedBlock := cfg.syncer.GetLastCheckedL1Block()
+	if blockNo, hash, ok := cfg.syncer.GetLastCheckedL1BlockHash(); ok {
+		if err := hermezDb.WriteL1BlockHash(blockNo, hash); err != nil {
+			funcErr = fmt.Errorf("failed to write l1 block hash, %w", err)
+			return funcErr
+		}
+	}
 
 	lastCheckedL1BlockCounter.Set(float64(latestCheckedBlock))
 
@@ -316,6 +349,130 @@ func UnwindL1SyncerStage(u *stagedsync.UnwindState, tx kv.RwTx, cfg L1SyncerCfg,
 	return nil
 }
 
+// findL1ForkPoint walks the stored l1 block hashes down from the highest one until it finds a block still on the l1
+// chain. It returns whether any stored block was reorged and the highest block kept.
+func findL1ForkPoint(hermezDb *hermez_db.HermezDb, syncer IL1Syncer) (uint64, bool, error) {
+	reorged := false
+	blockNo := uint64(math.MaxUint64)
+	for {
+		storedBlockNo, storedHash, found, err := hermezDb.GetL1BlockHashBefore(blockNo)
+		if err != nil {
+			return 0, false, err
+		}
+		if !found {
+			// no stored block is on the l1 chain anymore, everything is unwound
+			return 0, reorged, nil
+		}
+
+		header, err := syncer.GetHeader(storedBlockNo)
+		if err != nil {
+			return 0, false, err
+		}
+		if header != nil && header.Hash() == storedHash {
+			return storedBlockNo, reorged, nil
+		}
+
+		reorged = true
+		blockNo = storedBlockNo
+	}
+}
+
+// unwindL1Data deletes the l1 data read from the blocks after the fork point and rolls the l1 stages back to it. The
+// l1 batch data and off chain data of the batches sequenced after the fork point are deleted, and the l2 blocks using
+// a deleted info tree index are unwound.
+func unwindL1Data(tx kv.RwTx, hermezDb *hermez_db.HermezDb, forkPoint uint64, u stagedsync.Unwinder, logPrefix string) error {
+	from := forkPoint + 1
+
+	latestSequence, err := hermezDb.GetLatestSequence()
+	if err != nil {
+		return err
+	}
+	if err := hermezDb.DeleteSequencesFromL1Block(from); err != nil {
+		return err
+	}
+	if latestSequence != nil && latestSequence.L1BlockNo >= from {
+		// the first batch of the earliest deleted sequence follows the last batch of the sequence kept
+		fromBatch := uint64(1)
+		keptSequence, err := hermezDb.GetLatestSequence()
+		if err != nil {
+			return err
+		}
+		if keptSequence != nil {
+			fromBatch = keptSequence.BatchNo + 1
+		}
+		if err = hermezDb.DeleteL1BatchDataFrom(fromBatch); err != nil {
+			return err
+		}
+	}
+	if err := hermezDb.DeleteVerificationsFromL1Block(from); err != nil {
+		return err
+	}
+	fromIndex, infoTreeDeleted, err := hermezDb.DeleteL1InfoTreeUpdatesFromL1Block(from)
+	if err != nil {
+		return err
+	}
+	if err = hermezDb.DeleteL1BlockHashesFrom(from); err != nil {
+		return err
+	}
+
+	for _, stage := range []stages.SyncStage{stages.L1Syncer, stages.L1InfoTree, stages.L1BlockSync} {
+		progress, err := stages.GetStageProgress(tx, stage)
+		if err != nil {
+			return err
+		}
+		if progress > forkPoint {
+			if err = stages.SaveStageProgress(tx, stage, forkPoint); err != nil {
+				return err
+			}
+		}
+	}
+
+	var verifiedBatchNo uint64
+	verification, err := hermezDb.GetLatestVerification()
+	if err != nil {
+		return err
+	}
+	if verification != nil {
+		verifiedBatchNo = verification.BatchNo
+	}
+	if err = stages.SaveStageProgress(tx, stages.L1VerificationsBatchNo, verifiedBatchNo); err != nil {
+		return err
+	}
+
+	verifiedBlockNo, err := hermezDb.GetHighestVerifiedBlockNo()
+	if err != nil {
+		return err
+	}
+	checkedBlockNo, err := stages.GetStageProgress(tx, stages.VerificationsStateRootCheck)
+	if err != nil {
+		return err
+	}
+	if checkedBlockNo > verifiedBlockNo {
+		if err = stages.SaveStageProgress(tx, stages.VerificationsStateRootCheck, verifiedBlockNo); err != nil {
+			return err
+		}
+	}
+
+	if !infoTreeDeleted {
+		return nil
+	}
+
+	// index 0 is stored for the blocks not using the info tree, it is never an update to unwind
+	if fromIndex == 0 {
+		fromIndex = 1
+	}
+	l2BlockNo, found, err := hermezDb.GetFirstBlockWithL1InfoTreeIndexFrom(fromIndex)
+	if err != nil {
+		return err
+	}
+	if found && l2BlockNo > 0 && u != nil {
+		log.Warn(fmt.Sprintf("[%s] Unwinding the L2 blocks using reorged L1 info tree updates", logPrefix), "index", fromIndex, "block", l2BlockNo)
+		u.UnwindTo(l2BlockNo-1, stagedsync.StagedUnwind)
+	}
+
+	return nil
+}
+
 func PruneL1SyncerStage(s *stagedsync.PruneState, tx kv.RwTx, cfg L1SyncerCfg, ctx context.Context) (err error) {
 	// no need to prune this data
 	return nil
@@ -0,0 +1,197 @@
+package stages_test
+
+import (
+	"context"
+	"math/big"
+	"sync/atomic"
+	"testing"
+
+	ethereum "github.com/ledgerwatch/erigon"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/accounts/abi/bind"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/eth/stagedsync"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/zk/contracts"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	zkStages "github.com/ledgerwatch/erigon/zk/stages"
+	"github.com/ledgerwatch/erigon/zk/syncer"
+	zkTypes "github.com/ledgerwatch/erigon/zk/types"
+	"github.com/ledgerwatch/erigon/zkevm/etherman"
+	"github.com/stretchr/testify/require"
+)
+
+// reorgEtherman replaces the blocks of the simulated chain from the fork block with blocks having other hashes and
+// no logs, as a side chain would
+type reorgEtherman struct {
+	syncer.IEtherman
+	forkBlock atomic.Uint64
+}
+
+func (e *reorgEtherman) reorged(number uint64) bool {
+	forkBlock := e.forkBlock.Load()
+	return forkBlock > 0 && number >= forkBlock
+}
+
+func (e *reorgEtherman) sideHeader(header *types.Header) *types.Header {
+	if header == nil || !e.reorged(header.Number.Uint64()) {
+		return header
+	}
+	side := types.CopyHeader(header)
+	side.Extra = []byte("side chain")
+	return side
+}
+
+func (e *reorgEtherman) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	header, err := e.IEtherman.HeaderByNumber(ctx, number)
+	return e.sideHeader(header), err
+}
+
+func (e *reorgEtherman) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	block, err := e.IEtherman.BlockByNumber(ctx, number)
+	if err != nil || block == nil || !e.reorged(block.NumberU64()) {
+		return block, err
+	}
+	return types.NewBlockWithHeader(e.sideHeader(block.Header())), nil
+}
+
+func (e *reorgEtherman) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	logs, err := e.IEtherman.FilterLogs(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	kept := logs[:0]
+	for _, l := range logs {
+		if !e.reorged(l.BlockNumber) {
+			kept = append(kept, l)
+		}
+	}
+	return kept, nil
+}
+
+type testUnwinder struct {
+	unwindPoint *uint64
+}
+
+func (u *testUnwinder) UnwindTo(unwindPoint uint64, _ stagedsync.UnwindReason) {
+	u.unwindPoint = &unwindPoint
+}
+
+func (u *testUnwinder) IsUnwindSet() bool {
+	return u.unwindPoint != nil
+}
+
+func TestSpawnStageL1SyncerUnwindsL1Reorg(t *testing.T) {
+	ctx, db := context.Background(), memdb.NewTestDB(t)
+	tx := memdb.BeginRw(t, db)
+	require.NoError(t, hermez_db.CreateHermezBuckets(tx))
+	hermezDb := hermez_db.NewHermezDb(tx)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	auth, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	require.NoError(t, err)
+	client, backend, _, _, err := etherman.NewSimulatedEtherman(etherman.Config{}, auth)
+	require.NoError(t, err)
+
+	// the simulated chain holds the genesis and the block deploying the contracts, the data read from both of them is
+	// written as a previous stage run would have
+	for l1BlockNo := uint64(0); l1BlockNo <= 1; l1BlockNo++ {
+		header, err := backend.HeaderByNumber(ctx, new(big.Int).SetUint64(l1BlockNo))
+		require.NoError(t, err)
+		batchNo := l1BlockNo + 1
+		require.NoError(t, hermezDb.WriteL1BlockHash(l1BlockNo, header.Hash()))
+		require.NoError(t, hermezDb.WriteSequence(l1BlockNo, batchNo, common.Hash{}, common.Hash{}, common.Hash{}))
+		require.NoError(t, hermezDb.WriteVerification(l1BlockNo, batchNo, common.Hash{}, common.Hash{byte(batchNo)}))
+
+		update := &zkTypes.L1InfoTreeUpdate{Index: l1BlockNo, GER: common.Hash{byte(batchNo)}, BlockNumber: l1BlockNo}
+		require.NoError(t, hermezDb.WriteL1InfoTreeUpdate(update))
+		require.NoError(t, hermezDb.WriteL1InfoTreeUpdateToGer(update))
+		require.NoError(t, hermezDb.WriteL1InfoTreeLeaf(update.Index, common.Hash{byte(batchNo)}))
+		require.NoError(t, hermezDb.WriteBlockL1InfoTreeIndex(batchNo*10, update.Index))
+
+		// the batches are validium ones recovered from the l1
+		offChainData := []byte{byte(batchNo)}
+		require.NoError(t, hermezDb.WriteL1BatchData(batchNo, offChainData))
+		require.NoError(t, hermezDb.WriteOffChainData(crypto.Keccak256Hash(offChainData), offChainData))
+		require.NoError(t, hermezDb.WriteBatchOffChainDataHash(batchNo, crypto.Keccak256Hash(offChainData)))
+	}
+	require.NoError(t, stages.SaveStageProgress(tx, stages.L1BlockSync, 1))
+	require.NoError(t, stages.SaveStageProgress(tx, stages.L1Syncer, 1))
+	require.NoError(t, stages.SaveStageProgress(tx, stages.L1InfoTree, 2))
+	require.NoError(t, stages.SaveStageProgress(tx, stages.L1VerificationsBatchNo, 2))
+
+	// the block deploying the contracts is reorged out
+	em := &reorgEtherman{IEtherman: backend}
+	em.forkBlock.Store(1)
+
+	l1Syncer := syncer.NewL1Syncer(
+		ctx,
+		[]syncer.IEtherman{em},
+		client.SCAddresses,
+		[][]common.Hash{{contracts.SequencedBatchTopicPreEtrog, contracts.VerificationTopicPreEtrog}},
+		10,
+		10,
+		"latest",
+	)
+	defer func() {
+		l1Syncer.StopQueryBlocks()
+		l1Syncer.ConsumeQueryBlocks()
+		l1Syncer.WaitQueryBlocksToFinish()
+	}()
+
+	zkCfg := &ethconfig.Zk{L1RollupId: 1, L1FirstBlock: 1}
+	cfg := zkStages.StageL1SyncerCfg(db, l1Syncer, zkCfg)
+	s := &stagedsync.StageState{ID: stages.L1Syncer, BlockNumber: 0}
+	u := &testUnwinder{}
+
+	require.NoError(t, zkStages.SpawnStageL1Syncer(s, u, ctx, tx, cfg, false))
+
+	sequence, err := hermezDb.GetSequenceByBatchNo(1)
+	require.NoError(t, err)
+	require.NotNil(t, sequence)
+	sequence, err = hermezDb.GetSequenceByBatchNo(2)
+	require.NoError(t, err)
+	require.Nil(t, sequence)
+	verification, err := hermezDb.GetLatestVerification()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), verification.BatchNo)
+	update, err := hermezDb.GetLatestL1InfoTreeUpdate()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), update.Index)
+
+	// the l1 data of the batch sequenced in the reorged block is deleted
+	lastBatch, err := hermezDb.GetLastL1BatchData()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), lastBatch)
+	_, found, err := hermezDb.GetBatchOffChainDataHash(2)
+	require.NoError(t, err)
+	require.False(t, found)
+	data, err := hermezDb.GetOffChainData(crypto.Keccak256Hash([]byte{2}))
+	require.NoError(t, err)
+	require.Nil(t, data)
+	data, err = hermezDb.GetOffChainData(crypto.Keccak256Hash([]byte{1}))
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, data)
+
+	for stage, expected := range map[stages.SyncStage]uint64{stages.L1Syncer: 0, stages.L1InfoTree: 0, stages.L1BlockSync: 0, stages.L1VerificationsBatchNo: 1} {
+		progress, err := stages.GetStageProgress(tx, stage)
+		require.NoError(t, err)
+		require.Equal(t, expected, progress, stage)
+	}
+
+	// the l2 blocks using the reorged info tree update are unwound
+	require.True(t, u.IsUnwindSet())
+	require.Equal(t, uint64(19), *u.unwindPoint)
+
+	// the syncer queried the reorged block again and its new hash is stored
+	header, err := em.HeaderByNumber(ctx, big.NewInt(1))
+	require.NoError(t, err)
+	hash, found, err := hermezDb.GetL1BlockHash(1)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, header.Hash(), hash)
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/ledgerwatch/erigon/zk"
 	"github.com/ledgerwatch/erigon/zk/hermez_db"
 	zktx "github.com/ledgerwatch/erigon/zk/tx"
+	"github.com/ledgerwatch/erigon/zk/txpool"
 	"github.com/ledgerwatch/erigon/zk/utils"
 )
 
@@ -80,7 +81,7 @@ func sequencingBatchStep(
 		return err
 	}
 
-	sdb, err := newStageDb(ctx, cfg.db)
+	sdb, err := newStageDb(ctx, cfg.db, cfg.zk.SmtHashWorkers)
 	if err != nil {
 		return err
 	}
@@ -365,7 +366,14 @@ func sequencingBatchStep(
 					var newTransactions []types.Transaction
 					var newIds []common.Hash
 
-					newTransactions, newIds, allConditionsOK, err = getNextPoolTransactions(ctx, cfg, executionAt, batchState.forkId, batchState.yieldedTransactions)
+					var counterBudget txpool.ZkCounters
+					if cfg.zk.SequencerCounterAwarePacking {
+						if counterBudget, err = batchCounters.RemainingCounters(l1TreeUpdateIndex != 0); err != nil {
+							return err
+						}
+					}
+
+					newTransactions, newIds, allConditionsOK, err = getNextPoolTransactions(ctx, cfg, executionAt, batchState.forkId, batchState.yieldedTransactions, counterBudget)
 					if err != nil {
 						return err
 					}
@@ -448,6 +456,12 @@ func sequencingBatchStep(
 						batchState.blockState.transactionsToDiscard = append(batchState.blockState.transactionsToDiscard, batchState.blockState.transactionHashesToSlots[txHash])
 					}
 
+					if err == nil && cfg.zk.SequencerCounterAwarePacking && !batchState.isAnyRecovery() {
+						// remember what every executed transaction needs so that, should it stay in the pool after this attempt,
+						// the pool does not yield it to a batch without room for it
+						cfg.txPool.SetEstimatedCounters(batchState.blockState.transactionHashesToSlots[txHash], txCounters.CombineCounters().UsedAsArray())
+					}
+
 					switch anyOverflow {
 					case overflowCounters:
 						// as we know this has caused an overflow we need to make sure we don't keep it in the inclusion list and attempt to add it again in the
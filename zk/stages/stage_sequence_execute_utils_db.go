@@ -19,17 +19,20 @@ type stageDb struct {
 	eridb       *db2.EriDb
 	stateReader *state.PlainStateReader
 	smt         *smtNs.SMT
+
+	smtHashWorkers int
 }
 
-func newStageDb(ctx context.Context, db kv.RwDB) (sdb *stageDb, err error) {
+func newStageDb(ctx context.Context, db kv.RwDB, smtHashWorkers int) (sdb *stageDb, err error) {
 	var tx kv.RwTx
 	if tx, err = db.BeginRw(ctx); err != nil {
 		return nil, err
 	}
 
 	sdb = &stageDb{
-		ctx: ctx,
-		db:  db,
+		ctx:            ctx,
+		db:             db,
+		smtHashWorkers: smtHashWorkers,
 	}
 	sdb.SetTx(tx)
 	return sdb, nil
@@ -41,6 +44,7 @@ func (sdb *stageDb) SetTx(tx kv.RwTx) {
 	sdb.eridb = db2.NewEriDb(tx)
 	sdb.stateReader = state.NewPlainStateReader(tx)
 	sdb.smt = smtNs.NewSMT(sdb.eridb, false)
+	sdb.smt.SetHashWorkers(sdb.smtHashWorkers)
 }
 
 func (sdb *stageDb) CommitAndStart() (err error) {
@@ -38,5 +38,5 @@ func PruneSequencerInterhashesStage(
 	cfg ZkInterHashesCfg,
 	ctx context.Context,
 ) error {
-	return nil
+	return PruneZkIntermediateHashesStage(s, tx, cfg, ctx)
 }
@@ -8,11 +8,13 @@ import (
 
 	"encoding/binary"
 
+	"github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon/core/types"
 	"github.com/ledgerwatch/erigon/eth/ethconfig"
 	"github.com/ledgerwatch/erigon/eth/stagedsync"
 	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/zk/da"
 	"github.com/ledgerwatch/erigon/zk/hermez_db"
 	"github.com/ledgerwatch/erigon/zk/l1_data"
 	"github.com/ledgerwatch/erigon/zk/syncer"
@@ -20,16 +22,33 @@ import (
 )
 
 type SequencerL1BlockSyncCfg struct {
-	db     kv.RwDB
-	zkCfg  *ethconfig.Zk
-	syncer *syncer.L1Syncer
+	db        kv.RwDB
+	zkCfg     *ethconfig.Zk
+	syncer    *syncer.L1Syncer
+	daBackend da.DABackend
+	daErr     error
 }
 
 func StageSequencerL1BlockSyncCfg(db kv.RwDB, zkCfg *ethconfig.Zk, syncer *syncer.L1Syncer) SequencerL1BlockSyncCfg {
+	var daUrls []string
+	if zkCfg.DAUrl != "" {
+		daUrls = append(daUrls, zkCfg.DAUrl)
+	}
+	daUrls = append(daUrls, zkCfg.DACommitteeUrls...)
+
+	// a config error is returned by the stage, the flags are checked on startup already
+	daBackend, daErr := da.NewBackend(da.Config{
+		Urls:     daUrls,
+		Dir:      zkCfg.DADataDir,
+		CacheDir: zkCfg.DACacheDir,
+	})
+
 	return SequencerL1BlockSyncCfg{
-		db:     db,
-		zkCfg:  zkCfg,
-		syncer: syncer,
+		db:        db,
+		zkCfg:     zkCfg,
+		syncer:    syncer,
+		daBackend: daBackend,
+		daErr:     daErr,
 	}
 }
 
@@ -53,6 +72,10 @@ func SpawnSequencerL1BlockSyncStage(
 		return nil
 	}
 
+	if cfg.daErr != nil {
+		return cfg.daErr
+	}
+
 	var err error
 	freshTx := false
 	if tx == nil {
@@ -65,6 +88,7 @@ func SpawnSequencerL1BlockSyncStage(
 	}
 
 	hermezDb := hermez_db.NewHermezDb(tx)
+	offChainData := &hermezDbDABackend{hermezDb: hermezDb, backend: cfg.daBackend}
 
 	// perform a quick check to see if we have fully recovered from the l1 and exit the node
 	highestBatch, err := stages.GetStageProgress(tx, stages.HighestSeenBatchNumber)
@@ -166,7 +190,8 @@ LOOP:
 					return funcErr
 				}
 
-				batches, coinbase, limitTimestamp, err := l1_data.DecodeL1BatchData(transaction.GetData(), cfg.zkCfg.DAUrl)
+				offChainData.hashes = offChainData.hashes[:0]
+				batches, coinbase, limitTimestamp, err := l1_data.DecodeL1BatchData(transaction.GetData(), offChainData)
 				if err != nil {
 					funcErr = err
 					return funcErr
@@ -198,6 +223,16 @@ LOOP:
 					if funcErr = hermezDb.WriteL1BatchData(b, data); funcErr != nil {
 						return funcErr
 					}
+					// kept to read the l1 again from the block of the batch on unwind
+					if funcErr = hermezDb.WriteL1BatchDataBlock(b, l.BlockNumber); funcErr != nil {
+						return funcErr
+					}
+					// validium batches are linked to their off chain data, in the order it was fetched
+					if len(offChainData.hashes) == len(batches) {
+						if funcErr = hermezDb.WriteBatchOffChainDataHash(b, offChainData.hashes[idx]); funcErr != nil {
+							return funcErr
+						}
+					}
 
 					// check if we need to stop here based on config
 					if cfg.zkCfg.L1SyncStopBatch > 0 {
@@ -264,10 +299,107 @@ func checkStopBlockMap(earliest, target uint64, stopBlockMap map[uint64]struct{}
 	return true
 }
 
+// UnwindSequencerL1BlockSyncStage deletes the l1 data and off chain data of the batches after the one of the unwind
+// point, and moves the progress back so the l1 is read again from the block the batch was sequenced in. Nothing is
+// unwound when the unwind point has no batch.
 func UnwindSequencerL1BlockSyncStage(u *stagedsync.UnwindState, tx kv.RwTx, cfg SequencerL1BlockSyncCfg, ctx context.Context) (err error) {
+	if cfg.zkCfg.L1SyncStartBlock == 0 {
+		return nil
+	}
+
+	useExternalTx := tx != nil
+	if !useExternalTx {
+		if tx, err = cfg.db.BeginRw(ctx); err != nil {
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	hermezDb := hermez_db.NewHermezDb(tx)
+	batchNo, err := hermezDb.GetBatchNoByL2Block(u.UnwindPoint)
+	if err != nil && !errors.Is(err, hermez_db.ErrorNotStored) {
+		return err
+	}
+	// only the genesis block is in batch 0, a later block found in it is not stored
+	if errors.Is(err, hermez_db.ErrorNotStored) || (batchNo == 0 && u.UnwindPoint > 0) {
+		log.Warn(fmt.Sprintf("[%s] No batch stored for the unwind point, the L1 batch data is kept", u.LogPrefix()), "block", u.UnwindPoint)
+		return nil
+	}
+
+	lastBatch, err := hermezDb.GetLastL1BatchData()
+	if err != nil {
+		return err
+	}
+	if batchNo >= lastBatch {
+		return nil
+	}
+
+	// the batches before this change were stored without their block, they are read again from the start block
+	var progress uint64
+	l1Block, found, err := hermezDb.GetL1BatchDataBlock(batchNo)
+	if err != nil {
+		return err
+	}
+	if found && l1Block > 0 {
+		// the progress is the last block checked, the block of the batch is read again
+		progress = l1Block - 1
+	}
+
+	log.Info(fmt.Sprintf("[%s] Unwinding the L1 batch data", u.LogPrefix()), "fromBatch", batchNo+1, "l1Block", progress)
+	if err = hermezDb.DeleteL1BatchDataFrom(batchNo + 1); err != nil {
+		return err
+	}
+	current, err := stages.GetStageProgress(tx, stages.L1BlockSync)
+	if err != nil {
+		return err
+	}
+	if progress < current {
+		if err = stages.SaveStageProgress(tx, stages.L1BlockSync, progress); err != nil {
+			return err
+		}
+	}
+
+	if !useExternalTx {
+		return tx.Commit()
+	}
 	return nil
 }
 
 func PruneSequencerL1BlockSyncStage(s *stagedsync.PruneState, tx kv.RwTx, cfg SequencerL1BlockSyncCfg, ctx context.Context, logger log.Logger) error {
 	return nil
 }
+
+// hermezDbDABackend serves the off chain data stored in the hermez db and only fetches the data it does not have
+// from the data availability service, storing it. The hashes served are kept so the stage links them to the batches
+// once it has numbered them.
+type hermezDbDABackend struct {
+	hermezDb *hermez_db.HermezDb
+	backend  da.DABackend
+	hashes   []common.Hash
+}
+
+func (b *hermezDbDABackend) GetOffChainData(ctx context.Context, hash common.Hash) ([]byte, error) {
+	data, err := b.hermezDb.GetOffChainData(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		if b.backend == nil {
+			return nil, fmt.Errorf("off chain data %s is not stored and no data availability service is configured", hash)
+		}
+		if data, err = b.backend.GetOffChainData(ctx, hash); err != nil {
+			return nil, err
+		}
+		// the data not matching its hash must not be stored
+		if err = da.VerifyOffChainData(hash, data); err != nil {
+			return nil, err
+		}
+		if err = b.hermezDb.WriteOffChainData(hash, data); err != nil {
+			return nil, err
+		}
+	}
+
+	b.hashes = append(b.hashes, hash)
+	return data, nil
+}
@@ -0,0 +1,125 @@
+package stages
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/erigon/eth/stagedsync"
+	"github.com/ledgerwatch/erigon/eth/stagedsync/stages"
+	"github.com/ledgerwatch/erigon/zk/da"
+	"github.com/ledgerwatch/erigon/zk/hermez_db"
+	"github.com/stretchr/testify/require"
+)
+
+type countingDABackend struct {
+	data  map[common.Hash][]byte
+	calls int
+}
+
+func (b *countingDABackend) GetOffChainData(_ context.Context, hash common.Hash) ([]byte, error) {
+	b.calls++
+	return b.data[hash], nil
+}
+
+func TestHermezDbDABackend(t *testing.T) {
+	ctx, db := context.Background(), memdb.NewTestDB(t)
+	tx := memdb.BeginRw(t, db)
+	require.NoError(t, hermez_db.CreateHermezBuckets(tx))
+	hermezDb := hermez_db.NewHermezDb(tx)
+
+	data := []byte("offchaindata")
+	hash := crypto.Keccak256Hash(data)
+	wrongHash := common.Hash{1}
+	dac := &countingDABackend{data: map[common.Hash][]byte{hash: data, wrongHash: []byte("wrong")}}
+	backend := &hermezDbDABackend{hermezDb: hermezDb, backend: dac}
+
+	// the data is fetched once and then served from the db
+	for i := 0; i < 2; i++ {
+		got, err := backend.GetOffChainData(ctx, hash)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+	}
+	require.Equal(t, 1, dac.calls)
+	require.Equal(t, []common.Hash{hash, hash}, backend.hashes)
+
+	// the data not matching its hash is refused and not stored
+	_, err := backend.GetOffChainData(ctx, wrongHash)
+	require.ErrorIs(t, err, da.ErrHashMismatch)
+	stored, err := hermezDb.GetOffChainData(wrongHash)
+	require.NoError(t, err)
+	require.Nil(t, stored)
+
+	// without a data availability service only the stored data is served
+	backend = &hermezDbDABackend{hermezDb: hermezDb}
+	got, err := backend.GetOffChainData(ctx, hash)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+	_, err = backend.GetOffChainData(ctx, wrongHash)
+	require.Error(t, err)
+}
+
+func TestUnwindSequencerL1BlockSyncStage(t *testing.T) {
+	ctx, db := context.Background(), memdb.NewTestDB(t)
+	tx := memdb.BeginRw(t, db)
+	require.NoError(t, hermez_db.CreateHermezBuckets(tx))
+	hermezDb := hermez_db.NewHermezDb(tx)
+
+	shared, own := []byte("shared"), []byte("own")
+	sharedHash, ownHash := crypto.Keccak256Hash(shared), crypto.Keccak256Hash(own)
+	require.NoError(t, hermezDb.WriteOffChainData(sharedHash, shared))
+	require.NoError(t, hermezDb.WriteOffChainData(ownHash, own))
+
+	// the validium batches 1 to 3 hold the blocks 1 to 3 and are sequenced in the l1 blocks 20 to 22, the batches 1
+	// and 3 have the same off chain data
+	for b, hash := range map[uint64]common.Hash{1: sharedHash, 2: ownHash, 3: sharedHash} {
+		require.NoError(t, hermezDb.WriteBlockBatch(b, b))
+		require.NoError(t, hermezDb.WriteL1BatchData(b, []byte{byte(b)}))
+		require.NoError(t, hermezDb.WriteL1BatchDataBlock(b, 19+b))
+		require.NoError(t, hermezDb.WriteBatchOffChainDataHash(b, hash))
+	}
+	require.NoError(t, stages.SaveStageProgress(tx, stages.L1BlockSync, 100))
+
+	// an unwind point with no batch stored keeps everything
+	cfg := SequencerL1BlockSyncCfg{zkCfg: &ethconfig.Zk{L1SyncStartBlock: 10}}
+	u := &stagedsync.UnwindState{ID: stages.L1BlockSync, UnwindPoint: 5}
+	require.NoError(t, UnwindSequencerL1BlockSyncStage(u, tx, cfg, ctx))
+	lastBatch, err := hermezDb.GetLastL1BatchData()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), lastBatch)
+	progress, err := stages.GetStageProgress(tx, stages.L1BlockSync)
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), progress)
+
+	u = &stagedsync.UnwindState{ID: stages.L1BlockSync, UnwindPoint: 1}
+	require.NoError(t, UnwindSequencerL1BlockSyncStage(u, tx, cfg, ctx))
+
+	lastBatch, err = hermezDb.GetLastL1BatchData()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), lastBatch)
+	for b := uint64(2); b <= 3; b++ {
+		_, found, err := hermezDb.GetBatchOffChainDataHash(b)
+		require.NoError(t, err)
+		require.False(t, found)
+	}
+
+	// the data of the kept batch stays, the data only the unwound batches used is deleted
+	data, err := hermezDb.GetOffChainData(sharedHash)
+	require.NoError(t, err)
+	require.Equal(t, shared, data)
+	data, err = hermezDb.GetOffChainData(ownHash)
+	require.NoError(t, err)
+	require.Nil(t, data)
+
+	_, found, err := hermezDb.GetL1BatchDataBlock(2)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// the l1 is read again from the block the kept batch was sequenced in
+	progress, err = stages.GetStageProgress(tx, stages.L1BlockSync)
+	require.NoError(t, err)
+	require.Equal(t, uint64(19), progress)
+}
@@ -351,8 +351,7 @@ func DefaultZkStages(
 				return UnwindZkIntermediateHashesStage(u, s, txc.Tx, zkInterHashesCfg, ctx, false)
 			},
 			Prune: func(firstCycle bool, p *stages.PruneState, tx kv.RwTx, logger log.Logger) error {
-				// TODO: implement this in zk interhashes
-				return nil
+				return PruneZkIntermediateHashesStage(p, tx, zkInterHashesCfg, ctx)
 			},
 		},
 		{
@@ -50,6 +50,12 @@ type fetchJob struct {
 	To   uint64
 }
 
+// checkedBlock is the last l1 block the logs were queried up to
+type checkedBlock struct {
+	number uint64
+	hash   common.Hash
+}
+
 type jobResult struct {
 	Size  uint64
 	Error error
@@ -66,12 +72,15 @@ type L1Syncer struct {
 	blockRange          uint64
 	queryDelay          uint64
 
-	latestL1Block uint64
+	latestL1Block     uint64
+	latestL1BlockHash common.Hash
 
 	// atomic
 	isSyncStarted      atomic.Bool
 	isDownloading      atomic.Bool
 	lastCheckedL1Block atomic.Uint64
+	lastChecked        atomic.Pointer[checkedBlock]
+	reorgDetected      atomic.Bool
 	wgRunLoopDone      sync.WaitGroup
 	flagStop           atomic.Bool
 
@@ -80,6 +89,9 @@ type L1Syncer struct {
 	logsChanProgress chan string
 
 	highestBlockType string // finalized, latest, safe
+
+	// quorum, when set, is read from for the critical reads and keeps the evicted providers out of the round-robin
+	quorum *Quorum
 }
 
 func NewL1Syncer(ctx context.Context, etherMans []IEtherman, l1ContractAddresses []common.Address, topics [][]common.Hash, blockRange, queryDelay uint64, highestBlockType string) *L1Syncer {
@@ -98,6 +110,12 @@ func NewL1Syncer(ctx context.Context, etherMans []IEtherman, l1ContractAddresses
 	}
 }
 
+// SetQuorum makes the syncer read the sequenced batch logs, the acc input hashes and the finalized block from the
+// providers of the quorum, which must be the etherMans of the syncer
+func (s *L1Syncer) SetQuorum(q *Quorum) {
+	s.quorum = q
+}
+
 func (s *L1Syncer) getNextEtherman() IEtherman {
 	s.ethermanMtx.Lock()
 	defer s.ethermanMtx.Unlock()
@@ -106,6 +124,12 @@ func (s *L1Syncer) getNextEtherman() IEtherman {
 		s.ethermanIndex = 0
 	}
 
+	if s.quorum != nil {
+		etherman, next := s.quorum.next(int(s.ethermanIndex))
+		s.ethermanIndex = uint8(next)
+		return etherman
+	}
+
 	etherman := s.etherMans[s.ethermanIndex]
 	s.ethermanIndex++
 
@@ -124,6 +148,21 @@ func (s *L1Syncer) GetLastCheckedL1Block() uint64 {
 	return s.lastCheckedL1Block.Load()
 }
 
+// GetLastCheckedL1BlockHash returns the last l1 block checked and its hash, once the syncer has queried a range
+func (s *L1Syncer) GetLastCheckedL1BlockHash() (uint64, common.Hash, bool) {
+	checked := s.lastChecked.Load()
+	if checked == nil {
+		return 0, common.Hash{}, false
+	}
+	return checked.number, checked.hash, true
+}
+
+// IsReorgDetected reports that the last checked block is no longer on the l1 chain. The syncer stops querying new
+// blocks until it is restarted from before the reorg.
+func (s *L1Syncer) IsReorgDetected() bool {
+	return s.reorgDetected.Load()
+}
+
 func (s *L1Syncer) StopQueryBlocks() {
 	s.flagStop.Store(true)
 }
@@ -166,6 +205,8 @@ func (s *L1Syncer) RunQueryBlocks(lastCheckedBlock uint64) {
 	// set it to true to catch the first cycle run case where the check can pass before the latest block is checked
 	s.isDownloading.Store(true)
 	s.lastCheckedL1Block.Store(lastCheckedBlock)
+	s.lastChecked.Store(nil)
+	s.reorgDetected.Store(false)
 
 	s.wgRunLoopDone.Add(1)
 	s.flagStop.Store(false)
@@ -187,12 +228,17 @@ func (s *L1Syncer) RunQueryBlocks(lastCheckedBlock uint64) {
 			if err != nil {
 				log.Error("Error getting latest L1 block", "err", err)
 			} else {
-				if latestL1Block > s.lastCheckedL1Block.Load() {
+				if latestL1Block > s.lastCheckedL1Block.Load() && !s.reorgDetected.Load() && s.isLastCheckedReorged() {
+					log.Warn("L1 reorg detected, the last checked L1 block changed", "block", s.lastCheckedL1Block.Load())
+					s.reorgDetected.Store(true)
+				}
+				if latestL1Block > s.lastCheckedL1Block.Load() && !s.reorgDetected.Load() {
 					s.isDownloading.Store(true)
 					if err := s.queryBlocks(); err != nil {
 						log.Error("Error querying blocks", "err", err)
 					} else {
 						s.lastCheckedL1Block.Store(latestL1Block)
+						s.lastChecked.Store(&checkedBlock{number: latestL1Block, hash: s.latestL1BlockHash})
 					}
 				}
 			}
@@ -203,6 +249,22 @@ func (s *L1Syncer) RunQueryBlocks(lastCheckedBlock uint64) {
 	}()
 }
 
+// isLastCheckedReorged checks that the last checked block still has the hash it had when it was checked
+func (s *L1Syncer) isLastCheckedReorged() bool {
+	checked := s.lastChecked.Load()
+	if checked == nil || checked.hash == (common.Hash{}) {
+		return false
+	}
+
+	header, err := s.GetHeader(checked.number)
+	if err != nil {
+		log.Error("Error getting the last checked L1 block", "block", checked.number, "err", err)
+		return false
+	}
+
+	return header == nil || header.Hash() != checked.hash
+}
+
 func (s *L1Syncer) GetHeader(number uint64) (*ethTypes.Header, error) {
 	em := s.getNextEtherman()
 	return em.HeaderByNumber(context.Background(), new(big.Int).SetUint64(number))
@@ -320,13 +382,20 @@ func (s *L1Syncer) getLatestL1Block() (uint64, error) {
 		blockNumber = nil
 	}
 
-	latestBlock, err := em.BlockByNumber(context.Background(), blockNumber)
+	var latestBlock *ethTypes.Block
+	var err error
+	if s.quorum != nil && s.highestBlockType == "finalized" {
+		latestBlock, err = s.quorumFinalizedBlock(s.ctx)
+	} else {
+		latestBlock, err = em.BlockByNumber(context.Background(), blockNumber)
+	}
 	if err != nil {
 		return 0, err
 	}
 
 	latest := latestBlock.NumberU64()
 	s.latestL1Block = latest
+	s.latestL1BlockHash = latestBlock.Hash()
 
 	return latest, nil
 }
@@ -444,8 +513,7 @@ func (s *L1Syncer) getSequencedLogs(jobs <-chan fetchJob, results chan jobResult
 			var err error
 			retry := 0
 			for {
-				em := s.getNextEtherman()
-				logs, err = em.FilterLogs(context.Background(), query)
+				logs, err = s.filterLogs(query)
 				if err != nil {
 					log.Debug("getSequencedLogs retry error", "err", err)
 					retry++
@@ -502,11 +570,19 @@ func (s *L1Syncer) callGetRollupSequencedBatches(ctx context.Context, addr *comm
 	rollupID := fmt.Sprintf("%064x", rollupId)
 	batchNumber := fmt.Sprintf("%064x", batchNum)
 
-	em := s.getNextEtherman()
-	resp, err := em.CallContract(ctx, ethereum.CallMsg{
+	msg := ethereum.CallMsg{
 		To:   addr,
 		Data: common.FromHex(rollupSequencedBatchesSignature + rollupID + batchNumber),
-	}, nil)
+	}
+	var resp []byte
+	var err error
+	if s.quorum != nil {
+		resp, err = quorumRead(ctx, s.quorum, "getRollupSequencedBatches", func(ctx context.Context, em IEtherman) ([]byte, error) {
+			return em.CallContract(ctx, msg, nil)
+		}, bytesDigest)
+	} else {
+		resp, err = s.getNextEtherman().CallContract(ctx, msg, nil)
+	}
 
 	if err != nil {
 		return common.Hash{}, 0, err
@@ -568,11 +644,34 @@ func (s *L1Syncer) CallContract(ctx context.Context, addr *common.Address, data
 }
 
 func (s *L1Syncer) CheckL1BlockFinalized(blockNo uint64) (finalized bool, finalizedBn uint64, err error) {
-	em := s.getNextEtherman()
-	block, err := em.BlockByNumber(s.ctx, big.NewInt(rpc.FinalizedBlockNumber.Int64()))
+	var block *ethTypes.Block
+	if s.quorum != nil {
+		block, err = s.quorumFinalizedBlock(s.ctx)
+	} else {
+		block, err = s.getNextEtherman().BlockByNumber(s.ctx, big.NewInt(rpc.FinalizedBlockNumber.Int64()))
+	}
 	if err != nil {
 		return false, 0, err
 	}
 
 	return block.NumberU64() >= blockNo, block.NumberU64(), nil
 }
+
+func (s *L1Syncer) quorumFinalizedBlock(ctx context.Context) (*ethTypes.Block, error) {
+	return quorumRead(ctx, s.quorum, "finalizedBlock", func(ctx context.Context, em IEtherman) (*ethTypes.Block, error) {
+		block, err := em.BlockByNumber(ctx, big.NewInt(rpc.FinalizedBlockNumber.Int64()))
+		if err == nil && block == nil {
+			err = fmt.Errorf("finalized block not found")
+		}
+		return block, err
+	}, blockDigest)
+}
+
+func (s *L1Syncer) filterLogs(query ethereum.FilterQuery) ([]ethTypes.Log, error) {
+	if s.quorum == nil {
+		return s.getNextEtherman().FilterLogs(context.Background(), query)
+	}
+	return quorumRead(context.Background(), s.quorum, "getLogs", func(ctx context.Context, em IEtherman) ([]ethTypes.Log, error) {
+		return em.FilterLogs(ctx, query)
+	}, logsDigest)
+}
@@ -0,0 +1,284 @@
+package syncer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	ethTypes "github.com/ledgerwatch/erigon/core/types"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/log/v3"
+)
+
+const (
+	// providerEvictionPeriod is how long an evicted provider is left out before it is given another chance
+	providerEvictionPeriod = 5 * time.Minute
+	// maxDisagreements is the number of the latest disagreements kept for the report
+	maxDisagreements = 100
+)
+
+var ErrNoQuorum = errors.New("no quorum of the l1 providers agreed on the result")
+
+// ProviderReport is the standing of an l1 provider read with quorum
+type ProviderReport struct {
+	Index         int
+	Score         int64
+	Agreements    uint64
+	Disagreements uint64
+	Failures      uint64
+	Evicted       bool
+	// EvictedUntil is the end of the eviction of a provider that misbehaved
+	EvictedUntil time.Time
+}
+
+// Disagreement is a quorum read on which the providers did not all return the same result
+type Disagreement struct {
+	Time   time.Time
+	Method string
+	// Results is the digest of the result returned by each provider that answered, by provider index
+	Results map[int]common.Hash
+	// Errors is the error returned by each provider that failed, by provider index
+	Errors map[int]string
+	// Accepted is the digest of the result agreed on by the quorum, empty when no quorum was reached
+	Accepted common.Hash
+	Quorum   bool
+}
+
+// QuorumReport is the standing of the providers and the latest disagreements between them
+type QuorumReport struct {
+	Quorum        int
+	Providers     []ProviderReport
+	Disagreements []Disagreement
+}
+
+type provider struct {
+	em            IEtherman
+	score         atomic.Int64
+	agreements    atomic.Uint64
+	disagreements atomic.Uint64
+	failures      atomic.Uint64
+	evictedUntil  atomic.Int64
+}
+
+func (p *provider) evicted() bool {
+	return time.Now().UnixNano() < p.evictedUntil.Load()
+}
+
+// Quorum reads the critical l1 data from several providers and accepts a result only when enough of them agree on it.
+// A provider scores a point for every result agreed on and loses one for every error or result the quorum disagreed
+// with. A provider whose score falls to -maxFaults is evicted, it is not read from for a while and then starts over.
+type Quorum struct {
+	providers []*provider
+	quorum    int
+	maxFaults int64
+
+	mtx           sync.Mutex
+	disagreements []Disagreement
+}
+
+// NewQuorum creates a quorum of the providers, at least quorum of them have to agree on a result
+func NewQuorum(etherMans []IEtherman, quorum int, maxFaults uint64) *Quorum {
+	providers := make([]*provider, len(etherMans))
+	for i, em := range etherMans {
+		providers[i] = &provider{em: em}
+	}
+	return &Quorum{
+		providers: providers,
+		quorum:    quorum,
+		maxFaults: int64(max(maxFaults, 1)),
+	}
+}
+
+// active returns the indexes of the providers that are not evicted
+func (q *Quorum) active() []int {
+	active := make([]int, 0, len(q.providers))
+	for i, p := range q.providers {
+		if !p.evicted() {
+			active = append(active, i)
+		}
+	}
+	return active
+}
+
+func (q *Quorum) reward(p *provider) {
+	p.agreements.Add(1)
+	// the score is capped so that a provider long in agreement is still evicted quickly when it starts misbehaving
+	if p.score.Load() < q.maxFaults {
+		p.score.Add(1)
+	}
+}
+
+func (q *Quorum) penalize(index int, reason string) {
+	p := q.providers[index]
+	if p.score.Add(-1) > -q.maxFaults {
+		return
+	}
+	p.score.Store(0)
+	p.evictedUntil.Store(time.Now().Add(providerEvictionPeriod).UnixNano())
+	log.Warn("L1 provider evicted from the quorum", "provider", index, "reason", reason, "until", time.Now().Add(providerEvictionPeriod))
+}
+
+func (q *Quorum) record(d Disagreement) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if len(q.disagreements) >= maxDisagreements {
+		q.disagreements = q.disagreements[1:]
+	}
+	q.disagreements = append(q.disagreements, d)
+}
+
+// Report returns the standing of the providers and the latest disagreements, oldest first
+func (q *Quorum) Report() QuorumReport {
+	report := QuorumReport{
+		Quorum:    q.quorum,
+		Providers: make([]ProviderReport, len(q.providers)),
+	}
+	for i, p := range q.providers {
+		until := p.evictedUntil.Load()
+		report.Providers[i] = ProviderReport{
+			Index:         i,
+			Score:         p.score.Load(),
+			Agreements:    p.agreements.Load(),
+			Disagreements: p.disagreements.Load(),
+			Failures:      p.failures.Load(),
+			Evicted:       p.evicted(),
+		}
+		if until > 0 {
+			report.Providers[i].EvictedUntil = time.Unix(0, until)
+		}
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	report.Disagreements = make([]Disagreement, len(q.disagreements))
+	copy(report.Disagreements, q.disagreements)
+	return report
+}
+
+// next returns the first provider from the index that is not evicted and the index to start from on the next call, when
+// the providers are all evicted it returns the one at the index
+func (q *Quorum) next(index int) (IEtherman, int) {
+	for i := 0; i < len(q.providers); i++ {
+		candidate := (index + i) % len(q.providers)
+		if !q.providers[candidate].evicted() {
+			return q.providers[candidate].em, candidate + 1
+		}
+	}
+	return q.providers[index%len(q.providers)].em, index + 1
+}
+
+type quorumResult[T any] struct {
+	index  int
+	value  T
+	digest common.Hash
+	err    error
+}
+
+// quorumRead reads from all the active providers and returns the result with the digest at least quorum of them
+// returned
+func quorumRead[T any](ctx context.Context, q *Quorum, method string, read func(context.Context, IEtherman) (T, error), digest func(T) common.Hash) (T, error) {
+	var empty T
+	active := q.active()
+	if len(active) < q.quorum {
+		return empty, fmt.Errorf("%w: %s, %d providers active out of the %d needed", ErrNoQuorum, method, len(active), q.quorum)
+	}
+
+	results := make([]quorumResult[T], len(active))
+	var wg sync.WaitGroup
+	wg.Add(len(active))
+	for i, index := range active {
+		go func(i, index int) {
+			defer wg.Done()
+			value, err := read(ctx, q.providers[index].em)
+			results[i] = quorumResult[T]{index: index, value: value, err: err}
+			if err == nil {
+				results[i].digest = digest(value)
+			}
+		}(i, index)
+	}
+	wg.Wait()
+
+	counts := make(map[common.Hash]int)
+	accepted := -1
+	for i, r := range results {
+		if r.err != nil {
+			continue
+		}
+		counts[r.digest]++
+		if counts[r.digest] >= q.quorum && accepted < 0 {
+			accepted = i
+		}
+	}
+
+	d := Disagreement{
+		Time:    time.Now(),
+		Method:  method,
+		Results: make(map[int]common.Hash),
+		Errors:  make(map[int]string),
+		Quorum:  accepted >= 0,
+	}
+	if accepted >= 0 {
+		d.Accepted = results[accepted].digest
+	}
+	for _, r := range results {
+		p := q.providers[r.index]
+		if r.err != nil {
+			d.Errors[r.index] = r.err.Error()
+			p.failures.Add(1)
+			q.penalize(r.index, r.err.Error())
+			continue
+		}
+		d.Results[r.index] = r.digest
+		if accepted < 0 {
+			continue
+		}
+		if r.digest == d.Accepted {
+			q.reward(p)
+		} else {
+			p.disagreements.Add(1)
+			q.penalize(r.index, "result disagreed with the quorum")
+		}
+	}
+
+	if len(counts) > 1 || len(d.Errors) > 0 {
+		q.record(d)
+		log.Warn("L1 providers disagreed", "method", method, "quorum", d.Quorum, "results", d.Results, "errors", d.Errors)
+	}
+
+	if accepted < 0 {
+		return empty, fmt.Errorf("%w: %s", ErrNoQuorum, method)
+	}
+	return results[accepted].value, nil
+}
+
+func logsDigest(logs []ethTypes.Log) common.Hash {
+	buf := make([]byte, 0, len(logs)*128)
+	for _, l := range logs {
+		buf = binary.BigEndian.AppendUint64(buf, l.BlockNumber)
+		buf = append(buf, l.BlockHash[:]...)
+		buf = append(buf, l.TxHash[:]...)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(l.Index))
+		buf = append(buf, l.Address[:]...)
+		for _, topic := range l.Topics {
+			buf = append(buf, topic[:]...)
+		}
+		buf = append(buf, crypto.Keccak256(l.Data)...)
+	}
+	return crypto.Keccak256Hash(buf)
+}
+
+func bytesDigest(b []byte) common.Hash {
+	return crypto.Keccak256Hash(b)
+}
+
+func blockDigest(block *ethTypes.Block) common.Hash {
+	if block == nil {
+		return common.Hash{}
+	}
+	return block.Hash()
+}
@@ -0,0 +1,101 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	ethereum "github.com/ledgerwatch/erigon"
+	"github.com/ledgerwatch/erigon-lib/common"
+	ethTypes "github.com/ledgerwatch/erigon/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEtherman answers the logs, calls and blocks it is set up with
+type fakeEtherman struct {
+	IEtherman
+	logs      []ethTypes.Log
+	call      []byte
+	finalized uint64
+	err       error
+}
+
+func (e *fakeEtherman) FilterLogs(context.Context, ethereum.FilterQuery) ([]ethTypes.Log, error) {
+	return e.logs, e.err
+}
+
+func (e *fakeEtherman) CallContract(context.Context, ethereum.CallMsg, *big.Int) ([]byte, error) {
+	return e.call, e.err
+}
+
+func (e *fakeEtherman) BlockByNumber(context.Context, *big.Int) (*ethTypes.Block, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return ethTypes.NewBlockWithHeader(&ethTypes.Header{Number: new(big.Int).SetUint64(e.finalized)}), nil
+}
+
+func newQuorumSyncer(etherMans []IEtherman, quorum int, maxFaults uint64) (*L1Syncer, *Quorum) {
+	s := NewL1Syncer(context.Background(), etherMans, nil, nil, 10, 0, "finalized")
+	q := NewQuorum(etherMans, quorum, maxFaults)
+	s.SetQuorum(q)
+	return s, q
+}
+
+func TestQuorumReads(t *testing.T) {
+	logs := []ethTypes.Log{{BlockNumber: 5, TxHash: common.Hash{1}, Data: []byte{1}}}
+	forged := []ethTypes.Log{{BlockNumber: 5, TxHash: common.Hash{1}, Data: []byte{2}}}
+	call := make([]byte, 96)
+	call[0] = 1
+
+	honest1 := &fakeEtherman{logs: logs, call: call, finalized: 10}
+	honest2 := &fakeEtherman{logs: logs, call: call, finalized: 10}
+	liar := &fakeEtherman{logs: forged, call: make([]byte, 96), finalized: 20}
+	s, q := newQuorumSyncer([]IEtherman{liar, honest1, honest2}, 2, 2)
+
+	got, err := s.filterLogs(ethereum.FilterQuery{})
+	require.NoError(t, err)
+	require.Equal(t, logs, got)
+
+	accInputHash, err := s.GetElderberryAccInputHash(context.Background(), &common.Address{}, 1, 1)
+	require.NoError(t, err)
+	require.Equal(t, common.BytesToHash(call[:32]), accInputHash)
+
+	// the liar reached the max faults on the second disagreement
+	report := q.Report()
+	require.Len(t, report.Disagreements, 2)
+	require.True(t, report.Disagreements[0].Quorum)
+	require.Equal(t, "getLogs", report.Disagreements[0].Method)
+	require.Equal(t, logsDigest(logs), report.Disagreements[0].Accepted)
+	require.Equal(t, logsDigest(forged), report.Disagreements[0].Results[0])
+	require.True(t, report.Providers[0].Evicted)
+	require.Equal(t, uint64(2), report.Providers[0].Disagreements)
+	require.Equal(t, int64(2), report.Providers[1].Score)
+
+	// the evicted liar is neither read from with quorum nor in turn
+	finalized, finalizedBn, err := s.CheckL1BlockFinalized(10)
+	require.NoError(t, err)
+	require.True(t, finalized)
+	require.Equal(t, uint64(10), finalizedBn)
+	require.Len(t, q.Report().Disagreements, 2)
+	for i := 0; i < 4; i++ {
+		require.NotSame(t, liar, s.getNextEtherman())
+	}
+
+	// without the liar the two remaining providers must both answer
+	honest2.err = errors.New("unavailable")
+	_, err = s.filterLogs(ethereum.FilterQuery{})
+	require.ErrorIs(t, err, ErrNoQuorum)
+	report = q.Report()
+	require.Len(t, report.Disagreements, 3)
+	require.False(t, report.Disagreements[2].Quorum)
+	require.Equal(t, "unavailable", report.Disagreements[2].Errors[2])
+	require.Equal(t, uint64(1), report.Providers[2].Failures)
+}
+
+func TestQuorumNotEnoughProviders(t *testing.T) {
+	s, _ := newQuorumSyncer([]IEtherman{&fakeEtherman{finalized: 1}}, 2, 3)
+	_, _, err := s.CheckL1BlockFinalized(1)
+	require.ErrorIs(t, err, ErrNoQuorum)
+}
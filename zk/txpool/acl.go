@@ -26,6 +26,9 @@ const (
 	Allowlist          = "Allowlist"
 	BlockList          = "BlockList"
 	PolicyTransactions = "PolicyTransactions"
+	ScopedPolicies     = "ScopedPolicies"
+	MethodRules        = "MethodRules"
+	AuditLog           = "AuditLog"
 )
 
 func (t ACLTable) String() string {
@@ -42,6 +45,12 @@ func ResolveACLTable(table string) (ACLTable, error) {
 		return BlockList, nil
 	case "policytransactions":
 		return PolicyTransactions, nil
+	case "scopedpolicies":
+		return ScopedPolicies, nil
+	case "methodrules":
+		return MethodRules, nil
+	case "auditlog":
+		return AuditLog, nil
 	default:
 		return "", errUnknownACLTable
 	}
@@ -92,6 +101,9 @@ var (
 		Allowlist,
 		BlockList,
 		PolicyTransactions,
+		ScopedPolicies,
+		MethodRules,
+		AuditLog,
 	}
 
 	ACLTablesCfg = kv.TableCfg{}
@@ -101,6 +113,9 @@ var (
 	errUnknownACLTable    = errors.New("unknown acl table")
 	errUnknownPolicy      = errors.New("unknown policy")
 	errWrongOperation     = errors.New("wrong operation")
+	errInvalidPolicyScope = errors.New("invalid policy scope")
+
+	errUnknownMethodRuleAction = errors.New("unknown method rule action")
 )
 
 const ACLDB kv.Label = 255
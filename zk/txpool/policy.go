@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -75,6 +76,8 @@ const (
 	AllowListTypeB ACLTypeBinary = iota
 	BlockListTypeB
 	DisabledModeB
+	// MethodRuleTypeB is only used to record method rule changes in the policy transactions
+	MethodRuleTypeB
 )
 
 func (p ACLTypeBinary) ToByte() byte {
@@ -107,6 +110,8 @@ func ACLTypeBinaryFromByte(b byte) ACLTypeBinary {
 		return BlockListTypeB
 	case byte(DisabledModeB):
 		return DisabledModeB
+	case byte(MethodRuleTypeB):
+		return MethodRuleTypeB
 	default:
 		return BlockListTypeB // Default or error handling can be added here
 	}
@@ -121,6 +126,8 @@ func (p ACLTypeBinary) String() string {
 		return "blocklist"
 	case DisabledModeB:
 		return "disabled"
+	case MethodRuleTypeB:
+		return "methodrule"
 	default:
 		return "Unknown ACLTypeBinary"
 	}
@@ -135,9 +142,15 @@ const (
 	SendTx Policy = iota
 	// Deploy is the name of the policy that governs that an address may deploy a contract
 	Deploy
+	// RateLimitExempt is the name of the policy that exempts an address in the allowlist from the per sender
+	// rate limits of the pool, it is checked regardless of the ACL mode
+	RateLimitExempt
+	// Priority is the name of the policy that puts the transactions of an address in the allowlist in the
+	// priority lane of the pool when the priority ordering is used, it is checked regardless of the ACL mode
+	Priority
 )
 
-var policiesList = []Policy{SendTx, Deploy}
+var policiesList = []Policy{SendTx, Deploy, RateLimitExempt, Priority}
 
 func (p Policy) ToByte() byte {
 	return byte(p)
@@ -150,19 +163,27 @@ func (p Policy) ToByteArray() []byte {
 // IsSupportedPolicy checks if the given policy is supported
 func IsSupportedPolicy(policy Policy) bool {
 	switch policy {
-	case SendTx, Deploy:
+	case SendTx, Deploy, RateLimitExempt, Priority:
 		return true
 	default:
 		return false
 	}
 }
 
+func (p Policy) String() string {
+	return policyName(p)
+}
+
 func ResolvePolicy(policy string) (Policy, error) {
 	switch policy {
 	case "sendTx":
 		return SendTx, nil
 	case "deploy":
 		return Deploy, nil
+	case "rateLimitExempt":
+		return RateLimitExempt, nil
+	case "priority":
+		return Priority, nil
 	default:
 		return SendTx, errUnknownPolicy
 	}
@@ -208,6 +229,10 @@ func policyName(policy Policy) string {
 		return "sendTx"
 	case Deploy:
 		return "deploy"
+	case RateLimitExempt:
+		return "rateLimitExempt"
+	case Priority:
+		return "priority"
 	default:
 		return "unknown"
 	}
@@ -215,11 +240,13 @@ func policyName(policy Policy) string {
 
 // DoesAccountHavePolicy checks if the given account has the given policy for the online ACL mode
 func DoesAccountHavePolicy(ctx context.Context, aclDB kv.RwDB, addr common.Address, policy Policy) (bool, error) {
-	hasPolicy, _, err := checkIfAccountHasPolicy(ctx, aclDB, addr, policy)
+	hasPolicy, _, err := checkIfAccountHasPolicy(ctx, aclDB, addr, nil, policy)
 	return hasPolicy, err
 }
 
-func checkIfAccountHasPolicy(ctx context.Context, aclDB kv.RwDB, addr common.Address, policy Policy) (bool, ACLMode, error) {
+// checkIfAccountHasPolicy checks if the account has the policy in the table of the current mode, either
+// unconditionally or through a scoped policy that is active now and matches the destination contract
+func checkIfAccountHasPolicy(ctx context.Context, aclDB kv.RwDB, addr common.Address, to *common.Address, policy Policy) (bool, ACLMode, error) {
 	if !IsSupportedPolicy(policy) {
 		return false, DisabledMode, errUnknownPolicy
 	}
@@ -244,8 +271,10 @@ func checkIfAccountHasPolicy(ctx context.Context, aclDB kv.RwDB, addr common.Add
 		mode = ACLMode(value)
 
 		table := BlockList
+		aclType := BlockListTypeB
 		if mode == AllowlistMode {
 			table = Allowlist
+			aclType = AllowListTypeB
 		}
 
 		var policyBytes []byte
@@ -259,9 +288,11 @@ func checkIfAccountHasPolicy(ctx context.Context, aclDB kv.RwDB, addr common.Add
 			// If address is in the allowlist and has the policy, return true
 			// If address is in the blocklist and has the policy, return false
 			hasPolicy = true
+			return nil
 		}
 
-		return nil
+		hasPolicy, err = hasActiveScopedPolicy(tx, aclType, addr, to, policy, time.Now())
+		return err
 	})
 	if err != nil {
 		return false, mode, err
@@ -272,43 +303,34 @@ func checkIfAccountHasPolicy(ctx context.Context, aclDB kv.RwDB, addr common.Add
 
 // UpdatePolicies sets a policy for an address
 func UpdatePolicies(ctx context.Context, aclDB kv.RwDB, aclType string, addrs []common.Address, policies [][]Policy) error {
+	return UpdateScopedPolicies(ctx, aclDB, aclType, addrs, policies, nil)
+}
+
+// UpdateScopedPolicies sets the plain and the scoped policies for an address. The given policies are
+// the final state of the address in the acl, so scoped policies that are not provided are removed.
+// scoped may be nil, otherwise it must have the same length as addrs.
+func UpdateScopedPolicies(ctx context.Context, aclDB kv.RwDB, aclType string, addrs []common.Address, policies [][]Policy, scoped [][]ScopedPolicy) error {
 	table, err := resolveTable(aclType)
 	if err != nil {
 		return err
 	}
-	// Create an array to hold policy transactions
-	var policyTransactions []PolicyTransaction
-	timeNow := time.Now()
-	err = aclDB.Update(ctx, func(tx kv.RwTx) error {
-		for i, addr := range addrs {
-			// Add the policy transaction to the array
-			policyTransactions = append(policyTransactions, PolicyTransaction{
-				aclType:   ResolveACLTypeToBinary(aclType),
-				addr:      addr,
-				operation: Update,
-				timeTx:    timeNow,
-			})
-
-			if len(policies[i]) > 0 {
-				// just update the policies for the address to match the one provided
-				policyBytes := make([]byte, 0, len(policies[i]))
-				for _, p := range policies[i] {
-					policyBytes = append(policyBytes, p.ToByte())
-				}
-				// Update the policies in the table
-				if err := tx.Put(table, addr.Bytes(), policyBytes); err != nil {
-					return err
-				}
-				continue
-			}
-
-			// remove the address from the table
-			if err := tx.Delete(table, addr.Bytes()); err != nil {
+	if scoped != nil && len(scoped) != len(addrs) {
+		return fmt.Errorf("%w: got %d scoped policy sets for %d addresses", errInvalidPolicyScope, len(scoped), len(addrs))
+	}
+	for _, sps := range scoped {
+		for _, sp := range sps {
+			if err := sp.validate(); err != nil {
 				return err
 			}
 		}
+	}
 
-		return nil
+	aclTypeB := ResolveACLTypeToBinary(aclType)
+	// Create an array to hold policy transactions
+	var policyTransactions []PolicyTransaction
+	err = aclDB.Update(ctx, func(tx kv.RwTx) error {
+		policyTransactions, err = updateScopedPolicies(tx, table, aclTypeB, addrs, policies, scoped, time.Now())
+		return err
 	})
 	if err != nil {
 		return err
@@ -319,8 +341,68 @@ func UpdatePolicies(ctx context.Context, aclDB kv.RwDB, aclType string, addrs []
 	return InsertPolicyTransactions(ctx, aclDB, policyTransactions)
 }
 
+// updateScopedPolicies replaces the policies of the addresses within tx and returns the policy transactions
+// that record the change. The policies are expected to be validated already.
+func updateScopedPolicies(tx kv.RwTx, table string, aclTypeB ACLTypeBinary, addrs []common.Address, policies [][]Policy, scoped [][]ScopedPolicy, timeNow time.Time) ([]PolicyTransaction, error) {
+	policyTransactions := make([]PolicyTransaction, 0, len(addrs))
+	for i, addr := range addrs {
+		// Add the policy transaction to the array
+		policyTransactions = append(policyTransactions, PolicyTransaction{
+			aclType:   aclTypeB,
+			addr:      addr,
+			operation: Update,
+			timeTx:    timeNow,
+		})
+
+		// scoped policies are replaced as a whole
+		if err := deleteScopedPolicies(tx, aclTypeB, addr); err != nil {
+			return nil, err
+		}
+		if scoped != nil {
+			for _, sp := range scoped[i] {
+				sp.Addr = addr
+				if err := tx.Put(ScopedPolicies, scopedPolicyKey(aclTypeB, addr, sp.Contract, sp.Policy), scopedPolicyValue(sp)); err != nil {
+					return nil, err
+				}
+				policyTransactions = append(policyTransactions, PolicyTransaction{
+					aclType:   aclTypeB,
+					addr:      addr,
+					contract:  sp.Contract,
+					scoped:    true,
+					policy:    sp.Policy,
+					operation: Update,
+					timeTx:    timeNow,
+				})
+			}
+		}
+
+		if len(policies[i]) > 0 {
+			// just update the policies for the address to match the one provided
+			policyBytes := make([]byte, 0, len(policies[i]))
+			for _, p := range policies[i] {
+				policyBytes = append(policyBytes, p.ToByte())
+			}
+			// Update the policies in the table
+			if err := tx.Put(table, addr.Bytes(), policyBytes); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// remove the address from the table
+		if err := tx.Delete(table, addr.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
+	return policyTransactions, nil
+}
+
 type PolicyTransaction struct {
 	addr      common.Address //  20 bytes in size
+	contract  common.Address //  20 bytes in size, only set for contract scoped policies and method rules
+	scoped    bool           //  set for scoped policies, whose contract is zero when only the validity window is set
+	selector  []byte         //  4 bytes in size, only set for method rules
 	aclType   ACLTypeBinary
 	policy    Policy
 	operation Operation
@@ -337,22 +419,40 @@ func timestampToBytes(t time.Time) []byte {
 
 func InsertPolicyTransactions(ctx context.Context, aclDB kv.RwDB, pts []PolicyTransaction) error {
 	return aclDB.Update(ctx, func(tx kv.RwTx) error {
-		for _, pt := range pts {
-			t := pt.timeTx
-			// Convert time.Time to bytes
-			unixBytes := timestampToBytes(t)
-			// composite key.
-			addressTimestamp := append(pt.addr.Bytes(), unixBytes...)
-			value := append([]byte{pt.aclType.ToByte(), pt.operation.ToByte(), pt.policy.ToByte()}, addressTimestamp...)
-
-			if err := tx.Put(PolicyTransactions, addressTimestamp, value); err != nil {
-				return err
-			}
-		}
-		return nil
+		return insertPolicyTransactions(tx, pts)
 	})
 }
 
+func insertPolicyTransactions(tx kv.RwTx, pts []PolicyTransaction) error {
+	for _, pt := range pts {
+		t := pt.timeTx
+		// Convert time.Time to bytes
+		unixBytes := timestampToBytes(t)
+		// composite key.
+		addressTimestamp := append(pt.addr.Bytes(), unixBytes...)
+		value := append([]byte{pt.aclType.ToByte(), pt.operation.ToByte(), pt.policy.ToByte()}, addressTimestamp...)
+		key := addressTimestamp
+		switch {
+		case pt.aclType == MethodRuleTypeB:
+			// method rules are usually set for any sender, so the method is part of the key
+			value = append(value, pt.contract.Bytes()...)
+			value = append(value, pt.selector...)
+			key = append(common.Copy(addressTimestamp), pt.contract.Bytes()...)
+			key = append(key, pt.selector...)
+		case pt.scoped || pt.contract != (common.Address{}):
+			// the scoped policies of an address are logged together on an update, so the scope is part of the key
+			value = append(value, pt.contract.Bytes()...)
+			key = append(common.Copy(addressTimestamp), pt.contract.Bytes()...)
+			key = append(key, pt.policy.ToByte())
+		}
+
+		if err := tx.Put(PolicyTransactions, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Convert bytes back to time.Time (Unix timestamp)
 func bytesToTimestamp(b []byte) time.Time {
 	if len(b) != 8 {
@@ -375,10 +475,14 @@ func LastPolicyTransactions(ctx context.Context, aclDB kv.RwDB, count int) ([]Po
 			return err
 		}
 		defer c.Close()
-		_, value, err := c.Last()
+		k, value, err := c.Last()
 		if err != nil {
 			return err
 		}
+		if k == nil {
+			// no policy transactions yet
+			return nil
+		}
 
 		pt, err := byteToPolicyTransaction(value)
 		if err != nil {
@@ -387,10 +491,14 @@ func LastPolicyTransactions(ctx context.Context, aclDB kv.RwDB, count int) ([]Po
 		pts = append(pts, pt)
 
 		for i := 1; i < count; i++ {
-			_, value, err = c.Prev()
+			k, value, err = c.Prev()
 			if err != nil {
 				return err
 			}
+			if k == nil {
+				// less policy transactions than requested
+				break
+			}
 
 			pt, err := byteToPolicyTransaction(value)
 			if err != nil {
@@ -420,7 +528,9 @@ func byteToPolicyTransaction(value []byte) (PolicyTransaction, error) {
 	// 1 byte for policy,
 	// 20 bytes for address,
 	// 8 bytes for timestamp = 31 bytes in total
-	if len(value) != 31 {
+	// contract scoped policies append 20 bytes for the contract = 51 bytes in total
+	// method rules append 20 bytes for the contract and 4 bytes for the selector = 55 bytes in total
+	if len(value) != 31 && len(value) != 51 && len(value) != 55 {
 		return PolicyTransaction{}, fmt.Errorf("invalid value length %d", len(value))
 	}
 
@@ -441,16 +551,68 @@ func byteToPolicyTransaction(value []byte) (PolicyTransaction, error) {
 	timestampBytes := value[23:31]
 	timeTx := bytesToTimestamp(timestampBytes)
 
+	// Extract the contract from the optional last 20 bytes (31 to 50 inclusive)
+	var contract common.Address
+	if len(value) >= 51 {
+		copy(contract[:], value[31:51])
+	}
+
+	// Extract the selector from the optional last 4 bytes (51 to 54 inclusive)
+	var selector []byte
+	if len(value) == 55 {
+		selector = common.Copy(value[51:55])
+	}
+
 	// Return the reconstructed PolicyTransaction struct
 	return PolicyTransaction{
 		aclType:   aclType,
 		addr:      addr,
+		contract:  contract,
+		scoped:    len(value) == 51,
+		selector:  selector,
 		policy:    policy,
 		operation: operation,
 		timeTx:    timeTx,
 	}, nil
 }
 
+// MarshalJSON encodes the policy transaction for the ACL RPC
+func (pt PolicyTransaction) MarshalJSON() ([]byte, error) {
+	type policyTransactionJSON struct {
+		ACLType   string          `json:"aclType"`
+		Operation string          `json:"operation"`
+		Address   *common.Address `json:"address,omitempty"`
+		Contract  *common.Address `json:"contract,omitempty"`
+		Selector  string          `json:"selector,omitempty"`
+		Policy    string          `json:"policy,omitempty"`
+		Action    string          `json:"action,omitempty"`
+		Time      time.Time       `json:"time"`
+	}
+
+	enc := policyTransactionJSON{
+		ACLType:   pt.aclType.String(),
+		Operation: pt.operation.String(),
+		Time:      pt.timeTx,
+	}
+	if pt.operation != ModeChange {
+		addr := pt.addr
+		enc.Address = &addr
+	}
+	if pt.contract != (common.Address{}) {
+		contract := pt.contract
+		enc.Contract = &contract
+	}
+	switch {
+	case pt.aclType == MethodRuleTypeB:
+		enc.Selector = "0x" + hex.EncodeToString(pt.selector)
+		enc.Action = MethodRuleAction(pt.policy).String()
+	case pt.operation != ModeChange && (pt.operation != Update || pt.scoped):
+		enc.Policy = policyName(pt.policy)
+	}
+
+	return json.Marshal(enc)
+}
+
 func (pt PolicyTransaction) ToString() string {
 	// on mode change we only have aclType and timeTx
 	// so we need to check if the operation is ModeChange
@@ -461,6 +623,31 @@ func (pt PolicyTransaction) ToString() string {
 			pt.operation.String(),
 			pt.timeTx.Format(time.RFC3339)) // Use RFC3339 format for the
 	}
+	if pt.aclType == MethodRuleTypeB {
+		return fmt.Sprintf("ACLType: %s, %s, Operation: %s, Time: %s",
+			pt.aclType.String(),
+			MethodRule{
+				Contract: pt.contract,
+				Selector: [4]byte(pt.selector),
+				Sender:   pt.addr,
+				Action:   MethodRuleAction(pt.policy),
+			}.ToString(),
+			pt.operation.String(),
+			pt.timeTx.Format(time.RFC3339))
+	}
+	if pt.scoped || pt.contract != (common.Address{}) {
+		contract := "any"
+		if pt.contract != (common.Address{}) {
+			contract = hex.EncodeToString(pt.contract[:])
+		}
+		return fmt.Sprintf("ACLType: %s, Address: %s, Contract: %s, Policy: %s, Operation: %s, Time: %s",
+			pt.aclType.String(),
+			hex.EncodeToString(pt.addr[:]),
+			contract,
+			policyName(pt.policy),
+			pt.operation.String(),
+			pt.timeTx.Format(time.RFC3339))
+	}
 	return fmt.Sprintf("ACLType: %s, Address: %s, Policy: %s, Operation: %s, Time: %s",
 		pt.aclType.String(),
 		hex.EncodeToString(pt.addr[:]), // Convert address to hexadecimal string representation
@@ -469,8 +656,46 @@ func (pt PolicyTransaction) ToString() string {
 		pt.timeTx.Format(time.RFC3339)) // Use RFC3339 format for the time
 }
 
+// AddressPolicies are the policies of an address in an acl
+type AddressPolicies struct {
+	Addr     common.Address
+	Policies []Policy
+}
+
+// ListPolicies returns the addresses of the given acl with their policies
+func ListPolicies(ctx context.Context, aclDB kv.RwDB, aclType string) ([]AddressPolicies, error) {
+	table, err := resolveTable(aclType)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []AddressPolicies
+	err = aclDB.View(ctx, func(tx kv.Tx) error {
+		return tx.ForEach(table, nil, func(k, v []byte) error {
+			ap := AddressPolicies{
+				Addr:     common.BytesToAddress(k),
+				Policies: make([]Policy, 0, len(v)),
+			}
+			for _, p := range v {
+				ap.Policies = append(ap.Policies, Policy(p))
+			}
+			list = append(list, ap)
+			return nil
+		})
+	})
+
+	return list, err
+}
+
 // AddPolicy adds a policy to the ACL of given address
 func AddPolicy(ctx context.Context, aclDB kv.RwDB, aclType string, addr common.Address, policy Policy) error {
+	return aclDB.Update(ctx, func(tx kv.RwTx) error {
+		return AddPolicyTx(tx, aclType, addr, policy)
+	})
+}
+
+// AddPolicyTx adds a policy to the ACL of given address and records it in the policy transactions within tx
+func AddPolicyTx(tx kv.RwTx, aclType string, addr common.Address, policy Policy) error {
 	if !IsSupportedPolicy(policy) {
 		return errUnknownPolicy
 	}
@@ -480,58 +705,52 @@ func AddPolicy(ctx context.Context, aclDB kv.RwDB, aclType string, addr common.A
 		return err
 	}
 
-	err = aclDB.Update(ctx, func(tx kv.RwTx) error {
-		value, err := tx.GetOne(table, addr.Bytes())
-		if err != nil {
-			return err
-		}
-
-		policyBytes := policy.ToByteArray()
-		if value == nil {
-			return tx.Put(table, addr.Bytes(), policyBytes)
-		}
-
-		// Check if the policy already exists
-		if containsPolicy(value, policy) {
-			return nil
-		}
-
-		value = append(value, policyBytes...)
+	value, err := tx.GetOne(table, addr.Bytes())
+	if err != nil {
+		return err
+	}
 
-		return tx.Put(table, addr.Bytes(), value)
-	})
+	policyBytes := policy.ToByteArray()
+	switch {
+	case value == nil:
+		err = tx.Put(table, addr.Bytes(), policyBytes)
+	case !containsPolicy(value, policy):
+		err = tx.Put(table, addr.Bytes(), append(common.Copy(value), policyBytes...))
+	}
 	if err != nil {
 		return err
 	}
 
-	err = InsertPolicyTransactions(ctx, aclDB, []PolicyTransaction{{
+	return insertPolicyTransactions(tx, []PolicyTransaction{{
 		aclType:   ResolveACLTypeToBinary(aclType),
 		addr:      addr,
 		policy:    policy,
 		operation: Add,
 		timeTx:    time.Now(),
 	}})
-
-	return err
 }
 
 // RemovePolicy removes a policy from the ACL of given address
 func RemovePolicy(ctx context.Context, aclDB kv.RwDB, aclType string, addr common.Address, policy Policy) error {
+	return aclDB.Update(ctx, func(tx kv.RwTx) error {
+		return RemovePolicyTx(tx, aclType, addr, policy)
+	})
+}
+
+// RemovePolicyTx removes a policy from the ACL of given address and records it in the policy transactions within tx
+func RemovePolicyTx(tx kv.RwTx, aclType string, addr common.Address, policy Policy) error {
 	table, err := resolveTable(aclType)
 	if err != nil {
 		return err
 	}
 
-	err = aclDB.Update(ctx, func(tx kv.RwTx) error {
-		policies, err := tx.GetOne(table, addr.Bytes())
-		if err != nil {
-			return err
-		}
-		if policies == nil {
-			// No policies exist for this address
-			return nil
-		}
+	policies, err := tx.GetOne(table, addr.Bytes())
+	if err != nil {
+		return err
+	}
 
+	// No policies exist for this address when policies is nil
+	if policies != nil {
 		updatedPolicies := []byte{}
 
 		for _, p := range policies {
@@ -541,24 +760,22 @@ func RemovePolicy(ctx context.Context, aclDB kv.RwDB, aclType string, addr commo
 		}
 
 		if len(updatedPolicies) == 0 {
-			return tx.Delete(table, addr.Bytes())
+			err = tx.Delete(table, addr.Bytes())
+		} else {
+			err = tx.Put(table, addr.Bytes(), updatedPolicies)
+		}
+		if err != nil {
+			return err
 		}
-
-		return tx.Put(table, addr.Bytes(), updatedPolicies)
-	})
-	if err != nil {
-		return err
 	}
 
-	err = InsertPolicyTransactions(ctx, aclDB, []PolicyTransaction{{
+	return insertPolicyTransactions(tx, []PolicyTransaction{{
 		aclType:   ResolveACLTypeToBinary(aclType),
 		addr:      addr,
 		policy:    policy,
 		operation: Remove,
 		timeTx:    time.Now(),
 	}})
-
-	return err
 }
 
 func ListContentAtACL(ctx context.Context, db kv.RwDB) ([]string, error) {
@@ -567,6 +784,8 @@ func ListContentAtACL(ctx context.Context, db kv.RwDB) ([]string, error) {
 	var bufferConfig bytes.Buffer
 	var bufferBlockList bytes.Buffer
 	var bufferAllowlist bytes.Buffer
+	var bufferScoped bytes.Buffer
+	var bufferMethodRules bytes.Buffer
 
 	tables := db.AllTables()
 	buffer.WriteString(" \n")
@@ -637,38 +856,64 @@ func ListContentAtACL(ctx context.Context, db kv.RwDB) ([]string, error) {
 			bufferAllowlist.WriteString("\nAllowlist is empty")
 		}
 
-		return err
+		// ScopedPolicies table
+		scopedContent, err := scopedPoliciesContent(tx, time.Now())
+		if err != nil {
+			return err
+		}
+		buffer.WriteString(scopedContent)
+		bufferScoped.WriteString(scopedContent)
+
+		// MethodRules table
+		methodRulesContent, err := methodRulesContent(tx)
+		if err != nil {
+			return err
+		}
+		buffer.WriteString(methodRulesContent)
+		bufferMethodRules.WriteString(methodRulesContent)
+
+		return nil
 	})
 
 	combinedBuffers = append(combinedBuffers, buffer.String())
 	combinedBuffers = append(combinedBuffers, bufferConfig.String())
 	combinedBuffers = append(combinedBuffers, bufferBlockList.String())
 	combinedBuffers = append(combinedBuffers, bufferAllowlist.String())
+	combinedBuffers = append(combinedBuffers, bufferScoped.String())
+	combinedBuffers = append(combinedBuffers, bufferMethodRules.String())
 
 	return combinedBuffers, err
 }
 
 // SetMode sets the mode of the ACL
 func SetMode(ctx context.Context, aclDB kv.RwDB, mode string) error {
+	return aclDB.Update(ctx, func(tx kv.RwTx) error {
+		return SetModeTx(tx, mode)
+	})
+}
+
+// SetModeTx sets the mode of the ACL within tx
+func SetModeTx(tx kv.RwTx, mode string) error {
 	m, err := ResolveACLMode(mode)
 	if err != nil {
 		return err
 	}
 
-	return aclDB.Update(ctx, func(tx kv.RwTx) error {
-		err := tx.Put(Config, []byte(modeKey), []byte(m))
-
-		// Timestamp bytes + single byte.
-		mb := ResolveACLTypeToBinary(mode)
-		unixBytes := timestampToBytes(time.Now())
-		addressMode := append(mb.ToByteArray(), unixBytes...)
-		value := append([]byte{mb.ToByte()}, unixBytes...)
-		if err = tx.Put(PolicyTransactions, addressMode, value); err != nil {
-			return err
-		}
+	return setMode(tx, m, time.Now())
+}
 
+// setMode stores the mode and records the change in the policy transactions within tx
+func setMode(tx kv.RwTx, m ACLMode, timeNow time.Time) error {
+	if err := tx.Put(Config, []byte(modeKey), []byte(m)); err != nil {
 		return err
-	})
+	}
+
+	// Timestamp bytes + single byte.
+	mb := ResolveACLTypeToBinary(string(m))
+	unixBytes := timestampToBytes(timeNow)
+	addressMode := append(mb.ToByteArray(), unixBytes...)
+	value := append([]byte{mb.ToByte()}, unixBytes...)
+	return tx.Put(PolicyTransactions, addressMode, value)
 }
 
 // GetMode gets the mode of the ACL
@@ -710,19 +955,35 @@ func resolvePolicy(txn *types.TxSlot) Policy {
 	return SendTx
 }
 
-// isActionAllowed checks if the given action is allowed for the given address
-func (p *TxPool) isActionAllowed(ctx context.Context, addr common.Address, policy Policy) (bool, error) {
-	hasPolicy, mode, err := checkIfAccountHasPolicy(ctx, p.aclDB, addr, policy)
+// isActionAllowed checks if the given action is allowed for the given address.
+// to is the destination of the transaction and is used to match contract scoped policies, nil for deployments.
+// selector is the called method and is used to match method rules, nil if the call has no selector.
+// Method rules are only evaluated for senders the policies of the mode allow, so an allow rule can not let in a
+// blocklisted sender or a sender missing from the allowlist, unless the ACL is disabled.
+func (p *TxPool) isActionAllowed(ctx context.Context, addr common.Address, to *common.Address, selector []byte, policy Policy) (bool, error) {
+	hasPolicy, mode, err := checkIfAccountHasPolicy(ctx, p.aclDB, addr, to, policy)
 	if err != nil {
 		return false, err
 	}
 
-	switch mode {
-	case BlocklistMode:
+	allowed := hasPolicy
+	if mode == BlocklistMode {
 		// If the mode is blocklist, and address has a certain policy, then invert the result
 		// because, for example, if it has sendTx policy, it means it is not allowed to sendTx
-		return !hasPolicy, nil
-	default:
-		return hasPolicy, nil
+		allowed = !hasPolicy
+	}
+
+	if !allowed || mode == DisabledMode || policy != SendTx || to == nil || len(selector) != 4 {
+		return allowed, nil
 	}
+
+	rule, found, err := checkMethodRule(ctx, p.aclDB, addr, *to, [4]byte(selector))
+	if err != nil {
+		return false, err
+	}
+	if found {
+		return rule.Action == MethodAllow, nil
+	}
+
+	return true, nil
 }
@@ -0,0 +1,98 @@
+package txpool
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+var errMissingActor = errors.New("actor is required for ACL changes")
+
+// AuditEntry records who changed the ACL, what was changed and when
+type AuditEntry struct {
+	Actor  string            `json:"actor"`
+	Action string            `json:"action"`
+	Params map[string]string `json:"params,omitempty"`
+	Time   time.Time         `json:"time"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// auditKey orders the entries by time, the nanoseconds keep entries of the same second apart
+func auditKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+// InsertAuditEntry records an ACL change in the audit log
+func InsertAuditEntry(ctx context.Context, aclDB kv.RwDB, entry AuditEntry) error {
+	return aclDB.Update(ctx, func(tx kv.RwTx) error {
+		return InsertAuditEntryTx(tx, entry)
+	})
+}
+
+// InsertAuditEntryTx records an ACL change in the audit log within tx, so that it is committed together with the change
+func InsertAuditEntryTx(tx kv.RwTx, entry AuditEntry) error {
+	if entry.Actor == "" {
+		return errMissingActor
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	key := auditKey(entry.Time)
+	// entries recorded in the same nanosecond are moved forward to keep both
+	for {
+		existing, err := tx.GetOne(AuditLog, key)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			break
+		}
+		binary.BigEndian.PutUint64(key, binary.BigEndian.Uint64(key)+1)
+	}
+
+	return tx.Put(AuditLog, key, value)
+}
+
+// LastAuditEntries returns the last count entries of the audit log, newest first
+func LastAuditEntries(ctx context.Context, aclDB kv.RwDB, count int) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	err := aclDB.View(ctx, func(tx kv.Tx) error {
+		if count == 0 {
+			return nil
+		}
+		c, err := tx.Cursor(AuditLog)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		k, v, err := c.Last()
+		for ; k != nil && len(entries) < count; k, v, err = c.Prev() {
+			if err != nil {
+				return err
+			}
+
+			var entry AuditEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+
+		return err
+	})
+
+	return entries, err
+}
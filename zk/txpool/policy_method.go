@@ -0,0 +1,237 @@
+package txpool
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/length"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// method rule key: contract (20) + selector (4) + sender (20)
+const methodRuleKeyLen = length.Addr + 4 + length.Addr
+
+// MethodRuleAction is the action taken for a transaction that matches a method rule
+type MethodRuleAction byte
+
+const (
+	// MethodAllow allows the call even if the sender policies would not
+	MethodAllow MethodRuleAction = iota
+	// MethodDeny denies the call even if the sender policies would allow it
+	MethodDeny
+)
+
+func (a MethodRuleAction) ToByte() byte {
+	return byte(a)
+}
+
+func (a MethodRuleAction) String() string {
+	switch a {
+	case MethodAllow:
+		return "allow"
+	case MethodDeny:
+		return "deny"
+	default:
+		return "unknown"
+	}
+}
+
+func ResolveMethodRuleAction(action string) (MethodRuleAction, error) {
+	switch strings.ToLower(action) {
+	case "allow":
+		return MethodAllow, nil
+	case "deny":
+		return MethodDeny, nil
+	default:
+		return MethodDeny, errUnknownMethodRuleAction
+	}
+}
+
+// MethodRule allows or denies calls of a method, identified by its 4 byte selector, on a contract.
+// A zero Sender makes the rule apply to every sender, a rule for a specific sender takes
+// precedence over it, so a method can be denied for everyone except a governance key.
+type MethodRule struct {
+	Contract common.Address
+	Selector [4]byte
+	Sender   common.Address
+	Action   MethodRuleAction
+}
+
+func (r MethodRule) ToString() string {
+	sender := "any"
+	if r.Sender != (common.Address{}) {
+		sender = hex.EncodeToString(r.Sender[:])
+	}
+	return fmt.Sprintf("Contract: %s, Selector: 0x%s, Sender: %s, Action: %s",
+		hex.EncodeToString(r.Contract[:]),
+		hex.EncodeToString(r.Selector[:]),
+		sender,
+		r.Action.String())
+}
+
+func methodRuleKey(contract common.Address, selector [4]byte, sender common.Address) []byte {
+	key := make([]byte, 0, methodRuleKeyLen)
+	key = append(key, contract.Bytes()...)
+	key = append(key, selector[:]...)
+	return append(key, sender.Bytes()...)
+}
+
+// decodeMethodRule rebuilds a method rule from the table key and value
+func decodeMethodRule(k, v []byte) (MethodRule, error) {
+	if len(k) != methodRuleKeyLen {
+		return MethodRule{}, fmt.Errorf("invalid method rule key length %d", len(k))
+	}
+	if len(v) != 1 {
+		return MethodRule{}, fmt.Errorf("invalid method rule value length %d", len(v))
+	}
+
+	var r MethodRule
+	copy(r.Contract[:], k[:20])
+	copy(r.Selector[:], k[20:24])
+	copy(r.Sender[:], k[24:44])
+	r.Action = MethodRuleAction(v[0])
+
+	return r, nil
+}
+
+// findMethodRule returns the rule that applies to a call from sender, a rule for the sender
+// itself has precedence over the rule for any sender
+func findMethodRule(tx kv.Tx, sender, contract common.Address, selector [4]byte) (MethodRule, bool, error) {
+	for _, s := range []common.Address{sender, {}} {
+		key := methodRuleKey(contract, selector, s)
+		value, err := tx.GetOne(MethodRules, key)
+		if err != nil {
+			return MethodRule{}, false, err
+		}
+		if value == nil {
+			continue
+		}
+
+		r, err := decodeMethodRule(key, value)
+		if err != nil {
+			return MethodRule{}, false, err
+		}
+		return r, true, nil
+	}
+
+	return MethodRule{}, false, nil
+}
+
+// checkMethodRule returns the method rule that applies to the call, if any
+func checkMethodRule(ctx context.Context, aclDB kv.RwDB, sender, contract common.Address, selector [4]byte) (MethodRule, bool, error) {
+	var (
+		rule  MethodRule
+		found bool
+	)
+
+	err := aclDB.View(ctx, func(tx kv.Tx) error {
+		var err error
+		rule, found, err = findMethodRule(tx, sender, contract, selector)
+		return err
+	})
+
+	return rule, found, err
+}
+
+// SetMethodRule adds a method rule or replaces the action of an existing one
+func SetMethodRule(ctx context.Context, aclDB kv.RwDB, rule MethodRule) error {
+	if rule.Action != MethodAllow && rule.Action != MethodDeny {
+		return errUnknownMethodRuleAction
+	}
+
+	err := aclDB.Update(ctx, func(tx kv.RwTx) error {
+		return tx.Put(MethodRules, methodRuleKey(rule.Contract, rule.Selector, rule.Sender), []byte{rule.Action.ToByte()})
+	})
+	if err != nil {
+		return err
+	}
+
+	return InsertPolicyTransactions(ctx, aclDB, []PolicyTransaction{methodRuleTransaction(rule, Add, time.Now())})
+}
+
+// RemoveMethodRule removes the method rule of the given contract, selector and sender
+func RemoveMethodRule(ctx context.Context, aclDB kv.RwDB, contract common.Address, selector [4]byte, sender common.Address) error {
+	var (
+		rule  MethodRule
+		found bool
+	)
+
+	err := aclDB.Update(ctx, func(tx kv.RwTx) error {
+		key := methodRuleKey(contract, selector, sender)
+		value, err := tx.GetOne(MethodRules, key)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			// No rule exists for this method and sender
+			return nil
+		}
+
+		if rule, err = decodeMethodRule(key, value); err != nil {
+			return err
+		}
+		found = true
+
+		return tx.Delete(MethodRules, key)
+	})
+	if err != nil || !found {
+		return err
+	}
+
+	return InsertPolicyTransactions(ctx, aclDB, []PolicyTransaction{methodRuleTransaction(rule, Remove, time.Now())})
+}
+
+// ListMethodRules returns all method rules ordered by contract and selector
+func ListMethodRules(ctx context.Context, aclDB kv.RwDB) ([]MethodRule, error) {
+	var rules []MethodRule
+	err := aclDB.View(ctx, func(tx kv.Tx) error {
+		return tx.ForEach(MethodRules, nil, func(k, v []byte) error {
+			r, err := decodeMethodRule(k, v)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, r)
+			return nil
+		})
+	})
+
+	return rules, err
+}
+
+func methodRuleTransaction(rule MethodRule, operation Operation, timeTx time.Time) PolicyTransaction {
+	return PolicyTransaction{
+		aclType:   MethodRuleTypeB,
+		addr:      rule.Sender,
+		contract:  rule.Contract,
+		selector:  rule.Selector[:],
+		policy:    Policy(rule.Action.ToByte()),
+		operation: operation,
+		timeTx:    timeTx,
+	}
+}
+
+// methodRulesContent returns a printable representation of the method rules
+func methodRulesContent(tx kv.Tx) (string, error) {
+	var content strings.Builder
+	err := tx.ForEach(MethodRules, nil, func(k, v []byte) error {
+		r, err := decodeMethodRule(k, v)
+		if err != nil {
+			return err
+		}
+		content.WriteString(r.ToString() + "\n")
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if content.Len() == 0 {
+		return "\nMethod rules are empty", nil
+	}
+
+	return fmt.Sprintf("\nMethod rules\n%s", content.String()), nil
+}
@@ -0,0 +1,356 @@
+package txpool
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/length"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// scoped policy key: aclType (1) + address (20) + contract (20) + policy (1)
+const scopedPolicyKeyLen = 1 + length.Addr + length.Addr + 1
+
+// scoped policy value: validFrom (8) + validUntil (8)
+const scopedPolicyValueLen = 16
+
+// ScopedPolicy is a policy of an address that is restricted to a destination contract and/or a validity window.
+// A zero Contract means that the policy applies to any destination, a zero ValidFrom or ValidUntil means that
+// the window is not bounded on that side.
+type ScopedPolicy struct {
+	Addr       common.Address
+	Contract   common.Address
+	Policy     Policy
+	ValidFrom  time.Time
+	ValidUntil time.Time
+}
+
+// IsActive checks if the policy window contains the given time
+func (sp ScopedPolicy) IsActive(now time.Time) bool {
+	if !sp.ValidFrom.IsZero() && now.Before(sp.ValidFrom) {
+		return false
+	}
+	if !sp.ValidUntil.IsZero() && !now.Before(sp.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// IsExpired checks if the policy window has ended before the given time
+func (sp ScopedPolicy) IsExpired(now time.Time) bool {
+	return !sp.ValidUntil.IsZero() && !now.Before(sp.ValidUntil)
+}
+
+// validate checks that the scope makes sense for the policy
+func (sp ScopedPolicy) validate() error {
+	if !IsSupportedPolicy(sp.Policy) {
+		return errUnknownPolicy
+	}
+	if sp.Policy != SendTx && sp.Contract != (common.Address{}) {
+		return fmt.Errorf("%w: %s policy can not be scoped to a contract", errInvalidPolicyScope, sp.Policy.String())
+	}
+	if !sp.ValidFrom.IsZero() && !sp.ValidUntil.IsZero() && !sp.ValidFrom.Before(sp.ValidUntil) {
+		return fmt.Errorf("%w: valid from must be before valid until", errInvalidPolicyScope)
+	}
+	return nil
+}
+
+func (sp ScopedPolicy) ToString() string {
+	contract := "any"
+	if sp.Contract != (common.Address{}) {
+		contract = hex.EncodeToString(sp.Contract[:])
+	}
+	return fmt.Sprintf("Address: %s, Contract: %s, Policy: %s, ValidFrom: %s, ValidUntil: %s",
+		hex.EncodeToString(sp.Addr[:]),
+		contract,
+		policyName(sp.Policy),
+		formatPolicyTime(sp.ValidFrom),
+		formatPolicyTime(sp.ValidUntil))
+}
+
+func formatPolicyTime(t time.Time) string {
+	if t.IsZero() {
+		return "unbounded"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func scopedPolicyKey(aclType ACLTypeBinary, addr, contract common.Address, policy Policy) []byte {
+	key := make([]byte, 0, scopedPolicyKeyLen)
+	key = append(key, aclType.ToByte())
+	key = append(key, addr.Bytes()...)
+	key = append(key, contract.Bytes()...)
+	return append(key, policy.ToByte())
+}
+
+// scopedPolicyPrefix returns the prefix of all scoped policies of an address in the given acl
+func scopedPolicyPrefix(aclType ACLTypeBinary, addr common.Address) []byte {
+	return append(aclType.ToByteArray(), addr.Bytes()...)
+}
+
+func policyTimeToUint64(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.Unix())
+}
+
+func policyTimeFromUint64(v uint64) time.Time {
+	if v == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(v), 0)
+}
+
+func scopedPolicyValue(sp ScopedPolicy) []byte {
+	value := make([]byte, scopedPolicyValueLen)
+	binary.BigEndian.PutUint64(value[:8], policyTimeToUint64(sp.ValidFrom))
+	binary.BigEndian.PutUint64(value[8:], policyTimeToUint64(sp.ValidUntil))
+	return value
+}
+
+// decodeScopedPolicy rebuilds a scoped policy from the table key and value
+func decodeScopedPolicy(k, v []byte) (ACLTypeBinary, ScopedPolicy, error) {
+	if len(k) != scopedPolicyKeyLen {
+		return 0, ScopedPolicy{}, fmt.Errorf("invalid scoped policy key length %d", len(k))
+	}
+	if len(v) != scopedPolicyValueLen {
+		return 0, ScopedPolicy{}, fmt.Errorf("invalid scoped policy value length %d", len(v))
+	}
+
+	var sp ScopedPolicy
+	copy(sp.Addr[:], k[1:21])
+	copy(sp.Contract[:], k[21:41])
+	sp.Policy = Policy(k[41])
+	sp.ValidFrom = policyTimeFromUint64(binary.BigEndian.Uint64(v[:8]))
+	sp.ValidUntil = policyTimeFromUint64(binary.BigEndian.Uint64(v[8:]))
+
+	return ACLTypeBinary(k[0]), sp, nil
+}
+
+// hasActiveScopedPolicy checks if the address has an active scoped policy, either for any destination
+// or for the given destination contract
+func hasActiveScopedPolicy(tx kv.Tx, aclType ACLTypeBinary, addr common.Address, to *common.Address, policy Policy, now time.Time) (bool, error) {
+	contracts := []common.Address{{}}
+	if to != nil && *to != (common.Address{}) {
+		contracts = append(contracts, *to)
+	}
+
+	for _, contract := range contracts {
+		key := scopedPolicyKey(aclType, addr, contract, policy)
+		value, err := tx.GetOne(ScopedPolicies, key)
+		if err != nil {
+			return false, err
+		}
+		if value == nil {
+			continue
+		}
+
+		_, sp, err := decodeScopedPolicy(key, value)
+		if err != nil {
+			return false, err
+		}
+		if sp.IsActive(now) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// deleteScopedPolicies removes all scoped policies of an address in the given acl
+func deleteScopedPolicies(tx kv.RwTx, aclType ACLTypeBinary, addr common.Address) error {
+	prefix := scopedPolicyPrefix(aclType, addr)
+
+	var keys [][]byte
+	if err := tx.ForPrefix(ScopedPolicies, prefix, func(k, _ []byte) error {
+		keys = append(keys, common.Copy(k))
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err := tx.Delete(ScopedPolicies, k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddScopedPolicy adds a contract and/or time scoped policy to the ACL of given address.
+// If the same address, contract and policy are already present, the validity window is replaced.
+func AddScopedPolicy(ctx context.Context, aclDB kv.RwDB, aclType string, sp ScopedPolicy) error {
+	return aclDB.Update(ctx, func(tx kv.RwTx) error {
+		return AddScopedPolicyTx(tx, aclType, sp)
+	})
+}
+
+// AddScopedPolicyTx adds a scoped policy and records it in the policy transactions within tx
+func AddScopedPolicyTx(tx kv.RwTx, aclType string, sp ScopedPolicy) error {
+	if err := sp.validate(); err != nil {
+		return err
+	}
+
+	if _, err := resolveTable(aclType); err != nil {
+		return err
+	}
+
+	aclTypeB := ResolveACLTypeToBinary(aclType)
+	if err := tx.Put(ScopedPolicies, scopedPolicyKey(aclTypeB, sp.Addr, sp.Contract, sp.Policy), scopedPolicyValue(sp)); err != nil {
+		return err
+	}
+
+	return insertPolicyTransactions(tx, []PolicyTransaction{{
+		aclType:   aclTypeB,
+		addr:      sp.Addr,
+		contract:  sp.Contract,
+		scoped:    true,
+		policy:    sp.Policy,
+		operation: Add,
+		timeTx:    time.Now(),
+	}})
+}
+
+// RemoveScopedPolicy removes a scoped policy of given address and destination contract
+func RemoveScopedPolicy(ctx context.Context, aclDB kv.RwDB, aclType string, addr, contract common.Address, policy Policy) error {
+	return aclDB.Update(ctx, func(tx kv.RwTx) error {
+		return RemoveScopedPolicyTx(tx, aclType, addr, contract, policy)
+	})
+}
+
+// RemoveScopedPolicyTx removes a scoped policy and records it in the policy transactions within tx
+func RemoveScopedPolicyTx(tx kv.RwTx, aclType string, addr, contract common.Address, policy Policy) error {
+	if _, err := resolveTable(aclType); err != nil {
+		return err
+	}
+
+	aclTypeB := ResolveACLTypeToBinary(aclType)
+	if err := tx.Delete(ScopedPolicies, scopedPolicyKey(aclTypeB, addr, contract, policy)); err != nil {
+		return err
+	}
+
+	return insertPolicyTransactions(tx, []PolicyTransaction{{
+		aclType:   aclTypeB,
+		addr:      addr,
+		contract:  contract,
+		scoped:    true,
+		policy:    policy,
+		operation: Remove,
+		timeTx:    time.Now(),
+	}})
+}
+
+// HasScopedPolicy checks if the acl holds a scoped policy of given address and destination contract, active or not
+func HasScopedPolicy(ctx context.Context, aclDB kv.RwDB, aclType string, addr, contract common.Address, policy Policy) (bool, error) {
+	if _, err := resolveTable(aclType); err != nil {
+		return false, err
+	}
+
+	var found bool
+	err := aclDB.View(ctx, func(tx kv.Tx) (err error) {
+		found, err = tx.Has(ScopedPolicies, scopedPolicyKey(ResolveACLTypeToBinary(aclType), addr, contract, policy))
+		return err
+	})
+
+	return found, err
+}
+
+// ListScopedPolicies returns all scoped policies in the given acl
+func ListScopedPolicies(ctx context.Context, aclDB kv.RwDB, aclType string) ([]ScopedPolicy, error) {
+	if _, err := resolveTable(aclType); err != nil {
+		return nil, err
+	}
+
+	var policies []ScopedPolicy
+	err := aclDB.View(ctx, func(tx kv.Tx) error {
+		return tx.ForPrefix(ScopedPolicies, ResolveACLTypeToBinary(aclType).ToByteArray(), func(k, v []byte) error {
+			_, sp, err := decodeScopedPolicy(k, v)
+			if err != nil {
+				return err
+			}
+			policies = append(policies, sp)
+			return nil
+		})
+	})
+
+	return policies, err
+}
+
+// PruneExpiredScopedPolicies removes the scoped policies whose validity window ended before now.
+// Expired policies are never matched, so pruning only keeps the table small.
+func PruneExpiredScopedPolicies(ctx context.Context, aclDB kv.RwDB, now time.Time) (int, error) {
+	var expired []ScopedPolicy
+	var expiredTypes []ACLTypeBinary
+
+	err := aclDB.Update(ctx, func(tx kv.RwTx) error {
+		var keys [][]byte
+		if err := tx.ForEach(ScopedPolicies, nil, func(k, v []byte) error {
+			aclType, sp, err := decodeScopedPolicy(k, v)
+			if err != nil {
+				return err
+			}
+			if sp.IsExpired(now) {
+				keys = append(keys, common.Copy(k))
+				expired = append(expired, sp)
+				expiredTypes = append(expiredTypes, aclType)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := tx.Delete(ScopedPolicies, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	pts := make([]PolicyTransaction, 0, len(expired))
+	for i, sp := range expired {
+		pts = append(pts, PolicyTransaction{
+			aclType:   expiredTypes[i],
+			addr:      sp.Addr,
+			contract:  sp.Contract,
+			scoped:    true,
+			policy:    sp.Policy,
+			operation: Remove,
+			timeTx:    now,
+		})
+	}
+
+	return len(expired), InsertPolicyTransactions(ctx, aclDB, pts)
+}
+
+// scopedPoliciesContent returns a printable representation of the scoped policies of both acls
+func scopedPoliciesContent(tx kv.Tx, now time.Time) (string, error) {
+	var content strings.Builder
+	err := tx.ForEach(ScopedPolicies, nil, func(k, v []byte) error {
+		aclType, sp, err := decodeScopedPolicy(k, v)
+		if err != nil {
+			return err
+		}
+		content.WriteString(fmt.Sprintf("ACLType: %s, %s, Active: %v\n", aclType.String(), sp.ToString(), sp.IsActive(now)))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if content.Len() == 0 {
+		return "\nScoped policies are empty", nil
+	}
+
+	return fmt.Sprintf("\nScoped policies\n%s", content.String()), nil
+}
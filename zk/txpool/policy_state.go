@@ -0,0 +1,557 @@
+package txpool
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// ACLState is the whole content of the ACL in a form that can be kept in a file.
+// An empty Mode leaves the mode of the ACL untouched on import, the lists and method rules
+// are always the complete desired content, so anything not present in them is removed.
+type ACLState struct {
+	Mode        string               `json:"mode,omitempty"`
+	Allowlist   []ACLStateEntry      `json:"allowlist,omitempty"`
+	Blocklist   []ACLStateEntry      `json:"blocklist,omitempty"`
+	MethodRules []ACLStateMethodRule `json:"methodRules,omitempty"`
+}
+
+// ACLStateEntry are the plain and scoped policies of an address in an acl
+type ACLStateEntry struct {
+	Address  common.Address  `json:"address"`
+	Policies []string        `json:"policies,omitempty"`
+	Scoped   []ACLStateScope `json:"scoped,omitempty"`
+}
+
+// ACLStateScope is a scoped policy of an address, nil fields are not bounded
+type ACLStateScope struct {
+	Policy     string          `json:"policy"`
+	Contract   *common.Address `json:"contract,omitempty"`
+	ValidFrom  *time.Time      `json:"validFrom,omitempty"`
+	ValidUntil *time.Time      `json:"validUntil,omitempty"`
+}
+
+// ACLStateMethodRule is a method rule, the selector is 4 bytes hex and a nil sender matches any sender
+type ACLStateMethodRule struct {
+	Contract common.Address  `json:"contract"`
+	Selector string          `json:"selector"`
+	Sender   *common.Address `json:"sender,omitempty"`
+	Action   string          `json:"action"`
+}
+
+// ACLDiff are the changes needed to bring the ACL to a desired state
+type ACLDiff struct {
+	// Changes is a human readable description of every change
+	Changes []string
+
+	mode        ACLMode
+	updates     []aclListUpdate
+	setRules    []MethodRule
+	removeRules []MethodRule
+}
+
+// IsEmpty checks if the ACL already is in the desired state
+func (d *ACLDiff) IsEmpty() bool {
+	return len(d.Changes) == 0
+}
+
+func (d *ACLDiff) String() string {
+	if d.IsEmpty() {
+		return "No changes"
+	}
+	return strings.Join(d.Changes, "\n")
+}
+
+// aclListUpdate is the input of updateScopedPolicies for the addresses of an acl that change
+type aclListUpdate struct {
+	aclType  string
+	addrs    []common.Address
+	policies [][]Policy
+	scoped   [][]ScopedPolicy
+}
+
+// aclListContent are the plain and scoped policies of the addresses of an acl
+type aclListContent struct {
+	policies map[common.Address][]Policy
+	scoped   map[common.Address][]ScopedPolicy
+}
+
+func newACLListContent() aclListContent {
+	return aclListContent{
+		policies: make(map[common.Address][]Policy),
+		scoped:   make(map[common.Address][]ScopedPolicy),
+	}
+}
+
+// addresses returns the addresses present in any of the contents, sorted
+func (c aclListContent) addresses(other aclListContent) []common.Address {
+	seen := make(map[common.Address]struct{})
+	for _, m := range []aclListContent{c, other} {
+		for addr := range m.policies {
+			seen[addr] = struct{}{}
+		}
+		for addr := range m.scoped {
+			seen[addr] = struct{}{}
+		}
+	}
+
+	addrs := make([]common.Address, 0, len(seen))
+	for addr := range seen {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	return addrs
+}
+
+func readACLListContent(tx kv.Tx, aclType string) (aclListContent, error) {
+	table, err := resolveTable(aclType)
+	if err != nil {
+		return aclListContent{}, err
+	}
+
+	content := newACLListContent()
+	if err := tx.ForEach(table, nil, func(k, v []byte) error {
+		policies := make([]Policy, 0, len(v))
+		for _, p := range v {
+			policies = append(policies, Policy(p))
+		}
+		content.policies[common.BytesToAddress(k)] = policies
+		return nil
+	}); err != nil {
+		return aclListContent{}, err
+	}
+
+	if err := tx.ForPrefix(ScopedPolicies, ResolveACLTypeToBinary(aclType).ToByteArray(), func(k, v []byte) error {
+		_, sp, err := decodeScopedPolicy(k, v)
+		if err != nil {
+			return err
+		}
+		content.scoped[sp.Addr] = append(content.scoped[sp.Addr], sp)
+		return nil
+	}); err != nil {
+		return aclListContent{}, err
+	}
+
+	return content, nil
+}
+
+func readMethodRules(tx kv.Tx) ([]MethodRule, error) {
+	var rules []MethodRule
+	err := tx.ForEach(MethodRules, nil, func(k, v []byte) error {
+		r, err := decodeMethodRule(k, v)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, r)
+		return nil
+	})
+	return rules, err
+}
+
+// ExportACLState returns the whole content of the ACL
+func ExportACLState(ctx context.Context, aclDB kv.RwDB) (*ACLState, error) {
+	state := &ACLState{}
+	err := aclDB.View(ctx, func(tx kv.Tx) error {
+		mode, err := tx.GetOne(Config, []byte(modeKey))
+		if err != nil {
+			return err
+		}
+		state.Mode = string(mode)
+		if state.Mode == "" {
+			state.Mode = DisabledMode
+		}
+
+		for _, aclType := range []ACLType{AllowListType, BlockListType} {
+			content, err := readACLListContent(tx, string(aclType))
+			if err != nil {
+				return err
+			}
+
+			entries := make([]ACLStateEntry, 0, len(content.policies))
+			for _, addr := range content.addresses(newACLListContent()) {
+				entries = append(entries, encodeACLStateEntry(addr, content.policies[addr], content.scoped[addr]))
+			}
+
+			if aclType == AllowListType {
+				state.Allowlist = entries
+			} else {
+				state.Blocklist = entries
+			}
+		}
+
+		rules, err := readMethodRules(tx)
+		if err != nil {
+			return err
+		}
+		for _, r := range rules {
+			state.MethodRules = append(state.MethodRules, encodeACLStateMethodRule(r))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func encodeACLStateEntry(addr common.Address, policies []Policy, scoped []ScopedPolicy) ACLStateEntry {
+	entry := ACLStateEntry{Address: addr}
+	for _, p := range policies {
+		entry.Policies = append(entry.Policies, p.String())
+	}
+	for _, sp := range scoped {
+		scope := ACLStateScope{Policy: sp.Policy.String()}
+		if sp.Contract != (common.Address{}) {
+			contract := sp.Contract
+			scope.Contract = &contract
+		}
+		if !sp.ValidFrom.IsZero() {
+			validFrom := sp.ValidFrom.UTC()
+			scope.ValidFrom = &validFrom
+		}
+		if !sp.ValidUntil.IsZero() {
+			validUntil := sp.ValidUntil.UTC()
+			scope.ValidUntil = &validUntil
+		}
+		entry.Scoped = append(entry.Scoped, scope)
+	}
+	return entry
+}
+
+func encodeACLStateMethodRule(r MethodRule) ACLStateMethodRule {
+	enc := ACLStateMethodRule{
+		Contract: r.Contract,
+		Selector: "0x" + hex.EncodeToString(r.Selector[:]),
+		Action:   r.Action.String(),
+	}
+	if r.Sender != (common.Address{}) {
+		sender := r.Sender
+		enc.Sender = &sender
+	}
+	return enc
+}
+
+// decodeACLStateEntries validates the entries of an acl in the state file
+func decodeACLStateEntries(aclType string, entries []ACLStateEntry) (aclListContent, error) {
+	content := newACLListContent()
+	for _, entry := range entries {
+		if _, ok := content.policies[entry.Address]; ok {
+			return aclListContent{}, fmt.Errorf("%s: duplicate address %s", aclType, entry.Address.Hex())
+		}
+
+		policies := make([]Policy, 0, len(entry.Policies))
+		for _, name := range entry.Policies {
+			p, err := ResolvePolicy(name)
+			if err != nil {
+				return aclListContent{}, fmt.Errorf("%s: address %s: %w: %s", aclType, entry.Address.Hex(), err, name)
+			}
+			if !containsPolicy(policiesToBytes(policies), p) {
+				policies = append(policies, p)
+			}
+		}
+		content.policies[entry.Address] = policies
+
+		seen := make(map[string]struct{})
+		for _, scope := range entry.Scoped {
+			p, err := ResolvePolicy(scope.Policy)
+			if err != nil {
+				return aclListContent{}, fmt.Errorf("%s: address %s: %w: %s", aclType, entry.Address.Hex(), err, scope.Policy)
+			}
+			sp := ScopedPolicy{Addr: entry.Address, Policy: p}
+			if scope.Contract != nil {
+				sp.Contract = *scope.Contract
+			}
+			if scope.ValidFrom != nil {
+				sp.ValidFrom = *scope.ValidFrom
+			}
+			if scope.ValidUntil != nil {
+				sp.ValidUntil = *scope.ValidUntil
+			}
+			if err := sp.validate(); err != nil {
+				return aclListContent{}, fmt.Errorf("%s: address %s: %w", aclType, entry.Address.Hex(), err)
+			}
+
+			key := scopedPolicyID(sp)
+			if _, ok := seen[key]; ok {
+				return aclListContent{}, fmt.Errorf("%s: address %s: duplicate scoped policy %s", aclType, entry.Address.Hex(), sp.ToString())
+			}
+			seen[key] = struct{}{}
+			content.scoped[entry.Address] = append(content.scoped[entry.Address], sp)
+		}
+	}
+
+	return content, nil
+}
+
+func decodeACLStateMethodRules(rules []ACLStateMethodRule) ([]MethodRule, error) {
+	decoded := make([]MethodRule, 0, len(rules))
+	seen := make(map[string]struct{})
+	for _, enc := range rules {
+		selector, err := hex.DecodeString(strings.TrimPrefix(enc.Selector, "0x"))
+		if err != nil || len(selector) != 4 {
+			return nil, fmt.Errorf("method rule for %s: invalid selector %q", enc.Contract.Hex(), enc.Selector)
+		}
+		action, err := ResolveMethodRuleAction(enc.Action)
+		if err != nil {
+			return nil, fmt.Errorf("method rule for %s: %w: %s", enc.Contract.Hex(), err, enc.Action)
+		}
+
+		r := MethodRule{Contract: enc.Contract, Selector: [4]byte(selector), Action: action}
+		if enc.Sender != nil {
+			r.Sender = *enc.Sender
+		}
+
+		key := string(methodRuleKey(r.Contract, r.Selector, r.Sender))
+		if _, ok := seen[key]; ok {
+			return nil, fmt.Errorf("duplicate method rule %s", r.ToString())
+		}
+		seen[key] = struct{}{}
+		decoded = append(decoded, r)
+	}
+
+	return decoded, nil
+}
+
+func policiesToBytes(policies []Policy) []byte {
+	b := make([]byte, 0, len(policies))
+	for _, p := range policies {
+		b = append(b, p.ToByte())
+	}
+	return b
+}
+
+// scopedPolicyID identifies a scoped policy of an address, the validity window is its content
+func scopedPolicyID(sp ScopedPolicy) string {
+	return string(append(sp.Contract.Bytes(), sp.Policy.ToByte()))
+}
+
+// diffScopedPolicies describes the scoped policies that have to be added, removed or have their window changed
+func diffScopedPolicies(aclType string, addr common.Address, current, desired []ScopedPolicy) []string {
+	var changes []string
+
+	currentByID := make(map[string]ScopedPolicy, len(current))
+	for _, sp := range current {
+		currentByID[scopedPolicyID(sp)] = sp
+	}
+	desiredByID := make(map[string]struct{}, len(desired))
+
+	for _, sp := range desired {
+		id := scopedPolicyID(sp)
+		desiredByID[id] = struct{}{}
+
+		cur, ok := currentByID[id]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s: add scoped policy %s", aclType, sp.ToString()))
+		case policyTimeToUint64(cur.ValidFrom) != policyTimeToUint64(sp.ValidFrom) ||
+			policyTimeToUint64(cur.ValidUntil) != policyTimeToUint64(sp.ValidUntil):
+			changes = append(changes, fmt.Sprintf("%s: update scoped policy %s", aclType, sp.ToString()))
+		}
+	}
+
+	for _, sp := range current {
+		if _, ok := desiredByID[scopedPolicyID(sp)]; !ok {
+			changes = append(changes, fmt.Sprintf("%s: remove scoped policy %s", aclType, sp.ToString()))
+		}
+	}
+
+	return changes
+}
+
+// diffACLList computes the update of an acl, every address that changes gets its whole desired state
+func diffACLList(aclType string, current, desired aclListContent) (aclListUpdate, []string) {
+	update := aclListUpdate{aclType: aclType}
+	var changes []string
+
+	for _, addr := range current.addresses(desired) {
+		var addrChanges []string
+
+		for _, p := range desired.policies[addr] {
+			if !containsPolicy(policiesToBytes(current.policies[addr]), p) {
+				addrChanges = append(addrChanges, fmt.Sprintf("%s: add policy %s to %s", aclType, p.String(), addr.Hex()))
+			}
+		}
+		for _, p := range current.policies[addr] {
+			if !containsPolicy(policiesToBytes(desired.policies[addr]), p) {
+				addrChanges = append(addrChanges, fmt.Sprintf("%s: remove policy %s from %s", aclType, p.String(), addr.Hex()))
+			}
+		}
+		addrChanges = append(addrChanges, diffScopedPolicies(aclType, addr, current.scoped[addr], desired.scoped[addr])...)
+
+		if len(addrChanges) == 0 {
+			continue
+		}
+
+		changes = append(changes, addrChanges...)
+		update.addrs = append(update.addrs, addr)
+		update.policies = append(update.policies, desired.policies[addr])
+		update.scoped = append(update.scoped, desired.scoped[addr])
+	}
+
+	return update, changes
+}
+
+// diffACLState computes the changes needed to bring the ACL to the desired state within tx
+func diffACLState(tx kv.Tx, desired *ACLState) (*ACLDiff, error) {
+	diff := &ACLDiff{}
+
+	if desired.Mode != "" {
+		mode, err := ResolveACLMode(desired.Mode)
+		if err != nil {
+			return nil, err
+		}
+
+		current, err := tx.GetOne(Config, []byte(modeKey))
+		if err != nil {
+			return nil, err
+		}
+		currentMode := ACLMode(current)
+		if currentMode == "" {
+			currentMode = DisabledMode
+		}
+
+		if currentMode != mode {
+			diff.mode = mode
+			diff.Changes = append(diff.Changes, fmt.Sprintf("mode: %s -> %s", currentMode, mode))
+		}
+	}
+
+	for _, aclType := range []ACLType{AllowListType, BlockListType} {
+		entries := desired.Allowlist
+		if aclType == BlockListType {
+			entries = desired.Blocklist
+		}
+
+		want, err := decodeACLStateEntries(string(aclType), entries)
+		if err != nil {
+			return nil, err
+		}
+		have, err := readACLListContent(tx, string(aclType))
+		if err != nil {
+			return nil, err
+		}
+
+		update, changes := diffACLList(string(aclType), have, want)
+		if len(update.addrs) > 0 {
+			diff.updates = append(diff.updates, update)
+			diff.Changes = append(diff.Changes, changes...)
+		}
+	}
+
+	wantRules, err := decodeACLStateMethodRules(desired.MethodRules)
+	if err != nil {
+		return nil, err
+	}
+	haveRules, err := readMethodRules(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	haveByKey := make(map[string]MethodRule, len(haveRules))
+	for _, r := range haveRules {
+		haveByKey[string(methodRuleKey(r.Contract, r.Selector, r.Sender))] = r
+	}
+	wantByKey := make(map[string]struct{}, len(wantRules))
+	for _, r := range wantRules {
+		key := string(methodRuleKey(r.Contract, r.Selector, r.Sender))
+		wantByKey[key] = struct{}{}
+
+		cur, ok := haveByKey[key]
+		switch {
+		case !ok:
+			diff.setRules = append(diff.setRules, r)
+			diff.Changes = append(diff.Changes, fmt.Sprintf("method rules: add %s", r.ToString()))
+		case cur.Action != r.Action:
+			diff.setRules = append(diff.setRules, r)
+			diff.Changes = append(diff.Changes, fmt.Sprintf("method rules: update %s", r.ToString()))
+		}
+	}
+	for _, r := range haveRules {
+		if _, ok := wantByKey[string(methodRuleKey(r.Contract, r.Selector, r.Sender))]; !ok {
+			diff.removeRules = append(diff.removeRules, r)
+			diff.Changes = append(diff.Changes, fmt.Sprintf("method rules: remove %s", r.ToString()))
+		}
+	}
+
+	return diff, nil
+}
+
+// applyACLDiff applies the changes of the diff within tx and records them in the policy transactions
+func applyACLDiff(tx kv.RwTx, diff *ACLDiff, timeNow time.Time) error {
+	if diff.mode != "" {
+		if err := setMode(tx, diff.mode, timeNow); err != nil {
+			return err
+		}
+	}
+
+	var pts []PolicyTransaction
+	for _, update := range diff.updates {
+		table, err := resolveTable(update.aclType)
+		if err != nil {
+			return err
+		}
+
+		updatePts, err := updateScopedPolicies(tx, table, ResolveACLTypeToBinary(update.aclType), update.addrs, update.policies, update.scoped, timeNow)
+		if err != nil {
+			return err
+		}
+		pts = append(pts, updatePts...)
+	}
+
+	for _, r := range diff.setRules {
+		if err := tx.Put(MethodRules, methodRuleKey(r.Contract, r.Selector, r.Sender), []byte{r.Action.ToByte()}); err != nil {
+			return err
+		}
+		pts = append(pts, methodRuleTransaction(r, Add, timeNow))
+	}
+	for _, r := range diff.removeRules {
+		if err := tx.Delete(MethodRules, methodRuleKey(r.Contract, r.Selector, r.Sender)); err != nil {
+			return err
+		}
+		pts = append(pts, methodRuleTransaction(r, Remove, timeNow))
+	}
+
+	return insertPolicyTransactions(tx, pts)
+}
+
+// DiffACLState returns the changes needed to bring the ACL to the desired state without applying them
+func DiffACLState(ctx context.Context, aclDB kv.RwDB, desired *ACLState) (*ACLDiff, error) {
+	var diff *ACLDiff
+	err := aclDB.View(ctx, func(tx kv.Tx) error {
+		var err error
+		diff, err = diffACLState(tx, desired)
+		return err
+	})
+	return diff, err
+}
+
+// ImportACLState brings the ACL to the desired state. The diff is computed and applied in a single
+// transaction, so either the whole state is imported or nothing changes.
+func ImportACLState(ctx context.Context, aclDB kv.RwDB, desired *ACLState) (*ACLDiff, error) {
+	var diff *ACLDiff
+	err := aclDB.Update(ctx, func(tx kv.RwTx) error {
+		var err error
+		if diff, err = diffACLState(tx, desired); err != nil {
+			return err
+		}
+		if diff.IsEmpty() {
+			return nil
+		}
+		return applyACLDiff(tx, diff, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
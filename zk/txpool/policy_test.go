@@ -2,6 +2,7 @@ package txpool
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"strings"
@@ -587,7 +588,7 @@ func TestIsActionAllowed(t *testing.T) {
 		require.NoError(t, AddPolicy(ctx, db, "blocklist", addr, policy))
 
 		// Check if the action is allowed
-		allowed, err := txPool.isActionAllowed(ctx, addr, policy)
+		allowed, err := txPool.isActionAllowed(ctx, addr, nil, nil, policy)
 		require.NoError(t, err)
 		require.False(t, allowed) // In blocklist mode, having the policy means the action is not allowed
 	})
@@ -600,7 +601,7 @@ func TestIsActionAllowed(t *testing.T) {
 		policy := Deploy
 
 		// Check if the action is allowed
-		allowed, err := txPool.isActionAllowed(ctx, addr, policy)
+		allowed, err := txPool.isActionAllowed(ctx, addr, nil, nil, policy)
 		require.NoError(t, err)
 		require.True(t, allowed) // In blocklist mode, not having the policy means the action is allowed
 	})
@@ -616,7 +617,7 @@ func TestIsActionAllowed(t *testing.T) {
 		require.NoError(t, AddPolicy(ctx, db, "allowlist", addr, policy))
 
 		// Check if the action is allowed
-		allowed, err := txPool.isActionAllowed(ctx, addr, policy)
+		allowed, err := txPool.isActionAllowed(ctx, addr, nil, nil, policy)
 		require.NoError(t, err)
 		require.True(t, allowed) // In allowlist mode, having the policy means the action is allowed
 	})
@@ -629,7 +630,7 @@ func TestIsActionAllowed(t *testing.T) {
 		policy := Deploy
 
 		// Check if the action is allowed
-		allowed, err := txPool.isActionAllowed(ctx, addr, policy)
+		allowed, err := txPool.isActionAllowed(ctx, addr, nil, nil, policy)
 		require.NoError(t, err)
 		require.False(t, allowed) // In allowlist mode, not having the policy means the action is not allowed
 	})
@@ -642,7 +643,7 @@ func TestIsActionAllowed(t *testing.T) {
 		policy := SendTx
 
 		// Check if the action is allowed
-		allowed, err := txPool.isActionAllowed(ctx, addr, policy)
+		allowed, err := txPool.isActionAllowed(ctx, addr, nil, nil, policy)
 		require.NoError(t, err)
 		require.True(t, allowed) // In disabled mode, all actions are allowed
 	})
@@ -697,3 +698,407 @@ func TestListContentAtACL(t *testing.T) {
 		})
 	}
 }
+
+func TestScopedPolicies(t *testing.T) {
+	db := newTestACLDB(t, "")
+	ctx := context.Background()
+
+	txPool := &TxPool{aclDB: db}
+
+	addr := common.HexToAddress("0x1234567890abcdef")
+	contract := common.HexToAddress("0xabcdef1234567890")
+	otherContract := common.HexToAddress("0xfedcba0987654321")
+	now := time.Now()
+
+	t.Run("AddScopedPolicy - Deploy scoped to contract", func(t *testing.T) {
+		err := AddScopedPolicy(ctx, db, "allowlist", ScopedPolicy{Addr: addr, Contract: contract, Policy: Deploy})
+		require.ErrorIs(t, err, errInvalidPolicyScope)
+	})
+
+	t.Run("AddScopedPolicy - Invalid window", func(t *testing.T) {
+		err := AddScopedPolicy(ctx, db, "allowlist", ScopedPolicy{Addr: addr, Policy: SendTx, ValidFrom: now, ValidUntil: now.Add(-time.Hour)})
+		require.ErrorIs(t, err, errInvalidPolicyScope)
+	})
+
+	t.Run("AllowlistMode - Contract scoped policy", func(t *testing.T) {
+		require.NoError(t, SetMode(ctx, db, AllowlistMode))
+		require.NoError(t, AddScopedPolicy(ctx, db, "allowlist", ScopedPolicy{
+			Addr:       addr,
+			Contract:   contract,
+			Policy:     SendTx,
+			ValidUntil: now.Add(time.Hour),
+		}))
+
+		allowed, err := txPool.isActionAllowed(ctx, addr, &contract, nil, SendTx)
+		require.NoError(t, err)
+		require.True(t, allowed)
+
+		allowed, err = txPool.isActionAllowed(ctx, addr, &otherContract, nil, SendTx)
+		require.NoError(t, err)
+		require.False(t, allowed)
+
+		// without destination only policies scoped to any contract apply
+		hasPolicy, err := DoesAccountHavePolicy(ctx, db, addr, SendTx)
+		require.NoError(t, err)
+		require.False(t, hasPolicy)
+	})
+
+	t.Run("AllowlistMode - Expired and future policies", func(t *testing.T) {
+		require.NoError(t, SetMode(ctx, db, AllowlistMode))
+		require.NoError(t, AddScopedPolicy(ctx, db, "allowlist", ScopedPolicy{
+			Addr:       addr,
+			Contract:   otherContract,
+			Policy:     SendTx,
+			ValidUntil: now.Add(-time.Minute),
+		}))
+
+		allowed, err := txPool.isActionAllowed(ctx, addr, &otherContract, nil, SendTx)
+		require.NoError(t, err)
+		require.False(t, allowed)
+
+		require.NoError(t, AddScopedPolicy(ctx, db, "allowlist", ScopedPolicy{
+			Addr:      addr,
+			Policy:    Deploy,
+			ValidFrom: now.Add(time.Hour),
+		}))
+
+		allowed, err = txPool.isActionAllowed(ctx, addr, nil, nil, Deploy)
+		require.NoError(t, err)
+		require.False(t, allowed)
+	})
+
+	t.Run("BlocklistMode - Policy scoped to any contract", func(t *testing.T) {
+		require.NoError(t, SetMode(ctx, db, BlocklistMode))
+		require.NoError(t, AddScopedPolicy(ctx, db, "blocklist", ScopedPolicy{
+			Addr:       addr,
+			Policy:     SendTx,
+			ValidFrom:  now.Add(-time.Hour),
+			ValidUntil: now.Add(time.Hour),
+		}))
+
+		allowed, err := txPool.isActionAllowed(ctx, addr, &contract, nil, SendTx)
+		require.NoError(t, err)
+		require.False(t, allowed)
+
+		require.NoError(t, RemoveScopedPolicy(ctx, db, "blocklist", addr, common.Address{}, SendTx))
+
+		allowed, err = txPool.isActionAllowed(ctx, addr, &contract, nil, SendTx)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	})
+
+	t.Run("ListScopedPolicies and PruneExpiredScopedPolicies", func(t *testing.T) {
+		policies, err := ListScopedPolicies(ctx, db, "allowlist")
+		require.NoError(t, err)
+		require.Len(t, policies, 3)
+
+		pruned, err := PruneExpiredScopedPolicies(ctx, db, now)
+		require.NoError(t, err)
+		require.Equal(t, 1, pruned)
+
+		policies, err = ListScopedPolicies(ctx, db, "allowlist")
+		require.NoError(t, err)
+		require.Len(t, policies, 2)
+
+		content, err := ListContentAtACL(ctx, db)
+		require.NoError(t, err)
+		require.Contains(t, content[4], "Contract: 000000000000000000000000abcdef1234567890")
+	})
+
+	t.Run("UpdateScopedPolicies - Replaces scoped policies", func(t *testing.T) {
+		err := UpdateScopedPolicies(ctx, db, "allowlist", []common.Address{addr}, [][]Policy{{Deploy}}, [][]ScopedPolicy{{
+			{Contract: otherContract, Policy: SendTx},
+		}})
+		require.NoError(t, err)
+
+		policies, err := ListScopedPolicies(ctx, db, "allowlist")
+		require.NoError(t, err)
+		require.Len(t, policies, 1)
+		require.Equal(t, addr, policies[0].Addr)
+		require.Equal(t, otherContract, policies[0].Contract)
+
+		// a plain update removes the scoped policies of the address
+		require.NoError(t, UpdatePolicies(ctx, db, "allowlist", []common.Address{addr}, [][]Policy{{SendTx}}))
+
+		policies, err = ListScopedPolicies(ctx, db, "allowlist")
+		require.NoError(t, err)
+		require.Empty(t, policies)
+	})
+
+	t.Run("LastPolicyTransactions - Records contract", func(t *testing.T) {
+		// policy transactions are keyed by address and second, so use an address without history
+		partner := common.HexToAddress("0x0921598333cf3ce5fe2031c056c79aec59ee10b6")
+		require.NoError(t, AddScopedPolicy(ctx, db, "allowlist", ScopedPolicy{Addr: partner, Contract: contract, Policy: SendTx}))
+
+		pts, err := LastPolicyTransactions(ctx, db, 20)
+		require.NoError(t, err)
+
+		var found bool
+		for _, pt := range pts {
+			if pt.addr == partner {
+				require.Equal(t, contract, pt.contract)
+				require.Contains(t, pt.ToString(), "Contract: 000000000000000000000000abcdef1234567890")
+				found = true
+			}
+		}
+		require.True(t, found)
+	})
+
+	t.Run("LastPolicyTransactions - Records scoped updates", func(t *testing.T) {
+		member := common.HexToAddress("0x5b38da6a701c568545dcfcb03fcb875f56beddc4")
+		err := UpdateScopedPolicies(ctx, db, "allowlist", []common.Address{member}, [][]Policy{{Deploy}}, [][]ScopedPolicy{{
+			{Contract: contract, Policy: SendTx},
+			{Policy: SendTx, ValidUntil: now.Add(time.Hour)},
+		}})
+		require.NoError(t, err)
+
+		pts, err := LastPolicyTransactions(ctx, db, 50)
+		require.NoError(t, err)
+
+		var plain, scoped []PolicyTransaction
+		for _, pt := range pts {
+			if pt.addr != member {
+				continue
+			}
+			require.Equal(t, Update, pt.operation)
+			if pt.scoped {
+				scoped = append(scoped, pt)
+			} else {
+				plain = append(plain, pt)
+			}
+		}
+		require.Len(t, plain, 1)
+		require.Len(t, scoped, 2)
+		require.ElementsMatch(t, []common.Address{contract, {}}, []common.Address{scoped[0].contract, scoped[1].contract})
+		for _, pt := range scoped {
+			require.Equal(t, SendTx, pt.policy)
+			require.Contains(t, pt.ToString(), "Policy: sendTx")
+		}
+	})
+}
+
+func TestMethodRules(t *testing.T) {
+	db := newTestACLDB(t, "")
+	ctx := context.Background()
+
+	txPool := &TxPool{aclDB: db}
+
+	proxy := common.HexToAddress("0xabcdef1234567890")
+	governance := common.HexToAddress("0x0921598333cf3ce5fe2031c056c79aec59ee10b6")
+	user := common.HexToAddress("0x1234567890abcdef")
+	upgradeTo := []byte{0x36, 0x59, 0xcf, 0xe6}
+	transfer := []byte{0xa9, 0x05, 0x9c, 0xbb}
+
+	require.NoError(t, SetMethodRule(ctx, db, MethodRule{Contract: proxy, Selector: [4]byte(upgradeTo), Action: MethodDeny}))
+	require.NoError(t, SetMethodRule(ctx, db, MethodRule{Contract: proxy, Selector: [4]byte(upgradeTo), Sender: governance, Action: MethodAllow}))
+
+	t.Run("SetMethodRule - Unknown action", func(t *testing.T) {
+		err := SetMethodRule(ctx, db, MethodRule{Contract: proxy, Selector: [4]byte(upgradeTo), Action: MethodRuleAction(5)})
+		require.ErrorIs(t, err, errUnknownMethodRuleAction)
+	})
+
+	t.Run("DisabledMode - Rules are not evaluated", func(t *testing.T) {
+		require.NoError(t, SetMode(ctx, db, DisabledMode))
+
+		allowed, err := txPool.isActionAllowed(ctx, user, &proxy, upgradeTo, SendTx)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	})
+
+	t.Run("BlocklistMode - Deny for everyone except governance", func(t *testing.T) {
+		require.NoError(t, SetMode(ctx, db, BlocklistMode))
+
+		allowed, err := txPool.isActionAllowed(ctx, user, &proxy, upgradeTo, SendTx)
+		require.NoError(t, err)
+		require.False(t, allowed)
+
+		allowed, err = txPool.isActionAllowed(ctx, governance, &proxy, upgradeTo, SendTx)
+		require.NoError(t, err)
+		require.True(t, allowed)
+
+		// other methods are not affected
+		allowed, err = txPool.isActionAllowed(ctx, user, &proxy, transfer, SendTx)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	})
+
+	t.Run("BlocklistMode - Allow rule does not let a blocklisted sender in", func(t *testing.T) {
+		require.NoError(t, SetMode(ctx, db, BlocklistMode))
+		require.NoError(t, SetMethodRule(ctx, db, MethodRule{Contract: proxy, Selector: [4]byte(transfer), Action: MethodAllow}))
+		require.NoError(t, AddPolicy(ctx, db, "blocklist", user, SendTx))
+
+		allowed, err := txPool.isActionAllowed(ctx, user, &proxy, transfer, SendTx)
+		require.NoError(t, err)
+		require.False(t, allowed)
+
+		require.NoError(t, RemovePolicy(ctx, db, "blocklist", user, SendTx))
+		require.NoError(t, RemoveMethodRule(ctx, db, proxy, [4]byte(transfer), common.Address{}))
+	})
+
+	t.Run("AllowlistMode - Rule does not allow a sender without policies", func(t *testing.T) {
+		require.NoError(t, SetMode(ctx, db, AllowlistMode))
+
+		allowed, err := txPool.isActionAllowed(ctx, governance, &proxy, upgradeTo, SendTx)
+		require.NoError(t, err)
+		require.False(t, allowed)
+
+		// once allowlisted the rule for the sender applies
+		require.NoError(t, AddPolicy(ctx, db, "allowlist", governance, SendTx))
+		allowed, err = txPool.isActionAllowed(ctx, governance, &proxy, upgradeTo, SendTx)
+		require.NoError(t, err)
+		require.True(t, allowed)
+
+		allowed, err = txPool.isActionAllowed(ctx, governance, &proxy, transfer, SendTx)
+		require.NoError(t, err)
+		require.True(t, allowed)
+
+		// a deny rule wins over the sender policies
+		require.NoError(t, AddPolicy(ctx, db, "allowlist", user, SendTx))
+		allowed, err = txPool.isActionAllowed(ctx, user, &proxy, upgradeTo, SendTx)
+		require.NoError(t, err)
+		require.False(t, allowed)
+	})
+
+	t.Run("ListMethodRules and RemoveMethodRule", func(t *testing.T) {
+		rules, err := ListMethodRules(ctx, db)
+		require.NoError(t, err)
+		require.Len(t, rules, 2)
+
+		require.NoError(t, RemoveMethodRule(ctx, db, proxy, [4]byte(upgradeTo), common.Address{}))
+
+		rules, err = ListMethodRules(ctx, db)
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		require.Equal(t, governance, rules[0].Sender)
+
+		content, err := ListContentAtACL(ctx, db)
+		require.NoError(t, err)
+		require.Contains(t, content[5], "Selector: 0x3659cfe6, Sender: 0921598333cf3ce5fe2031c056c79aec59ee10b6, Action: allow")
+	})
+
+	t.Run("LastPolicyTransactions - Records method rules", func(t *testing.T) {
+		pts, err := LastPolicyTransactions(ctx, db, 20)
+		require.NoError(t, err)
+
+		var ruleTransactions []string
+		for _, pt := range pts {
+			if pt.aclType == MethodRuleTypeB {
+				ruleTransactions = append(ruleTransactions, pt.ToString())
+			}
+		}
+		require.True(t, containsSubstring(ruleTransactions, "Sender: 0921598333cf3ce5fe2031c056c79aec59ee10b6, Action: allow, Operation: add"))
+		require.True(t, containsSubstring(ruleTransactions, "Sender: any, Action: deny, Operation: remove"))
+	})
+}
+
+func TestImportExportACLState(t *testing.T) {
+	db := newTestACLDB(t, "")
+	ctx := context.Background()
+
+	addr1 := common.HexToAddress("0x1234567890abcdef")
+	addr2 := common.HexToAddress("0xabcdef1234567890")
+	addr3 := common.HexToAddress("0x0921598333cf3ce5fe2031c056c79aec59ee10b6")
+	contract := common.HexToAddress("0x000000000000000000000000000000000000dead")
+	validUntil := time.Unix(time.Now().Add(time.Hour).Unix(), 0).UTC()
+
+	require.NoError(t, SetMode(ctx, db, BlocklistMode))
+	require.NoError(t, AddPolicy(ctx, db, "allowlist", addr1, SendTx))
+	require.NoError(t, AddPolicy(ctx, db, "allowlist", addr2, Deploy))
+	require.NoError(t, AddPolicy(ctx, db, "blocklist", addr3, SendTx))
+	require.NoError(t, SetMethodRule(ctx, db, MethodRule{Contract: contract, Selector: [4]byte{0x36, 0x59, 0xcf, 0xe6}, Action: MethodDeny}))
+
+	desired := &ACLState{
+		Mode: AllowlistMode,
+		Allowlist: []ACLStateEntry{
+			{Address: addr1, Policies: []string{"sendTx", "deploy"}},
+			{Address: addr3, Scoped: []ACLStateScope{{Policy: "sendTx", Contract: &contract, ValidUntil: &validUntil}}},
+		},
+		MethodRules: []ACLStateMethodRule{
+			{Contract: contract, Selector: "0x3659cfe6", Sender: &addr1, Action: "allow"},
+		},
+	}
+
+	t.Run("DiffACLState - Dry run does not change the ACL", func(t *testing.T) {
+		diff, err := DiffACLState(ctx, db, desired)
+		require.NoError(t, err)
+		require.False(t, diff.IsEmpty())
+		require.Contains(t, diff.Changes, "mode: blocklist -> allowlist")
+		require.Contains(t, diff.Changes, "allowlist: add policy deploy to "+addr1.Hex())
+		require.Contains(t, diff.Changes, "allowlist: remove policy deploy from "+addr2.Hex())
+		require.Contains(t, diff.Changes, "blocklist: remove policy sendTx from "+addr3.Hex())
+		require.True(t, containsSubstring(diff.Changes, "allowlist: add scoped policy Address: "+hex.EncodeToString(addr3[:])))
+		require.True(t, containsSubstring(diff.Changes, "method rules: add"))
+		require.True(t, containsSubstring(diff.Changes, "method rules: remove"))
+
+		mode, err := GetMode(ctx, db)
+		require.NoError(t, err)
+		require.Equal(t, ACLMode(BlocklistMode), mode)
+	})
+
+	t.Run("DiffACLState - Invalid state", func(t *testing.T) {
+		_, err := DiffACLState(ctx, db, &ACLState{Allowlist: []ACLStateEntry{{Address: addr1}, {Address: addr1}}})
+		require.ErrorContains(t, err, "duplicate address")
+
+		_, err = DiffACLState(ctx, db, &ACLState{Blocklist: []ACLStateEntry{{Address: addr1, Policies: []string{"mint"}}}})
+		require.ErrorIs(t, err, errUnknownPolicy)
+
+		_, err = DiffACLState(ctx, db, &ACLState{MethodRules: []ACLStateMethodRule{{Contract: contract, Selector: "0x12", Action: "allow"}}})
+		require.ErrorContains(t, err, "invalid selector")
+
+		_, err = DiffACLState(ctx, db, &ACLState{Mode: "open"})
+		require.ErrorIs(t, err, errInvalidMode)
+	})
+
+	t.Run("ImportACLState - Applies the whole state", func(t *testing.T) {
+		diff, err := ImportACLState(ctx, db, desired)
+		require.NoError(t, err)
+		require.False(t, diff.IsEmpty())
+
+		exported, err := ExportACLState(ctx, db)
+		require.NoError(t, err)
+		require.Equal(t, AllowlistMode, exported.Mode)
+		require.Len(t, exported.Allowlist, 2)
+		require.Empty(t, exported.Blocklist)
+		require.Equal(t, desired.MethodRules, exported.MethodRules)
+
+		for _, entry := range exported.Allowlist {
+			switch entry.Address {
+			case addr1:
+				require.ElementsMatch(t, []string{"sendTx", "deploy"}, entry.Policies)
+			case addr3:
+				require.Empty(t, entry.Policies)
+				require.Len(t, entry.Scoped, 1)
+				require.Equal(t, contract, *entry.Scoped[0].Contract)
+				require.Equal(t, validUntil, *entry.Scoped[0].ValidUntil)
+			default:
+				t.Fatalf("unexpected address %s", entry.Address.Hex())
+			}
+		}
+
+		allowed, err := DoesAccountHavePolicy(ctx, db, addr1, Deploy)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	})
+
+	t.Run("ImportACLState - Exported state has no changes", func(t *testing.T) {
+		exported, err := ExportACLState(ctx, db)
+		require.NoError(t, err)
+
+		diff, err := ImportACLState(ctx, db, exported)
+		require.NoError(t, err)
+		require.True(t, diff.IsEmpty(), diff.String())
+	})
+
+	t.Run("ImportACLState - Invalid state changes nothing", func(t *testing.T) {
+		invalid := &ACLState{
+			Mode:      DisabledMode,
+			Allowlist: []ACLStateEntry{{Address: addr2, Scoped: []ACLStateScope{{Policy: "deploy", Contract: &contract}}}},
+		}
+		_, err := ImportACLState(ctx, db, invalid)
+		require.ErrorIs(t, err, errInvalidPolicyScope)
+
+		mode, err := GetMode(ctx, db)
+		require.NoError(t, err)
+		require.Equal(t, ACLMode(AllowlistMode), mode)
+	})
+}
@@ -150,6 +150,7 @@ const (
 	SmartContractDeploymentDisabled DiscardReason = 28 // to == null not allowed, config set to block smart contract deployment
 	GasLimitTooHigh                 DiscardReason = 29 // gas limit is too high
 	Expired                         DiscardReason = 30 // used when a transaction is purged from the pool
+	SenderRateLimited               DiscardReason = 31 // sender exceeded its transactions or gas limit for the current window
 )
 
 func (r DiscardReason) String() string {
@@ -214,6 +215,8 @@ func (r DiscardReason) String() string {
 		return "smart contract deployment disabled"
 	case GasLimitTooHigh:
 		return fmt.Sprintf("gas limit too high. Max: %d", transactionGasLimit)
+	case SenderRateLimited:
+		return "sender exceeded its rate limit"
 	default:
 		panic(fmt.Sprintf("discard reason: %d", r))
 	}
@@ -228,15 +231,17 @@ type metaTx struct {
 	minTip                    uint64
 	bestIndex                 int
 	worstIndex                int
-	timestamp                 uint64 // when it was added to pool
-	created                   uint64 // unix timestamp of creation
+	timestamp                 uint64     // when it was added to pool
+	created                   uint64     // unix timestamp of creation
+	arrival                   uint64     // order of arrival to the pool, used by the fifo ordering
+	counters                  ZkCounters // estimated zk counters, nil until the sequencer executed the transaction
 	subPool                   SubPoolMarker
 	currentSubPool            SubPoolType
 	alreadyYielded            bool
 }
 
 func newMetaTx(slot *types.TxSlot, isLocal bool, timestmap uint64) *metaTx {
-	mt := &metaTx{Tx: slot, worstIndex: -1, bestIndex: -1, timestamp: timestmap, created: uint64(time.Now().Unix())}
+	mt := &metaTx{Tx: slot, worstIndex: -1, bestIndex: -1, timestamp: timestmap, created: uint64(time.Now().Unix()), arrival: arrivalCounter.Add(1)}
 	if isLocal {
 		mt.subPool = IsLocal
 	}
@@ -335,6 +340,13 @@ type TxPool struct {
 
 	// limbo specific fields where bad batch transactions identified by the executor go
 	limbo *Limbo
+
+	// per sender limits of the transactions added to the pool
+	senderLimiter *senderLimiter
+
+	// order in which best yields the pending transactions
+	ordering        OrderingStrategy
+	prioritySenders prioritySenders
 }
 
 func CreateTxPoolBuckets(tx kv.RwTx) error {
@@ -360,6 +372,19 @@ func New(newTxs chan types.Announcements, coreDB kv.RoDB, cfg txpoolcfg.Config,
 		search:           &metaTx{Tx: &types.TxSlot{}},
 		senderIDTxnCount: map[uint64]int{},
 	}
+	var zkCfg *ethconfig.Zk
+	if ethCfg != nil {
+		zkCfg = ethCfg.Zk
+	}
+	var orderingName string
+	if zkCfg != nil {
+		orderingName = zkCfg.TxPoolOrdering
+	}
+	ordering, err := NewOrderingStrategy(orderingName)
+	if err != nil {
+		return nil, err
+	}
+
 	tracedSenders := make(map[common.Address]struct{})
 	for _, sender := range cfg.TracedSenders {
 		tracedSenders[common.BytesToAddress([]byte(sender))] = struct{}{}
@@ -389,6 +414,8 @@ func New(newTxs chan types.Announcements, coreDB kv.RoDB, cfg txpoolcfg.Config,
 		flushMtx:                &sync.Mutex{},
 		aclDB:                   aclDB,
 		limbo:                   newLimbo(),
+		senderLimiter:           newSenderLimiter(zkCfg),
+		ordering:                ordering,
 	}, nil
 }
 
@@ -409,6 +436,7 @@ func (p *TxPool) OnNewBlock(ctx context.Context, stateChanges *remote.StateChang
 	defer p.lock.Unlock()
 
 	p.lastSeenBlock.Store(stateChanges.ChangeBatch[len(stateChanges.ChangeBatch)-1].BlockHeight)
+	p.senderLimiter.prune(time.Now())
 	if !p.started.Load() {
 		if err := p.fromDB(ctx, tx, coreTx); err != nil {
 			return fmt.Errorf("loading txs from DB: %w", err)
@@ -569,7 +597,7 @@ func (p *TxPool) processRemoteTxs(ctx context.Context) error {
 	}
 
 	announcements, _, err := p.addTxs(p.lastSeenBlock.Load(), cacheView, p.senders, newTxs,
-		p.pendingBaseFee.Load(), p.blockGasLimit.Load(), p.pending, p.baseFee, p.queued, p.all, p.byHash, p.addLocked, p.discardLocked, true)
+		p.pendingBaseFee.Load(), p.blockGasLimit.Load(), p.pending, p.baseFee, p.queued, p.all, p.byHash, p.addLockedWithinSenderLimits, p.discardLocked, true)
 	if err != nil {
 		return err
 	}
@@ -681,12 +709,12 @@ func (p *TxPool) ResetYieldedStatus() {
 }
 
 func (p *TxPool) YieldBest(n uint16, txs *types.TxsRlp, tx kv.Tx, onTopOf, availableGas, availableBlobGas uint64, toSkip mapset.Set[[32]byte]) (bool, int, error) {
-	return p.best(n, txs, tx, onTopOf, availableGas, availableBlobGas, toSkip)
+	return p.best(n, txs, tx, onTopOf, availableGas, availableBlobGas, toSkip, nil)
 }
 
 func (p *TxPool) PeekBest(n uint16, txs *types.TxsRlp, tx kv.Tx, onTopOf, availableGas, availableBlobGas uint64) (bool, error) {
 	set := mapset.NewThreadUnsafeSet[[32]byte]()
-	onTime, _, err := p.best(n, txs, tx, onTopOf, availableGas, availableBlobGas, set)
+	onTime, _, err := p.best(n, txs, tx, onTopOf, availableGas, availableBlobGas, set, nil)
 	return onTime, err
 }
 
@@ -786,8 +814,12 @@ func (p *TxPool) validateTx(txn *types.TxSlot, isLocal bool, stateCache kvcache.
 
 	switch resolvePolicy(txn) {
 	case SendTx:
+		var selector []byte
+		if txn.DataLen >= 4 {
+			selector = txn.Selector[:]
+		}
 		var allow bool
-		allow, err := p.isActionAllowed(context.TODO(), from, SendTx)
+		allow, err := p.isActionAllowed(context.TODO(), from, &txn.To, selector, SendTx)
 		if err != nil {
 			panic(err)
 		}
@@ -797,7 +829,7 @@ func (p *TxPool) validateTx(txn *types.TxSlot, isLocal bool, stateCache kvcache.
 	case Deploy:
 		var allow bool
 		// check that sender may deploy contracts
-		allow, err := p.isActionAllowed(context.TODO(), from, Deploy)
+		allow, err := p.isActionAllowed(context.TODO(), from, nil, nil, Deploy)
 		if err != nil {
 			panic(err)
 		}
@@ -967,12 +999,20 @@ func (p *TxPool) AddLocalTxs(ctx context.Context, newTransactions types.TxSlots,
 		return nil, err
 	}
 
+	// newTxs only has the transactions that passed the validation, keep their position in reasons
+	goodIdx := make([]int, 0, len(newTxs.Txs))
+	for i, reason := range reasons {
+		if reason == NotSet {
+			goodIdx = append(goodIdx, i)
+		}
+	}
+
 	announcements, addReasons, err := p.addTxs(p.lastSeenBlock.Load(), cacheView, p.senders, newTxs,
-		p.pendingBaseFee.Load(), p.blockGasLimit.Load(), p.pending, p.baseFee, p.queued, p.all, p.byHash, p.addLocked, p.discardLocked, true)
+		p.pendingBaseFee.Load(), p.blockGasLimit.Load(), p.pending, p.baseFee, p.queued, p.all, p.byHash, p.addLockedWithinSenderLimits, p.discardLocked, true)
 	if err == nil {
 		for i, reason := range addReasons {
 			if reason != NotSet {
-				reasons[i] = reason
+				reasons[goodIdx[i]] = reason
 			}
 		}
 	} else {
@@ -981,10 +1021,10 @@ func (p *TxPool) AddLocalTxs(ctx context.Context, newTransactions types.TxSlots,
 	p.promoted.Reset()
 	p.promoted.AppendOther(announcements)
 
-	reasons = fillDiscardReasons(reasons, newTxs, p.discardReasonsLRU)
+	reasons = fillDiscardReasons(reasons, newTransactions, p.discardReasonsLRU)
 	for i, reason := range reasons {
 		if reason == Success {
-			txn := newTxs.Txs[i]
+			txn := newTransactions.Txs[i]
 			if txn.Traced {
 				log.Info(fmt.Sprintf("TX TRACING: AddLocalTxs promotes idHash=%x, senderId=%d", txn.IDHash, txn.SenderID))
 			}
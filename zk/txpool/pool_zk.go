@@ -149,7 +149,8 @@ func (p *TxPool) onSenderStateChange(senderID uint64, senderNonce uint64, sender
 
 // zk: the implementation of best here is changed only to not take into account block gas limits as we don't care about
 // these in zk.  Instead we do a quick check on the transaction maximum gas in zk
-func (p *TxPool) best(n uint16, txs *types.TxsRlp, tx kv.Tx, onTopOf, availableGas, availableBlobGas uint64, toSkip mapset.Set[[32]byte]) (bool, int, error) {
+// counterBudget, when not nil, are the zk counters left in the batch: transactions with estimated counters that do not fit are skipped
+func (p *TxPool) best(n uint16, txs *types.TxsRlp, tx kv.Tx, onTopOf, availableGas, availableBlobGas uint64, toSkip mapset.Set[[32]byte], counterBudget ZkCounters) (bool, int, error) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
@@ -165,21 +166,26 @@ func (p *TxPool) best(n uint16, txs *types.TxsRlp, tx kv.Tx, onTopOf, availableG
 	isShanghai := p.isShanghai()
 	isLondon := p.isLondon()
 	_ = isLondon
-	best := p.pending.best
+	p.pending.EnforceBestInvariants()
+
+	best, err := p.orderedPendingLocked()
+	if err != nil {
+		return false, 0, err
+	}
 
-	txs.Resize(uint(cmp.Min(int(n), len(best.ms))))
+	txs.Resize(uint(cmp.Min(int(n), len(best))))
 	var toRemove []*metaTx
 	count := 0
+	// once a transaction of a sender is skipped for its counters the following nonces can not be executed either
+	var skippedSenders map[uint64]struct{}
 
-	p.pending.EnforceBestInvariants()
-
-	for i := 0; count < int(n) && i < len(best.ms); i++ {
+	for i := 0; count < int(n) && i < len(best); i++ {
 		// if we wouldn't have enough gas for a standard transaction then quit out early
 		if availableGas < fixedgas.TxGas {
 			break
 		}
 
-		mt := best.ms[i]
+		mt := best[i]
 
 		if toSkip.Contains(mt.Tx.IDHash) {
 			continue
@@ -192,6 +198,20 @@ func (p *TxPool) best(n uint16, txs *types.TxsRlp, tx kv.Tx, onTopOf, availableG
 			continue
 		}
 
+		if counterBudget != nil {
+			if _, ok := skippedSenders[mt.Tx.SenderID]; ok {
+				continue
+			}
+			if mt.counters != nil && !mt.counters.fits(counterBudget) {
+				if skippedSenders == nil {
+					skippedSenders = make(map[uint64]struct{})
+				}
+				skippedSenders[mt.Tx.SenderID] = struct{}{}
+				countersSkippedCounter.Inc()
+				continue
+			}
+		}
+
 		if mt.Tx.Gas > transactionGasLimit {
 			// Skip transactions with very large gas limit, these shouldn't enter the pool at all
 			log.Debug("found a transaction in the pending pool with too high gas for tx - clear the tx pool")
@@ -225,6 +245,9 @@ func (p *TxPool) best(n uint16, txs *types.TxsRlp, tx kv.Tx, onTopOf, availableG
 		if intrinsicGas <= availableGas { // check for potential underflow
 			availableGas -= intrinsicGas
 		}
+		if counterBudget != nil && mt.counters != nil {
+			mt.counters.deductFrom(counterBudget)
+		}
 
 		txs.Txs[count] = rlpTx
 		txs.TxIds[count] = mt.Tx.IDHash
@@ -243,6 +266,11 @@ func (p *TxPool) best(n uint16, txs *types.TxsRlp, tx kv.Tx, onTopOf, availableG
 	return true, count, nil
 }
 
+// ACLDB returns the database of the access lists used by the pool
+func (p *TxPool) ACLDB() kv.RwDB {
+	return p.aclDB
+}
+
 func (p *TxPool) ForceUpdateLatestBlock(blockNumber uint64) {
 	if p != nil {
 		p.lastSeenBlock.Store(blockNumber)
@@ -0,0 +1,58 @@
+package txpool
+
+import (
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/metrics"
+	"github.com/ledgerwatch/erigon-lib/types"
+)
+
+var (
+	countersSkippedCounter  = metrics.GetOrCreateCounter(`txpool_counters_skipped`)
+	countersRecordedCounter = metrics.GetOrCreateCounter(`txpool_counters_recorded`)
+)
+
+// ZkCounters are the zk counters a transaction is expected to use or a batch has left, in the order of
+// vm.CounterKeyNames. The pool does not depend on the vm so it only knows them as numbers.
+type ZkCounters []int
+
+// fits reports if every counter is within the budget, counters missing from the budget are not limited
+func (c ZkCounters) fits(budget ZkCounters) bool {
+	for i, used := range c {
+		if i < len(budget) && used > budget[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// deductFrom takes the counters from the budget
+func (c ZkCounters) deductFrom(budget ZkCounters) {
+	for i, used := range c {
+		if i < len(budget) {
+			budget[i] -= used
+		}
+	}
+}
+
+// SetEstimatedCounters records the counters a pending transaction used when it was last executed, so that
+// it is only yielded again to a batch that has enough counters left for it. The estimate lives as long as the
+// transaction is in the pool.
+func (p *TxPool) SetEstimatedCounters(txHash common.Hash, counters ZkCounters) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	mt, ok := p.byHash[string(txHash[:])]
+	if !ok {
+		return
+	}
+	mt.counters = append(ZkCounters(nil), counters...)
+	countersRecordedCounter.Inc()
+}
+
+// YieldBestWithinCounters is YieldBest for a batch with the given counters left. The transactions with an estimate
+// that does not fit in what is left are skipped, the ones without an estimate are yielded as usual.
+func (p *TxPool) YieldBestWithinCounters(n uint16, txs *types.TxsRlp, tx kv.Tx, onTopOf, availableGas, availableBlobGas uint64, toSkip mapset.Set[[32]byte], budget ZkCounters) (bool, int, error) {
+	return p.best(n, txs, tx, onTopOf, availableGas, availableBlobGas, toSkip, append(ZkCounters(nil), budget...))
+}
@@ -0,0 +1,136 @@
+package txpool
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/datadir"
+	"github.com/ledgerwatch/erigon-lib/common/u256"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces"
+	"github.com/ledgerwatch/erigon-lib/gointerfaces/remote"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/kvcache"
+	"github.com/ledgerwatch/erigon-lib/kv/memdb"
+	"github.com/ledgerwatch/erigon-lib/kv/temporal/temporaltest"
+	"github.com/ledgerwatch/erigon-lib/txpool/txpoolcfg"
+	"github.com/ledgerwatch/erigon-lib/types"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZkCountersFits(t *testing.T) {
+	budget := ZkCounters{10, 10, 10}
+
+	require.True(t, ZkCounters{10, 0, 5}.fits(budget))
+	require.False(t, ZkCounters{0, 11, 0}.fits(budget))
+	// counters missing from the budget are not limited
+	require.True(t, ZkCounters{1, 1, 1, 1000}.fits(budget))
+
+	ZkCounters{4, 5, 6}.deductFrom(budget)
+	require.Equal(t, ZkCounters{6, 5, 4}, budget)
+}
+
+func TestBestWithinCounters(t *testing.T) {
+	var big, small [20]byte
+	big[0] = 1
+	small[0] = 2
+	pool, tx, _ := newTestZkPool(t, &ethconfig.Zk{}, big, small)
+	ctx := context.Background()
+
+	// the big sender pays the higher tip so it is first in the default order
+	var txSlots types.TxSlots
+	for i, addr := range [][20]byte{big, small} {
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tip := uint64(400000 - i*100000)
+			txSlot := &types.TxSlot{
+				Tip:    *uint256.NewInt(tip),
+				FeeCap: *uint256.NewInt(tip),
+				Gas:    100000,
+				Nonce:  nonce,
+				Rlp:    []byte{byte(i + 1), byte(nonce + 1)},
+			}
+			txSlot.IDHash[0] = byte(i + 1)
+			txSlot.IDHash[1] = byte(nonce + 1)
+			txSlots.Append(txSlot, addr[:], true)
+		}
+	}
+	reasons, err := pool.AddLocalTxs(ctx, txSlots, tx)
+	require.NoError(t, err)
+	require.Equal(t, []DiscardReason{Success, Success, Success, Success}, reasons)
+
+	pool.SetEstimatedCounters(common.Hash{1, 1}, ZkCounters{100, 50})
+	pool.SetEstimatedCounters(common.Hash{2, 1}, ZkCounters{30, 10})
+	pool.SetEstimatedCounters(common.Hash{2, 2}, ZkCounters{30, 10})
+	// unknown transactions are ignored
+	pool.SetEstimatedCounters(common.Hash{3, 1}, ZkCounters{1, 1})
+
+	yield := func(budget ZkCounters) []common.Hash {
+		var yielded types.TxsRlp
+		_, _, err := pool.YieldBestWithinCounters(10, &yielded, tx, 0, 30_000_000, 0, mapset.NewThreadUnsafeSet[[32]byte](), budget)
+		require.NoError(t, err)
+		return yielded.TxIds
+	}
+
+	// without a budget everything is yielded in the default order
+	require.Equal(t, []common.Hash{{1, 1}, {1, 2}, {2, 1}, {2, 2}}, yield(nil))
+
+	// the first transaction of the big sender does not fit so its next nonce is skipped too
+	require.Equal(t, []common.Hash{{2, 1}, {2, 2}}, yield(ZkCounters{99, 100}))
+
+	// the budget is consumed by the yielded transactions
+	require.Equal(t, []common.Hash{{2, 1}}, yield(ZkCounters{59, 100}))
+
+	// the budget of the caller is not changed
+	budget := ZkCounters{1000, 1000}
+	require.Len(t, yield(budget), 4)
+	require.Equal(t, ZkCounters{1000, 1000}, budget)
+}
+
+// newTestZkPool returns a started pool with the given zk config and senders funded at nonce 0
+func newTestZkPool(t *testing.T, zkCfg *ethconfig.Zk, senders ...[20]byte) (*TxPool, kv.RwTx, kv.RwDB) {
+	ch := make(chan types.Announcements, 100)
+	_, coreDB, _ := temporaltest.NewTestDB(t, datadir.New(t.TempDir()))
+	t.Cleanup(coreDB.Close)
+
+	db := memdb.NewTestPoolDB(t)
+	path := fmt.Sprintf("/tmp/db-test-%v", time.Now().UTC().Format(time.RFC3339Nano))
+	txPoolDB := newTestTxPoolDB(t, path)
+	t.Cleanup(txPoolDB.Close)
+	aclsDB := newTestACLDB(t, path)
+	t.Cleanup(aclsDB.Close)
+
+	ethCfg := ethconfig.Defaults
+	ethCfg.Zk = zkCfg
+	pool, err := New(ch, coreDB, txpoolcfg.DefaultConfig, &ethCfg, kvcache.New(kvcache.DefaultCoherentConfig), *u256.N1, nil, nil, aclsDB)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	change := &remote.StateChangeBatch{
+		PendingBlockBaseFee: 200000,
+		BlockGasLimit:       1000000,
+		ChangeBatch: []*remote.StateChange{
+			{BlockHeight: 0, BlockHash: gointerfaces.ConvertHashToH256([32]byte{})},
+		},
+	}
+	for _, addr := range senders {
+		v := make([]byte, types.EncodeSenderLengthForStorage(0, *uint256.NewInt(18 * common.Ether)))
+		types.EncodeSender(0, *uint256.NewInt(18 * common.Ether), v)
+		change.ChangeBatch[0].Changes = append(change.ChangeBatch[0].Changes, &remote.AccountChange{
+			Action:  remote.Action_UPSERT,
+			Address: gointerfaces.ConvertAddressToH160(addr),
+			Data:    v,
+		})
+	}
+
+	tx, err := db.BeginRw(ctx)
+	require.NoError(t, err)
+	t.Cleanup(tx.Rollback)
+	require.NoError(t, pool.OnNewBlock(ctx, change, types.TxSlots{}, types.TxSlots{}, tx))
+
+	return pool, tx, aclsDB
+}
@@ -1,6 +1,8 @@
 package txpool
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"sync/atomic"
@@ -29,10 +31,16 @@ const (
 	DbKeyTxRootPrefix        = uint8(50)
 	DbKeyTxHashPrefix        = uint8(51)
 	DbKeyTxSenderPrefix      = uint8(52)
+	DbKeyTxFailurePrefix     = uint8(53)
 )
 
 var emptyHash = common.Hash{}
 
+var (
+	ErrLimboTxNotFound     = errors.New("transaction not found in limbo")
+	ErrLimboTxNotProcessed = errors.New("transaction is still waiting for the executor")
+)
+
 type LimboSendersWithChangedState struct {
 	Storage map[uint64]int32
 }
@@ -66,6 +74,8 @@ type LimboBlockTransactionDetails struct {
 	Root        common.Hash
 	Hash        common.Hash
 	Sender      common.Address
+	// Failure is the error of the executor when the transaction was found invalid, empty otherwise
+	Failure string
 }
 
 func newLimboBlockTransactionDetails(rlp, streamBytes []byte, hash common.Hash, sender common.Address) *LimboBlockTransactionDetails {
@@ -192,6 +202,24 @@ func (_this *LimboBlockDetails) AppendTransaction(rlp, streamBytes []byte, hash
 	return uint32(len(_this.Transactions))
 }
 
+func (_this *LimboBlockDetails) clone() *LimboBlockDetails {
+	c := *_this
+	c.L1InfoTreeMinTimestamps = make(map[uint64]uint64, len(_this.L1InfoTreeMinTimestamps))
+	for k, v := range _this.L1InfoTreeMinTimestamps {
+		c.L1InfoTreeMinTimestamps[k] = v
+	}
+	c.Transactions = make([]*LimboBlockTransactionDetails, len(_this.Transactions))
+	for i, limboTx := range _this.Transactions {
+		txClone := *limboTx
+		c.Transactions[i] = &txClone
+	}
+	return &c
+}
+
+func (_this *LimboBlockDetails) removeTransaction(txIndex uint32) {
+	_this.Transactions = append(_this.Transactions[:txIndex], _this.Transactions[txIndex+1:]...)
+}
+
 func (_this *LimboBlockDetails) getTxDetailsByHash(txHash *common.Hash) (*LimboBlockTransactionDetails, uint32) {
 	for i, limboTx := range _this.Transactions {
 		if limboTx.Hash == *txHash {
@@ -276,12 +304,137 @@ func (p *TxPool) GetUncheckedLimboBlocksDetailsClonedWeak() []*LimboBlockDetails
 	return limboBlocksClone
 }
 
-func (p *TxPool) MarkProcessedLimboDetails(size int, invalidBatchesIndices []int, invalidTxs []*string) {
+// GetLimboBlocksDetailsCloned returns copies of the unchecked and of the invalid limbo blocks,
+// so they can be inspected without racing with the limbo processor
+func (p *TxPool) GetLimboBlocksDetailsCloned() ([]*LimboBlockDetails, []*LimboBlockDetails) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	unchecked := make([]*LimboBlockDetails, len(p.limbo.uncheckedLimboBlocks))
+	for i, limboBlock := range p.limbo.uncheckedLimboBlocks {
+		unchecked[i] = limboBlock.clone()
+	}
+	invalid := make([]*LimboBlockDetails, len(p.limbo.invalidLimboBlocks))
+	for i, limboBlock := range p.limbo.invalidLimboBlocks {
+		invalid[i] = limboBlock.clone()
+	}
+
+	return unchecked, invalid
+}
+
+// DropLimboTransaction removes a transaction from the limbo for good, it will not come back to the pool.
+// The transaction must have been processed by the executor already.
+func (p *TxPool) DropLimboTransaction(ctx context.Context, db kv.RwDB, txHash common.Hash) error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	for _, idHash := range invalidTxs {
+	if _, _, err := p.removeLimboTransaction(txHash); err != nil {
+		return err
+	}
+
+	// if the unwind has not happened yet the transaction must be discarded when it does
+	p.limbo.invalidTxsMap[hexutils.BytesToHex(txHash[:])] = 0
+
+	return db.Update(ctx, func(tx kv.RwTx) error {
+		return p.flushLockedLimbo(tx)
+	})
+}
+
+// RequeueLimboTransaction removes a transaction from the limbo and gives it back to the pool, where it is
+// validated again like any other incoming transaction. The transaction must have been processed by the executor already.
+func (p *TxPool) RequeueLimboTransaction(ctx context.Context, db kv.RwDB, txHash common.Hash) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	slots, limboTx, err := p.removeLimboTransaction(txHash)
+	if err != nil {
+		return err
+	}
+
+	delete(p.limbo.invalidTxsMap, hexutils.BytesToHex(txHash[:]))
+
+	if len(slots.Txs) == 0 {
+		// the transaction was already discarded by the unwind, so it is parsed again from the limbo block
+		parseCtx := types.NewTxParseContext(p.chainID)
+		parseCtx.WithSender(false)
+		txn := &types.TxSlot{}
+		if _, err := parseCtx.ParseTransaction(limboTx.Rlp, 0, txn, nil, true /* hasEnvelope */, false, nil); err != nil {
+			return fmt.Errorf("failed to parse limbo transaction %x: %w", txHash, err)
+		}
+		slots.Append(txn, limboTx.Sender[:], true)
+	}
+
+	for i, txn := range slots.Txs {
+		if _, ok := p.unprocessedRemoteByHash[string(txn.IDHash[:])]; ok {
+			continue
+		}
+		p.unprocessedRemoteByHash[string(txn.IDHash[:])] = len(p.unprocessedRemoteTxs.Txs)
+		p.unprocessedRemoteTxs.Append(txn, slots.Senders.At(i), slots.IsLocal[i])
+	}
+
+	return db.Update(ctx, func(tx kv.RwTx) error {
+		return p.flushLockedLimbo(tx)
+	})
+}
+
+// removeLimboTransaction removes the transaction from the limbo slots and from the invalid limbo blocks, dropping the
+// blocks left without transactions. It returns the removed slots and the details of the transaction from its limbo block.
+// should be called from within a locked context from the pool
+func (p *TxPool) removeLimboTransaction(txHash common.Hash) (*types.TxSlots, *LimboBlockTransactionDetails, error) {
+	if p.isTxKnownToLimbo(txHash) {
+		return nil, nil, ErrLimboTxNotProcessed
+	}
+
+	removedSlots := &types.TxSlots{}
+	remainingSlots := &types.TxSlots{}
+	for i, slot := range p.limbo.limboSlots.Txs {
+		if slot.IDHash == txHash {
+			removedSlots.Append(slot, p.limbo.limboSlots.Senders.At(i), p.limbo.limboSlots.IsLocal[i])
+		} else {
+			remainingSlots.Append(slot, p.limbo.limboSlots.Senders.At(i), p.limbo.limboSlots.IsLocal[i])
+		}
+	}
+
+	var removedTx *LimboBlockTransactionDetails
+	remainingBlocks := make([]*LimboBlockDetails, 0, len(p.limbo.invalidLimboBlocks))
+	for _, limboBlock := range p.limbo.invalidLimboBlocks {
+		if limboTx, txIndex := limboBlock.getTxDetailsByHash(&txHash); limboTx != nil {
+			removedTx = limboTx
+			limboBlock.removeTransaction(txIndex)
+		}
+		if len(limboBlock.Transactions) > 0 {
+			remainingBlocks = append(remainingBlocks, limboBlock)
+		}
+	}
+
+	if removedTx == nil && len(removedSlots.Txs) == 0 {
+		return nil, nil, ErrLimboTxNotFound
+	}
+
+	p.limbo.limboSlots = remainingSlots
+	p.limbo.invalidLimboBlocks = remainingBlocks
+
+	return removedSlots, removedTx, nil
+}
+
+// MarkProcessedLimboDetails moves the first size unchecked blocks out of the limbo, keeping the invalid ones.
+// invalidTxsFailures are the executor errors of invalidTxs, in the same order.
+func (p *TxPool) MarkProcessedLimboDetails(size int, invalidBatchesIndices []int, invalidTxs []*string, invalidTxsFailures []string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for i, idHash := range invalidTxs {
 		p.limbo.invalidTxsMap[*idHash] = 0
+
+		if i >= len(invalidTxsFailures) {
+			continue
+		}
+		hash := common.BytesToHash(hexutils.HexToBytes(*idHash))
+		for _, limboBlock := range p.limbo.uncheckedLimboBlocks[:size] {
+			if limboTx, _ := limboBlock.getTxDetailsByHash(&hash); limboTx != nil {
+				limboTx.Failure = invalidTxsFailures[i]
+			}
+		}
 	}
 
 	for _, invalidBatchesIndex := range invalidBatchesIndices {
@@ -184,6 +184,14 @@ func flushLockedLimboBlock(tx kv.RwTx, limboBlock *LimboBlockDetails, limboBlock
 		if err := tx.Put(TablePoolLimbo, keyBytesTx, limboTx.Sender[:]); err != nil {
 			return err
 		}
+
+		// Transaction - Failure, only known for invalid transactions
+		if limboTx.Failure != "" {
+			keyBytesTx[0] = DbKeyTxFailurePrefix
+			if err := tx.Put(TablePoolLimbo, keyBytesTx, []byte(limboTx.Failure)); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -264,6 +272,9 @@ func (p *TxPool) fromDBLimbo(ctx context.Context, tx kv.Tx, cacheView kvcache.Ca
 		case DbKeyTxSenderPrefix:
 			txIndex := binary.LittleEndian.Uint32(k[9:13])
 			copy(fromDBLimboBlock(p, int(txIndex), k, v).Transactions[txIndex].Sender[:], v)
+		case DbKeyTxFailurePrefix:
+			txIndex := binary.LittleEndian.Uint32(k[9:13])
+			fromDBLimboBlock(p, int(txIndex), k, v).Transactions[txIndex].Failure = string(v)
 		case DbKeyAwaitingBlockHandlingPrefix:
 			p.limbo.awaitingBlockHandling.Store(v[0] != 0)
 		default:
@@ -137,6 +137,7 @@ func store(t *testing.T, dbPath string) *TxPool {
 				Root:        common.BytesToHash([]byte{40, 22, 20, 34, 241, 15, 19, 105, 101, 136, 1, 2, 4, 134, 41, 15, 19, 145, 10, 0, 1, 2, 4, 4, 41, 15, 19, 12, 10, 214, 0, 0}),
 				Hash:        common.HexToHash("2504231FF7150135925D98A462A331522D9C2F712C85BEBCD0B5BEBDF50DD7AA"),
 				Sender:      common.HexToAddress("0x92f20480f6c693ab9fddfffff1e400532d24847f"),
+				Failure:     "not enough counters to continue the computation",
 			},
 		},
 	})
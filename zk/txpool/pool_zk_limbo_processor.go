@@ -88,6 +88,7 @@ func (_this *LimboSubPoolProcessor) run() {
 	}
 
 	invalidTxs := []*string{}
+	invalidTxsFailures := []string{}
 	invalidBlocksIndices := []int{}
 	lastAddedInvalidBlockIndex := -1
 
@@ -98,6 +99,7 @@ func (_this *LimboSubPoolProcessor) run() {
 			if err != nil {
 				idHash := hexutils.BytesToHex(limboTx.Hash[:])
 				invalidTxs = append(invalidTxs, &idHash)
+				invalidTxsFailures = append(invalidTxsFailures, err.Error())
 				if lastAddedInvalidBlockIndex != i {
 					invalidBlocksIndices = append(invalidBlocksIndices, i)
 					lastAddedInvalidBlockIndex = i
@@ -111,5 +113,5 @@ func (_this *LimboSubPoolProcessor) run() {
 		}
 	}
 
-	_this.txPool.MarkProcessedLimboDetails(size, invalidBlocksIndices, invalidTxs)
+	_this.txPool.MarkProcessedLimboDetails(size, invalidBlocksIndices, invalidTxs, invalidTxsFailures)
 }
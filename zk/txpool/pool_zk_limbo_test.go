@@ -0,0 +1,128 @@
+package txpool
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/kvcache"
+	"github.com/ledgerwatch/erigon-lib/txpool/txpoolcfg"
+	"github.com/ledgerwatch/erigon-lib/types"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/status-im/keycard-go/hexutils"
+	"gotest.tools/v3/assert"
+)
+
+func newLimboTestPool(t *testing.T, db kv.RwDB, aclDb kv.RwDB) *TxPool {
+	ethCfg := ethconfig.Defaults
+	zkCfg := *ethCfg.Zk
+	zkCfg.Limbo = true
+	ethCfg.Zk = &zkCfg
+
+	p, err := New(make(chan types.Announcements), db, txpoolcfg.DefaultConfig, &ethCfg, kvcache.NewDummy(), *uint256.NewInt(1101), big.NewInt(0), big.NewInt(0), aclDb)
+	assert.NilError(t, err)
+	return p
+}
+
+func TestMarkProcessedLimboDetailsFailures(t *testing.T) {
+	db, tx, aclDb := initDb(t, t.TempDir(), true)
+	tx.Rollback()
+	defer db.Close()
+	defer aclDb.Close()
+
+	p := newLimboTestPool(t, db, aclDb)
+
+	validHash := common.HexToHash("0x01")
+	invalidHash := common.HexToHash("0x02")
+	block := NewLimboBlockDetails()
+	block.AppendTransaction([]byte{1}, nil, validHash, common.Address{})
+	block.AppendTransaction([]byte{2}, nil, invalidHash, common.Address{})
+	p.ProcessUncheckedLimboBlockDetails(block)
+
+	idHash := hexutils.BytesToHex(invalidHash[:])
+	p.MarkProcessedLimboDetails(1, []int{0}, []*string{&idHash}, []string{"out of counters"})
+
+	unchecked, invalid := p.GetLimboBlocksDetailsCloned()
+	assert.Equal(t, 0, len(unchecked))
+	assert.Equal(t, 1, len(invalid))
+	assert.Equal(t, "", invalid[0].Transactions[0].Failure)
+	assert.Equal(t, "out of counters", invalid[0].Transactions[1].Failure)
+
+	// the listing is a copy, changing it does not change the limbo
+	invalid[0].Transactions[1].Failure = ""
+	assert.Equal(t, "out of counters", p.limbo.invalidLimboBlocks[0].Transactions[1].Failure)
+}
+
+func TestDropAndRequeueLimboTransaction(t *testing.T) {
+	ctx := context.Background()
+	dbPath := t.TempDir()
+	db, tx, aclDb := initDb(t, dbPath, true)
+	tx.Rollback()
+	defer db.Close()
+	defer aclDb.Close()
+
+	p := newLimboTestPool(t, db, aclDb)
+
+	parseCtx := types.NewTxParseContext(p.chainID)
+	parseCtx.WithSender(false)
+	txn01 := &types.TxSlot{}
+	_, err := parseCtx.ParseTransaction(tx01Rlp, 0, txn01, nil, false /* hasEnvelope */, false, nil)
+	assert.NilError(t, err)
+	txn02 := &types.TxSlot{}
+	_, err = parseCtx.ParseTransaction(tx02Rlp, 0, txn02, nil, false /* hasEnvelope */, false, nil)
+	assert.NilError(t, err)
+
+	// tx01 is back in the limbo slots after the unwind, tx02 was found invalid and discarded by it
+	p.limbo.limboSlots.Append(txn01, tx01Sender, true)
+	invalidBlock := NewLimboBlockDetails()
+	invalidBlock.BlockNumber = 10
+	invalidBlock.AppendTransaction(tx01Rlp, nil, txn01.IDHash, common.BytesToAddress(tx01Sender))
+	invalidBlock.AppendTransaction(tx02Rlp, nil, txn02.IDHash, common.BytesToAddress(tx02Sender))
+	invalidBlock.Transactions[1].Failure = "out of counters"
+	p.limbo.invalidLimboBlocks = append(p.limbo.invalidLimboBlocks, invalidBlock)
+
+	// a block still waiting for the executor can not be changed
+	uncheckedHash := common.HexToHash("0x03")
+	uncheckedBlock := NewLimboBlockDetails()
+	uncheckedBlock.BlockNumber = 11
+	uncheckedBlock.Witness = []byte{1}
+	uncheckedBlock.AppendTransaction([]byte{3}, []byte{3}, uncheckedHash, common.Address{})
+	p.limbo.uncheckedLimboBlocks = append(p.limbo.uncheckedLimboBlocks, uncheckedBlock)
+
+	assert.ErrorIs(t, p.DropLimboTransaction(ctx, db, uncheckedHash), ErrLimboTxNotProcessed)
+	assert.ErrorIs(t, p.RequeueLimboTransaction(ctx, db, uncheckedHash), ErrLimboTxNotProcessed)
+	assert.ErrorIs(t, p.DropLimboTransaction(ctx, db, common.HexToHash("0x04")), ErrLimboTxNotFound)
+
+	err = p.DropLimboTransaction(ctx, db, txn01.IDHash)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(p.limbo.limboSlots.Txs))
+	assert.Equal(t, 1, len(p.limbo.invalidLimboBlocks[0].Transactions))
+	_, ok := p.limbo.invalidTxsMap[hexutils.BytesToHex(txn01.IDHash[:])]
+	assert.Assert(t, ok)
+	assert.Equal(t, 0, len(p.unprocessedRemoteTxs.Txs))
+
+	err = p.RequeueLimboTransaction(ctx, db, txn02.IDHash)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(p.limbo.invalidLimboBlocks))
+	assert.Equal(t, 1, len(p.unprocessedRemoteTxs.Txs))
+	assert.Equal(t, txn02.IDHash, p.unprocessedRemoteTxs.Txs[0].IDHash)
+	assert.DeepEqual(t, tx02Sender, []byte(p.unprocessedRemoteTxs.Senders.At(0)))
+
+	// the changes are persisted
+	restored := newLimboTestPool(t, db, aclDb)
+	err = db.View(ctx, func(tx kv.Tx) error {
+		cacheView, err := restored._stateCache.View(ctx, tx)
+		if err != nil {
+			return err
+		}
+		return restored.fromDBLimbo(ctx, tx, cacheView)
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, p.limbo.invalidTxsMap, restored.limbo.invalidTxsMap)
+	assert.Equal(t, 0, len(restored.limbo.limboSlots.Txs))
+	assert.Equal(t, 0, len(restored.limbo.invalidLimboBlocks))
+	assert.DeepEqual(t, p.limbo.uncheckedLimboBlocks, restored.limbo.uncheckedLimboBlocks)
+}
@@ -0,0 +1,272 @@
+package txpool
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+const (
+	// DefaultOrdering yields the pending transactions in the order of the pending sub pool: local transactions
+	// first, then by effective tip, nonce distance, balance distance and block of arrival
+	DefaultOrdering = "default"
+	// TipOrdering yields the pending transactions by effective tip only, local transactions have no precedence
+	TipOrdering = "tip"
+	// FifoOrdering yields the pending transactions in the order they arrived to the pool
+	FifoOrdering = "fifo"
+	// RoundRobinOrdering yields one transaction of every sender before yielding the next one of any sender
+	RoundRobinOrdering = "round-robin"
+	// PriorityOrdering yields the transactions of the senders with the priority policy in the ACL allowlist
+	// first, in the default order, followed by everyone else in the default order
+	PriorityOrdering = "priority"
+)
+
+var OrderingStrategies = []string{DefaultOrdering, TipOrdering, FifoOrdering, RoundRobinOrdering, PriorityOrdering}
+
+// arrivalCounter numbers the transactions in the order they are added to the pool
+var arrivalCounter atomic.Uint64
+
+// OrderingCandidate is what an ordering strategy knows about a pending transaction
+type OrderingCandidate struct {
+	Hash     common.Hash    `json:"hash"`
+	Sender   common.Address `json:"sender"`
+	Nonce    uint64         `json:"nonce"`
+	Tip      uint64         `json:"tip"`     // effective tip
+	Arrival  uint64         `json:"arrival"` // the lower, the earlier the transaction arrived to the pool
+	Local    bool           `json:"local"`
+	Priority bool           `json:"priority"`
+	Rank     int            `json:"rank"` // position in the default ordering
+
+	round int // number of transactions of the same sender before this one
+	mt    *metaTx
+}
+
+// OrderingStrategy decides the order in which the pending transactions are yielded to the sequencer
+type OrderingStrategy interface {
+	Name() string
+	// Order receives the pending transactions in the default order and returns them in the order they should be yielded.
+	// The transactions of a sender must be kept in nonce order, otherwise the sequencer would execute a nonce gap.
+	Order(candidates []*OrderingCandidate) []*OrderingCandidate
+}
+
+// NewOrderingStrategy returns the ordering strategy with the given name, empty meaning the default one
+func NewOrderingStrategy(name string) (OrderingStrategy, error) {
+	switch name {
+	case "", DefaultOrdering:
+		return defaultOrdering{}, nil
+	case TipOrdering:
+		return senderHeadsOrdering{name: TipOrdering, less: func(a, b *OrderingCandidate) bool {
+			if a.Tip != b.Tip {
+				return a.Tip > b.Tip
+			}
+			return a.Arrival < b.Arrival
+		}}, nil
+	case FifoOrdering:
+		return senderHeadsOrdering{name: FifoOrdering, less: func(a, b *OrderingCandidate) bool {
+			return a.Arrival < b.Arrival
+		}}, nil
+	case RoundRobinOrdering:
+		return senderHeadsOrdering{name: RoundRobinOrdering, less: func(a, b *OrderingCandidate) bool {
+			if a.round != b.round {
+				return a.round < b.round
+			}
+			return a.Rank < b.Rank
+		}}, nil
+	case PriorityOrdering:
+		return senderHeadsOrdering{name: PriorityOrdering, less: func(a, b *OrderingCandidate) bool {
+			if a.Priority != b.Priority {
+				return a.Priority
+			}
+			return a.Rank < b.Rank
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown txpool ordering %q, supported: %v", name, OrderingStrategies)
+	}
+}
+
+type defaultOrdering struct{}
+
+func (defaultOrdering) Name() string { return DefaultOrdering }
+
+func (defaultOrdering) Order(candidates []*OrderingCandidate) []*OrderingCandidate {
+	return candidates
+}
+
+// senderHeadsOrdering only ever compares the next transaction of every sender, so whatever the comparison
+// the transactions of a sender are yielded in nonce order
+type senderHeadsOrdering struct {
+	name string
+	less func(a, b *OrderingCandidate) bool
+}
+
+func (o senderHeadsOrdering) Name() string { return o.name }
+
+func (o senderHeadsOrdering) Order(candidates []*OrderingCandidate) []*OrderingCandidate {
+	bySender := make(map[common.Address][]*OrderingCandidate)
+	for _, c := range candidates {
+		bySender[c.Sender] = append(bySender[c.Sender], c)
+	}
+
+	heads := &candidateHeap{less: o.less}
+	for _, queue := range bySender {
+		sort.SliceStable(queue, func(i, j int) bool { return queue[i].Nonce < queue[j].Nonce })
+		for i, c := range queue {
+			c.round = i
+		}
+		heads.items = append(heads.items, queue[0])
+		bySender[queue[0].Sender] = queue[1:]
+	}
+	heap.Init(heads)
+
+	ordered := make([]*OrderingCandidate, 0, len(candidates))
+	for heads.Len() > 0 {
+		c := heap.Pop(heads).(*OrderingCandidate)
+		ordered = append(ordered, c)
+		if queue := bySender[c.Sender]; len(queue) > 0 {
+			heap.Push(heads, queue[0])
+			bySender[c.Sender] = queue[1:]
+		}
+	}
+
+	return ordered
+}
+
+type candidateHeap struct {
+	items []*OrderingCandidate
+	less  func(a, b *OrderingCandidate) bool
+}
+
+func (h *candidateHeap) Len() int           { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+func (h *candidateHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x any)         { h.items = append(h.items, x.(*OrderingCandidate)) }
+func (h *candidateHeap) Pop() any {
+	old := h.items
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	h.items = old[:len(old)-1]
+	return item
+}
+
+// prioritySenders caches the priority policy of the senders for a block, so the ACL is read once per sender and
+// block rather than on every yield. A change of the policy applies from the next block.
+type prioritySenders struct {
+	block   uint64
+	senders map[common.Address]bool
+}
+
+// isPrioritySenderLocked checks if the sender has the priority policy, tx is opened on the first cache miss
+// should be called from within a locked context from the pool
+func (p *TxPool) isPrioritySenderLocked(tx *kv.Tx, sender common.Address, now time.Time) (bool, error) {
+	if block := p.lastSeenBlock.Load(); p.prioritySenders.senders == nil || p.prioritySenders.block != block {
+		p.prioritySenders = prioritySenders{block: block, senders: make(map[common.Address]bool)}
+	}
+
+	if priority, ok := p.prioritySenders.senders[sender]; ok {
+		return priority, nil
+	}
+
+	if *tx == nil {
+		var err error
+		if *tx, err = p.aclDB.BeginRo(context.TODO()); err != nil {
+			return false, err
+		}
+	}
+
+	priority, err := hasAllowlistPolicy(*tx, sender, Priority, now)
+	if err != nil {
+		return false, err
+	}
+	p.prioritySenders.senders[sender] = priority
+
+	return priority, nil
+}
+
+// orderingCandidatesLocked describes the pending transactions, given in the default order, for the ordering strategies
+// should be called from within a locked context from the pool
+func (p *TxPool) orderingCandidatesLocked(ms []*metaTx, withPriority bool) ([]*OrderingCandidate, error) {
+	var tx kv.Tx
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+
+	pendingBaseFee := uint256.NewInt(p.pendingBaseFee.Load())
+	now := time.Now()
+	candidates := make([]*OrderingCandidate, len(ms))
+	for i, mt := range ms {
+		c := &OrderingCandidate{
+			Hash:    mt.Tx.IDHash,
+			Sender:  p.senders.senderID2Addr[mt.Tx.SenderID],
+			Nonce:   mt.Tx.Nonce,
+			Tip:     effectiveTip(mt, pendingBaseFee),
+			Arrival: mt.arrival,
+			Local:   mt.subPool&IsLocal > 0,
+			Rank:    i,
+			mt:      mt,
+		}
+
+		if withPriority {
+			var err error
+			if c.Priority, err = p.isPrioritySenderLocked(&tx, c.Sender, now); err != nil {
+				return nil, err
+			}
+		}
+
+		candidates[i] = c
+	}
+
+	return candidates, nil
+}
+
+// PendingOrderingSnapshot records the pending transactions in the default order, as the ordering strategies see them.
+// It can be replayed through OrderingStrategy.Order to compare the strategies on a real pool.
+func (p *TxPool) PendingOrderingSnapshot() ([]*OrderingCandidate, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.pending.EnforceBestInvariants()
+	return p.orderingCandidatesLocked(p.pending.best.ms, true)
+}
+
+// orderedPendingLocked returns the pending transactions in the order of the configured strategy
+// should be called from within a locked context from the pool
+func (p *TxPool) orderedPendingLocked() ([]*metaTx, error) {
+	ms := p.pending.best.ms
+	if p.ordering == nil || p.ordering.Name() == DefaultOrdering {
+		// the pending sub pool is already sorted in the default order
+		return ms, nil
+	}
+
+	candidates, err := p.orderingCandidatesLocked(ms, p.ordering.Name() == PriorityOrdering)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := p.ordering.Order(candidates)
+	res := make([]*metaTx, len(ordered))
+	for i, c := range ordered {
+		res[i] = c.mt
+	}
+	return res, nil
+}
+
+// effectiveTip is the tip the sequencer gets from the transaction with the given base fee, as compared by metaTx.better
+func effectiveTip(mt *metaTx, pendingBaseFee *uint256.Int) uint64 {
+	if mt.minFeeCap.Cmp(pendingBaseFee) < 0 {
+		return 0
+	}
+	difference := new(uint256.Int).Sub(&mt.minFeeCap, pendingBaseFee)
+	if difference.Cmp(uint256.NewInt(mt.minTip)) <= 0 {
+		return difference.Uint64()
+	}
+	return mt.minTip
+}
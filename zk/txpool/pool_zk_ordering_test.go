@@ -0,0 +1,120 @@
+package txpool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/types"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/stretchr/testify/require"
+)
+
+// loadOrderingSnapshot reads a snapshot recorded with PendingOrderingSnapshot
+func loadOrderingSnapshot(t *testing.T, path string) []*OrderingCandidate {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var candidates []*OrderingCandidate
+	require.NoError(t, json.Unmarshal(data, &candidates))
+	return candidates
+}
+
+func TestOrderingStrategiesReplay(t *testing.T) {
+	// the first byte of the hashes in the snapshot is the sender and the nonce, e.g. 0xb7 is the nonce 7 of 0xbb
+	expected := map[string][]byte{
+		DefaultOrdering:    {0xa0, 0xa1, 0xa2, 0xb7, 0xb8, 0xc0, 0xc1, 0xd3},
+		TipOrdering:        {0xb7, 0xc0, 0xc1, 0xa0, 0xa1, 0xb8, 0xd3, 0xa2},
+		FifoOrdering:       {0xc0, 0xb7, 0xd3, 0xa0, 0xa1, 0xb8, 0xc1, 0xa2},
+		RoundRobinOrdering: {0xa0, 0xb7, 0xc0, 0xd3, 0xa1, 0xb8, 0xc1, 0xa2},
+		PriorityOrdering:   {0xc0, 0xc1, 0xa0, 0xa1, 0xa2, 0xb7, 0xb8, 0xd3},
+	}
+	require.Len(t, expected, len(OrderingStrategies))
+
+	for _, name := range OrderingStrategies {
+		t.Run(name, func(t *testing.T) {
+			strategy, err := NewOrderingStrategy(name)
+			require.NoError(t, err)
+			require.Equal(t, name, strategy.Name())
+
+			snapshot := loadOrderingSnapshot(t, "testdata/pending_snapshot.json")
+			ordered := strategy.Order(snapshot)
+			require.Len(t, ordered, len(snapshot))
+
+			got := make([]byte, len(ordered))
+			lastNonce := make(map[common.Address]uint64)
+			for i, c := range ordered {
+				got[i] = c.Hash[0]
+				if nonce, ok := lastNonce[c.Sender]; ok {
+					require.Greater(t, c.Nonce, nonce, "nonce order of %x", c.Sender)
+				}
+				lastNonce[c.Sender] = c.Nonce
+			}
+			require.Equal(t, fmt.Sprintf("%x", expected[name]), fmt.Sprintf("%x", got))
+		})
+	}
+
+	_, err := NewOrderingStrategy("lottery")
+	require.Error(t, err)
+}
+
+func TestBestWithPriorityOrdering(t *testing.T) {
+	var regular, priority [20]byte
+	regular[0] = 1
+	priority[0] = 2
+	pool, tx, aclsDB := newTestZkPool(t, &ethconfig.Zk{TxPoolOrdering: PriorityOrdering}, regular, priority)
+	ctx := context.Background()
+	require.NoError(t, AddPolicy(ctx, aclsDB, "allowlist", priority, Priority))
+
+	// the regular sender pays the higher tip so it is first in the default order
+	var txSlots types.TxSlots
+	for i, addr := range [][20]byte{regular, priority} {
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tip := uint64(400000 - i*100000)
+			txSlot := &types.TxSlot{
+				Tip:    *uint256.NewInt(tip),
+				FeeCap: *uint256.NewInt(tip),
+				Gas:    100000,
+				Nonce:  nonce,
+				Rlp:    []byte{byte(i + 1), byte(nonce + 1)},
+			}
+			txSlot.IDHash[0] = byte(i + 1)
+			txSlot.IDHash[1] = byte(nonce + 1)
+			txSlots.Append(txSlot, addr[:], true)
+		}
+	}
+	reasons, err := pool.AddLocalTxs(ctx, txSlots, tx)
+	require.NoError(t, err)
+	require.Equal(t, []DiscardReason{Success, Success, Success, Success}, reasons)
+
+	snapshot, err := pool.PendingOrderingSnapshot()
+	require.NoError(t, err)
+	require.Len(t, snapshot, 4)
+	require.Equal(t, common.Address(regular), snapshot[0].Sender)
+	require.False(t, snapshot[0].Priority)
+	require.True(t, snapshot[3].Priority)
+
+	var yielded types.TxsRlp
+	_, count, err := pool.YieldBest(10, &yielded, tx, 0, 30_000_000, 0, mapset.NewThreadUnsafeSet[[32]byte]())
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+	require.Equal(t, []common.Hash{
+		{2, 1}, {2, 2}, {1, 1}, {1, 2},
+	}, yielded.TxIds)
+
+	// the priority of the senders is read once per block
+	require.NoError(t, AddPolicy(ctx, aclsDB, "allowlist", regular, Priority))
+	snapshot, err = pool.PendingOrderingSnapshot()
+	require.NoError(t, err)
+	require.False(t, snapshot[0].Priority)
+
+	pool.lastSeenBlock.Store(pool.lastSeenBlock.Load() + 1)
+	snapshot, err = pool.PendingOrderingSnapshot()
+	require.NoError(t, err)
+	require.True(t, snapshot[0].Priority)
+}
@@ -0,0 +1,193 @@
+package txpool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/metrics"
+	"github.com/ledgerwatch/erigon-lib/types"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/ledgerwatch/log/v3"
+)
+
+var (
+	senderTxLimitCounter     = metrics.GetOrCreateCounter(`txpool_sender_rate_limited{limit="txs"}`)
+	senderGasLimitCounter    = metrics.GetOrCreateCounter(`txpool_sender_rate_limited{limit="gas"}`)
+	senderLimitExemptCounter = metrics.GetOrCreateCounter(`txpool_sender_rate_limit_exempted`)
+	senderLimitTrackedGauge  = metrics.GetOrCreateGauge(`txpool_sender_rate_limit_tracked`)
+)
+
+// defaultSenderLimitWindow is used when limits are set without a window
+const defaultSenderLimitWindow = time.Minute
+
+// senderUsage is what a sender has added to the pool in its current window
+type senderUsage struct {
+	windowStart time.Time
+	txs         uint64
+	gas         uint64
+}
+
+// senderLimiter limits the transactions and the gas that a single sender can add to the pool in a
+// fixed time window, so that one address can not fill the pending sub-pool of the sequencer.
+// It is not thread safe and is used under the pool lock.
+type senderLimiter struct {
+	txLimit   uint64 // 0 means no limit
+	gasLimit  uint64 // 0 means no limit
+	window    time.Duration
+	aclExempt bool
+	usage     map[common.Address]*senderUsage
+}
+
+func newSenderLimiter(cfg *ethconfig.Zk) *senderLimiter {
+	l := &senderLimiter{
+		usage: make(map[common.Address]*senderUsage),
+	}
+	if cfg == nil {
+		return l
+	}
+
+	l.txLimit = cfg.TxPoolSenderTxLimit
+	l.gasLimit = cfg.TxPoolSenderGasLimit
+	l.window = cfg.TxPoolSenderLimitWindow
+	l.aclExempt = cfg.TxPoolSenderLimitACLExempt
+	if l.window <= 0 {
+		l.window = defaultSenderLimitWindow
+	}
+
+	return l
+}
+
+func (l *senderLimiter) enabled() bool {
+	return l.txLimit > 0 || l.gasLimit > 0
+}
+
+// allow checks if the sender can add a transaction with the given gas and records it if so.
+// The returned reason is Success or SenderRateLimited.
+func (l *senderLimiter) allow(sender common.Address, gas uint64, now time.Time) DiscardReason {
+	reason := l.check(sender, gas, now)
+	if reason == Success {
+		l.record(sender, gas, now)
+	}
+	return reason
+}
+
+// check checks if the sender can add a transaction with the given gas without using its quota
+func (l *senderLimiter) check(sender common.Address, txGas uint64, now time.Time) DiscardReason {
+	var txs, gas uint64
+	if u, ok := l.usage[sender]; ok && now.Before(u.windowStart.Add(l.window)) {
+		txs, gas = u.txs, u.gas
+	}
+
+	if l.txLimit > 0 && txs+1 > l.txLimit {
+		senderTxLimitCounter.Inc()
+		return SenderRateLimited
+	}
+	if l.gasLimit > 0 && gas+txGas > l.gasLimit {
+		senderGasLimitCounter.Inc()
+		return SenderRateLimited
+	}
+
+	return Success
+}
+
+// record uses the quota of the sender for a transaction the pool accepted
+func (l *senderLimiter) record(sender common.Address, gas uint64, now time.Time) {
+	u, ok := l.usage[sender]
+	if !ok || !now.Before(u.windowStart.Add(l.window)) {
+		u = &senderUsage{windowStart: now}
+		l.usage[sender] = u
+	}
+
+	u.txs++
+	u.gas += gas
+	senderLimitTrackedGauge.SetInt(len(l.usage))
+}
+
+// prune removes the senders whose window has ended, they start a new window on their next transaction anyway
+func (l *senderLimiter) prune(now time.Time) {
+	for sender, u := range l.usage {
+		if !now.Before(u.windowStart.Add(l.window)) {
+			delete(l.usage, sender)
+		}
+	}
+	senderLimitTrackedGauge.SetInt(len(l.usage))
+}
+
+// isRateLimitExempt checks if the address has the rateLimitExempt policy in the allowlist, either as a plain
+// policy or as an active scoped policy, so exemptions can be granted for a limited time
+func isRateLimitExempt(ctx context.Context, aclDB kv.RwDB, addr common.Address, now time.Time) (bool, error) {
+	var exempt bool
+	err := aclDB.View(ctx, func(tx kv.Tx) error {
+		var err error
+		exempt, err = hasAllowlistPolicy(tx, addr, RateLimitExempt, now)
+		return err
+	})
+
+	return exempt, err
+}
+
+// hasAllowlistPolicy checks if the address has the policy in the allowlist, either as a plain policy or as an
+// active scoped policy, regardless of the ACL mode
+func hasAllowlistPolicy(tx kv.Tx, addr common.Address, policy Policy, now time.Time) (bool, error) {
+	value, err := tx.GetOne(Allowlist, addr.Bytes())
+	if err != nil {
+		return false, err
+	}
+	if value != nil && containsPolicy(value, policy) {
+		return true, nil
+	}
+
+	return hasActiveScopedPolicy(tx, AllowListTypeB, addr, nil, policy, now)
+}
+
+// checkSenderLimits checks the per sender limits of the pool for a new transaction, limited is false when the
+// limits are disabled or the sender is exempt from them
+func (p *TxPool) checkSenderLimits(txn *types.TxSlot, from common.Address, now time.Time) (limited bool, reason DiscardReason) {
+	if p.senderLimiter == nil || !p.senderLimiter.enabled() {
+		return false, Success
+	}
+
+	if p.senderLimiter.aclExempt {
+		exempt, err := isRateLimitExempt(context.TODO(), p.aclDB, from, now)
+		if err != nil {
+			panic(err)
+		}
+		if exempt {
+			senderLimitExemptCounter.Inc()
+			return false, Success
+		}
+	}
+
+	reason = p.senderLimiter.check(from, txn.Gas, now)
+	if reason != Success && txn.Traced {
+		log.Info(fmt.Sprintf("TX TRACING: addLocked sender rate limited idHash=%x sender=%x", txn.IDHash, from))
+	}
+
+	return true, reason
+}
+
+// addLockedWithinSenderLimits adds a new transaction to the pool if its sender is within its limits. The quota of the
+// sender is only used once the pool accepted the transaction, the transactions restored from the db were accepted
+// before and are added with addLocked.
+func (p *TxPool) addLockedWithinSenderLimits(mt *metaTx, announcements *types.Announcements) DiscardReason {
+	from := p.senders.senderID2Addr[mt.Tx.SenderID]
+	now := time.Now()
+
+	limited, reason := p.checkSenderLimits(mt.Tx, from, now)
+	if reason != Success {
+		return reason
+	}
+
+	if reason = p.addLocked(mt, announcements); reason != NotSet {
+		return reason
+	}
+
+	if limited {
+		p.senderLimiter.record(from, mt.Tx.Gas, now)
+	}
+
+	return NotSet
+}
@@ -0,0 +1,135 @@
+package txpool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/types"
+	"github.com/ledgerwatch/erigon/eth/ethconfig"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSenderLimiter(t *testing.T) {
+	sender := common.HexToAddress("0x1234567890abcdef")
+	other := common.HexToAddress("0xabcdef1234567890")
+	now := time.Now()
+
+	t.Run("Disabled without limits", func(t *testing.T) {
+		require.False(t, newSenderLimiter(&ethconfig.Zk{}).enabled())
+		require.False(t, newSenderLimiter(nil).enabled())
+	})
+
+	t.Run("Transactions per window", func(t *testing.T) {
+		l := newSenderLimiter(&ethconfig.Zk{TxPoolSenderTxLimit: 2, TxPoolSenderLimitWindow: time.Second})
+
+		require.Equal(t, Success, l.allow(sender, 21000, now))
+		require.Equal(t, Success, l.allow(sender, 21000, now))
+		require.Equal(t, SenderRateLimited, l.allow(sender, 21000, now))
+
+		// other senders have their own quota
+		require.Equal(t, Success, l.allow(other, 21000, now))
+
+		// a new window starts once the previous one ended
+		require.Equal(t, Success, l.allow(sender, 21000, now.Add(time.Second)))
+	})
+
+	t.Run("Gas per window", func(t *testing.T) {
+		l := newSenderLimiter(&ethconfig.Zk{TxPoolSenderGasLimit: 50000})
+
+		require.Equal(t, defaultSenderLimitWindow, l.window)
+		require.Equal(t, Success, l.allow(sender, 30000, now))
+		require.Equal(t, SenderRateLimited, l.allow(sender, 30000, now))
+		// a rejected transaction does not use the quota
+		require.Equal(t, Success, l.allow(sender, 20000, now))
+	})
+
+	t.Run("Prune ended windows", func(t *testing.T) {
+		l := newSenderLimiter(&ethconfig.Zk{TxPoolSenderTxLimit: 1, TxPoolSenderLimitWindow: time.Second})
+
+		require.Equal(t, Success, l.allow(sender, 21000, now))
+		require.Equal(t, Success, l.allow(other, 21000, now.Add(500*time.Millisecond)))
+
+		l.prune(now.Add(time.Second))
+		require.Len(t, l.usage, 1)
+		require.Contains(t, l.usage, other)
+	})
+}
+
+func TestAddLocalTxsSenderLimits(t *testing.T) {
+	var limited, exempt [20]byte
+	limited[0] = 1
+	exempt[0] = 2
+	pool, tx, aclsDB := newTestZkPool(t, &ethconfig.Zk{
+		TxPoolSenderTxLimit:        2,
+		TxPoolSenderLimitWindow:    time.Hour,
+		TxPoolSenderLimitACLExempt: true,
+	}, limited, exempt)
+	ctx := context.Background()
+	require.NoError(t, AddPolicy(ctx, aclsDB, "allowlist", exempt, RateLimitExempt))
+
+	var txSlots types.TxSlots
+	for i, addr := range [][20]byte{limited, exempt} {
+		for nonce := uint64(0); nonce < 3; nonce++ {
+			txSlot := &types.TxSlot{
+				Tip:    *uint256.NewInt(300000),
+				FeeCap: *uint256.NewInt(300000),
+				Gas:    100000,
+				Nonce:  nonce,
+			}
+			txSlot.IDHash[0] = byte(i + 1)
+			txSlot.IDHash[1] = byte(nonce + 1)
+			txSlots.Append(txSlot, addr[:], true)
+		}
+	}
+
+	reasons, err := pool.AddLocalTxs(ctx, txSlots, tx)
+	require.NoError(t, err)
+	require.Equal(t, []DiscardReason{Success, Success, SenderRateLimited, Success, Success, Success}, reasons)
+	require.Equal(t, "sender exceeded its rate limit", reasons[2].String())
+}
+
+func TestAddLocalTxsSenderLimitsOnlyCountAccepted(t *testing.T) {
+	var sender [20]byte
+	sender[0] = 1
+	pool, tx, _ := newTestZkPool(t, &ethconfig.Zk{
+		TxPoolSenderTxLimit:     2,
+		TxPoolSenderLimitWindow: time.Hour,
+	}, sender)
+	ctx := context.Background()
+
+	newTx := func(nonce uint64, id byte) types.TxSlots {
+		var txSlots types.TxSlots
+		txSlot := &types.TxSlot{
+			Tip:    *uint256.NewInt(300000),
+			FeeCap: *uint256.NewInt(300000),
+			Gas:    100000,
+			Nonce:  nonce,
+		}
+		txSlot.IDHash[0] = id
+		txSlots.Append(txSlot, sender[:], true)
+		return txSlots
+	}
+
+	reasons, err := pool.AddLocalTxs(ctx, newTx(0, 1), tx)
+	require.NoError(t, err)
+	require.Equal(t, []DiscardReason{Success}, reasons)
+
+	// neither a duplicate nor a transaction the pool does not take uses the quota
+	reasons, err = pool.AddLocalTxs(ctx, newTx(0, 1), tx)
+	require.NoError(t, err)
+	require.NotEqual(t, Success, reasons[0])
+	reasons, err = pool.AddLocalTxs(ctx, newTx(0, 2), tx)
+	require.NoError(t, err)
+	require.Equal(t, []DiscardReason{NotReplaced}, reasons)
+
+	reasons, err = pool.AddLocalTxs(ctx, newTx(1, 3), tx)
+	require.NoError(t, err)
+	require.Equal(t, []DiscardReason{Success}, reasons)
+
+	reasons, err = pool.AddLocalTxs(ctx, newTx(2, 4), tx)
+	require.NoError(t, err)
+	require.Equal(t, []DiscardReason{SenderRateLimited}, reasons)
+}
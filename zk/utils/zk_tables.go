@@ -1,7 +1,12 @@
 package utils
 
 import (
+	"context"
+	"math/big"
+
+	libcommon "github.com/ledgerwatch/erigon-lib/common"
 	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon/core/types"
 	"github.com/ledgerwatch/erigon/smt/pkg/db"
 	"github.com/ledgerwatch/erigon/zk/hermez_db"
 )
@@ -27,3 +32,27 @@ func PopulateMemoryMutationTables(batch kv.RwTx) error {
 
 	return nil
 }
+
+// HeaderReader reads the header of a block, as the block reader does
+type HeaderReader interface {
+	HeaderByNumber(ctx context.Context, tx kv.Getter, blockNum uint64) (*types.Header, error)
+}
+
+// GetRetainedRoot returns the root of the tree retained at the block, nil if the tree of the block is not retained or
+// the retained root does not match the header of the block
+func GetRetainedRoot(ctx context.Context, tx kv.Tx, headerReader HeaderReader, blockNumber uint64) (*big.Int, error) {
+	root, err := db.NewRoEriDb(tx).GetRetainedRoot(blockNumber)
+	if err != nil || root == nil {
+		return nil, err
+	}
+
+	// a root that does not match the block was left behind by an unwind
+	header, err := headerReader.HeaderByNumber(ctx, tx, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil || libcommon.BigToHash(root) != header.Root {
+		return nil, nil
+	}
+	return root, nil
+}
@@ -229,8 +229,14 @@ func (g *Generator) generateWitness(tx kv.Tx, ctx context.Context, batchNum uint
 		return nil, nil
 	}
 
+	// a retained tree is read as is, otherwise the tree is rewound to the block before the batch
+	retainedRoot, err := zkUtils.GetRetainedRoot(ctx, tx, g.blockReader, startBlock-1)
+	if err != nil {
+		return nil, err
+	}
+
 	if startBlock-1 < latestBlock {
-		if latestBlock-startBlock > g.witnessUnwindLimit {
+		if retainedRoot == nil && latestBlock-startBlock > g.witnessUnwindLimit {
 			return nil, fmt.Errorf("requested block is too old, block must be within %d blocks of the head block number (currently %d)", g.witnessUnwindLimit, latestBlock)
 		}
 
@@ -242,10 +248,12 @@ func (g *Generator) generateWitness(tx kv.Tx, ctx context.Context, batchNum uint
 			return nil, fmt.Errorf("unwind hash state: %w", err)
 		}
 
-		interHashStageCfg := zkStages.StageZkInterHashesCfg(nil, true, true, false, g.dirs.Tmp, g.blockReader, nil, g.historyV3, g.agg, nil)
+		if retainedRoot == nil {
+			interHashStageCfg := zkStages.StageZkInterHashesCfg(nil, true, true, false, g.dirs.Tmp, g.blockReader, nil, g.historyV3, g.agg, nil)
 
-		if err = zkStages.UnwindZkIntermediateHashesStage(unwindState, stageState, batch, interHashStageCfg, ctx, true); err != nil {
-			return nil, fmt.Errorf("unwind intermediate hashes: %w", err)
+			if err = zkStages.UnwindZkIntermediateHashesStage(unwindState, stageState, batch, interHashStageCfg, ctx, true); err != nil {
+				return nil, fmt.Errorf("unwind intermediate hashes: %w", err)
+			}
 		}
 
 		tx = batch
@@ -403,6 +411,9 @@ func (g *Generator) generateWitness(tx kv.Tx, ctx context.Context, batchNum uint
 
 	eridb := db2.NewEriDb(batch)
 	smtTrie := smt.NewSMT(eridb, false)
+	if retainedRoot != nil {
+		smtTrie = smt.NewSMTAtRoot(eridb, retainedRoot)
+	}
 
 	witness, err := smt.BuildWitness(smtTrie, rl, ctx)
 	if err != nil {
@@ -1,6 +1,8 @@
 package etherman
 
 import (
+	"net/http"
+
 	"github.com/ledgerwatch/erigon-lib/common"
 )
 
@@ -17,4 +19,7 @@ type Config struct {
 
 	PrivateKeyPath     string `mapstructure:"PrivateKeyPath"`
 	PrivateKeyPassword string `mapstructure:"PrivateKeyPassword"`
+
+	// Transport sends the requests to an http URL when it is set, e.g. through the L1 cache
+	Transport http.RoundTripper `mapstructure:"-"`
 }
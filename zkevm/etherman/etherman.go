@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net/http"
 	"strings"
 	"time"
 
@@ -20,11 +21,13 @@ import (
 	"github.com/ledgerwatch/erigon/crypto"
 	"github.com/ledgerwatch/erigon/ethclient"
 	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rpc"
 	"github.com/ledgerwatch/erigon/zkevm/etherman/smartcontracts/matic"
 	"github.com/ledgerwatch/erigon/zkevm/etherman/smartcontracts/polygonzkevm"
 	"github.com/ledgerwatch/erigon/zkevm/etherman/smartcontracts/polygonzkevmglobalexitroot"
 	ethmanTypes "github.com/ledgerwatch/erigon/zkevm/etherman/types"
 	"github.com/ledgerwatch/erigon/zkevm/log"
+	ethlog "github.com/ledgerwatch/log/v3"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -131,7 +134,7 @@ type Client struct {
 // NewClient creates a new etherman.
 func NewClient(cfg Config) (*Client, error) {
 	// Connect to ethereum node
-	ethClient, err := ethclient.Dial(cfg.URL)
+	ethClient, err := dial(cfg)
 	if err != nil {
 		log.Errorf("error connecting to %s: %+v", cfg.URL, err)
 		return nil, err
@@ -194,6 +197,18 @@ func NewClient(cfg Config) (*Client, error) {
 	}, nil
 }
 
+// dial connects to the ethereum node, through the transport of the config for http URLs
+func dial(cfg Config) (*ethclient.Client, error) {
+	if cfg.Transport == nil || !strings.HasPrefix(cfg.URL, "http") {
+		return ethclient.Dial(cfg.URL)
+	}
+	rpcClient, err := rpc.DialHTTPWithClient(cfg.URL, &http.Client{Transport: cfg.Transport}, ethlog.Root())
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(rpcClient), nil
+}
+
 // VerifyGenBlockNumber verifies if the genesis Block Number is valid
 func (etherMan *Client) VerifyGenBlockNumber(ctx context.Context, genBlockNumber uint64) (bool, error) {
 	genBlock := big.NewInt(0).SetUint64(genBlockNumber)